@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations of every supported database driver,
+// each driver reads the files of the directory named after it.
+package migrations
+
+import "embed"
+
+// FS holds the migrations in the golang-migrate layout, NNNNNN_name.up.sql and NNNNNN_name.down.sql.
+//
+//go:embed postgres/*.sql mysql/*.sql sqlite/*.sql
+var FS embed.FS
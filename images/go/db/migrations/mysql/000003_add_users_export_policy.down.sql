@@ -0,0 +1 @@
+DELETE FROM casbin_rule WHERE ptype = 'p' AND v0 = 'admin' AND v1 = 'users' AND v2 = 'export';
@@ -0,0 +1,4 @@
+DELETE FROM casbin_rule WHERE ptype = 'p' AND v0 = 'admin' AND v1 = 'audit' AND v2 = 'read';
+DROP TRIGGER IF EXISTS audit_events_append_only_update;
+DROP TRIGGER IF EXISTS audit_events_append_only_delete;
+DROP TABLE IF EXISTS audit_events;
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id            VARCHAR(27)        PRIMARY KEY,
+    action        VARCHAR(64)        NOT NULL,
+    outcome       VARCHAR(16)        NOT NULL,
+    actor_id      VARCHAR(27)        NOT NULL DEFAULT '',
+    actor_email   VARCHAR(255)       NOT NULL DEFAULT '',
+    target_id     VARCHAR(27)        NOT NULL DEFAULT '',
+    target_email  VARCHAR(255)       NOT NULL DEFAULT '',
+    ip            VARCHAR(64)        NOT NULL DEFAULT '',
+    user_agent    TEXT               NOT NULL DEFAULT (''),
+    changes       TEXT               NOT NULL DEFAULT ('{}'),
+    created_at    BIGINT             NOT NULL,
+    INDEX idx_audit_events_action (action),
+    INDEX idx_audit_events_actor_id (actor_id),
+    INDEX idx_audit_events_target_id (target_id),
+    INDEX idx_audit_events_created_at (created_at)
+);
+
+-- APPEND ONLY
+
+CREATE TRIGGER audit_events_append_only_update
+    BEFORE UPDATE ON audit_events
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+CREATE TRIGGER audit_events_append_only_delete
+    BEFORE DELETE ON audit_events
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+-- RULE FOR AUDIT QUERY
+
+INSERT INTO casbin_rule (ptype, v0, v1, v2)
+VALUES
+    ('p', 'admin', 'audit', 'read');
@@ -0,0 +1,3 @@
+-- VERSION USED FOR OPTIMISTIC CONCURRENCY CONTROL, EXPOSED AS THE ETAG OF A USER
+
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
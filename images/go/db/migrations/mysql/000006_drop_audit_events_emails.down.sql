@@ -0,0 +1 @@
+ALTER TABLE audit_events ADD COLUMN actor_email VARCHAR(255) NOT NULL DEFAULT '' AFTER actor_id, ADD COLUMN target_email VARCHAR(255) NOT NULL DEFAULT '' AFTER target_id;
@@ -0,0 +1,13 @@
+-- THE AUDIT TRAIL KEEPS THE IDS ONLY, A PURGED USER LEAVES NO EMAIL BEHIND
+
+DROP TRIGGER IF EXISTS audit_events_append_only_update;
+
+UPDATE audit_events
+SET changes = JSON_SET(changes, '$.email', JSON_OBJECT('changed', TRUE))
+WHERE JSON_CONTAINS_PATH(changes, 'one', '$.email');
+
+CREATE TRIGGER audit_events_append_only_update
+    BEFORE UPDATE ON audit_events
+    FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+
+ALTER TABLE audit_events DROP COLUMN actor_email, DROP COLUMN target_email;
@@ -0,0 +1,5 @@
+-- RULE FOR PERSONAL DATA EXPORT
+
+INSERT INTO casbin_rule (ptype, v0, v1, v2)
+VALUES
+    ('p', 'admin', 'users', 'export');
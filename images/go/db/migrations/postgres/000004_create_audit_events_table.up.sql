@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id            VARCHAR(27)        PRIMARY KEY,
+    action        VARCHAR(64)        NOT NULL,
+    outcome       VARCHAR(16)        NOT NULL,
+    actor_id      VARCHAR(27)        NOT NULL DEFAULT '',
+    actor_email   VARCHAR(255)       NOT NULL DEFAULT '',
+    target_id     VARCHAR(27)        NOT NULL DEFAULT '',
+    target_email  VARCHAR(255)       NOT NULL DEFAULT '',
+    ip            VARCHAR(64)        NOT NULL DEFAULT '',
+    user_agent    TEXT               NOT NULL DEFAULT '',
+    changes       TEXT               NOT NULL DEFAULT '{}',
+    created_at    BIGINT             NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events (target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+-- APPEND ONLY
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- RULE FOR AUDIT QUERY
+
+INSERT INTO casbin_rule (ptype, v0, v1, v2)
+VALUES
+    ('p', 'admin', 'audit', 'read');
@@ -0,0 +1,2 @@
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS actor_email VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS target_email VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- THE AUDIT TRAIL KEEPS THE IDS ONLY, A PURGED USER LEAVES NO EMAIL BEHIND
+
+ALTER TABLE audit_events DISABLE TRIGGER audit_events_append_only;
+
+UPDATE audit_events
+SET changes = jsonb_set(changes::jsonb, '{email}', '{"changed": true}')::text
+WHERE changes::jsonb ? 'email';
+
+ALTER TABLE audit_events ENABLE TRIGGER audit_events_append_only;
+
+ALTER TABLE audit_events DROP COLUMN IF EXISTS actor_email;
+ALTER TABLE audit_events DROP COLUMN IF EXISTS target_email;
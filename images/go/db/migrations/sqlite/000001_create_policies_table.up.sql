@@ -0,0 +1,32 @@
+-- COLUMN TYPES MATCH THE GORM ADAPTER OF CASBIN, ITS AUTO MIGRATION WOULD ALTER THE TABLE OTHERWISE
+
+CREATE TABLE IF NOT EXISTS "casbin_rule" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "ptype" text,
+    "v0" text,
+    "v1" text,
+    "v2" text,
+    "v3" text,
+    "v4" text,
+    "v5" text
+);
+
+-- RULE FOR ROLE AUTHORIZATION
+
+INSERT INTO casbin_rule (ptype, v0, v1, v2)
+VALUES
+    ('p', 'admin', 'users', 'read'),
+    ('p', 'admin', 'users', 'create'),
+    ('p', 'admin', 'users', 'delete'),
+    ('p', 'admin', 'users', 'restore'),
+    ('p', 'admin', 'roles', 'write'),
+    ('p', 'moderator', 'users', 'read');
+
+-- RULE FOR ROUTE PERMISSION
+
+
+-- ADMIN GROUPING
+
+INSERT INTO casbin_rule (ptype, v0, v1)
+VALUES
+    ('g', 'tirtanewwhakim22@gmail.com', 'admin');
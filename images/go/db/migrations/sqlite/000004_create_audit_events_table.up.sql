@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id            VARCHAR(27)        PRIMARY KEY,
+    action        VARCHAR(64)        NOT NULL,
+    outcome       VARCHAR(16)        NOT NULL,
+    actor_id      VARCHAR(27)        NOT NULL DEFAULT '',
+    actor_email   VARCHAR(255)       NOT NULL DEFAULT '',
+    target_id     VARCHAR(27)        NOT NULL DEFAULT '',
+    target_email  VARCHAR(255)       NOT NULL DEFAULT '',
+    ip            VARCHAR(64)        NOT NULL DEFAULT '',
+    user_agent    TEXT               NOT NULL DEFAULT '',
+    changes       TEXT               NOT NULL DEFAULT '{}',
+    created_at    BIGINT             NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events (action);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events (target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events (created_at);
+
+-- APPEND ONLY
+
+CREATE TRIGGER IF NOT EXISTS audit_events_append_only_update
+    BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_append_only_delete
+    BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+-- RULE FOR AUDIT QUERY
+
+INSERT INTO casbin_rule (ptype, v0, v1, v2)
+VALUES
+    ('p', 'admin', 'audit', 'read');
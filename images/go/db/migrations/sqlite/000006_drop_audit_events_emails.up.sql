@@ -0,0 +1,16 @@
+-- THE AUDIT TRAIL KEEPS THE IDS ONLY, A PURGED USER LEAVES NO EMAIL BEHIND
+
+DROP TRIGGER IF EXISTS audit_events_append_only_update;
+
+UPDATE audit_events
+SET changes = json_set(changes, '$.email', json('{"changed": true}'))
+WHERE json_extract(changes, '$.email') IS NOT NULL;
+
+CREATE TRIGGER IF NOT EXISTS audit_events_append_only_update
+    BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+ALTER TABLE audit_events DROP COLUMN actor_email;
+ALTER TABLE audit_events DROP COLUMN target_email;
@@ -0,0 +1,9 @@
+// Package seeds embeds the fixtures of the seed profiles, each profile reads the file named after it.
+package seeds
+
+import "embed"
+
+// FS holds the fixtures of the profiles, dev.yaml, e2e.yaml and demo.yaml.
+//
+//go:embed *.yaml
+var FS embed.FS
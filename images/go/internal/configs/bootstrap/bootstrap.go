@@ -1,33 +1,88 @@
 package bootstrap
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+
 	"github.com/casbin/casbin/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
 	casbinconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/casbin"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/export"
 	fiberconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/fiber"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
 	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/reload"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/retention"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/secret"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/security"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/seed"
 	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
 	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
 	"gorm.io/gorm"
 )
 
 // App struct holds all the application configurations and dependencies.
 type App struct {
-	Redis          *cache.RedisConfig     // Redis configuration
-	CasbinEnforcer *casbin.Enforcer       // Casbin enforcer for policy enforcement
-	Gorm           *gorm.DB               // GORM database instance
-	FiberServer    *fiberconfig.Fiber     // Fiber server configuration
-	Hash           *hash.Argon2           // Argon2 hashing configuration
-	Logger         *loggerconfig.Logger   // Logger configuration
-	SQL            *sqlconfig.SqlConfig   // SQL configuration
-	Timeout        *timeout.Config        // Timeout configuration
-	Token          *tokenconfig.JWTToken  // JWT token configuration
-	Secret         *tokenconfig.SecretKey // Secret key for JWT
+	Redis          *cache.RedisConfig        // Redis configuration
+	CasbinEnforcer *casbin.Enforcer          // Casbin enforcer for policy enforcement
+	Gorm           *gorm.DB                  // GORM database instance
+	FiberServer    *fiberconfig.Fiber        // Fiber server configuration
+	Hash           *hash.Argon2              // Argon2 hashing configuration
+	Logger         *loggerconfig.Logger      // Logger configuration
+	SQL            *sqlconfig.SqlConfig      // SQL configuration
+	Timeout        *timeout.Config           // Timeout configuration
+	Token          *tokenconfig.JWTToken     // JWT token configuration
+	Secret         *tokenconfig.SecretKey    // Secret key for JWT
+	Retention      *retention.Config         // Retention policy for soft-deleted users
+	Export         *export.Config            // Personal data export settings
+	Secrets        *secret.Store             // Secret providers refreshing the secrets
+	PolicyWatcher  *repository.PolicyWatcher // Announces the policy changes of CasbinEnforcer to the other processes, nil when unset
+}
+
+var (
+	secretsOnce sync.Once
+	secretStore *secret.Store
+	secretsErr  error
+)
+
+// Secrets resolves the secrets through the providers of SECRET_PROVIDERS once, before any configuration reads them,
+// and returns the Store refreshing them.
+func Secrets() (*secret.Store, error) {
+	secretsOnce.Do(func() {
+		secretConfig, err := secret.NewConfig()
+		if err != nil {
+			secretsErr = err
+			return
+		}
+		provider, err := secretConfig.NewProvider()
+		if err != nil {
+			secretsErr = err
+			return
+		}
+		store, err := secret.NewStore(provider, secret.Keys...)
+		if err != nil {
+			secretsErr = err
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), secretConfig.Vault.Period())
+		defer cancel()
+		if _, err := store.Refresh(ctx); err != nil {
+			secretsErr = err
+			return
+		}
+		secretStore = store
+	})
+	return secretStore, secretsErr
 }
 
 // configLoader is a generic function that loads a configuration using the provided function.
@@ -35,8 +90,154 @@ func configLoader[T any](configFunc func() (*T, error)) (*T, error) {
 	return configFunc() // Execute the configuration function and return the result
 }
 
+// check loads a configuration, naming it in the error returned when loading fails.
+func check[T any](name string, configFunc func() (*T, error)) error {
+	if _, err := configLoader(configFunc); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Validate loads every configuration without connecting to any service and reports all the errors together.
+func Validate() error {
+	_, secretErr := Secrets()
+	if secretErr != nil {
+		secretErr = fmt.Errorf("secret: %w", secretErr)
+	}
+	_, _, tokenErr := tokenconfig.NewJWTToken()
+	if tokenErr != nil {
+		tokenErr = fmt.Errorf("token: %w", tokenErr)
+	}
+	var seedErr error
+	if sqlConfig, err := sqlconfig.NewConfig(); err == nil && sqlConfig.SeedProfile != "" {
+		if _, err := seed.Load(sqlConfig.SeedProfile); err != nil {
+			seedErr = fmt.Errorf("seed: %w", err)
+		}
+	}
+	// Each preforked process holds its own registry, a scrape would only see the metrics of the child answering it
+	var preforkErr error
+	if fiberConfig, err := fiberconfig.NewFiberConfig(); err == nil && fiberConfig.Prefork {
+		if metricsConfig, err := metrics.NewConfig(); err == nil && metricsConfig.Enabled {
+			preforkErr = errors.New("metrics: METRICS_ENABLED requires FIBER_PREFORK=false, each preforked process holds its own metrics")
+		}
+	}
+	return errors.Join(
+		check("logger", loggerconfig.NewLoggerConfig),
+		check("fiber", fiberconfig.NewFiberConfig),
+		check("redis", cache.NewConfig),
+		check("sql", sqlconfig.NewConfig),
+		check("timeout", timeout.NewConfig),
+		check("retention", retention.NewConfig),
+		check("export", export.NewConfig),
+		check("casbin", casbinconfig.NewConfig),
+		check("cors", security.NewCors),
+		check("limiter", security.NewLimiter),
+		check("reload", reload.NewConfig),
+		check("metrics", metrics.NewConfig),
+		check("tracing", tracing.NewConfig),
+		secretErr,
+		tokenErr,
+		seedErr,
+		preforkErr,
+	)
+}
+
+// Dump lists every setting with the layer it comes from, the secrets redacted.
+// The settings are listed even when invalid, the validation error is returned alongside them.
+func Dump() ([]configs.Setting, error) {
+	values := []any{
+		&loggerconfig.LoggerConfig{},
+		&fiberconfig.FiberConfig{},
+		&fiberconfig.SSLConfig{},
+		&cache.RedisConfig{},
+		&sqlconfig.SqlConfig{},
+		&timeout.Minutes{},
+		&retention.Config{},
+		&export.Config{},
+		&casbinconfig.Casbin{},
+		&tokenconfig.JWTToken{},
+		&tokenconfig.SecretKey{},
+		&security.Cors{},
+		&security.Limiter{},
+		&reload.Config{},
+		&secret.Config{},
+		&metrics.Config{},
+		&tracing.Config{},
+	}
+	config := configs.GetConfig()
+	for _, value := range values {
+		// Validate reports the errors, the parsed settings are kept
+		_ = config.Load(value)
+	}
+	return config.Dump(values...), Validate()
+}
+
+// autoMigrate applies the pending migrations, replicas starting together wait for the one holding the migration lock.
+func autoMigrate(gormDB *gorm.DB, sqlConfig *sqlconfig.SqlConfig, logger *loggerconfig.Logger) error {
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migration.New(sqlDB, sqlConfig.Driver, logger.App)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.WithLockTimeout(sqlConfig.MigrateLockWait()).Up(context.Background())
+	if err != nil {
+		return err
+	}
+	logger.App.Info().Msgf("Successfully applied %d migrations", applied)
+	return nil
+}
+
+// seedProfile adds the entries of the embedded fixture of the profile missing from the database,
+// under the migration lock so replicas starting together seed one after the other.
+func seedProfile(gormDB *gorm.DB, enforcer *casbin.Enforcer, argon2 *hash.Argon2, sqlConfig *sqlconfig.SqlConfig, logger *loggerconfig.Logger) error {
+	fixture, err := seed.Load(sqlConfig.SeedProfile)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return err
+	}
+	migrator, err := migration.New(sqlDB, sqlConfig.Driver, logger.App)
+	if err != nil {
+		return err
+	}
+	usersRepository, err := repository.NewUsersRepositoryImpl(gormDB, logger.App)
+	if err != nil {
+		return err
+	}
+	auditRepository, err := repository.NewAuditRepositoryImpl(gormDB, logger.App)
+	if err != nil {
+		return err
+	}
+	unitOfWork, err := repository.NewUnitOfWork(gormDB, logger.App)
+	if err != nil {
+		return err
+	}
+	seeder, err := seed.NewSeeder(usersRepository, auditRepository, unitOfWork, enforcer, argon2, logger.App)
+	if err != nil {
+		return err
+	}
+	return migrator.WithLockTimeout(sqlConfig.MigrateLockWait()).Locked(context.Background(), func(ctx context.Context) error {
+		// The enforcer was loaded before the lock, a replica may have seeded the rules since
+		if err := enforcer.LoadPolicy(); err != nil {
+			return err
+		}
+		_, err := seeder.Seed(ctx, fixture)
+		return err
+	})
+}
+
 // New initializes and returns a new App instance with all configurations loaded.
 func New() (*App, error) {
+	// Report every invalid setting together before loading anything
+	if err := Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
 	// Load Logger configuration
 	logger, loggerErr := configLoader(loggerconfig.NewLogger)
 	if loggerErr != nil {
@@ -86,6 +287,22 @@ func New() (*App, error) {
 	}
 	logger.App.Info().Msg("Successfully loaded Timeout configuration")
 
+	// Load Retention configuration
+	retentionConfig, retentionErr := configLoader(retention.NewConfig)
+	if retentionErr != nil {
+		logger.App.Error().Msgs("Failed to load Retention config:", retentionErr)
+		return nil, retentionErr // Return error if loading Retention config fails
+	}
+	logger.App.Info().Msg("Successfully loaded Retention configuration")
+
+	// Load Export configuration
+	exportConfig, exportErr := configLoader(export.NewConfig)
+	if exportErr != nil {
+		logger.App.Error().Msgs("Failed to load Export config:", exportErr)
+		return nil, exportErr // Return error if loading Export config fails
+	}
+	logger.App.Info().Msg("Successfully loaded Export configuration")
+
 	// Load JWT token configuration and secret key
 	jwtToken, key, tokenErr := tokenconfig.NewJWTToken()
 	if tokenErr != nil {
@@ -102,6 +319,23 @@ func New() (*App, error) {
 	}
 	logger.App.Info().Msg("Successfully initialized GORM")
 
+	// Expose the statistics of the connection pool
+	if sqlDB, dbErr := gormDB.DB(); dbErr != nil {
+		logger.App.Error().Msgs("Failed to access the connection pool:", dbErr)
+		return nil, dbErr // Return error if the pool is not reachable
+	} else if metricsErr := metrics.RegisterDB(sqlDB, sqlConfig.Name); metricsErr != nil {
+		logger.App.Error().Msgs("Failed to register the pool metrics:", metricsErr)
+		return nil, metricsErr // Return error if registering the pool metrics fails
+	}
+
+	// Apply the pending migrations before Casbin loads its policies
+	if sqlConfig.AutoMigrate {
+		if migrateErr := autoMigrate(gormDB, sqlConfig, logger); migrateErr != nil {
+			logger.App.Error().Msgs("Failed to apply migrations:", migrateErr)
+			return nil, migrateErr // Return error if applying migrations fails
+		}
+	}
+
 	// Initialize Casbin middleware and enforcer for authorization
 	enforcer, casbinErr := casbinconfig.NewCasbin(gormDB, func(ctx *fiber.Ctx) string {
 		payload := ctx.Locals("users").(*tokenconfig.Payload)
@@ -114,19 +348,57 @@ func New() (*App, error) {
 	}
 	logger.App.Info().Msg("Successfully initialized Casbin enforcer")
 
+	// Announce the policy changes over Redis, the other processes reload their enforcer while the watcher listens
+	policyWatcher, watcherErr := newPolicyWatcher(redisConfig, enforcer, logger)
+	if watcherErr != nil {
+		logger.App.Error().Msgs("Failed to initialize the policy watcher:", watcherErr)
+		return nil, watcherErr // Return error if initializing the policy watcher fails
+	}
+
+	// Seed the fixture of the profile, in the parent process only as the prefork children share its database
+	if sqlConfig.SeedProfile != "" && !fiber.IsChild() {
+		if seedErr := seedProfile(gormDB, enforcer, argon2, sqlConfig, logger); seedErr != nil {
+			logger.App.Error().Msgs("Failed to seed the fixture:", seedErr)
+			return nil, seedErr // Return error if seeding fails
+		}
+		logger.App.Info().Msgf("Successfully seeded the %s profile", sqlConfig.SeedProfile)
+	}
+
 	logger.App.Info().Msg("Application initialized successfully")
 
 	// Return a new App instance with all configurations and dependencies
 	return &App{
-		Redis:          redisConfig,   // Assign Redis config
-		FiberServer:    fiberServer,   // Assign Fiber server config
-		Gorm:           gormDB,        // Assign GORM instance
-		Hash:           argon2,        // Assign Argon2 hash config
-		Logger:         logger,        // Assign Logger config
-		SQL:            sqlConfig,     // Assign SQL config
-		Timeout:        timeoutConfig, // Assign Timeout config
-		Token:          jwtToken,      // Assign JWT token config
-		Secret:         key,           // Assign secret key for JWT
-		CasbinEnforcer: enforcer,      // Assign Casbin enforcer
+		Redis:          redisConfig,     // Assign Redis config
+		FiberServer:    fiberServer,     // Assign Fiber server config
+		Gorm:           gormDB,          // Assign GORM instance
+		Hash:           argon2,          // Assign Argon2 hash config
+		Logger:         logger,          // Assign Logger config
+		SQL:            sqlConfig,       // Assign SQL config
+		Timeout:        timeoutConfig,   // Assign Timeout config
+		Token:          jwtToken,        // Assign JWT token config
+		Secret:         key,             // Assign secret key for JWT
+		CasbinEnforcer: enforcer,        // Assign Casbin enforcer
+		Retention:      retentionConfig, // Assign Retention config
+		Export:         exportConfig,    // Assign Export config
+		Secrets:        secretStore,     // Assign the Store refreshing the secrets
+		PolicyWatcher:  policyWatcher,   // Assign the policy watcher
 	}, nil
 }
+
+// newPolicyWatcher sets a PolicyWatcher on the enforcer, reloading its policy when another process announces a change.
+func newPolicyWatcher(redisConfig *cache.RedisConfig, enforcer *casbin.Enforcer, logger *loggerconfig.Logger) (*repository.PolicyWatcher, error) {
+	redisClient, err := redisConfig.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	watcher := repository.NewPolicyWatcher(redisClient, logger.App)
+	if err := enforcer.SetWatcher(watcher); err != nil {
+		return nil, err
+	}
+	// SetWatcher ignores the errors of the reload, they are logged instead
+	return watcher, watcher.SetUpdateCallback(func(string) {
+		if err := enforcer.LoadPolicy(); err != nil {
+			logger.App.Error().Msgf("Failed to reload the policy changed by another process: %v", err)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package bootstrap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+)
+
+// Seeds a SQLite database through its single connection, the migration lock holding none
+func TestSeedProfile_SQLite(t *testing.T) {
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 1, MinCon: 1, SeedProfile: "dev"}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	logger := &loggerconfig.Logger{App: &log.DefaultLogger}
+	require.NoError(t, autoMigrate(db, config, logger))
+
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	require.NoError(t, err)
+	enforcer, err := casbin.NewEnforcer("../../../resource/model/rbac_model.conf", adapter)
+	require.NoError(t, err)
+	argon2, err := hash.NewHashArgon2()
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() { done <- seedProfile(db, enforcer, argon2, config, logger) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("seeding did not finish")
+	}
+
+	var count int64
+	require.NoError(t, db.WithContext(context.Background()).Model(&entity.Users{}).Count(&count).Error)
+	require.Positive(t, count)
+}
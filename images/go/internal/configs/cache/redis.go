@@ -1,24 +1,55 @@
 package cache
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"os"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
 )
 
 // RedisConfig holds the configuration for connecting to Redis.
 type RedisConfig struct {
-	Name     int    `env:"CACHE_DB_NAME"`                     // Redis database name.
-	Host     string `env:"CACHE_DB_HOST,required"`            // Redis server hostname.
-	Port     int    `env:"CACHE_DB_PORT,required"`            // Redis server port.
-	User     string `env:"CACHE_DB_USER"`                     // Redis username.
-	Password string `env:"CACHE_DB_PASS"`                     // Redis password.
-	MaxCon   int    `env:"CACHE_DB_MAX_CON" envDefault:"100"` // Maximum number of connections.
-	MinCon   int    `env:"CACHE_DB_MIN_CON" envDefault:"10"`  // Minimum number of connections.
-	MaxTime  int    `env:"CACHE_DB_MAX_TIME" envDefault:"10"` // Maximum idle connection time (in minutes).
-	MinTime  int    `env:"CACHE_DB_MIN_TIME" envDefault:"2"`  // Minimum idle connection time (in minutes).
+	Name             int      `env:"CACHE_DB_NAME"`                            // Redis database name.
+	Host             string   `env:"CACHE_DB_HOST,required"`                   // Redis server hostname.
+	Port             int      `env:"CACHE_DB_PORT,required"`                   // Redis server port.
+	Addrs            []string `env:"CACHE_DB_ADDRS" envSeparator:","`          // Addresses of the cluster nodes or sentinels, replacing the host and port.
+	Cluster          bool     `env:"CACHE_CLUSTER" envDefault:"false"`         // Connect to a Redis Cluster, even through a single address.
+	Master           string   `env:"CACHE_SENTINEL_MASTER"`                    // Name of the master monitored by the sentinels, enables Sentinel.
+	SentinelUser     string   `env:"CACHE_SENTINEL_USER"`                      // Sentinel username.
+	SentinelPassword string   `env:"CACHE_SENTINEL_PASS"`                      // Sentinel password.
+	User             string   `env:"CACHE_DB_USER"`                            // Redis username.
+	Password         string   `env:"CACHE_DB_PASS"`                            // Redis password.
+	MaxCon           int      `env:"CACHE_DB_MAX_CON" envDefault:"100"`        // Maximum number of connections.
+	MinCon           int      `env:"CACHE_DB_MIN_CON" envDefault:"10"`         // Minimum number of connections.
+	MaxTime          int      `env:"CACHE_DB_MAX_TIME" envDefault:"10"`        // Maximum idle connection time (in minutes).
+	MinTime          int      `env:"CACHE_DB_MIN_TIME" envDefault:"2"`         // Minimum idle connection time (in minutes).
+	TLS              bool     `env:"CACHE_TLS" envDefault:"false"`             // Connect over TLS.
+	TLSCA            string   `env:"CACHE_TLS_CA"`                             // Path of the PEM bundle verifying the server, instead of the system roots.
+	TLSName          string   `env:"CACHE_TLS_SERVER_NAME"`                    // Server name verified in the certificate.
+	TLSInsecure      bool     `env:"CACHE_TLS_INSECURE" envDefault:"false"`    // Skip verifying the server certificate.
+	MaxRetries       int      `env:"CACHE_MAX_RETRIES" envDefault:"3"`         // Retries of a failed command, -1 disables them.
+	MinRetryBackoff  int      `env:"CACHE_MIN_RETRY_BACKOFF" envDefault:"8"`   // Minimum backoff between retries (in milliseconds).
+	MaxRetryBackoff  int      `env:"CACHE_MAX_RETRY_BACKOFF" envDefault:"512"` // Maximum backoff between retries (in milliseconds).
+	BreakerFailures  int      `env:"CACHE_BREAKER_FAILURES" envDefault:"5"`    // Consecutive failures opening the circuit breaker of the cache.
+	BreakerOpen      int      `env:"CACHE_BREAKER_OPEN" envDefault:"30"`       // Time the circuit breaker stays open before probing Redis again (in seconds).
+	TTL              int      `env:"CACHE_TTL" envDefault:"30"`                // Expiration of cached entries (in minutes).
+	SoftTTL          int      `env:"CACHE_SOFT_TTL" envDefault:"25"`           // Age after which cached entries are refreshed in the background (in minutes).
+	Jitter           int      `env:"CACHE_TTL_JITTER" envDefault:"10"`         // Random spread applied to the TTLs (in percent).
+	Lock             bool     `env:"CACHE_LOCK" envDefault:"false"`            // Coalesce reloads across replicas with a Redis lock.
+	LockTime         int      `env:"CACHE_LOCK_TIME" envDefault:"5"`           // Expiration of the reload lock (in seconds).
+	Local            bool     `env:"CACHE_LOCAL" envDefault:"false"`           // Hold cached entries in process in front of Redis.
+	LocalMax         int      `env:"CACHE_LOCAL_SIZE" envDefault:"10000"`      // Maximum number of entries held in process.
+	LocalTTL         int      `env:"CACHE_LOCAL_TTL" envDefault:"30"`          // Expiration of the entries held in process (in seconds).
+	Codec            string   `env:"CACHE_CODEC" envDefault:"json"`            // Encoding of cached entries: json, go-json or msgpack.
+	Compression      string   `env:"CACHE_COMPRESSION" envDefault:"none"`      // Compression of cached entries: none, gzip or zstd.
+	CompressionMin   int      `env:"CACHE_COMPRESSION_MIN" envDefault:"1024"`  // Minimum encoded size of compressed entries (in bytes).
 }
 
 // NewConfig initializes a new RedisConfig by loading the configuration.
@@ -31,17 +62,141 @@ func NewConfig() (*RedisConfig, error) {
 	return &config, nil // Return the loaded configuration.
 }
 
+// Validate reports every setting contradicting another one or out of its range.
+func (redisConfig *RedisConfig) Validate() error {
+	var errs []error
+	if redisConfig.MinCon < 0 || redisConfig.MinCon > redisConfig.MaxCon {
+		errs = append(errs, fmt.Errorf("CACHE_DB_MIN_CON %d must be between 0 and CACHE_DB_MAX_CON %d", redisConfig.MinCon, redisConfig.MaxCon))
+	}
+	if redisConfig.MinRetryBackoff > redisConfig.MaxRetryBackoff {
+		errs = append(errs, fmt.Errorf("CACHE_MIN_RETRY_BACKOFF %d must not exceed CACHE_MAX_RETRY_BACKOFF %d", redisConfig.MinRetryBackoff, redisConfig.MaxRetryBackoff))
+	}
+	// A soft TTL above the TTL is clamped to it
+	if redisConfig.TTL <= 0 || redisConfig.SoftTTL < 0 {
+		errs = append(errs, errors.New("CACHE_TTL must be positive and CACHE_SOFT_TTL must not be negative"))
+	}
+	if redisConfig.Jitter < 0 || redisConfig.Jitter > 100 {
+		errs = append(errs, fmt.Errorf("CACHE_TTL_JITTER %d must be a percentage", redisConfig.Jitter))
+	}
+	if redisConfig.Master != "" && redisConfig.Cluster {
+		errs = append(errs, errors.New("CACHE_SENTINEL_MASTER and CACHE_CLUSTER can not be set together"))
+	}
+	if !redisConfig.TLS && (redisConfig.TLSCA != "" || redisConfig.TLSName != "" || redisConfig.TLSInsecure) {
+		errs = append(errs, errors.New("CACHE_TLS_CA, CACHE_TLS_SERVER_NAME and CACHE_TLS_INSECURE require CACHE_TLS"))
+	}
+	switch redisConfig.Codec {
+	case "json", "go-json", "msgpack":
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_CODEC %q is not supported, use json, go-json or msgpack", redisConfig.Codec))
+	}
+	switch redisConfig.Compression {
+	case "none", "gzip", "zstd":
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_COMPRESSION %q is not supported, use none, gzip or zstd", redisConfig.Compression))
+	}
+	return errors.Join(errs...)
+}
+
 // NewClient creates a new Redis client using the configuration.
-func (redisConfig *RedisConfig) NewClient() *redis.Client {
-	addr := fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port)
-	return redis.NewClient(&redis.Options{
-		Addr:            addr,                                             // Redis server address.
-		Username:        redisConfig.User,                                 // Redis username.
-		Password:        redisConfig.Password,                             // Redis password.
-		DB:              redisConfig.Name,                                 // Redis database number.
-		MinIdleConns:    redisConfig.MinCon,                               // Minimum number of idle connections.
-		MaxIdleConns:    redisConfig.MaxCon,                               // Maximum number of idle connections.
-		ConnMaxIdleTime: time.Duration(redisConfig.MinTime) * time.Minute, // Maximum idle connection time.
-		ConnMaxLifetime: time.Duration(redisConfig.MaxTime) * time.Minute, // Maximum connection lifetime.
-	})
+// It is a single node, a Sentinel failover or a Cluster client depending on the addresses, the master name and CACHE_CLUSTER.
+// Every command is traced.
+func (redisConfig *RedisConfig) NewClient() (redis.UniversalClient, error) {
+	options, err := redisConfig.UniversalOptions()
+	if err != nil {
+		return nil, err
+	}
+	var client redis.UniversalClient
+	if redisConfig.Cluster {
+		client = redis.NewClusterClient(options.Cluster())
+	} else {
+		client = redis.NewUniversalClient(options)
+	}
+	client.AddHook(tracing.RedisHook{})
+	return client, nil
+}
+
+// UniversalOptions returns the options of the Redis client described by the configuration.
+func (redisConfig *RedisConfig) UniversalOptions() (*redis.UniversalOptions, error) {
+	addrs := redisConfig.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%d", redisConfig.Host, redisConfig.Port)}
+	}
+	tlsConfig, err := redisConfig.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redis.UniversalOptions{
+		Addrs:            addrs,                                                         // Redis server, cluster node or sentinel addresses.
+		MasterName:       redisConfig.Master,                                            // Sentinel master name.
+		Username:         redisConfig.User,                                              // Redis username.
+		Password:         redisConfig.Password,                                          // Redis password.
+		SentinelUsername: redisConfig.SentinelUser,                                      // Sentinel username.
+		SentinelPassword: redisConfig.SentinelPassword,                                  // Sentinel password.
+		DB:               redisConfig.Name,                                              // Redis database number.
+		MinIdleConns:     redisConfig.MinCon,                                            // Minimum number of idle connections.
+		MaxIdleConns:     redisConfig.MaxCon,                                            // Maximum number of idle connections.
+		ConnMaxIdleTime:  time.Duration(redisConfig.MinTime) * time.Minute,              // Maximum idle connection time.
+		ConnMaxLifetime:  time.Duration(redisConfig.MaxTime) * time.Minute,              // Maximum connection lifetime.
+		MaxRetries:       redisConfig.MaxRetries,                                        // Retries of a failed command.
+		MinRetryBackoff:  time.Duration(redisConfig.MinRetryBackoff) * time.Millisecond, // Minimum backoff between retries.
+		MaxRetryBackoff:  time.Duration(redisConfig.MaxRetryBackoff) * time.Millisecond, // Maximum backoff between retries.
+		TLSConfig:        tlsConfig,                                                     // TLS settings, nil without TLS.
+	}, nil
+}
+
+// TLSConfig returns the TLS settings of the connections, or nil when TLS is disabled.
+func (redisConfig *RedisConfig) TLSConfig() (*tls.Config, error) {
+	if !redisConfig.TLS {
+		return nil, nil
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         redisConfig.TLSName,
+		InsecureSkipVerify: redisConfig.TLSInsecure,
+	}
+	if redisConfig.TLSCA != "" {
+		pem, err := os.ReadFile(redisConfig.TLSCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CACHE_TLS_CA: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CACHE_TLS_CA holds no PEM certificate")
+		}
+	}
+	return config, nil
+}
+
+// CacheTTL returns how long cached entries are kept.
+func (redisConfig *RedisConfig) CacheTTL() time.Duration {
+	return time.Duration(redisConfig.TTL) * time.Minute
+}
+
+// CacheSoftTTL returns the age after which cached entries are stale and refreshed in the background, never beyond CacheTTL.
+func (redisConfig *RedisConfig) CacheSoftTTL() time.Duration {
+	return min(time.Duration(redisConfig.SoftTTL)*time.Minute, redisConfig.CacheTTL())
+}
+
+// CacheJitter spreads the TTL randomly by up to the configured percentage, so entries written together do not expire together.
+func (redisConfig *RedisConfig) CacheJitter(ttl time.Duration) time.Duration {
+	if redisConfig.Jitter <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * float64(redisConfig.Jitter) / 100
+	return ttl + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// CacheLockTTL returns how long a replica may hold the reload lock of a key.
+func (redisConfig *RedisConfig) CacheLockTTL() time.Duration {
+	return time.Duration(redisConfig.LockTime) * time.Second
+}
+
+// CacheBreakerOpen returns how long the circuit breaker of the cache stays open before probing Redis again.
+func (redisConfig *RedisConfig) CacheBreakerOpen() time.Duration {
+	return time.Duration(redisConfig.BreakerOpen) * time.Second
+}
+
+// CacheLocalTTL returns how long entries are held in process.
+func (redisConfig *RedisConfig) CacheLocalTTL() time.Duration {
+	return time.Duration(redisConfig.LocalTTL) * time.Second
 }
@@ -1,10 +1,16 @@
 package cache_test
 
 import (
+	"encoding/pem"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
 )
@@ -32,6 +38,7 @@ func TestNewConfig_Success(t *testing.T) {
 	require.Equal(t, 5432, config.Port)
 	require.Equal(t, "user", config.User)
 	require.Equal(t, "password", config.Password)
+	require.Equal(t, 30*time.Minute, config.CacheTTL())
 }
 
 func TestNewConfig_MissingEnvVars(t *testing.T) {
@@ -68,9 +75,11 @@ func TestCreatesNewRedisClientWithValidConfig(t *testing.T) {
 		MinTime:  2,
 	}
 
-	client := config.NewClient()
+	universalClient, err := config.NewClient()
+	require.NoError(t, err)
 
-	assert.NotNil(t, client)
+	client, ok := universalClient.(*redis.Client)
+	require.True(t, ok)
 	assert.Equal(t, "localhost:6379", client.Options().Addr)
 	assert.Equal(t, "user", client.Options().Username)
 	assert.Equal(t, "password", client.Options().Password)
@@ -95,8 +104,132 @@ func TestHandlesMissingOrEmptyHostAndPort(t *testing.T) {
 		MinTime:  2,
 	}
 
-	client := config.NewClient()
+	universalClient, err := config.NewClient()
+	require.NoError(t, err)
 
-	assert.NotNil(t, client)
+	client, ok := universalClient.(*redis.Client)
+	require.True(t, ok)
 	assert.Equal(t, ":0", client.Options().Addr)
 }
+
+// Soft TTL never exceeds the hard TTL
+func TestCacheSoftTTLClampedToTTL(t *testing.T) {
+	config := &cache.RedisConfig{TTL: 30, SoftTTL: 25}
+	assert.Equal(t, 25*time.Minute, config.CacheSoftTTL())
+
+	config.SoftTTL = 60
+	assert.Equal(t, 30*time.Minute, config.CacheSoftTTL())
+}
+
+// Jitter spreads the TTL within the configured percentage
+func TestCacheJitterWithinBounds(t *testing.T) {
+	config := &cache.RedisConfig{Jitter: 10}
+	for i := 0; i < 100; i++ {
+		ttl := config.CacheJitter(time.Hour)
+		assert.GreaterOrEqual(t, ttl, 54*time.Minute)
+		assert.LessOrEqual(t, ttl, 66*time.Minute)
+	}
+
+	config.Jitter = 0
+	assert.Equal(t, time.Hour, config.CacheJitter(time.Hour))
+}
+
+// Selects the client of the topology
+func TestNewClientTopology(t *testing.T) {
+	t.Run("Cluster", func(t *testing.T) {
+		config := &cache.RedisConfig{Addrs: []string{"node1:6379", "node2:6379", "node3:6379"}}
+		client, err := config.NewClient()
+		require.NoError(t, err)
+		defer client.Close()
+
+		cluster, ok := client.(*redis.ClusterClient)
+		require.True(t, ok)
+		assert.Equal(t, []string{"node1:6379", "node2:6379", "node3:6379"}, cluster.Options().Addrs)
+	})
+
+	t.Run("ClusterThroughSingleAddress", func(t *testing.T) {
+		config := &cache.RedisConfig{Host: "configuration-endpoint", Port: 6379, Cluster: true}
+		client, err := config.NewClient()
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, ok := client.(*redis.ClusterClient)
+		require.True(t, ok)
+	})
+
+	t.Run("Sentinel", func(t *testing.T) {
+		config := &cache.RedisConfig{Addrs: []string{"sentinel1:26379", "sentinel2:26379"}, Master: "mymaster", SentinelPassword: "secret"}
+		options, err := config.UniversalOptions()
+		require.NoError(t, err)
+		assert.Equal(t, "mymaster", options.MasterName)
+		assert.Equal(t, "secret", options.SentinelPassword)
+
+		client, err := config.NewClient()
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, ok := client.(*redis.Client)
+		require.True(t, ok)
+	})
+}
+
+// Passes the retry and backoff settings
+func TestUniversalOptionsRetry(t *testing.T) {
+	config := &cache.RedisConfig{MaxRetries: 5, MinRetryBackoff: 10, MaxRetryBackoff: 1000}
+
+	options, err := config.UniversalOptions()
+	require.NoError(t, err)
+	assert.Equal(t, 5, options.MaxRetries)
+	assert.Equal(t, 10*time.Millisecond, options.MinRetryBackoff)
+	assert.Equal(t, time.Second, options.MaxRetryBackoff)
+	assert.Nil(t, options.TLSConfig)
+}
+
+// Loads the custom certificate authority of TLS connections
+func TestTLSConfig(t *testing.T) {
+	t.Run("CustomCA", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+
+		config := &cache.RedisConfig{TLS: true, TLSCA: path, TLSName: "redis.internal"}
+		tlsConfig, err := config.TLSConfig()
+		require.NoError(t, err)
+		require.NotNil(t, tlsConfig.RootCAs)
+		assert.Equal(t, "redis.internal", tlsConfig.ServerName)
+		assert.False(t, tlsConfig.InsecureSkipVerify)
+	})
+
+	t.Run("MissingCA", func(t *testing.T) {
+		config := &cache.RedisConfig{TLS: true, TLSCA: filepath.Join(t.TempDir(), "missing.pem")}
+		_, err := config.NewClient()
+		require.Error(t, err)
+	})
+
+	t.Run("InvalidCA", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0o600))
+
+		config := &cache.RedisConfig{TLS: true, TLSCA: path}
+		_, err := config.TLSConfig()
+		require.Error(t, err)
+	})
+}
+
+// Reports every setting contradicting another one
+func TestValidate(t *testing.T) {
+	config := &cache.RedisConfig{MaxCon: 100, MinCon: 10, MinRetryBackoff: 8, MaxRetryBackoff: 512, TTL: 30, SoftTTL: 25, Jitter: 10, Codec: "json", Compression: "none"}
+	require.NoError(t, config.Validate())
+
+	config.MinCon = 200
+	config.Master = "mymaster"
+	config.Cluster = true
+	config.TLSInsecure = true
+	config.Codec = "xml"
+	err := config.Validate()
+	require.ErrorContains(t, err, "CACHE_DB_MIN_CON")
+	require.ErrorContains(t, err, "CACHE_SENTINEL_MASTER")
+	require.ErrorContains(t, err, "CACHE_TLS")
+	require.ErrorContains(t, err, "CACHE_CODEC")
+}
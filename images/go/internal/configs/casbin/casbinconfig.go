@@ -43,6 +43,22 @@ func NewCasbin(db *gorm.DB, lookup func(ctx *fiber.Ctx) string) (*casbin.Enforce
 
 // Casbin holds the configuration for Casbin.
 type Casbin struct {
-	ModelPath string `env:"MODEL_PATH" envDefault:"resource/model"` // Path to the Casbin model file
-	ModelName string `env:"MODEL_FILENAME" envDefault:"rbac_model.conf"`
+	ModelPath  string `env:"MODEL_PATH" envDefault:"resource/model"` // Path to the Casbin model file
+	ModelName  string `env:"MODEL_FILENAME" envDefault:"rbac_model.conf"`
+	PolicyPath string `env:"POLICY_PATH" envDefault:"resource/policy"` // Path to the policy file seeded and synced by the CLI
+	PolicyName string `env:"POLICY_FILENAME" envDefault:"policy.csv"`  // Name of the policy file, in the CSV format of Casbin
+}
+
+// NewConfig loads the Casbin configuration from the environment.
+func NewConfig() (*Casbin, error) {
+	var casbinInstance Casbin
+	if err := configs.GetConfig().Load(&casbinInstance); err != nil {
+		return nil, err
+	}
+	return &casbinInstance, nil
+}
+
+// PolicyFile returns the path of the policy file.
+func (casbinInstance Casbin) PolicyFile() string {
+	return pathhelper.AddWorkdirToSomePath(casbinInstance.PolicyPath, casbinInstance.PolicyName)
 }
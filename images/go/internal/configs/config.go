@@ -2,48 +2,348 @@ package configs
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 	path_helper "github.com/tirtahakimpambudhi/restful_api/pkg/helper/path"
+	"gopkg.in/yaml.v3"
 )
 
+// Layers a setting is read from, from the lowest to the highest precedence.
+const (
+	SourceDefault = "default" // The envDefault tag of the field
+	SourceYAML    = "yaml"    // The YAML file
+	SourceDotenv  = ".env"    // The .env file
+	SourceEnv     = "env"     // The process environment
+	SourceFlag    = "flag"    // A command line flag
+	SourceSecret  = "secret"  // A secret provider, below the flags only
+)
+
+// redacted replaces the value of the secret settings in a dump.
+const redacted = "******"
+
+// Validator is implemented by the configurations checking their settings together once loaded.
+type Validator interface {
+	Validate() error
+}
+
+// Setting is a loaded setting with the layer it comes from.
+type Setting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
 // Config struct holds the application configuration.
 type Config struct {
-	Filename string
+	Filename     string
+	YAMLFilename string
+	files        map[string]Setting // Settings filled from the .env or YAML file, by key
+	err          error              // Error reading the files, returned by every Load
 }
 
-// Load loads and parses environment variables into the provided struct(s).
-// values must contain at least one argument.
+// Load loads and parses environment variables into the provided struct(s), then validates the ones implementing Validator.
+// values must contain at least one argument. The errors of every value are reported together.
 func (c *Config) Load(values ...any) error {
 	if len(values) == 0 {
 		return errors.New("Load arguments must be filled with at least one value")
 	}
+	errs := []error{c.err}
 	for _, value := range values {
 		if err := env.Parse(value); err != nil {
-			return err
+			errs = append(errs, err)
+			continue
+		}
+		if validator, ok := value.(Validator); ok {
+			errs = append(errs, validator.Validate())
 		}
 	}
-	return nil
+	return errors.Join(errs...)
+}
+
+// Source returns the layer the setting is read from, a setting changed since a file filled it is read from the process environment.
+func (c *Config) Source(key string) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if source, ok := overrides[key]; ok {
+		return source
+	}
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return SourceDefault
+	}
+	if file, ok := c.files[key]; ok && file.Value == value {
+		return file.Source
+	}
+	return SourceEnv
+}
+
+// Dump lists the settings of the loaded values sorted by key, with the layer they come from.
+// The value of a field tagged redact:"true", or whose key names a password or a secret unless tagged redact:"false", is replaced when not empty.
+func (c *Config) Dump(values ...any) []Setting {
+	var settings []Setting
+	for _, value := range values {
+		settings = c.dump(settings, reflect.ValueOf(value))
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// dump appends the settings of the fields of value, walking into the nested configurations.
+func (c *Config) dump(settings []Setting, value reflect.Value) []Setting {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return settings
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return settings
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag, ok := field.Tag.Lookup("env")
+		if !ok {
+			settings = c.dump(settings, value.Field(i))
+			continue
+		}
+		key := strings.Split(tag, ",")[0]
+		setting := Setting{Key: key, Value: format(value.Field(i)), Source: c.Source(key)}
+		redact, tagged := field.Tag.Lookup("redact")
+		if !tagged {
+			redact = strconv.FormatBool(strings.Contains(key, "PASS") || strings.Contains(key, "SECRET"))
+		}
+		if setting.Value != "" && redact == "true" {
+			setting.Value = redacted
+		}
+		settings = append(settings, setting)
+	}
+	return settings
+}
+
+// format writes a value as it is written in the environment, slices are comma separated.
+func format(value reflect.Value) string {
+	if value.Kind() == reflect.Slice {
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(value.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value.Interface())
 }
 
 var (
 	ConfigFile string = ".env"
-	instance   Config
-	once       sync.Once
+	// YAMLFile is the optional YAML file holding the settings below the .env file.
+	YAMLFile  string = "config.yaml"
+	instance  Config
+	once      sync.Once
+	overrides = map[string]string{} // Layer of the settings set by Set and SetSecret
+	mutex     sync.Mutex            // Guards overrides and the settings filled from the files
 )
 
-// GetConfig loads the .env file if it exists, and returns the Config instance.
+// Set sets a setting from a command line flag, above every other layer.
+func Set(key string, value string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	overrides[key] = SourceFlag
+	return os.Setenv(key, value)
+}
+
+// SetSecret sets a setting resolved by a secret provider, above every layer but the flags.
+func SetSecret(key string, value string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if overrides[key] == SourceFlag {
+		return nil
+	}
+	overrides[key] = SourceSecret
+	return os.Setenv(key, value)
+}
+
+// GetConfig merges the layers into the process environment once, and returns the Config instance.
+// The process environment keeps its values, the .env file fills the settings it lacks, then the YAML file the remaining ones.
+// Both files are optional, an unreadable YAML file is reported by every Load.
 func GetConfig() *Config {
 	once.Do(func() {
 		instance = Config{
-			Filename: path_helper.AddWorkdirToSomePath(ConfigFile),
+			Filename:     resolve(ConfigFile),
+			YAMLFilename: resolve(YAMLFile),
+			files:        map[string]Setting{},
 		}
-		if err := godotenv.Load(instance.Filename); err != nil {
-			log.Printf("No .env file found, using environment variables")
+		settings, err := instance.read()
+		instance.err = err
+		for key, setting := range settings {
+			if _, ok := os.LookupEnv(key); ok {
+				continue
+			}
+			if err := os.Setenv(key, setting.Value); err == nil {
+				instance.files[key] = setting
+			}
 		}
 	})
 	return &instance
 }
+
+// Change is a setting changed in the files since they were read, To is empty when the setting is removed.
+type Change struct {
+	Key    string
+	From   string
+	To     string
+	Source string // Layer holding the new value, empty when the setting is removed
+
+	previous *Setting // Setting filled from the files before the change, nil when it was unset
+}
+
+// Changes reads the files again and returns the settings they changed, sorted by key.
+// The settings of the process environment, the flags and the secret providers are left out, they keep their precedence.
+func (c *Config) Changes() ([]Change, error) {
+	settings, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	var changes []Change
+	for key := range merge(c.files, settings) {
+		if _, ok := overrides[key]; ok {
+			continue
+		}
+		current, set := os.LookupEnv(key)
+		previous, filled := c.files[key]
+		if set && (!filled || previous.Value != current) {
+			// The setting is owned by the process environment
+			continue
+		}
+		next, ok := settings[key]
+		switch {
+		case !ok && filled:
+			changes = append(changes, Change{Key: key, From: previous.Value, previous: &previous})
+		case ok && !filled:
+			changes = append(changes, Change{Key: key, To: next.Value, Source: next.Source})
+		case ok && next.Value != previous.Value:
+			changes = append(changes, Change{Key: key, From: previous.Value, To: next.Value, Source: next.Source, previous: &previous})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// Apply sets the changed settings in the process environment, the next Load reads them.
+func (c *Config) Apply(changes []Change) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	var errs []error
+	for _, change := range changes {
+		if change.Source == "" {
+			errs = append(errs, os.Unsetenv(change.Key))
+			delete(c.files, change.Key)
+			continue
+		}
+		errs = append(errs, os.Setenv(change.Key, change.To))
+		c.files[change.Key] = Setting{Key: change.Key, Value: change.To, Source: change.Source}
+	}
+	return errors.Join(errs...)
+}
+
+// Revert restores the settings applied by Apply.
+func (c *Config) Revert(changes []Change) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	var errs []error
+	for _, change := range changes {
+		if change.previous == nil {
+			errs = append(errs, os.Unsetenv(change.Key))
+			delete(c.files, change.Key)
+			continue
+		}
+		errs = append(errs, os.Setenv(change.Key, change.previous.Value))
+		c.files[change.Key] = *change.previous
+	}
+	return errors.Join(errs...)
+}
+
+// read reads the settings of both files, the .env file taking precedence over the YAML file. A missing file holds no setting,
+// the settings of the .env file are returned with the error of an unreadable YAML file.
+func (c *Config) read() (map[string]Setting, error) {
+	settings := map[string]Setting{}
+	var yamlErr error
+	body, err := os.ReadFile(c.YAMLFilename)
+	if err == nil {
+		var document map[string]any
+		if err := yaml.Unmarshal(body, &document); err != nil {
+			yamlErr = fmt.Errorf("%s: %w", filepath.Base(c.YAMLFilename), err)
+		}
+		values := map[string]string{}
+		flatten(values, "", document)
+		for key, value := range values {
+			settings[key] = Setting{Key: key, Value: value, Source: SourceYAML}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		yamlErr = err
+	}
+
+	dotenv, err := godotenv.Read(c.Filename)
+	if err != nil {
+		log.Printf("No .env file found, using environment variables")
+	}
+	for key, value := range dotenv {
+		settings[key] = Setting{Key: key, Value: value, Source: SourceDotenv}
+	}
+	return settings, yamlErr
+}
+
+// merge returns the keys of both maps.
+func merge(previous map[string]Setting, next map[string]Setting) map[string]bool {
+	keys := make(map[string]bool, len(previous)+len(next))
+	for key := range previous {
+		keys[key] = true
+	}
+	for key := range next {
+		keys[key] = true
+	}
+	return keys
+}
+
+// resolve returns the absolute path of a file, relative paths are read from the working directory.
+func resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return path_helper.AddWorkdirToSomePath(path)
+}
+
+// flatten names the settings of a YAML document like their environment variables, the nested keys are joined by _ and upper cased,
+// so db: {max_con: 10} sets DB_MAX_CON. Lists are comma separated.
+func flatten(settings map[string]string, prefix string, document map[string]any) {
+	for key, value := range document {
+		key = strings.ToUpper(prefix + key)
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(settings, key+"_", value)
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				items[i] = fmt.Sprint(item)
+			}
+			settings[key] = strings.Join(items, ",")
+		case nil:
+			settings[key] = ""
+		default:
+			settings[key] = fmt.Sprint(value)
+		}
+	}
+}
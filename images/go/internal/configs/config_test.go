@@ -1,7 +1,9 @@
 package configs_test
 
 import (
+	"errors"
 	"os"
+	"strings"
 	"testing"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 	"github.com/stretchr/testify/assert"
@@ -82,3 +84,99 @@ func TestConfig_Load_Fail_WithoutEnvConfig(t *testing.T) {
 	// Assertions
 	assert.Error(t, err)
 }
+
+type LayeredConfig struct {
+	Name    string   `env:"LAYERED_NAME"`
+	Hosts   []string `env:"LAYERED_HOSTS" envSeparator:","`
+	Secret  string   `env:"LAYERED_SECRET"`
+	Token   string   `env:"LAYERED_TOKEN" redact:"true"`
+	Missing int      `env:"LAYERED_MISSING" envDefault:"3"`
+}
+
+type ValidatedConfig struct {
+	Port string `env:"PORT"`
+}
+
+func (config *ValidatedConfig) Validate() error {
+	return errors.New("PORT is invalid")
+}
+
+func TestMain(m *testing.M) {
+	// The YAML file is read once, by the first test loading a configuration
+	yamlFile := "layered.yaml"
+	configs.YAMLFile = yamlFile
+	if err := os.WriteFile(yamlFile, []byte("layered:\n  name: yaml\n  hosts: [a, b]\n  secret: hidden\n  token: hidden\n"), 0644); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.Remove(yamlFile)
+	os.Exit(code)
+}
+
+func TestConfig_Layers(t *testing.T) {
+	config := configs.GetConfig()
+	layered := &LayeredConfig{}
+	assert.NoError(t, config.Load(layered))
+	assert.Equal(t, &LayeredConfig{Name: "yaml", Hosts: []string{"a", "b"}, Secret: "hidden", Token: "hidden", Missing: 3}, layered)
+	assert.Equal(t, configs.SourceYAML, config.Source("LAYERED_NAME"))
+	assert.Equal(t, configs.SourceDefault, config.Source("LAYERED_MISSING"))
+
+	// a flag takes precedence over every other source
+	assert.NoError(t, configs.Set("LAYERED_NAME", "flag"))
+	defer os.Unsetenv("LAYERED_NAME")
+	assert.NoError(t, config.Load(layered))
+	assert.Equal(t, "flag", layered.Name)
+
+	// the secrets are redacted
+	assert.Equal(t, []configs.Setting{
+		{Key: "LAYERED_HOSTS", Value: "a,b", Source: configs.SourceYAML},
+		{Key: "LAYERED_MISSING", Value: "3", Source: configs.SourceDefault},
+		{Key: "LAYERED_NAME", Value: "flag", Source: configs.SourceFlag},
+		{Key: "LAYERED_SECRET", Value: "******", Source: configs.SourceYAML},
+		{Key: "LAYERED_TOKEN", Value: "******", Source: configs.SourceYAML},
+	}, config.Dump(layered))
+}
+
+func TestConfig_Load_ReportsEveryError(t *testing.T) {
+	os.Setenv("LAYERED_MISSING", "three")
+	defer os.Unsetenv("LAYERED_MISSING")
+
+	err := configs.GetConfig().Load(&LayeredConfig{}, &ValidatedConfig{})
+
+	// Assertions
+	assert.ErrorContains(t, err, `"Missing"`)
+	assert.ErrorContains(t, err, "PORT is invalid")
+}
+
+func TestConfig_Changes(t *testing.T) {
+	config := configs.GetConfig()
+	body, err := os.ReadFile(config.YAMLFilename)
+	assert.NoError(t, err)
+	defer os.WriteFile(config.YAMLFilename, body, 0644)
+	assert.NoError(t, os.WriteFile(config.YAMLFilename, []byte("layered:\n  hosts: [c]\n  secret: hidden\n  extra: added\n"), 0644))
+
+	changes, err := config.Changes()
+	assert.NoError(t, err)
+	layered := map[string]configs.Change{}
+	for _, change := range changes {
+		if strings.HasPrefix(change.Key, "LAYERED_") {
+			layered[change.Key] = change
+		}
+	}
+	// the name is set by a flag and the secret is unchanged
+	assert.Len(t, layered, 3)
+	assert.Equal(t, "c", layered["LAYERED_HOSTS"].To)
+	assert.Equal(t, "added", layered["LAYERED_EXTRA"].To)
+	assert.Equal(t, "", layered["LAYERED_TOKEN"].Source)
+
+	assert.NoError(t, config.Apply(changes))
+	assert.Equal(t, "c", os.Getenv("LAYERED_HOSTS"))
+	assert.Equal(t, configs.SourceYAML, config.Source("LAYERED_EXTRA"))
+	_, set := os.LookupEnv("LAYERED_TOKEN")
+	assert.False(t, set)
+
+	assert.NoError(t, config.Revert(changes))
+	assert.Equal(t, "a,b", os.Getenv("LAYERED_HOSTS"))
+	assert.Equal(t, "hidden", os.Getenv("LAYERED_TOKEN"))
+	assert.Equal(t, configs.SourceDefault, config.Source("LAYERED_EXTRA"))
+}
@@ -0,0 +1,46 @@
+package export
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
+
+// Config holds the settings of the personal data export.
+type Config struct {
+	SyncLimit  int `env:"USER_EXPORT_SYNC_LIMIT" envDefault:"500"` // Records above which the archive is generated by a background job, one per document entry, role, permission and audit event.
+	Expiration int `env:"USER_EXPORT_EXPIRATION" envDefault:"60"`  // Minutes a generated archive is kept for download.
+}
+
+// NewConfig initializes a new export Config by loading the configuration.
+func NewConfig() (*Config, error) {
+	var config Config
+	// Load configuration values into Config struct.
+	if err := configs.GetConfig().Load(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate reports a negative limit and an expiration that is not positive.
+func (config *Config) Validate() error {
+	var errs []error
+	if config.SyncLimit < 0 {
+		errs = append(errs, errors.New("USER_EXPORT_SYNC_LIMIT must not be negative"))
+	}
+	if config.Expiration <= 0 {
+		errs = append(errs, errors.New("USER_EXPORT_EXPIRATION must be positive"))
+	}
+	return errors.Join(errs...)
+}
+
+// Background reports whether an export with the given number of records is too large to build within the request.
+func (config Config) Background(records int) bool {
+	return records > config.SyncLimit
+}
+
+// TTL returns how long a generated archive is kept for download.
+func (config Config) TTL() time.Duration {
+	return time.Duration(config.Expiration) * time.Minute
+}
@@ -0,0 +1,45 @@
+package export_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/export"
+)
+
+func TestNewConfig_Default(t *testing.T) {
+	config, err := export.NewConfig()
+
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	require.Equal(t, 500, config.SyncLimit)
+	require.Equal(t, 60*time.Minute, config.TTL())
+}
+
+func TestNewConfig_FromEnv(t *testing.T) {
+	t.Setenv("USER_EXPORT_SYNC_LIMIT", "10")
+	t.Setenv("USER_EXPORT_EXPIRATION", "5")
+
+	config, err := export.NewConfig()
+
+	require.NoError(t, err)
+	require.Equal(t, 10, config.SyncLimit)
+	require.Equal(t, 5*time.Minute, config.TTL())
+}
+
+func TestNewConfig_InvalidValue(t *testing.T) {
+	t.Setenv("USER_EXPORT_SYNC_LIMIT", "invalid")
+
+	config, err := export.NewConfig()
+
+	require.Error(t, err)
+	require.Nil(t, config)
+}
+
+func TestConfig_Background(t *testing.T) {
+	config := export.Config{SyncLimit: 10}
+
+	require.False(t, config.Background(10))
+	require.True(t, config.Background(11))
+}
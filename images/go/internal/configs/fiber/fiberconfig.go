@@ -3,10 +3,12 @@ package fiber
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	goJson "github.com/goccy/go-json"
 	"github.com/gofiber/fiber/v2"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
 	pathhelper "github.com/tirtahakimpambudhi/restful_api/pkg/helper/path"
 	"net/http"
@@ -36,6 +38,14 @@ func NewFiberConfig() (*FiberConfig, error) {
 	return &fiberConfig, nil
 }
 
+// Validate reports the certificate set without its key, or the key without its certificate.
+func (sslConfig *SSLConfig) Validate() error {
+	if (sslConfig.CertFile == "") != (sslConfig.KeyFile == "") {
+		return errors.New("FIBER_SSL_CERT and FIBER_SSL_KEY must be set together")
+	}
+	return nil
+}
+
 // SSLConfig represents the SSL configuration for the Fiber server.
 type SSLConfig struct {
 	Path     string `env:"FIBER_SSL_PATH" envDefault:"resource/ssl"`
@@ -48,7 +58,7 @@ type FiberConfig struct {
 	Host              string `env:"FIBER_HOST,required" envDefault:"localhost"`
 	Port              string `env:"FIBER_PORT,required" envDefault:"8081"`
 	SSL               *SSLConfig
-	Prefork           bool   `env:"FIBER_PREFORK" envDefault:"true"`
+	Prefork           bool   `env:"FIBER_PREFORK" envDefault:"false"`
 	StrictRouting     bool   `env:"FIBER_STRICT_ROUTING" envDefault:"true"`
 	CaseSensitive     bool   `env:"FIBER_CASE_SENSITIVE" envDefault:"true"`
 	BodyLimit         int    `env:"FIBER_BODY_LIMIT" envDefault:"4"`
@@ -58,6 +68,18 @@ type FiberConfig struct {
 	JSON              string `env:"FIBER_JSON" envDefault:"json"`
 }
 
+// Validate reports every limit that is not positive and an unknown JSON library.
+func (fiberConfig *FiberConfig) Validate() error {
+	var errs []error
+	if fiberConfig.BodyLimit <= 0 || fiberConfig.ReadTimeout <= 0 || fiberConfig.WriteTimeout <= 0 {
+		errs = append(errs, errors.New("FIBER_BODY_LIMIT, FIBER_READ_TIMEOUT and FIBER_WRITE_TIMEOUT must be positive"))
+	}
+	if fiberConfig.JSON != "json" && fiberConfig.JSON != "go-json" {
+		errs = append(errs, fmt.Errorf("FIBER_JSON %q is not supported, use json or go-json", fiberConfig.JSON))
+	}
+	return errors.Join(errs...)
+}
+
 // ToFiberAppConfig converts a FiberConfig instance to a fiber.Config instance for Fiber server configuration.
 func (fiberConfig *FiberConfig) ToFiberAppConfig() fiber.Config {
 	// Create a new fiber.Config instance based on the FiberConfig values.
@@ -74,15 +96,15 @@ func (fiberConfig *FiberConfig) ToFiberAppConfig() fiber.Config {
 			if errors.As(err, &errFiber) {
 				ctx.Status(errFiber.Code)
 				code := errorshandler.ConvertStatusCodeToString(errFiber.Code)
-				return ctx.JSON(response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.TypeErr(code), errFiber.Message)}})
+				return ctx.JSON(withRequestID(ctx, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.TypeErr(code), errFiber.Message)}}))
 			}
 			var standardErr *response.StandardErrors
 			if errors.As(err, &standardErr) {
 				ctx.Status(standardErr.Errors[0].Status)
-				return ctx.JSON(standardErr)
+				return ctx.JSON(withRequestID(ctx, standardErr))
 			}
 			ctx.Status(http.StatusInternalServerError)
-			return ctx.JSON(response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server :"+err.Error())}})
+			return ctx.JSON(withRequestID(ctx, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server :"+err.Error())}}))
 		},
 	}
 	// Set up JSON encoding and decoding based on the specified JSON library.
@@ -97,3 +119,21 @@ func (fiberConfig *FiberConfig) ToFiberAppConfig() fiber.Config {
 	// Return the fiber.Config instance.
 	return config
 }
+
+// withRequestID adds the ID of the request, stored by the RequestMeta middleware, to the meta of every error of the body.
+func withRequestID(ctx *fiber.Ctx, body *response.StandardErrors) *response.StandardErrors {
+	requestID := request.MetaFromContext(ctx.Context()).RequestID
+	if requestID == "" {
+		return body
+	}
+	for _, err := range body.Errors {
+		if err == nil {
+			continue
+		}
+		if err.Meta == nil {
+			err.Meta = map[string]any{}
+		}
+		err.Meta["request_id"] = requestID
+	}
+	return body
+}
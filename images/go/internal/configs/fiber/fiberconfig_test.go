@@ -2,8 +2,14 @@
 package fiber_test
 
 import (
+	"encoding/json"
 	"github.com/gofiber/fiber/v2"
 	fiber2 "github.com/tirtahakimpambudhi/restful_api/internal/configs/fiber"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -50,3 +56,29 @@ func TestConverts_FiberConfig_To_FiberConfig_Correctly(t *testing.T) {
 	require.Equal(t, expectedConfig.WriteTimeout, actualConfig.WriteTimeout)
 	require.Equal(t, expectedConfig.ReadTimeout, actualConfig.ReadTimeout)
 }
+
+func TestErrorHandler_AddsRequestID(t *testing.T) {
+	app := fiber.New((&fiber2.FiberConfig{}).ToFiberAppConfig())
+	app.Get("/users", func(ctx *fiber.Ctx) error {
+		ctx.Context().SetUserValue(request.MetaKey, &request.Meta{RequestID: "req-1"})
+		return &response.StandardErrors{Errors: []*response.Error{
+			errorshandler.NewError(errorshandler.BAD_REQUEST, "email is required"),
+			errorshandler.NewError(errorshandler.BAD_REQUEST, "password is required"),
+		}}
+	})
+	app.Get("/fiber", func(ctx *fiber.Ctx) error {
+		ctx.Context().SetUserValue(request.MetaKey, &request.Meta{RequestID: "req-2"})
+		return fiber.ErrForbidden
+	})
+
+	for path, requestID := range map[string]string{"/users": "req-1", "/fiber": "req-2"} {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, path, nil))
+		require.NoError(t, err)
+		var body response.StandardErrors
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		require.NotEmpty(t, body.Errors)
+		for _, errBody := range body.Errors {
+			require.Equal(t, requestID, errBody.Meta["request_id"])
+		}
+	}
+}
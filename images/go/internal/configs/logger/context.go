@@ -0,0 +1,41 @@
+package loggerconfig
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+)
+
+// FromContext returns a logger attaching the request ID, user ID and route of the request carried by ctx to every line.
+// logger is returned as is when ctx carries no request, like in the background jobs.
+func FromContext(ctx context.Context, logger *log.Logger) *log.Logger {
+	if ctx == nil {
+		return logger
+	}
+	meta := request.MetaFromContext(ctx)
+	if meta.RequestID == "" && meta.ActorID == "" {
+		return logger
+	}
+	fields := log.NewContext(append(log.Context(nil), logger.Context...))
+	if meta.RequestID != "" {
+		fields.Str("request_id", meta.RequestID)
+	}
+	if meta.ActorID != "" {
+		fields.Str("user_id", meta.ActorID)
+	}
+	if meta.Route != "" {
+		fields.Str("route", meta.Route)
+	}
+	return &log.Logger{
+		// The level is read atomically, a configuration reload may change it
+		Level:        log.Level(atomic.LoadUint32((*uint32)(&logger.Level))),
+		Caller:       logger.Caller,
+		TimeField:    logger.TimeField,
+		TimeFormat:   logger.TimeFormat,
+		TimeLocation: logger.TimeLocation,
+		Context:      fields.Value(),
+		Writer:       logger.Writer,
+	}
+}
@@ -0,0 +1,183 @@
+package loggerconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/phuslu/log"
+)
+
+// HTTPFormats holds the accepted values of LOG_HTTP_FORMAT: loki sends the Loki push API body,
+// elasticsearch sends the newline delimited body of the bulk API.
+var HTTPFormats = []string{"loki", "elasticsearch"}
+
+// errClosed is returned by the writes following Close.
+var errClosed = errors.New("log writer is closed")
+
+// HTTPWriterConfig holds the settings of an HTTPWriter.
+type HTTPWriterConfig struct {
+	URL           string            // Endpoint receiving the batches
+	Format        string            // One of HTTPFormats
+	Authorization string            // Authorization header of the requests, none when empty
+	Labels        map[string]string // Labels of the Loki stream
+	BatchSize     int               // Lines sent by a request at most
+	FlushInterval time.Duration     // Time between two requests when the batch is not full
+}
+
+// line is a log line waiting to be shipped.
+type line struct {
+	at   time.Time
+	body []byte
+}
+
+// HTTPWriter ships the log lines in batches from a background goroutine, so logging never waits for the endpoint.
+// When the queue is full the lines are dropped and counted, a failed request is reported on stderr and its batch dropped.
+type HTTPWriter struct {
+	config  HTTPWriterConfig
+	client  *http.Client
+	lines   chan line
+	done    chan struct{}
+	mutex   sync.RWMutex // Guards closed against the writes racing Close
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewHTTPWriter creates an HTTPWriter and starts shipping.
+func NewHTTPWriter(config HTTPWriterConfig, client *http.Client) *HTTPWriter {
+	if config.BatchSize < 1 {
+		config.BatchSize = 1
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	w := &HTTPWriter{config: config, client: client, lines: make(chan line, 64*config.BatchSize), done: make(chan struct{})}
+	go w.run()
+	return w
+}
+
+// WriteEntry implements log.Writer, the line is queued.
+func (w *HTTPWriter) WriteEntry(e *log.Entry) (int, error) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		return 0, errClosed
+	}
+	body := bytes.TrimRight(e.Value(), "\n")
+	select {
+	case w.lines <- line{at: time.Now(), body: append([]byte(nil), body...)}:
+		return len(body), nil
+	default:
+		w.dropped.Add(1)
+		return 0, nil
+	}
+}
+
+// Dropped returns the number of lines dropped as the queue was full.
+func (w *HTTPWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close ships the queued lines and stops the writer.
+func (w *HTTPWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.lines)
+	w.mutex.Unlock()
+	<-w.done
+	return nil
+}
+
+// run batches the queued lines until Close.
+func (w *HTTPWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]line, 0, w.config.BatchSize)
+	for {
+		select {
+		case next, ok := <-w.lines:
+			if !ok {
+				w.ship(batch)
+				return
+			}
+			batch = append(batch, next)
+			if len(batch) < w.config.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		w.ship(batch)
+		batch = batch[:0]
+	}
+}
+
+// ship sends a batch, reporting a failure on stderr as logging it could loop.
+func (w *HTTPWriter) ship(batch []line) {
+	if len(batch) == 0 {
+		return
+	}
+	body, contentType, err := w.encode(batch)
+	if err == nil {
+		err = w.send(body, contentType)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "log shipping dropped %d lines: %v\n", len(batch), err)
+	}
+}
+
+// encode writes the body of a batch in the configured format.
+func (w *HTTPWriter) encode(batch []line) ([]byte, string, error) {
+	var buffer bytes.Buffer
+	if w.config.Format == "elasticsearch" {
+		for _, line := range batch {
+			// The index or data stream is named by the URL, like /logs/_bulk
+			buffer.WriteString(`{"create":{}}` + "\n")
+			buffer.Write(line.body)
+			buffer.WriteByte('\n')
+		}
+		return buffer.Bytes(), "application/x-ndjson", nil
+	}
+	values := make([][2]string, len(batch))
+	for i, line := range batch {
+		values[i] = [2]string{strconv.FormatInt(line.at.UnixNano(), 10), string(line.body)}
+	}
+	body, err := json.Marshal(map[string]any{
+		"streams": []map[string]any{{"stream": w.config.Labels, "values": values}},
+	})
+	return body, "application/json", err
+}
+
+// send posts a body to the endpoint.
+func (w *HTTPWriter) send(body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.config.Authorization != "" {
+		req.Header.Set("Authorization", w.config.Authorization)
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%s answered %s", w.config.URL, res.Status)
+	}
+	return nil
+}
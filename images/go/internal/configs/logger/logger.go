@@ -1,15 +1,18 @@
 package loggerconfig
 
 import (
+	"errors"
+	"io"
+
 	"github.com/phuslu/log"
-	"os"
 )
 
 // Logger manages logging configuration and loggers for application and access logs.
 type Logger struct {
-	Config *LoggerConfig
-	Access *log.Logger
-	App    *log.Logger
+	Config  *LoggerConfig
+	Access  *log.Logger
+	App     *log.Logger
+	closers []io.Closer // Sinks of both loggers buffering lines
 }
 
 // NewLogger creates a new Logger instance with configuration and loggers.
@@ -22,28 +25,33 @@ func NewLogger() (*Logger, error) {
 		return nil, err
 	}
 
-	// Initialize application logger with configuration settings
-	appLog := &log.Logger{
-		TimeFormat: config.TimeFormat,
-		Level:      log.DebugLevel,
-		Caller:     1,
-	}
-
-	// Set writer for application logger based on terminal status
-	if log.IsTerminal(os.Stderr.Fd()) {
-		appLog.Writer = config.NewConsoleWriter()
-	} else {
-		appLog.Writer = config.NewFileWriterWithRotate("app.log")
-	}
+	// Each logger writes to the sinks of LOG_SINKS at its own level
+	appWriter, appCloser := config.NewWriter("app.log", "app")
+	accessWriter, accessCloser := config.NewWriter("access.log", "access")
 
 	// Return new Logger instance with access and application loggers
 	return &Logger{
 		Config: config,
 		Access: &log.Logger{
-			Level:  log.InfoLevel,
+			Level:  log.ParseLevel(config.AccessLevel),
 			Caller: 1,
-			Writer: config.NewFileWriterWithRotate("access.log"),
+			Writer: accessWriter,
 		},
-		App: appLog,
+		App: &log.Logger{
+			TimeFormat: config.TimeFormat,
+			Level:      log.ParseLevel(config.Level),
+			Caller:     1,
+			Writer:     appWriter,
+		},
+		closers: []io.Closer{appCloser, accessCloser},
 	}, err
 }
+
+// Close flushes the sinks buffering lines, like the HTTP shipper, the loggers must not be used afterwards.
+func (logger *Logger) Close() error {
+	var errs []error
+	for _, closer := range logger.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -1,6 +1,10 @@
 package loggerconfig
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+
 	"github.com/phuslu/log"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 	pathhelper "github.com/tirtahakimpambudhi/restful_api/pkg/helper/path"
@@ -18,6 +22,67 @@ type LoggerConfig struct {
 	ColorOutput    bool   `env:"LOG_COLOR_OUTPUT" envDefault:"false"`
 	QuoteString    bool   `env:"LOG_QUOTE_STR" envDefault:"false"`
 	EndWithMessage bool   `env:"LOG_END_WITH_MESSAGE" envDefault:"false"`
+	Level          string `env:"LOG_LEVEL" envDefault:"debug"`       // Minimum level of the application logs (trace, debug, info, warn, error)
+	AccessLevel    string `env:"LOG_ACCESS_LEVEL" envDefault:"info"` // Minimum level of the access logs
+
+	Sinks        []string `env:"LOG_SINKS" envSeparator:"," envDefault:"auto"`                   // Outputs of both loggers, see Sinks
+	SampleEvery  int      `env:"LOG_SAMPLE_EVERY" envDefault:"1"`                                // Keeps 1 in N of the lines below the warn level, 1 keeps them all
+	RedactFields []string `env:"LOG_REDACT_FIELDS" envSeparator:"," envDefault:"password,email"` // Fields whose value is replaced, an email field also masks the addresses in the messages
+
+	SyslogNetwork string `env:"LOG_SYSLOG_NETWORK" envDefault:"unixgram"` // Network of the syslog server (unixgram, udp, tcp)
+	SyslogAddress string `env:"LOG_SYSLOG_ADDR" envDefault:"/dev/log"`    // Address of the syslog server
+	SyslogTag     string `env:"LOG_SYSLOG_TAG" envDefault:"restful_api"`  // Tag of the syslog messages
+
+	HTTPURL       string `env:"LOG_HTTP_URL"`                           // Endpoint receiving the batches, like http://loki:3100/loki/api/v1/push or http://elasticsearch:9200/logs/_bulk
+	HTTPFormat    string `env:"LOG_HTTP_FORMAT" envDefault:"loki"`      // Body of the batches, loki or elasticsearch
+	HTTPAuth      string `env:"LOG_HTTP_AUTH" redact:"true"`            // Authorization header of the requests, like Basic dXNlcjpwYXNz
+	HTTPBatchSize int    `env:"LOG_HTTP_BATCH_SIZE" envDefault:"100"`   // Lines sent by a request at most
+	HTTPFlush     int    `env:"LOG_HTTP_FLUSH_INTERVAL" envDefault:"5"` // Seconds between two requests when the batch is not full
+}
+
+// Levels holds the accepted values of LOG_LEVEL and LOG_ACCESS_LEVEL.
+var Levels = []string{"trace", "debug", "info", "warn", "error"}
+
+// Sinks holds the accepted values of LOG_SINKS: auto writes to the console on a terminal and to rotated files otherwise,
+// console writes human readable lines to stderr, stdout writes JSON lines to stdout, file writes JSON lines to rotated files,
+// syslog sends to the syslog server and http ships batches to a Loki or Elasticsearch compatible endpoint.
+var Sinks = []string{"auto", "console", "stdout", "file", "syslog", "http"}
+
+// Validate reports every unknown level, sink or format and the settings missing to a selected sink.
+func (loggerConfig *LoggerConfig) Validate() error {
+	var errs []error
+	if !slices.Contains(Levels, loggerConfig.Level) {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL %q is not supported, use one of %v", loggerConfig.Level, Levels))
+	}
+	if !slices.Contains(Levels, loggerConfig.AccessLevel) {
+		errs = append(errs, fmt.Errorf("LOG_ACCESS_LEVEL %q is not supported, use one of %v", loggerConfig.AccessLevel, Levels))
+	}
+	if len(loggerConfig.Sinks) == 0 {
+		errs = append(errs, errors.New("LOG_SINKS must hold at least one sink"))
+	}
+	for _, sink := range loggerConfig.Sinks {
+		if !slices.Contains(Sinks, sink) {
+			errs = append(errs, fmt.Errorf("LOG_SINKS holds the unknown sink %q, use some of %v", sink, Sinks))
+		}
+	}
+	if loggerConfig.SampleEvery < 1 {
+		errs = append(errs, fmt.Errorf("LOG_SAMPLE_EVERY must be at least 1, got %d", loggerConfig.SampleEvery))
+	}
+	if slices.Contains(loggerConfig.Sinks, "syslog") && loggerConfig.SyslogAddress == "" {
+		errs = append(errs, errors.New("LOG_SYSLOG_ADDR is required by the syslog sink"))
+	}
+	if slices.Contains(loggerConfig.Sinks, "http") {
+		if loggerConfig.HTTPURL == "" {
+			errs = append(errs, errors.New("LOG_HTTP_URL is required by the http sink"))
+		}
+		if !slices.Contains(HTTPFormats, loggerConfig.HTTPFormat) {
+			errs = append(errs, fmt.Errorf("LOG_HTTP_FORMAT %q is not supported, use one of %v", loggerConfig.HTTPFormat, HTTPFormats))
+		}
+		if loggerConfig.HTTPBatchSize < 1 || loggerConfig.HTTPFlush < 1 {
+			errs = append(errs, errors.New("LOG_HTTP_BATCH_SIZE and LOG_HTTP_FLUSH_INTERVAL must be positive"))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // NewFileWriterWithRotate creates a file writer with rotation settings.
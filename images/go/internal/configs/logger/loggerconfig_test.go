@@ -2,8 +2,12 @@
 package loggerconfig_test
 
 import (
+	"bytes"
+	"context"
+	"github.com/phuslu/log"
 	"github.com/stretchr/testify/require"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
 	"os"
 	"path"
 	"testing"
@@ -107,3 +111,20 @@ func TestNewConsoleWriter_CorrectTimeFormat(t *testing.T) {
 	consoleWriter := config.NewConsoleWriter()
 	require.NotNil(t, consoleWriter)
 }
+
+// Attaches the request fields to every line
+func TestFromContext(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &log.Logger{Level: log.InfoLevel, Context: log.NewContext(nil).Str("service", "api").Value(), Writer: &log.IOWriter{Writer: &buffer}}
+
+	require.Same(t, logger, loggerconfig.FromContext(context.Background(), logger))
+
+	ctx := request.WithMeta(context.Background(), &request.Meta{RequestID: "req-1", ActorID: "user-1", Route: "GET /api/v1/users"})
+	loggerconfig.FromContext(ctx, logger).Info().Msg("Listed users")
+	require.Contains(t, buffer.String(), `"service":"api","request_id":"req-1","user_id":"user-1","route":"GET /api/v1/users"`)
+
+	buffer.Reset()
+	loggerconfig.FromContext(ctx, logger).Debug().Msg("Hidden")
+	require.Empty(t, buffer.String())
+	require.Equal(t, `"service":"api"`, string(logger.Context)[1:])
+}
@@ -0,0 +1,206 @@
+package loggerconfig
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/phuslu/log"
+)
+
+// redacted replaces the value of the redacted fields.
+const redacted = `"******"`
+
+// emailPattern matches the email addresses written in the messages.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// NewWriter returns the writer of a logger sending to the sinks of LOG_SINKS, filename names its rotated file
+// and stream its Loki stream or syslog tag suffix. The lines are sampled, then redacted, before reaching the sinks.
+// The returned closer flushes the sinks buffering lines.
+func (loggerConfig LoggerConfig) NewWriter(filename string, stream string) (log.Writer, io.Closer) {
+	var sinks log.MultiEntryWriter
+	for _, sink := range loggerConfig.Sinks {
+		switch sink {
+		case "auto":
+			if log.IsTerminal(os.Stderr.Fd()) && stream == "app" {
+				sinks = append(sinks, loggerConfig.NewConsoleWriter())
+			} else {
+				sinks = append(sinks, loggerConfig.NewFileWriterWithRotate(filename))
+			}
+		case "console":
+			sinks = append(sinks, loggerConfig.NewConsoleWriter())
+		case "stdout":
+			sinks = append(sinks, &log.IOWriter{Writer: os.Stdout})
+		case "file":
+			sinks = append(sinks, loggerConfig.NewFileWriterWithRotate(filename))
+		case "syslog":
+			sinks = append(sinks, &log.SyslogWriter{
+				Network: loggerConfig.SyslogNetwork,
+				Address: loggerConfig.SyslogAddress,
+				Tag:     loggerConfig.SyslogTag + "-" + stream,
+			})
+		case "http":
+			sinks = append(sinks, NewHTTPWriter(HTTPWriterConfig{
+				URL:           loggerConfig.HTTPURL,
+				Format:        loggerConfig.HTTPFormat,
+				Authorization: loggerConfig.HTTPAuth,
+				Labels:        map[string]string{"service": loggerConfig.SyslogTag, "logger": stream},
+				BatchSize:     loggerConfig.HTTPBatchSize,
+				FlushInterval: time.Duration(loggerConfig.HTTPFlush) * time.Second,
+			}, &http.Client{Timeout: 10 * time.Second}))
+		}
+	}
+
+	var writer log.Writer = &sinks
+	if len(loggerConfig.RedactFields) > 0 {
+		writer = NewRedactWriter(writer, loggerConfig.RedactFields...)
+	}
+	if loggerConfig.SampleEvery > 1 {
+		writer = &SampleWriter{Writer: writer, Every: uint64(loggerConfig.SampleEvery)}
+	}
+	return writer, &sinks
+}
+
+// SampleWriter keeps 1 in Every of the lines below the warn level, the warnings and errors are always written.
+type SampleWriter struct {
+	Writer log.Writer
+	Every  uint64
+	count  atomic.Uint64
+}
+
+// WriteEntry implements log.Writer.
+func (w *SampleWriter) WriteEntry(e *log.Entry) (int, error) {
+	if e.Level < log.WarnLevel && w.Every > 1 && (w.count.Add(1)-1)%w.Every != 0 {
+		return 0, nil
+	}
+	return w.Writer.WriteEntry(e)
+}
+
+// RedactWriter replaces the value of the fields of a JSON line named by one of its keys, at any depth.
+type RedactWriter struct {
+	Writer log.Writer
+	keys   [][]byte // Fields as they start in a line, "name":
+	emails bool     // Masks the email addresses anywhere in the line
+}
+
+// NewRedactWriter creates a RedactWriter of the fields, an email field also masks the addresses written in the messages.
+func NewRedactWriter(writer log.Writer, fields ...string) *RedactWriter {
+	w := &RedactWriter{Writer: writer}
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		w.keys = append(w.keys, []byte(`"`+field+`":`))
+		w.emails = w.emails || field == "email"
+	}
+	return w
+}
+
+// WriteEntry implements log.Writer, the entry is written as is when it holds nothing to redact.
+func (w *RedactWriter) WriteEntry(e *log.Entry) (int, error) {
+	line := []byte(e.Value())
+	redactedLine := w.redact(line)
+	if w.emails && emailPattern.Match(redactedLine) {
+		redactedLine = emailPattern.ReplaceAll(redactedLine, []byte("******"))
+	}
+	if bytes.Equal(redactedLine, line) {
+		return w.Writer.WriteEntry(e)
+	}
+	entry := log.NewContext(redactedLine)
+	entry.Level = e.Level
+	return w.Writer.WriteEntry(entry)
+}
+
+// redact returns the line with the value of the redacted fields replaced, line itself when it holds none of them.
+func (w *RedactWriter) redact(line []byte) []byte {
+	found := false
+	for _, key := range w.keys {
+		if bytes.Contains(line, key) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return line
+	}
+	out := make([]byte, 0, len(line))
+	for i := 0; i < len(line); {
+		if line[i] != '"' {
+			out = append(out, line[i])
+			i++
+			continue
+		}
+		end := stringEnd(line, i)
+		out = append(out, line[i:end]...)
+		if end < len(line) && line[end] == ':' && w.redacts(line[i:end+1]) {
+			out = append(out, ':')
+			out = append(out, redacted...)
+			i = valueEnd(line, end+1)
+			continue
+		}
+		i = end
+	}
+	return out
+}
+
+// redacts reports whether a key, quoted and followed by a colon, names a redacted field.
+func (w *RedactWriter) redacts(key []byte) bool {
+	for _, redactedKey := range w.keys {
+		if bytes.Equal(key, redactedKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns the index following the closing quote of the string starting at start.
+func stringEnd(line []byte, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(line)
+}
+
+// valueEnd returns the index following the JSON value starting at start.
+func valueEnd(line []byte, start int) int {
+	if start >= len(line) {
+		return start
+	}
+	switch line[start] {
+	case '"':
+		return stringEnd(line, start)
+	case '{', '[':
+		depth := 0
+		for i := start; i < len(line); i++ {
+			switch line[i] {
+			case '"':
+				i = stringEnd(line, i) - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return len(line)
+	}
+	for i := start; i < len(line); i++ {
+		if line[i] == ',' || line[i] == '}' || line[i] == ']' || line[i] == '\n' {
+			return i
+		}
+	}
+	return len(line)
+}
@@ -0,0 +1,127 @@
+package loggerconfig_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+)
+
+// Replaces the redacted fields at any depth and masks the addresses of the messages
+func TestRedactWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &log.Logger{Writer: loggerconfig.NewRedactWriter(&log.IOWriter{Writer: &buffer}, "password", "email")}
+
+	logger.Info().Str("email", "jane@example.com").Dict("user", log.NewContext(nil).Str("password", `p"a,ss}`).Int("age", 30).Value()).Msg("Created user jane@example.com")
+	line := buffer.String()
+	assert.Contains(t, line, `"email":"******"`)
+	assert.Contains(t, line, `"user":{"password":"******","age":30}`)
+	assert.Contains(t, line, `"message":"Created user ******"`)
+	assert.NotContains(t, line, "jane")
+	assert.True(t, json.Valid([]byte(line)))
+
+	buffer.Reset()
+	logger.Info().Str("username", "jane").Msg("Untouched")
+	assert.Contains(t, buffer.String(), `"username":"jane"`)
+}
+
+// Keeps 1 in N of the lines below warn and every warning
+func TestSampleWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := &log.Logger{Level: log.DebugLevel, Writer: &loggerconfig.SampleWriter{Writer: &log.IOWriter{Writer: &buffer}, Every: 5}}
+	for i := 0; i < 10; i++ {
+		logger.Info().Int("i", i).Msg("Noisy")
+	}
+	logger.Warn().Msg("Kept")
+	assert.Equal(t, 3, strings.Count(buffer.String(), "\n"))
+	assert.Contains(t, buffer.String(), `"i":0`)
+	assert.Contains(t, buffer.String(), `"i":5`)
+	assert.Contains(t, buffer.String(), "Kept")
+}
+
+// Ships the lines in batches in both formats
+func TestHTTPWriter(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		requests []*http.Request
+		bodies   []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		defer mutex.Unlock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	t.Run("Loki", func(t *testing.T) {
+		requests, bodies = nil, nil
+		writer := loggerconfig.NewHTTPWriter(loggerconfig.HTTPWriterConfig{
+			URL: server.URL, Format: "loki", Authorization: "Bearer token", Labels: map[string]string{"logger": "app"}, BatchSize: 2, FlushInterval: time.Hour,
+		}, server.Client())
+		logger := &log.Logger{Writer: writer}
+		for i := 0; i < 3; i++ {
+			logger.Info().Int("i", i).Msg("Shipped")
+		}
+		require.NoError(t, writer.Close())
+
+		require.Len(t, requests, 2)
+		assert.Equal(t, "Bearer token", requests[0].Header.Get("Authorization"))
+		var push struct {
+			Streams []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][2]string       `json:"values"`
+			} `json:"streams"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(bodies[0]), &push))
+		require.Len(t, push.Streams, 1)
+		assert.Equal(t, map[string]string{"logger": "app"}, push.Streams[0].Stream)
+		require.Len(t, push.Streams[0].Values, 2)
+		assert.Contains(t, push.Streams[0].Values[1][1], `"i":1`)
+	})
+
+	t.Run("Elasticsearch", func(t *testing.T) {
+		requests, bodies = nil, nil
+		writer := loggerconfig.NewHTTPWriter(loggerconfig.HTTPWriterConfig{URL: server.URL, Format: "elasticsearch", BatchSize: 10, FlushInterval: time.Hour}, server.Client())
+		logger := &log.Logger{Writer: writer}
+		logger.Info().Msg("First")
+		logger.Info().Msg("Second")
+		require.NoError(t, writer.Close())
+
+		require.Len(t, requests, 1)
+		assert.Equal(t, "application/x-ndjson", requests[0].Header.Get("Content-Type"))
+		lines := strings.Split(strings.TrimSuffix(bodies[0], "\n"), "\n")
+		require.Len(t, lines, 4)
+		assert.Equal(t, `{"create":{}}`, lines[0])
+		assert.Contains(t, lines[3], "Second")
+
+		_, err := writer.WriteEntry(log.NewContext([]byte(`{}`)))
+		require.Error(t, err)
+	})
+}
+
+// Reports the unknown sinks and the settings missing to the selected ones
+func TestLoggerConfig_ValidateSinks(t *testing.T) {
+	config := &loggerconfig.LoggerConfig{Level: "info", AccessLevel: "info", Sinks: []string{"stdout"}, SampleEvery: 1}
+	require.NoError(t, config.Validate())
+
+	config.AccessLevel = "verbose"
+	config.Sinks = []string{"stdout", "kafka", "http"}
+	config.SampleEvery = 0
+	err := config.Validate()
+	require.ErrorContains(t, err, "LOG_ACCESS_LEVEL")
+	require.ErrorContains(t, err, "kafka")
+	require.ErrorContains(t, err, "LOG_SAMPLE_EVERY")
+	require.ErrorContains(t, err, "LOG_HTTP_URL")
+}
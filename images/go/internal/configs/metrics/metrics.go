@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
+)
+
+// Namespace prefixes the metrics of the application, the Go runtime, process and pool metrics keep their usual names.
+const Namespace = "restful_api"
+
+// Config holds the settings of the Prometheus endpoint.
+type Config struct {
+	Enabled bool   `env:"METRICS_ENABLED" envDefault:"true"` // Serves GET /metrics and records the metrics, requires FIBER_PREFORK=false
+	Token   string `env:"METRICS_TOKEN" redact:"true"`       // Bearer token required by GET /metrics, none when empty
+}
+
+// NewConfig initializes a new metrics Config by loading the configuration.
+func NewConfig() (*Config, error) {
+	var config Config
+	// Load configuration values into Config struct.
+	if err := configs.GetConfig().Load(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate reports a token holding spaces, which can not be sent in the Authorization header.
+func (config *Config) Validate() error {
+	for _, r := range config.Token {
+		if r <= ' ' {
+			return errors.New("METRICS_TOKEN must not hold spaces")
+		}
+	}
+	return nil
+}
+
+// Registry holds every collector exposed by GET /metrics.
+// It lives in the memory of the process, which is why the metrics can not be enabled with FIBER_PREFORK.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Requests counts the answered requests by route pattern and status.
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_total",
+		Help:      "Answered HTTP requests by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+
+	// RequestErrors counts the errors of the error responses by route pattern and error code.
+	RequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "http_request_errors_total",
+		Help:      "Errors of the HTTP error responses by method, route pattern and error code.",
+	}, []string{"method", "route", "code"})
+
+	// RequestDuration observes the latency of the requests by route pattern.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// RequestsInFlight gauges the requests being served.
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests being served.",
+	})
+
+	// CacheLookups counts the lookups of the Redis cache by result: hit, stale, miss or error.
+	CacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Lookups of the Redis cache by result (hit, stale, miss, error).",
+	}, []string{"result"})
+
+	// AuthorizationDecisions counts the Casbin decisions by permission and result: allow, deny or error.
+	AuthorizationDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "casbin_decisions_total",
+		Help:      "Casbin authorization decisions by permission and result (allow, deny, error).",
+	}, []string{"permission", "result"})
+
+	// Logins counts the login attempts by result and failure reason.
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "auth_logins_total",
+		Help:      "Login attempts by result (success, failure) and failure reason.",
+	}, []string{"result", "reason"})
+
+	// breakerOpen reports the circuit breakers guarding the dependencies that are not closed.
+	breakerOpen = prometheus.NewDesc(
+		prometheus.BuildFQName(Namespace, "", "circuit_breaker_open"),
+		"Whether the circuit breaker guarding a dependency is open or half-open.",
+		[]string{"name", "state"}, nil,
+	)
+)
+
+func init() {
+	Registry.MustRegister(
+		Requests, RequestErrors, RequestDuration, RequestsInFlight, CacheLookups, AuthorizationDecisions, Logins,
+		breakerCollector{},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// RegisterDB exposes the pool statistics of a database under the name, registering the same name twice is a no-op.
+func RegisterDB(db *sql.DB, name string) error {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+	return err
+}
+
+// LocalCacheStats is the activity of an in-process cache since it was created.
+type LocalCacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
+
+// RegisterLocalCache exposes the activity of the in-process cache under the name, read from stats on every scrape.
+// Registering the same name twice is a no-op.
+func RegisterLocalCache(name string, stats func() LocalCacheStats) error {
+	err := Registry.Register(newLocalCacheCollector(name, stats))
+	if errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the collectors of Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// breakerCollector reads the state of the registered circuit breakers on every scrape.
+type breakerCollector struct{}
+
+// Describe implements prometheus.Collector.
+func (breakerCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- breakerOpen
+}
+
+// Collect implements prometheus.Collector.
+func (breakerCollector) Collect(metrics chan<- prometheus.Metric) {
+	for _, snapshot := range breaker.Snapshots() {
+		open := 0.0
+		if snapshot.State != breaker.Closed.String() {
+			open = 1
+		}
+		metrics <- prometheus.MustNewConstMetric(breakerOpen, prometheus.GaugeValue, open, snapshot.Name, snapshot.State)
+	}
+}
+
+// localCacheCollector reads the statistics of an in-process cache on every scrape.
+type localCacheCollector struct {
+	stats         func() LocalCacheStats
+	lookups       *prometheus.Desc
+	evictions     *prometheus.Desc
+	invalidations *prometheus.Desc
+	entries       *prometheus.Desc
+}
+
+// newLocalCacheCollector creates the collector of the cache, its name is a constant label so every cache registers once.
+func newLocalCacheCollector(name string, stats func() LocalCacheStats) localCacheCollector {
+	labels := prometheus.Labels{"cache": name}
+	return localCacheCollector{
+		stats: stats,
+		lookups: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "local_cache_lookups_total"),
+			"Lookups of the in-process cache by result (hit, miss), the misses are looked up in Redis.", []string{"result"}, labels),
+		evictions: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "local_cache_evictions_total"),
+			"Entries dropped by the in-process cache to stay within CACHE_LOCAL_SIZE.", nil, labels),
+		invalidations: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "local_cache_invalidations_total"),
+			"Entries dropped by the in-process cache on the invalidations of this or another replica.", nil, labels),
+		entries: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "local_cache_entries"),
+			"Entries held by the in-process cache.", nil, labels),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (collector localCacheCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.lookups
+	descs <- collector.evictions
+	descs <- collector.invalidations
+	descs <- collector.entries
+}
+
+// Collect implements prometheus.Collector.
+func (collector localCacheCollector) Collect(metrics chan<- prometheus.Metric) {
+	stats := collector.stats()
+	metrics <- prometheus.MustNewConstMetric(collector.lookups, prometheus.CounterValue, float64(stats.Hits), "hit")
+	metrics <- prometheus.MustNewConstMetric(collector.lookups, prometheus.CounterValue, float64(stats.Misses), "miss")
+	metrics <- prometheus.MustNewConstMetric(collector.evictions, prometheus.CounterValue, float64(stats.Evictions))
+	metrics <- prometheus.MustNewConstMetric(collector.invalidations, prometheus.CounterValue, float64(stats.Invalidations))
+	metrics <- prometheus.MustNewConstMetric(collector.entries, prometheus.GaugeValue, float64(stats.Entries))
+}
@@ -0,0 +1,119 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	fiberconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/fiber"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http/middleware"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
+	"gorm.io/gorm"
+)
+
+// newApp creates an app with the error handler and error rendering of the server, recording the metrics of a route answering 200 and of a route failing with two errors.
+func newApp(t *testing.T) *fiber.App {
+	t.Helper()
+	t.Setenv("METRICS_ENABLED", "true")
+	app := fiber.New((&fiberconfig.FiberConfig{}).ToFiberAppConfig())
+	recorder, err := middleware.Metrics()
+	require.NoError(t, err)
+	renderer, err := middleware.RenderErrors()
+	require.NoError(t, err)
+	handler, err := middleware.MetricsHandler()
+	require.NoError(t, err)
+	app.Use(recorder, renderer)
+	app.Get("/metrics", handler)
+	app.Get("/users/:id", func(ctx *fiber.Ctx) error {
+		return ctx.SendString(ctx.Params("id"))
+	})
+	app.Post("/users", func(ctx *fiber.Ctx) error {
+		return &response.StandardErrors{Errors: []*response.Error{
+			errorshandler.NewError(errorshandler.BAD_REQUEST, "email is required"),
+			errorshandler.NewError(errorshandler.BAD_REQUEST, "password is required"),
+		}}
+	})
+	return app
+}
+
+// Records the requests under the route pattern and the error codes of the body
+func TestMetrics_Requests(t *testing.T) {
+	app := newApp(t)
+	before := testutil.ToFloat64(metrics.Requests.WithLabelValues("GET", "/users/:id", "200"))
+	beforeErrors := testutil.ToFloat64(metrics.RequestErrors.WithLabelValues("POST", "/users", "BAD_REQUEST"))
+
+	for _, id := range []string{"a", "b"} {
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/users/"+id, nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+	}
+	res, err := app.Test(httptest.NewRequest(http.MethodPost, "/users", nil))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	assert.Equal(t, before+2, testutil.ToFloat64(metrics.Requests.WithLabelValues("GET", "/users/:id", "200")))
+	assert.Equal(t, beforeErrors+2, testutil.ToFloat64(metrics.RequestErrors.WithLabelValues("POST", "/users", "BAD_REQUEST")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.RequestsInFlight))
+}
+
+// Serves the exposition format with the runtime, pool, breaker and in-process cache metrics, guarded by the token when set
+func TestMetricsHandler(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.NoError(t, metrics.RegisterDB(sqlDB, "test"))
+	require.NoError(t, metrics.RegisterDB(sqlDB, "test"))
+	breaker.New("metrics:test", 1, time.Minute).Failure()
+	stats := func() metrics.LocalCacheStats {
+		return metrics.LocalCacheStats{Hits: 3, Misses: 2, Evictions: 1, Entries: 4}
+	}
+	require.NoError(t, metrics.RegisterLocalCache("test", stats))
+	require.NoError(t, metrics.RegisterLocalCache("test", stats))
+	require.NoError(t, metrics.RegisterLocalCache("other", stats))
+
+	t.Run("Scrape", func(t *testing.T) {
+		res, err := newApp(t).Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "go_goroutines")
+		assert.Contains(t, string(body), `go_sql_max_open_connections{db_name="test"}`)
+		assert.Contains(t, string(body), `restful_api_circuit_breaker_open{name="metrics:test",state="open"} 1`)
+		assert.Contains(t, string(body), `restful_api_local_cache_lookups_total{cache="test",result="hit"} 3`)
+		assert.Contains(t, string(body), `restful_api_local_cache_lookups_total{cache="other",result="miss"} 2`)
+		assert.Contains(t, string(body), `restful_api_local_cache_evictions_total{cache="test"} 1`)
+		assert.Contains(t, string(body), `restful_api_local_cache_entries{cache="test"} 4`)
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		t.Setenv("METRICS_TOKEN", "scrape")
+		app := newApp(t)
+		res, err := app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer scrape")
+		res, err = app.Test(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+}
+
+// Reports a token which can not be sent in a header
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, (&metrics.Config{Token: "scrape"}).Validate())
+	require.Error(t, (&metrics.Config{Token: "two words"}).Validate())
+}
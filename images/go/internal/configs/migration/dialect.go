@@ -0,0 +1,102 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"time"
+
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+)
+
+// lockSalt is the salt of the advisory lock keys of golang-migrate.
+const lockSalt uint32 = 1486364155
+
+// dialect holds the statements differing between the drivers.
+type dialect struct {
+	createTable   string                                                                             // Creates the schema_migrations table when missing
+	insertVersion string                                                                             // Inserts the version and the dirty flag
+	lock          func(ctx context.Context, conn *sql.Conn) (func(ctx context.Context) error, error) // Acquires the migration lock and returns its release
+	lockless      bool                                                                               // The lock is a no-op, Locked runs without holding a connection
+}
+
+// dialectOf returns the dialect of the driver.
+func dialectOf(driver string) (dialect, error) {
+	switch driver {
+	case sqlconfig.Postgres:
+		return dialect{
+			createTable:   "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint not null primary key, dirty boolean not null)",
+			insertVersion: "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)",
+			lock:          postgresLock,
+		}, nil
+	case sqlconfig.MySQL:
+		return dialect{
+			createTable:   "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint not null primary key, dirty boolean not null)",
+			insertVersion: "INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)",
+			lock:          mysqlLock,
+		}, nil
+	case sqlconfig.SQLite:
+		return dialect{
+			createTable:   "CREATE TABLE IF NOT EXISTS schema_migrations (version uint64,dirty bool); CREATE UNIQUE INDEX IF NOT EXISTS version_unique ON schema_migrations (version);",
+			insertVersion: "INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)",
+			// a sqlite database belongs to a single process, there is no replica to race with
+			lock: func(ctx context.Context, conn *sql.Conn) (func(ctx context.Context) error, error) {
+				return func(ctx context.Context) error { return nil }, nil
+			},
+			// the pool holds a single connection, Locked must leave it to fn
+			lockless: true,
+		}, nil
+	default:
+		return dialect{}, fmt.Errorf("database driver %q is not supported", driver)
+	}
+}
+
+// lockKey derives the advisory lock key like golang-migrate, so the migrate CLI and the application exclude each other.
+func lockKey(names ...string) uint32 {
+	return crc32.ChecksumIEEE([]byte(strings.Join(names, "\x00"))) * lockSalt
+}
+
+// postgresLock waits for the session advisory lock of the schema_migrations table of the current database and schema.
+func postgresLock(ctx context.Context, conn *sql.Conn) (func(ctx context.Context) error, error) {
+	var database, schema string
+	if err := conn.QueryRowContext(ctx, "SELECT CURRENT_DATABASE(), CURRENT_SCHEMA()").Scan(&database, &schema); err != nil {
+		return nil, err
+	}
+	key := int64(lockKey(schema, "schema_migrations", database))
+	// canceling ctx stops waiting for the lock
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+		return err
+	}, nil
+}
+
+// mysqlLock waits for the named lock of the schema_migrations table of the current database until the deadline of ctx.
+func mysqlLock(ctx context.Context, conn *sql.Conn) (func(ctx context.Context) error, error) {
+	var database string
+	if err := conn.QueryRowContext(ctx, "SELECT DATABASE()").Scan(&database); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprint(lockKey(database + ":schema_migrations"))
+	// a negative timeout waits forever
+	timeout := -1
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = max(int(time.Until(deadline).Seconds()), 0)
+	}
+	var acquired sql.NullBool
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", key, timeout).Scan(&acquired); err != nil {
+		return nil, err
+	}
+	if !acquired.Bool {
+		return nil, errors.New("timed out waiting for the migration lock")
+	}
+	return func(ctx context.Context) error {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", key)
+		return err
+	}, nil
+}
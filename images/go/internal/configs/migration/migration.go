@@ -0,0 +1,324 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/db/migrations"
+)
+
+var (
+	// ErrDirty is returned when a previous migration failed halfway, the schema must be fixed by hand then forced to a version.
+	ErrDirty = errors.New("database is dirty, fix the schema and force the version")
+	// ErrUnknownVersion is returned when a version has no migration.
+	ErrUnknownVersion = errors.New("no migration with this version")
+)
+
+// fileRegexp matches the golang-migrate file names, NNNNNN_name.up.sql and NNNNNN_name.down.sql.
+var fileRegexp = regexp.MustCompile(`^([0-9]+)_(.*)\.(up|down)\.sql$`)
+
+// Migration is a schema change with the statements applying and reverting it.
+type Migration struct {
+	Version int64  // Version of the migration, the number prefixing its files
+	Name    string // Name of the migration
+	Up      string // Statements applying the migration
+	Down    string // Statements reverting the migration
+}
+
+// Status tells whether a migration is applied.
+type Status struct {
+	Migration
+	Applied bool // The migration is at or below the current version
+	Dirty   bool // The migration is the current version and failed halfway
+}
+
+// Migrator applies the embedded migrations of a driver, tracking the current version in the schema_migrations table of golang-migrate.
+type Migrator struct {
+	db          *sql.DB
+	dialect     dialect
+	migrations  []Migration
+	logger      *log.Logger
+	lockTimeout time.Duration
+}
+
+// New creates a Migrator applying the embedded migrations of the driver to db.
+func New(db *sql.DB, driver string, logger *log.Logger) (*Migrator, error) {
+	if db == nil || logger == nil {
+		return nil, errors.New("DB or Logger is nil")
+	}
+	dialect, err := dialectOf(driver)
+	if err != nil {
+		return nil, err
+	}
+	source, err := fs.Sub(migrations.FS, driver)
+	if err != nil {
+		return nil, err
+	}
+	list, err := Load(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: list, logger: logger}, nil
+}
+
+// Load reads the migrations of source sorted by version.
+func Load(source fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		matches := fileRegexp.FindStringSubmatch(file.Name())
+		if file.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", file.Name(), err)
+		}
+		body, err := fs.ReadFile(source, file.Name())
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		}
+		if matches[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		list = append(list, *migration)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// WithLockTimeout limits the wait for the migration lock held by another replica, no limit when not positive.
+func (m *Migrator) WithLockTimeout(timeout time.Duration) *Migrator {
+	m.lockTimeout = timeout
+	return m
+}
+
+// Migrations returns the migrations sorted by version.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("version %d: %w", version, ErrDirty)
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			m.logger.Info().Msgf("Applying migration %d_%s", migration.Version, migration.Name)
+			if err := m.apply(ctx, conn, migration.Version, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, every applied migration when steps is not positive,
+// and returns how many were reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, dirty, err := m.version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("version %d: %w", version, ErrDirty)
+		}
+		for i := len(m.migrations) - 1; i >= 0 && (steps <= 0 || reverted < steps); i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+			// The version left once reverted is the previous migration, none for the first one.
+			previous := int64(0)
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			m.logger.Info().Msgf("Reverting migration %d_%s", migration.Version, migration.Name)
+			if err := m.apply(ctx, conn, previous, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Version returns the current version, 0 when no migration is applied, and whether the last migration failed halfway.
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return 0, false, err
+	}
+	return m.version(ctx, conn)
+}
+
+// Status returns every migration with whether it is applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		statuses = append(statuses, Status{
+			Migration: migration,
+			Applied:   migration.Version <= version,
+			Dirty:     dirty && migration.Version == version,
+		})
+	}
+	return statuses, nil
+}
+
+// Force sets the current version without running any migration and clears the dirty flag, 0 removes the version.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	if version != 0 && !m.exists(version) {
+		return fmt.Errorf("version %d: %w", version, ErrUnknownVersion)
+	}
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		m.logger.Warn().Msgf("Forcing migration version %d", version)
+		return m.setVersion(ctx, conn, version, false)
+	})
+}
+
+// exists tells whether a migration has the version.
+func (m *Migrator) exists(version int64) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Locked runs fn while holding the migration lock, for the startup work that must run once across the replicas like the seeding.
+// The lock holds a connection of the pool, fn needs another one. SQLite has no lock to hold, fn runs at once.
+func (m *Migrator) Locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	if m.dialect.lockless {
+		return fn(ctx)
+	}
+	return m.locked(ctx, func(*sql.Conn) error {
+		return fn(ctx)
+	})
+}
+
+// locked runs fn on a connection holding the migration lock, so that replicas starting together apply the migrations once.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	// Advisory locks belong to a session, the lock and the migrations go through the same connection.
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockCtx := ctx
+	if m.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, m.lockTimeout)
+		defer cancel()
+	}
+	unlock, err := m.dialect.lock(lockCtx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to acquire the migration lock: %w", err)
+	}
+	defer func() {
+		// The context may be over, the lock is released regardless.
+		if err := unlock(context.WithoutCancel(ctx)); err != nil {
+			m.logger.Error().Msgf("Failed to release the migration lock: %v", err)
+		}
+	}()
+
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// apply runs the statements of a migration, the version is marked dirty until they succeed.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, version int64, statements string) error {
+	if err := m.setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+	// The files hold several statements, they run as they are, without placeholders.
+	if _, err := conn.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	return m.setVersion(ctx, conn, version, false)
+}
+
+// ensureTable creates the schema_migrations table when missing.
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, m.dialect.createTable)
+	return err
+}
+
+// version reads the current version, 0 when no migration is applied.
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// setVersion replaces the current version, 0 leaves the table empty as golang-migrate does when no migration is applied.
+func (m *Migrator) setVersion(ctx context.Context, conn *sql.Conn, version int64, dirty bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if version > 0 {
+		if _, err := tx.ExecContext(ctx, m.dialect.insertVersion, version, dirty); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,209 @@
+package migration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/glebarez/sqlite"
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"gorm.io/gorm"
+)
+
+func openSQLite(t *testing.T) (*gorm.DB, *migration.Migrator) {
+	t.Helper()
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 10, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migration.New(sqlDB, sqlconfig.SQLite, &log.DefaultLogger)
+	require.NoError(t, err)
+	return db, migrator
+}
+
+func TestNew(t *testing.T) {
+	db, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = migration.New(nil, sqlconfig.Postgres, &log.DefaultLogger)
+	require.Error(t, err)
+	_, err = migration.New(db, "oracle", &log.DefaultLogger)
+	require.ErrorContains(t, err, `"oracle" is not supported`)
+
+	// every driver embeds the same migrations
+	var versions [][]int64
+	for _, driver := range []string{sqlconfig.Postgres, sqlconfig.MySQL, sqlconfig.SQLite} {
+		migrator, err := migration.New(db, driver, &log.DefaultLogger)
+		require.NoError(t, err)
+		var driverVersions []int64
+		for _, migration := range migrator.Migrations() {
+			require.NotEmpty(t, migration.Up, driver)
+			require.NotEmpty(t, migration.Down, driver)
+			driverVersions = append(driverVersions, migration.Version)
+		}
+		versions = append(versions, driverVersions)
+	}
+	require.Equal(t, []int64{1, 2, 3, 4, 5, 6}, versions[0])
+	require.Equal(t, versions[0], versions[1])
+	require.Equal(t, versions[0], versions[2])
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+
+	version, dirty, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+	require.False(t, dirty)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 6, applied)
+	require.True(t, db.Migrator().HasTable("users"))
+	require.True(t, db.Migrator().HasColumn("users", "version"))
+	require.False(t, db.Migrator().HasColumn("audit_events", "target_email"))
+
+	// nothing is pending anymore
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Zero(t, applied)
+
+	reverted, err := migrator.Down(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, reverted)
+	require.False(t, db.Migrator().HasTable("audit_events"))
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 6)
+	require.True(t, statuses[2].Applied)
+	require.False(t, statuses[3].Applied)
+
+	reverted, err = migrator.Down(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, reverted)
+	require.False(t, db.Migrator().HasTable("users"))
+	version, _, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+}
+
+func TestMigrator_Dirty(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+	// casbin_rule already exists with another definition, the policies of migration 1 fail and leave it dirty
+	require.NoError(t, db.Exec("CREATE TABLE casbin_rule (id INTEGER)").Error)
+
+	applied, err := migrator.Up(ctx)
+	require.Error(t, err)
+	require.Zero(t, applied)
+	version, dirty, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), version)
+	require.True(t, dirty)
+
+	// a dirty database is never migrated until forced
+	_, err = migrator.Up(ctx)
+	require.ErrorIs(t, err, migration.ErrDirty)
+	_, err = migrator.Down(ctx, 1)
+	require.ErrorIs(t, err, migration.ErrDirty)
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[0].Dirty)
+
+	require.ErrorIs(t, migrator.Force(ctx, 42), migration.ErrUnknownVersion)
+	require.NoError(t, db.Exec("DROP TABLE casbin_rule").Error)
+	require.NoError(t, migrator.Force(ctx, 0))
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 6, applied)
+}
+
+func TestMigrator_DropAuditEventsEmails(t *testing.T) {
+	ctx := context.Background()
+	db, migrator := openSQLite(t)
+	_, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	_, err = migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`INSERT INTO audit_events (id, action, outcome, actor_email, target_email, changes, created_at) VALUES ('1', 'users.update', 'success', 'admin@example.com', 'john@example.com', '{"email":{"from":"old@example.com","to":"john@example.com"}}', 1)`).Error)
+
+	// the emails are dropped from the columns and from the stored diffs
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, applied)
+	require.False(t, db.Migrator().HasColumn("audit_events", "actor_email"))
+	require.False(t, db.Migrator().HasColumn("audit_events", "target_email"))
+	var changes string
+	require.NoError(t, db.Raw("SELECT changes FROM audit_events WHERE id = '1'").Scan(&changes).Error)
+	require.JSONEq(t, `{"email":{"changed":true}}`, changes)
+
+	// the trail is append-only again
+	require.ErrorContains(t, db.Exec("UPDATE audit_events SET action = 'users.delete'").Error, "append-only")
+}
+
+func TestMigrator_PostgresLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrator, err := migration.New(db, sqlconfig.Postgres, &log.DefaultLogger)
+	require.NoError(t, err)
+
+	// the key is the one golang-migrate uses for the app database, so the migrate CLI waits as well
+	mock.ExpectQuery(`SELECT CURRENT_DATABASE\(\), CURRENT_SCHEMA\(\)`).WillReturnRows(sqlmock.NewRows([]string{"current_database", "current_schema"}).AddRow("app", "public"))
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(1787120056)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, dirty FROM schema_migrations`).WillReturnRows(sqlmock.NewRows([]string{"version", "dirty"}).AddRow(6, false))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(1787120056)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+	require.NoError(t, err)
+	require.Zero(t, applied)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Locked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrator, err := migration.New(db, sqlconfig.Postgres, &log.DefaultLogger)
+	require.NoError(t, err)
+
+	// fn runs between the lock and its release
+	mock.ExpectQuery(`SELECT CURRENT_DATABASE\(\), CURRENT_SCHEMA\(\)`).WillReturnRows(sqlmock.NewRows([]string{"current_database", "current_schema"}).AddRow("app", "public"))
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(int64(1787120056)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO casbin_rule`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(int64(1787120056)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = migrator.Locked(context.Background(), func(ctx context.Context) error {
+		_, err := db.ExecContext(ctx, "INSERT INTO casbin_rule (ptype) VALUES ('g')")
+		return err
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_MySQLLockTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+	migrator, err := migration.New(db, sqlconfig.MySQL, &log.DefaultLogger)
+	require.NoError(t, err)
+
+	// another replica holds the lock past the timeout
+	mock.ExpectQuery(`SELECT DATABASE\(\)`).WillReturnRows(sqlmock.NewRows([]string{"database"}).AddRow("app"))
+	mock.ExpectQuery(`SELECT GET_LOCK\(\?, \?\)`).WithArgs("2938252834", 2).WillReturnRows(sqlmock.NewRows([]string{"lock"}).AddRow(0))
+
+	_, err = migrator.WithLockTimeout(3 * time.Second).Up(context.Background())
+	require.ErrorContains(t, err, "timed out waiting for the migration lock")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
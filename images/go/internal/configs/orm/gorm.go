@@ -2,11 +2,15 @@ package orm
 
 import (
 	"fmt"
+	"sync"
+
+	"github.com/glebarez/sqlite"
 	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"sync"
-	"time"
+	"gorm.io/plugin/dbresolver"
 )
 
 var (
@@ -23,41 +27,94 @@ func NewGorm() (*gorm.DB, error) {
 	// use once.Do to execute the initialization code only once
 	once.Do(func() {
 		// create a new configuration for SQL
-		config, err := sqlconfig.NewConfig()
+		var config *sqlconfig.SqlConfig
+		config, err = sqlconfig.NewConfig()
 		if err != nil {
 			// if there's an error creating the config, return immediately
 			return
 		}
-
-		// format the data source name with the configuration settings
-		dataSourceName := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Jakarta", config.Host, config.User, config.Password, config.Name, config.Port)
-
-		// open a new connection to the database using Gorm
-		db, err = gorm.Open(postgres.Open(dataSourceName), &gorm.Config{
-			Logger:                 nil,  // enable SQL logging
-			SkipDefaultTransaction: true, // skip default transaction handling
-			PrepareStmt:            true, // prepare statements for better performance
-		})
-
+		var dialector gorm.Dialector
+		dialector, err = Dialector(config.Driver, config.DSN())
 		if err != nil {
-			// if there's an error opening the database, return immediately
 			return
 		}
-
-		// retrieve the underlying SQL database instance from Gorm
-		sqlDB, err := db.DB()
-		if err != nil {
-			// if there's an error retrieving the SQL database, return immediately
-			return
-		}
-
-		// configure database connection pool settings
-		sqlDB.SetConnMaxLifetime(30 * time.Minute) // set maximum connection lifetime
-		sqlDB.SetConnMaxIdleTime(5 * time.Minute)  // set maximum idle connection time
-		sqlDB.SetMaxIdleConns(10)                  // set maximum number of idle connections
-		sqlDB.SetMaxOpenConns(100)                 // set maximum number of open connections
+		db, err = Open(dialector, config)
 	})
 
 	// return the database instance and any error encountered
 	return db, err
 }
+
+// Dialector returns the Gorm dialector of the driver opening the data source name.
+func Dialector(driver string, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case sqlconfig.Postgres:
+		return postgres.Open(dsn), nil
+	case sqlconfig.MySQL:
+		return mysql.Open(dsn), nil
+	case sqlconfig.SQLite:
+		// pure Go driver, no cgo needed
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("database driver %q is not supported", driver)
+	}
+}
+
+// Open opens a Gorm connection to the primary database with the pool settings of the configuration.
+// When read replicas are configured, queries outside of a transaction go to them while writes and transactions use the primary.
+func Open(dialector gorm.Dialector, config *sqlconfig.SqlConfig, replicas ...gorm.Dialector) (*gorm.DB, error) {
+	// open a new connection to the database using Gorm
+	gormDB, err := gorm.Open(dialector, &gorm.Config{
+		Logger:                 nil,  // enable SQL logging
+		SkipDefaultTransaction: true, // skip default transaction handling
+		PrepareStmt:            true, // prepare statements for better performance
+	})
+	if err != nil {
+		// if there's an error opening the database, return immediately
+		return nil, err
+	}
+	// trace every statement, replicas included
+	if err := gormDB.Use(tracing.GormPlugin{}); err != nil {
+		return nil, err
+	}
+
+	// the replicas of the configuration are used unless given explicitly
+	if len(replicas) == 0 {
+		for _, dsn := range config.Replicas {
+			replica, err := Dialector(config.Driver, dsn)
+			if err != nil {
+				return nil, err
+			}
+			replicas = append(replicas, replica)
+		}
+	}
+	if len(replicas) > 0 {
+		// route the reads to the replicas, the resolver applies the pool settings to every replica
+		resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: dbresolver.RandomPolicy{}}).
+			SetConnMaxLifetime(config.ConnMaxLifetime()).
+			SetConnMaxIdleTime(config.ConnMaxIdleTime()).
+			SetMaxIdleConns(config.MinCon).
+			SetMaxOpenConns(config.MaxCon)
+		if err := gormDB.Use(resolver); err != nil {
+			return nil, err
+		}
+	}
+
+	// retrieve the underlying SQL database instance from Gorm
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		// if there's an error retrieving the SQL database, return immediately
+		return nil, err
+	}
+
+	// configure database connection pool settings
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime()) // set maximum connection lifetime
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime()) // set maximum idle connection time
+	sqlDB.SetMaxIdleConns(config.MinCon)               // set maximum number of idle connections
+	sqlDB.SetMaxOpenConns(config.MaxCon)               // set maximum number of open connections
+	if dialector.Name() == sqlconfig.SQLite {
+		// sqlite has a single writer and an in-memory database lives in a single connection
+		sqlDB.SetMaxOpenConns(1)
+	}
+	return gormDB, nil
+}
@@ -0,0 +1,152 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
+
+// ErrNotReloadable is returned when the files change a setting read only on startup.
+var ErrNotReloadable = errors.New("settings can not change without a restart")
+
+// Config holds the settings of the configuration reload.
+type Config struct {
+	Interval int `env:"CONFIG_RELOAD_INTERVAL" envDefault:"5"` // Seconds between two checks of the files, 0 reloads on SIGHUP only
+}
+
+// NewConfig initializes a new reload Config by loading the configuration.
+func NewConfig() (*Config, error) {
+	var config Config
+	// Load configuration values into Config struct.
+	if err := configs.GetConfig().Load(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate reports a negative interval.
+func (config *Config) Validate() error {
+	if config.Interval < 0 {
+		return errors.New("CONFIG_RELOAD_INTERVAL must not be negative")
+	}
+	return nil
+}
+
+// Period returns the time between two checks of the files.
+func (config Config) Period() time.Duration {
+	return time.Duration(config.Interval) * time.Second
+}
+
+// Reloader applies the settings changed in the .env and YAML files while serving.
+// Only the reloadable settings may change, a reload changing any other one is rejected as a whole.
+type Reloader struct {
+	config     *configs.Config
+	reloadable map[string]bool
+	apply      func() error
+	logger     *log.Logger
+	mutex      sync.Mutex
+	modified   time.Time // Latest modification time of the files seen
+}
+
+// NewReloader creates a Reloader accepting changes of the reloadable settings.
+// apply loads the reloadable configurations once the changes are set and swaps them in, on error the changes are reverted.
+func NewReloader(apply func() error, logger *log.Logger, reloadable ...string) (*Reloader, error) {
+	if apply == nil || logger == nil || len(reloadable) == 0 {
+		return nil, errors.New("apply, Logger or the reloadable settings are nil")
+	}
+	config := configs.GetConfig()
+	reloader := &Reloader{config: config, reloadable: map[string]bool{}, apply: apply, logger: logger}
+	for _, key := range reloadable {
+		reloader.reloadable[key] = true
+	}
+	reloader.modified = reloader.lastModified()
+	return reloader, nil
+}
+
+// Reload applies the settings changed in the files, logging each change, and returns them.
+func (reloader *Reloader) Reload() ([]configs.Change, error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	changes, err := reloader.config.Changes()
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		reloader.logger.Debug().Msg("The configuration files hold no change")
+		return nil, nil
+	}
+
+	var rejected []string
+	for _, change := range changes {
+		if !reloader.reloadable[change.Key] {
+			rejected = append(rejected, change.Key)
+		}
+	}
+	if len(rejected) > 0 {
+		// The values are left out of the error, they may be secrets
+		return nil, fmt.Errorf("%w: %s", ErrNotReloadable, strings.Join(rejected, ", "))
+	}
+
+	if err := reloader.config.Apply(changes); err != nil {
+		return nil, errors.Join(err, reloader.config.Revert(changes))
+	}
+	if err := reloader.apply(); err != nil {
+		return nil, errors.Join(err, reloader.config.Revert(changes))
+	}
+	for _, change := range changes {
+		reloader.logger.Info().Str("key", change.Key).Str("from", change.From).Str("to", change.To).Msg("Reloaded setting")
+	}
+	return changes, nil
+}
+
+// Watch reloads the configuration on SIGHUP, and when the files are modified or removed every interval, until the context is done.
+func (reloader *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			reloader.logger.Info().Msg("Reloading the configuration on SIGHUP")
+		case <-tick:
+			modified := reloader.lastModified()
+			if modified.Equal(reloader.modified) {
+				continue
+			}
+			reloader.modified = modified
+			reloader.logger.Info().Msg("Reloading the modified configuration files")
+		}
+		if _, err := reloader.Reload(); err != nil {
+			reloader.logger.Error().Err(err).Msg("Rejected the configuration reload")
+		}
+	}
+}
+
+// lastModified returns the latest modification time of the files, the zero time when neither exists.
+func (reloader *Reloader) lastModified() time.Time {
+	var modified time.Time
+	for _, filename := range []string{reloader.config.Filename, reloader.config.YAMLFilename} {
+		if info, err := os.Stat(filename); err == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified
+}
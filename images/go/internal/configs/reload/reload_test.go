@@ -0,0 +1,101 @@
+package reload_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/reload"
+)
+
+// writeConfig replaces the YAML file read by the configuration.
+func writeConfig(t *testing.T, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(configs.GetConfig().YAMLFilename, []byte(content), 0o600))
+}
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "reload")
+	if err != nil {
+		panic(err)
+	}
+	configs.ConfigFile = filepath.Join(dir, ".env")
+	configs.YAMLFile = filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configs.YAMLFile, []byte("limiter:\n  max: 20\nfiber:\n  port: 8081\n"), 0o600); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestReloader(t *testing.T) {
+	var applied []string
+	var applyErr error
+	reloader, err := reload.NewReloader(func() error {
+		applied = append(applied, os.Getenv("LIMITER_MAX"))
+		return applyErr
+	}, &log.DefaultLogger, "LIMITER_MAX")
+	require.NoError(t, err)
+
+	changes, err := reloader.Reload()
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	writeConfig(t, "limiter:\n  max: 50\nfiber:\n  port: 8081\n")
+	changes, err = reloader.Reload()
+	require.NoError(t, err)
+	require.Equal(t, []configs.Change{{Key: "LIMITER_MAX", From: "20", To: "50", Source: configs.SourceYAML}}, stripped(changes))
+	require.Equal(t, []string{"50"}, applied)
+
+	// a setting read on startup rejects the whole reload
+	writeConfig(t, "limiter:\n  max: 60\nfiber:\n  port: 9000\n")
+	_, err = reloader.Reload()
+	require.ErrorIs(t, err, reload.ErrNotReloadable)
+	require.ErrorContains(t, err, "FIBER_PORT")
+	require.Equal(t, "50", os.Getenv("LIMITER_MAX"))
+
+	// settings failing to apply are reverted
+	applyErr = errors.New("LIMITER_MAX must be positive")
+	writeConfig(t, "limiter:\n  max: -1\nfiber:\n  port: 8081\n")
+	_, err = reloader.Reload()
+	require.ErrorIs(t, err, applyErr)
+	require.Equal(t, "50", os.Getenv("LIMITER_MAX"))
+}
+
+func TestReloader_Watch(t *testing.T) {
+	reloaded := make(chan string, 1)
+	reloader, err := reload.NewReloader(func() error {
+		reloaded <- os.Getenv("LIMITER_MAX")
+		return nil
+	}, &log.DefaultLogger, "LIMITER_MAX")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reloader.Watch(ctx, 10*time.Millisecond)
+
+	writeConfig(t, "limiter:\n  max: 70\nfiber:\n  port: 8081\n")
+	// the modification time may be unchanged on coarse file systems
+	require.NoError(t, os.Chtimes(configs.GetConfig().YAMLFilename, time.Now(), time.Now().Add(time.Minute)))
+	select {
+	case value := <-reloaded:
+		require.Equal(t, "70", value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the modified file was not reloaded")
+	}
+}
+
+// stripped drops the unexported fields of the changes to compare them.
+func stripped(changes []configs.Change) []configs.Change {
+	result := make([]configs.Change, len(changes))
+	for i, change := range changes {
+		result[i] = configs.Change{Key: change.Key, From: change.From, To: change.To, Source: change.Source}
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package retention
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
+
+// Config holds the retention policy for soft-deleted users.
+type Config struct {
+	Days      int `env:"USER_RETENTION_DAYS" envDefault:"30"`        // Days a soft-deleted user is kept before it is purged, 0 disables the job.
+	Interval  int `env:"USER_RETENTION_INTERVAL" envDefault:"60"`    // Minutes between two retention runs.
+	BatchSize int `env:"USER_RETENTION_BATCH_SIZE" envDefault:"100"` // Number of users fetched per purge batch.
+}
+
+// NewConfig initializes a new retention Config by loading the configuration.
+func NewConfig() (*Config, error) {
+	var config Config
+	// Load configuration values into Config struct.
+	if err := configs.GetConfig().Load(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate reports the negative settings, 0 disables the job instead.
+func (config *Config) Validate() error {
+	if config.Days < 0 || config.Interval < 0 || config.BatchSize < 0 {
+		return errors.New("USER_RETENTION_DAYS, USER_RETENTION_INTERVAL and USER_RETENTION_BATCH_SIZE must not be negative")
+	}
+	return nil
+}
+
+// Enabled reports whether soft-deleted users should be purged at all.
+func (config Config) Enabled() bool {
+	return config.Days > 0 && config.Interval > 0 && config.BatchSize > 0
+}
+
+// Period returns the time between two retention runs.
+func (config Config) Period() time.Duration {
+	return time.Duration(config.Interval) * time.Minute
+}
+
+// Cutoff returns the moment before which soft-deleted users are expired.
+func (config Config) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -config.Days)
+}
@@ -0,0 +1,55 @@
+package retention_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/retention"
+)
+
+func TestNewConfig_Default(t *testing.T) {
+	config, err := retention.NewConfig()
+
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	require.Equal(t, 30, config.Days)
+	require.Equal(t, 60, config.Interval)
+	require.Equal(t, 100, config.BatchSize)
+	require.True(t, config.Enabled())
+}
+
+func TestNewConfig_FromEnv(t *testing.T) {
+	t.Setenv("USER_RETENTION_DAYS", "7")
+	t.Setenv("USER_RETENTION_INTERVAL", "15")
+	t.Setenv("USER_RETENTION_BATCH_SIZE", "10")
+
+	config, err := retention.NewConfig()
+
+	require.NoError(t, err)
+	require.Equal(t, 7, config.Days)
+	require.Equal(t, 15*time.Minute, config.Period())
+	require.Equal(t, 10, config.BatchSize)
+}
+
+func TestNewConfig_InvalidValue(t *testing.T) {
+	t.Setenv("USER_RETENTION_DAYS", "invalid")
+
+	config, err := retention.NewConfig()
+
+	require.Error(t, err)
+	require.Nil(t, config)
+}
+
+func TestConfig_Disabled(t *testing.T) {
+	config := retention.Config{Days: 0, Interval: 60, BatchSize: 100}
+
+	require.False(t, config.Enabled())
+}
+
+func TestConfig_Cutoff(t *testing.T) {
+	config := retention.Config{Days: 30}
+	now := time.Date(2024, time.March, 31, 12, 0, 0, 0, time.UTC)
+
+	require.Equal(t, time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), config.Cutoff(now))
+}
@@ -0,0 +1,161 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
+
+// Names of the providers selected by SECRET_PROVIDERS.
+const (
+	ProviderEnv   = "env"   // The process environment and the .env file
+	ProviderFile  = "file"  // The file named by the <KEY>_FILE setting
+	ProviderVault = "vault" // A Vault KV version 2 secret
+)
+
+// Keys are the settings resolved through the secret providers.
+var Keys = []string{
+	"SECRET_KEY_ACCESS_TOKEN",
+	"SECRET_KEY_REFRESH_TOKEN",
+	"SECRET_KEY_FP_TOKEN",
+	"SECRET_KEY_CSRF",
+	"DB_PASS",
+	"CACHE_DB_PASS",
+}
+
+// SecretProvider resolves secrets from a store.
+type SecretProvider interface {
+	// Secrets returns the values of the keys the store holds, the missing keys are left out.
+	Secrets(ctx context.Context, keys []string) (map[string]string, error)
+}
+
+// Config holds the settings of the secret providers.
+type Config struct {
+	Providers []string `env:"SECRET_PROVIDERS" envSeparator:"," envDefault:"file,env" redact:"false"` // Providers by precedence
+	Interval  int      `env:"SECRET_REFRESH_INTERVAL" envDefault:"60" redact:"false"`                 // Seconds between two refreshes, 0 resolves the secrets on startup only
+	Vault     VaultConfig
+}
+
+// NewConfig initializes a new secret Config by loading the configuration.
+func NewConfig() (*Config, error) {
+	var config Config
+	// Load configuration values into Config struct.
+	if err := configs.GetConfig().Load(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate reports the unknown providers, a negative interval and the Vault settings missing when Vault is selected.
+func (config *Config) Validate() error {
+	var errs []error
+	for _, provider := range config.Providers {
+		if !slices.Contains([]string{ProviderEnv, ProviderFile, ProviderVault}, provider) {
+			errs = append(errs, fmt.Errorf("SECRET_PROVIDERS holds the unknown provider %q", provider))
+		}
+	}
+	if config.Interval < 0 {
+		errs = append(errs, errors.New("SECRET_REFRESH_INTERVAL must not be negative"))
+	}
+	if slices.Contains(config.Providers, ProviderVault) {
+		errs = append(errs, config.Vault.Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// Period returns the time between two refreshes of the secrets.
+func (config Config) Period() time.Duration {
+	return time.Duration(config.Interval) * time.Second
+}
+
+// NewProvider chains the selected providers, the first one holding a key resolves it.
+func (config *Config) NewProvider() (SecretProvider, error) {
+	var providers Chain
+	for _, name := range config.Providers {
+		switch name {
+		case ProviderEnv:
+			providers = append(providers, EnvProvider{})
+		case ProviderFile:
+			providers = append(providers, FileProvider{})
+		case ProviderVault:
+			vault, err := NewVaultProvider(&config.Vault, &http.Client{Timeout: config.Vault.Period()})
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, vault)
+		default:
+			return nil, fmt.Errorf("unknown secret provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
+// EnvProvider reads the secrets from the process environment, filled by the .env file.
+type EnvProvider struct{}
+
+// Secrets returns the keys set to a non empty value.
+func (EnvProvider) Secrets(_ context.Context, keys []string) (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			secrets[key] = value
+		}
+	}
+	return secrets, nil
+}
+
+// FileProvider reads each secret from the file named by its <KEY>_FILE setting, as mounted by Docker and Kubernetes secrets.
+type FileProvider struct{}
+
+// Secrets returns the content of the files, without the trailing line break.
+func (FileProvider) Secrets(_ context.Context, keys []string) (map[string]string, error) {
+	secrets := map[string]string{}
+	var errs []error
+	for _, key := range keys {
+		filename := os.Getenv(key + "_FILE")
+		if filename == "" {
+			continue
+		}
+		body, err := os.ReadFile(filename)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s_FILE: %w", key, err))
+			continue
+		}
+		secrets[key] = strings.TrimRight(string(body), "\r\n")
+	}
+	return secrets, errors.Join(errs...)
+}
+
+// Chain resolves each key from the first provider holding it.
+type Chain []SecretProvider
+
+// Secrets asks the providers in turn for the keys still missing.
+func (chain Chain) Secrets(ctx context.Context, keys []string) (map[string]string, error) {
+	secrets := map[string]string{}
+	for _, provider := range chain {
+		var missing []string
+		for _, key := range keys {
+			if _, ok := secrets[key]; !ok {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		found, err := provider.Secrets(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range found {
+			secrets[key] = value
+		}
+	}
+	return secrets, nil
+}
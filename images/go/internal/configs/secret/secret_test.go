@@ -0,0 +1,129 @@
+package secret_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/secret"
+)
+
+// fakeVault serves the secret/data/app KV version 2 secret holding the fields of data, to the token root only.
+func fakeVault(t *testing.T, data *atomic.Value) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/app" {
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"data":` + data.Load().(string) + `,"metadata":{"version":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Reads the fields of the secret
+func TestVaultProvider(t *testing.T) {
+	var data atomic.Value
+	data.Store(`{"DB_PASS":"vault-pass","OTHER":"ignored"}`)
+	server := fakeVault(t, &data)
+
+	vault, err := secret.NewVaultProvider(&secret.VaultConfig{Address: server.URL, Token: "root", Mount: "secret", Path: "app"}, server.Client())
+	require.NoError(t, err)
+	secrets, err := vault.Secrets(context.Background(), []string{"DB_PASS", "SECRET_KEY_CSRF"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_PASS": "vault-pass"}, secrets)
+
+	t.Run("Denied", func(t *testing.T) {
+		vault, err := secret.NewVaultProvider(&secret.VaultConfig{Address: server.URL, Token: "wrong", Mount: "secret", Path: "app"}, server.Client())
+		require.NoError(t, err)
+		_, err = vault.Secrets(context.Background(), []string{"DB_PASS"})
+		require.ErrorContains(t, err, "403")
+	})
+}
+
+// Reads the file named by the _FILE setting, without the trailing line break
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db_pass")
+	require.NoError(t, os.WriteFile(path, []byte("file-pass\n"), 0o600))
+	t.Setenv("DB_PASS_FILE", path)
+	t.Setenv("CACHE_DB_PASS_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	secrets, err := secret.FileProvider{}.Secrets(context.Background(), []string{"DB_PASS"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DB_PASS": "file-pass"}, secrets)
+
+	_, err = secret.FileProvider{}.Secrets(context.Background(), []string{"CACHE_DB_PASS"})
+	require.ErrorContains(t, err, "CACHE_DB_PASS_FILE")
+}
+
+// The first provider holding a key resolves it
+func TestChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "csrf")
+	require.NoError(t, os.WriteFile(path, []byte("file-csrf"), 0o600))
+	t.Setenv("SECRET_KEY_CSRF_FILE", path)
+	t.Setenv("SECRET_KEY_CSRF", "env-csrf")
+	t.Setenv("DB_PASS", "env-pass")
+
+	secrets, err := secret.Chain{secret.FileProvider{}, secret.EnvProvider{}}.Secrets(context.Background(), []string{"SECRET_KEY_CSRF", "DB_PASS", "CACHE_DB_PASS"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SECRET_KEY_CSRF": "file-csrf", "DB_PASS": "env-pass"}, secrets)
+}
+
+// Sets the rotated secrets and reports their keys
+func TestStore_Refresh(t *testing.T) {
+	var data atomic.Value
+	data.Store(`{"SECRET_KEY_CSRF":"first"}`)
+	server := fakeVault(t, &data)
+	t.Setenv("SECRET_KEY_CSRF", "")
+
+	vault, err := secret.NewVaultProvider(&secret.VaultConfig{Address: server.URL, Token: "root", Mount: "secret", Path: "app"}, server.Client())
+	require.NoError(t, err)
+	store, err := secret.NewStore(vault, "SECRET_KEY_CSRF")
+	require.NoError(t, err)
+	var notified []string
+	store.OnRotate(func(keys []string) error {
+		notified = append(notified, keys...)
+		return nil
+	})
+
+	rotated, err := store.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SECRET_KEY_CSRF"}, rotated)
+	assert.Equal(t, "first", os.Getenv("SECRET_KEY_CSRF"))
+	assert.Equal(t, configs.SourceSecret, configs.GetConfig().Source("SECRET_KEY_CSRF"))
+
+	rotated, err = store.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, rotated)
+
+	data.Store(`{"SECRET_KEY_CSRF":"second"}`)
+	rotated, err = store.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SECRET_KEY_CSRF"}, rotated)
+	assert.Equal(t, "second", os.Getenv("SECRET_KEY_CSRF"))
+	assert.Equal(t, []string{"SECRET_KEY_CSRF", "SECRET_KEY_CSRF"}, notified)
+}
+
+// Reports the unknown providers and the Vault settings missing
+func TestConfig_Validate(t *testing.T) {
+	config := &secret.Config{Providers: []string{"file", "env"}, Interval: 60}
+	require.NoError(t, config.Validate())
+
+	config.Providers = []string{"vault", "aws"}
+	err := config.Validate()
+	require.ErrorContains(t, err, "aws")
+	require.ErrorContains(t, err, "VAULT_ADDR")
+	require.ErrorContains(t, err, "VAULT_TOKEN")
+}
@@ -0,0 +1,90 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
+
+// Store sets the resolved secrets in the process environment, where the configurations read them, and refreshes them.
+type Store struct {
+	provider SecretProvider
+	keys     []string
+	mutex    sync.Mutex
+	rotate   []func(keys []string) error // Called with the keys changed by a refresh
+}
+
+// NewStore creates a Store resolving the keys through provider.
+func NewStore(provider SecretProvider, keys ...string) (*Store, error) {
+	if provider == nil || len(keys) == 0 {
+		return nil, errors.New("SecretProvider or the keys are nil")
+	}
+	return &Store{provider: provider, keys: keys}, nil
+}
+
+// OnRotate registers a function applying the secrets rotated by a refresh, like swapping the signing keys in.
+func (store *Store) OnRotate(rotate func(keys []string) error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.rotate = append(store.rotate, rotate)
+}
+
+// Refresh resolves the secrets and sets the changed ones, then calls the OnRotate functions with their keys, sorted.
+// A setting given by a flag keeps its value.
+func (store *Store) Refresh(ctx context.Context) ([]string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	secrets, err := store.provider.Secrets(ctx, store.keys)
+	if err != nil {
+		return nil, err
+	}
+	var rotated []string
+	for key, value := range secrets {
+		if os.Getenv(key) == value {
+			continue
+		}
+		if err := configs.SetSecret(key, value); err != nil {
+			return nil, err
+		}
+		rotated = append(rotated, key)
+	}
+	if len(rotated) == 0 {
+		return nil, nil
+	}
+	sort.Strings(rotated)
+	var errs []error
+	for _, rotate := range store.rotate {
+		errs = append(errs, rotate(rotated))
+	}
+	return rotated, errors.Join(errs...)
+}
+
+// Watch refreshes the secrets every interval until the context is done, logging the rotated keys but never their values.
+func (store *Store) Watch(ctx context.Context, interval time.Duration, logger *log.Logger) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		rotated, err := store.Refresh(ctx)
+		if err != nil {
+			logger.Error().Err(err).Strs("keys", rotated).Msg("Failed to refresh the secrets")
+			continue
+		}
+		if len(rotated) > 0 {
+			logger.Info().Strs("keys", rotated).Msg("Rotated secrets")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VaultConfig holds the settings of the Vault KV version 2 secret holding the secrets.
+type VaultConfig struct {
+	Address   string `env:"VAULT_ADDR"`                      // Address of the server, like https://vault.internal:8200
+	Token     string `env:"VAULT_TOKEN" redact:"true"`       // Token reading the secret
+	Namespace string `env:"VAULT_NAMESPACE"`                 // Namespace of the secret, empty for the root one
+	Mount     string `env:"VAULT_MOUNT" envDefault:"secret"` // Mount path of the KV version 2 engine
+	Path      string `env:"VAULT_PATH"`                      // Path of the secret in the engine
+	Timeout   int    `env:"VAULT_TIMEOUT" envDefault:"5"`    // Seconds to wait for the server
+}
+
+// Validate reports the settings missing to read the secret.
+func (config *VaultConfig) Validate() error {
+	var errs []error
+	if config.Address == "" {
+		errs = append(errs, errors.New("VAULT_ADDR is required by the vault secret provider"))
+	}
+	if config.Token == "" {
+		errs = append(errs, errors.New("VAULT_TOKEN is required by the vault secret provider"))
+	}
+	if config.Mount == "" || config.Path == "" {
+		errs = append(errs, errors.New("VAULT_MOUNT and VAULT_PATH are required by the vault secret provider"))
+	}
+	if config.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("VAULT_TIMEOUT must be positive, got %d", config.Timeout))
+	}
+	return errors.Join(errs...)
+}
+
+// Period returns the time to wait for the server.
+func (config VaultConfig) Period() time.Duration {
+	return time.Duration(config.Timeout) * time.Second
+}
+
+// VaultProvider reads the secrets from the latest version of a Vault KV version 2 secret, each key being a field of the secret.
+type VaultProvider struct {
+	config *VaultConfig
+	client *http.Client
+}
+
+// NewVaultProvider creates a VaultProvider reading the secret through client.
+func NewVaultProvider(config *VaultConfig, client *http.Client) (*VaultProvider, error) {
+	if config == nil || client == nil {
+		return nil, errors.New("VaultConfig or Client is nil")
+	}
+	return &VaultProvider{config: config, client: client}, nil
+}
+
+// Secrets reads the secret once and returns the keys among its fields.
+func (vault *VaultProvider) Secrets(ctx context.Context, keys []string) (map[string]string, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(vault.config.Address, "/"), strings.Trim(vault.config.Mount, "/"), strings.Trim(vault.config.Path, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", vault.config.Token)
+	if vault.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.config.Namespace)
+	}
+	res, err := vault.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		// The body is left out of the error, it may echo the secret
+		io.Copy(io.Discard, res.Body)
+		return nil, fmt.Errorf("vault: reading %s/%s answered %s", vault.config.Mount, vault.config.Path, res.Status)
+	}
+
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("vault: failed to decode the secret: %w", err)
+	}
+	secrets := map[string]string{}
+	for _, key := range keys {
+		if value, ok := body.Data.Data[key]; ok && value != nil {
+			secrets[key] = fmt.Sprint(value)
+		}
+	}
+	return secrets, nil
+}
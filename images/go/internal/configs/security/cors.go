@@ -1,6 +1,9 @@
 package security
 
 import (
+	"errors"
+	"strings"
+
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 )
@@ -14,6 +17,14 @@ type Cors struct {
 	Credentials   bool   `env:"CORS_ALLOW_CREDENTIALS,required"`
 }
 
+// Validate reports the wildcard origin allowed with credentials, which browsers reject
+func (c *Cors) Validate() error {
+	if c.Credentials && strings.Contains(c.AllowOrigins, "*") {
+		return errors.New("CORS_ALLOW_ORIGINS can not hold the wildcard * when CORS_ALLOW_CREDENTIALS is true")
+	}
+	return nil
+}
+
 // Fiber convert config cors to cors config fiber middleware
 func (c Cors) Fiber() cors.Config {
 	return cors.Config{
@@ -23,3 +23,15 @@ func TestCorsConfig_Fail(t *testing.T) {
 	require.Nil(t, cors)
 	require.Error(t, err)
 }
+
+func TestCorsConfig_FailWildcardWithCredentials(t *testing.T) {
+	t.Setenv("CORS_ALLOW_METHODS", "GET")
+	t.Setenv("CORS_ALLOW_HEADERS", "Origin")
+	t.Setenv("CORS_EXPOSE_HEADERS", "Origin")
+	t.Setenv("CORS_ALLOW_ORIGINS", "*")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	cors, err := security.NewCors()
+	require.Nil(t, cors)
+	require.ErrorContains(t, err, "CORS_ALLOW_ORIGINS")
+}
@@ -0,0 +1,35 @@
+package security
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
+
+// Limiter configuration for the rate limiting of the requests per client
+type Limiter struct {
+	Max        int `env:"LIMITER_MAX" envDefault:"20"`        // Requests allowed per client in a window
+	Expiration int `env:"LIMITER_EXPIRATION" envDefault:"30"` // Length of the window (in seconds)
+}
+
+// Validate reports the thresholds that are not positive
+func (l *Limiter) Validate() error {
+	if l.Max <= 0 || l.Expiration <= 0 {
+		return errors.New("LIMITER_MAX and LIMITER_EXPIRATION must be positive")
+	}
+	return nil
+}
+
+// Window returns the length of the window the requests are counted in
+func (l *Limiter) Window() time.Duration {
+	return time.Duration(l.Expiration) * time.Second
+}
+
+func NewLimiter() (*Limiter, error) {
+	var limiterConf Limiter
+	if err := configs.GetConfig().Load(&limiterConf); err != nil {
+		return nil, err
+	}
+	return &limiterConf, nil
+}
@@ -0,0 +1,321 @@
+package seed
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	"github.com/tirtahakimpambudhi/restful_api/db/seeds"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/mapper"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// Profiles holds the names of the embedded fixtures.
+var Profiles = []string{"dev", "e2e", "demo"}
+
+// ErrUnknownProfile is returned when no embedded fixture has the profile name.
+var ErrUnknownProfile = errors.New("unknown seed profile")
+
+// Fixture holds the users, roles and Casbin rules to seed, JSON fixtures are read as YAML.
+type Fixture struct {
+	Users []User     `yaml:"users"` // Users created when no user has their email
+	Roles []Role     `yaml:"roles"` // Roles with the permissions added to the policy
+	Rules [][]string `yaml:"rules"` // Casbin rules, the policy type (p or g) followed by its values
+}
+
+// User is a user of a fixture, the password is hashed when the user is created.
+type User struct {
+	Username string   `yaml:"username"`
+	Email    string   `yaml:"email"`
+	Password string   `yaml:"password"`
+	Roles    []string `yaml:"roles"` // Roles given to the user, also when the user already exists
+}
+
+// Role is a role of a fixture with the objects and actions it is allowed.
+type Role struct {
+	Name        string       `yaml:"name"`
+	Permissions []Permission `yaml:"permissions"`
+}
+
+// Permission allows an action on an object.
+type Permission struct {
+	Object string `yaml:"object"`
+	Action string `yaml:"action"`
+}
+
+// Result counts what a seed added, the entries already present are not counted.
+type Result struct {
+	Users    int // Users created
+	Roles    int // Roles given to users, from the users and the g rules
+	Policies int // p rules added, from the roles and the p rules
+}
+
+// Load reads the embedded fixture of the profile.
+func Load(profile string) (*Fixture, error) {
+	if !slices.Contains(Profiles, profile) {
+		return nil, fmt.Errorf("%w %q, expected one of %v", ErrUnknownProfile, profile, Profiles)
+	}
+	body, err := fs.ReadFile(seeds.FS, profile+".yaml")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(body)
+}
+
+// Read reads the fixture file at path, in YAML or JSON.
+func Read(path string) (*Fixture, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixture, err := Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+// Parse decodes a fixture and validates every entry, reporting all the errors together.
+func Parse(body []byte) (*Fixture, error) {
+	var fixture Fixture
+	// JSON is valid YAML, both are decoded the same way
+	if err := yaml.Unmarshal(body, &fixture); err != nil {
+		return nil, err
+	}
+
+	validate := validator.New()
+	var errs []error
+	for i, user := range fixture.Users {
+		if err := validate.Struct(request.User{Username: user.Username, Email: user.Email, Password: user.Password}); err != nil {
+			errs = append(errs, fmt.Errorf("users[%d]: %w", i, err))
+		}
+		if slices.Contains(user.Roles, "") {
+			errs = append(errs, fmt.Errorf("users[%d]: empty role", i))
+		}
+	}
+	for i, role := range fixture.Roles {
+		if role.Name == "" {
+			errs = append(errs, fmt.Errorf("roles[%d]: empty name", i))
+		}
+		for j, permission := range role.Permissions {
+			if permission.Object == "" || permission.Action == "" {
+				errs = append(errs, fmt.Errorf("roles[%d].permissions[%d]: object and action are required", i, j))
+			}
+		}
+	}
+	for i, rule := range fixture.Rules {
+		switch {
+		case len(rule) == 0 || (rule[0] != "p" && rule[0] != "g"):
+			errs = append(errs, fmt.Errorf("rules[%d]: the policy type must be p or g", i))
+		case rule[0] == "p" && len(rule) != 4:
+			errs = append(errs, fmt.Errorf("rules[%d]: a p rule holds a subject, an object and an action", i))
+		case rule[0] == "g" && len(rule) != 3:
+			errs = append(errs, fmt.Errorf("rules[%d]: a g rule holds a user and a role", i))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// Seeder adds the entries of fixtures missing from the database. Users are identified by email and
+// rules by their values, so seeding a fixture again only adds what is missing and never overwrites.
+type Seeder struct {
+	usersRepository repository.UsersRepository
+	auditRepository repository.AuditRepository
+	unitOfWork      repository.UnitOfWork
+	enforcer        *casbin.Enforcer
+	hashing         *hash.Argon2
+	logger          *log.Logger
+	actor           string
+}
+
+// NewSeeder creates a Seeder saving the users through the repositories and the rules through the enforcer.
+func NewSeeder(usersRepository repository.UsersRepository, auditRepository repository.AuditRepository, unitOfWork repository.UnitOfWork, enforcer *casbin.Enforcer, hashing *hash.Argon2, logger *log.Logger) (*Seeder, error) {
+	if usersRepository == nil || auditRepository == nil || unitOfWork == nil || enforcer == nil || hashing == nil || logger == nil {
+		return nil, errors.New("repositories, enforcer, hashing or Logger is nil")
+	}
+	return &Seeder{
+		usersRepository: usersRepository,
+		auditRepository: auditRepository,
+		unitOfWork:      unitOfWork,
+		enforcer:        enforcer,
+		hashing:         hashing,
+		logger:          logger,
+		actor:           "seed",
+	}, nil
+}
+
+// WithActor sets the actor of the audit events recorded by the seeder, seed by default.
+func (seeder *Seeder) WithActor(actor string) *Seeder {
+	seeder.actor = actor
+	return seeder
+}
+
+// Seed adds the roles, rules and users of the fixture missing from the database.
+func (seeder *Seeder) Seed(ctx context.Context, fixture *Fixture) (*Result, error) {
+	result := &Result{}
+	for _, role := range fixture.Roles {
+		for _, permission := range role.Permissions {
+			added, err := seeder.addPolicy(role.Name, permission.Object, permission.Action)
+			if err != nil {
+				return result, fmt.Errorf("role %s: %w", role.Name, err)
+			}
+			if added {
+				result.Policies++
+			}
+		}
+	}
+	for _, rule := range fixture.Rules {
+		var (
+			added bool
+			err   error
+		)
+		if rule[0] == "p" {
+			added, err = seeder.addPolicy(rule[1:]...)
+			if added {
+				result.Policies++
+			}
+		} else {
+			added, err = seeder.addRole(ctx, rule[1], rule[2])
+			if added {
+				result.Roles++
+			}
+		}
+		if err != nil {
+			return result, fmt.Errorf("rule %v: %w", rule, err)
+		}
+	}
+	for _, user := range fixture.Users {
+		if err := seeder.seedUser(ctx, user, result); err != nil {
+			return result, fmt.Errorf("user %s: %w", user.Email, err)
+		}
+	}
+	seeder.logger.Info().Msgf("Seeded %d users, %d roles and %d policies", result.Users, result.Roles, result.Policies)
+	return result, nil
+}
+
+// seedUser creates the user when no user has its email, soft-deleted or not, then gives it the roles it misses.
+func (seeder *Seeder) seedUser(ctx context.Context, user User, result *Result) error {
+	exist, err := seeder.exists(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		password, err := seeder.hashing.Create(user.Password)
+		if err != nil {
+			return err
+		}
+		users := mapper.RequestUserToEntity(ksuid.New().String(), request.User{Username: user.Username, Email: user.Email, Password: password})
+		// The user and its audit event are saved together
+		err = seeder.unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := seeder.usersRepository.Create(ctx, users); err != nil {
+				return err
+			}
+			return seeder.record(ctx, entity.AuditUserCreate, users, map[string]any{
+				"username": map[string]any{"from": "", "to": users.Username},
+				"email":    map[string]any{"changed": true},
+			})
+		})
+		switch {
+		case err == nil:
+			result.Users++
+		case seeder.createdMeanwhile(ctx, user.Email, err):
+			// A duplicate key, another process seeded the user since the check
+			seeder.logger.Info().Msgf("User %s was seeded by another process", user.Email)
+		default:
+			return err
+		}
+	}
+	for _, role := range user.Roles {
+		added, err := seeder.addRole(ctx, user.Email, role)
+		if err != nil {
+			return err
+		}
+		if added {
+			result.Roles++
+		}
+	}
+	return nil
+}
+
+// exists tells whether a user has the email, a soft-deleted user still holds it.
+func (seeder *Seeder) exists(ctx context.Context, email string) (bool, error) {
+	var users entity.Users
+	err := seeder.usersRepository.GetByEmailWithDeleted(ctx, &users, email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// createdMeanwhile tells whether the creation of the user failed on a duplicate key, the drivers report it differently
+// so the duplicate is told by the user now having the email.
+func (seeder *Seeder) createdMeanwhile(ctx context.Context, email string, err error) bool {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return true
+	}
+	exist, errExist := seeder.exists(ctx, email)
+	return errExist == nil && exist
+}
+
+// addPolicy adds the p rule when missing and tells whether it was added.
+func (seeder *Seeder) addPolicy(rule ...string) (bool, error) {
+	exist, err := seeder.enforcer.HasPolicy(rule)
+	if err != nil || exist {
+		return false, err
+	}
+	return seeder.enforcer.AddPolicy(rule)
+}
+
+// addRole gives the role to the user when missing, recording the role change, and tells whether it was given.
+func (seeder *Seeder) addRole(ctx context.Context, user string, role string) (bool, error) {
+	// AddRoleForUser does not report an existing assignment, the roles are checked first
+	roles, err := seeder.enforcer.GetRolesForUser(user)
+	if err != nil || slices.Contains(roles, role) {
+		return false, err
+	}
+	if _, err := seeder.enforcer.AddRoleForUser(user, role); err != nil {
+		return false, err
+	}
+	changes := map[string]any{"roles": map[string]any{"from": roles, "to": append(slices.Clone(roles), role)}}
+	// the audit trail refers to the user by its ID, a role inheriting another one has none
+	var target entity.Users
+	if err := seeder.usersRepository.GetByEmailWithDeleted(ctx, &target, user); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, fmt.Errorf("the role is given but its audit event is not recorded: %w", err)
+	}
+	if err := seeder.record(ctx, entity.AuditRoleChange, &entity.Users{ID: target.ID}, changes); err != nil {
+		return true, fmt.Errorf("the role is given but its audit event is not recorded: %w", err)
+	}
+	return true, nil
+}
+
+// record appends a successful audit event of the seeder on the target user.
+func (seeder *Seeder) record(ctx context.Context, action string, target *entity.Users, changes map[string]any) error {
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return seeder.auditRepository.Create(ctx, &entity.AuditEvents{
+		ID:       ksuid.New().String(),
+		Action:   action,
+		Outcome:  entity.AuditSuccess,
+		ActorID:  seeder.actor,
+		TargetID: target.ID,
+		Changes:  string(encoded),
+	})
+}
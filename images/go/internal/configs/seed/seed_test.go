@@ -0,0 +1,192 @@
+package seed_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/seed"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"gorm.io/gorm"
+)
+
+// newSeeder creates a Seeder on an in-memory SQLite database with the migrations applied.
+func newSeeder(t *testing.T) (*seed.Seeder, *gorm.DB, *casbin.Enforcer, *hash.Argon2) {
+	t.Helper()
+	logger := &log.DefaultLogger
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 10, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migration.New(sqlDB, sqlconfig.SQLite, logger)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	require.NoError(t, err)
+	enforcer, err := casbin.NewEnforcer("../../../resource/model/rbac_model.conf", adapter)
+	require.NoError(t, err)
+	argon2, err := hash.NewHashArgon2()
+	require.NoError(t, err)
+	usersRepository, err := repository.NewUsersRepositoryImpl(db, logger)
+	require.NoError(t, err)
+	auditRepository, err := repository.NewAuditRepositoryImpl(db, logger)
+	require.NoError(t, err)
+	unitOfWork, err := repository.NewUnitOfWork(db, logger)
+	require.NoError(t, err)
+	seeder, err := seed.NewSeeder(usersRepository, auditRepository, unitOfWork, enforcer, argon2, logger)
+	require.NoError(t, err)
+	return seeder, db, enforcer, argon2
+}
+
+func TestLoad(t *testing.T) {
+	for _, profile := range seed.Profiles {
+		fixture, err := seed.Load(profile)
+		require.NoError(t, err, profile)
+		require.NotEmpty(t, fixture.Users, profile)
+	}
+	_, err := seed.Load("production")
+	require.ErrorIs(t, err, seed.ErrUnknownProfile)
+}
+
+func TestRead(t *testing.T) {
+	// JSON fixtures have the layout of the YAML ones
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"roles": [{"name": "auditor", "permissions": [{"object": "audit", "action": "read"}]}],
+		"users": [{"username": "budi santoso", "email": "budi@gmail.com", "password": "secret-password", "roles": ["auditor"]}],
+		"rules": [["g", "budi@gmail.com", "moderator"]]
+	}`), 0o600))
+	fixture, err := seed.Read(path)
+	require.NoError(t, err)
+	require.Equal(t, &seed.Fixture{
+		Users: []seed.User{{Username: "budi santoso", Email: "budi@gmail.com", Password: "secret-password", Roles: []string{"auditor"}}},
+		Roles: []seed.Role{{Name: "auditor", Permissions: []seed.Permission{{Object: "audit", Action: "read"}}}},
+		Rules: [][]string{{"g", "budi@gmail.com", "moderator"}},
+	}, fixture)
+}
+
+func TestParse(t *testing.T) {
+	// every invalid entry is reported at once
+	_, err := seed.Parse([]byte(`
+users:
+  - username: budi
+    email: not-an-email
+    password: short
+roles:
+  - permissions:
+      - object: users
+rules:
+  - [x, admin]
+  - [p, admin, users]
+  - [g, budi@gmail.com]
+`))
+	require.ErrorContains(t, err, "users[0]")
+	require.ErrorContains(t, err, "roles[0]: empty name")
+	require.ErrorContains(t, err, "roles[0].permissions[0]")
+	require.ErrorContains(t, err, "rules[0]")
+	require.ErrorContains(t, err, "rules[1]")
+	require.ErrorContains(t, err, "rules[2]")
+
+	_, err = seed.Parse([]byte("users: {"))
+	require.Error(t, err)
+}
+
+func TestSeeder(t *testing.T) {
+	ctx := context.Background()
+	seeder, db, enforcer, argon2 := newSeeder(t)
+	fixture, err := seed.Load("demo")
+	require.NoError(t, err)
+
+	result, err := seeder.Seed(ctx, fixture)
+	require.NoError(t, err)
+	require.Equal(t, &seed.Result{Users: 4, Roles: 3, Policies: 2}, result)
+
+	var users entity.Users
+	require.NoError(t, db.Where("email = ?", "auditor@demo.local").First(&users).Error)
+	match, err := argon2.Match("demo-auditor-password", users.Password)
+	require.NoError(t, err)
+	require.True(t, match)
+	authorized, err := enforcer.Enforce("auditor@demo.local", "audit", "read")
+	require.NoError(t, err)
+	require.True(t, authorized)
+	authorized, err = enforcer.Enforce("alice@demo.local", "users", "read")
+	require.NoError(t, err)
+	require.True(t, authorized)
+	var created int64
+	require.NoError(t, db.Model(&entity.AuditEvents{}).Where("action = ? AND actor_id = ?", entity.AuditUserCreate, "seed").Count(&created).Error)
+	require.Equal(t, int64(4), created)
+
+	// seeding again adds nothing and keeps the passwords
+	require.NoError(t, db.Model(&entity.Users{}).Where("email = ?", "auditor@demo.local").Update("password", "changed").Error)
+	result, err = seeder.Seed(ctx, fixture)
+	require.NoError(t, err)
+	require.Equal(t, &seed.Result{}, result)
+	require.NoError(t, db.Where("email = ?", "auditor@demo.local").First(&users).Error)
+	require.Equal(t, "changed", users.Password)
+
+	// a soft-deleted user still holds its email and is not created again
+	require.NoError(t, db.Model(&entity.Users{}).Where("email = ?", "auditor@demo.local").Update("deleted_at", 1).Error)
+	result, err = seeder.Seed(ctx, fixture)
+	require.NoError(t, err)
+	require.Equal(t, &seed.Result{}, result)
+
+	// an existing user is given the roles it misses
+	result, err = seeder.Seed(ctx, &seed.Fixture{Users: []seed.User{{Email: "alice@demo.local", Roles: []string{"moderator", "admin"}}}})
+	require.NoError(t, err)
+	require.Equal(t, &seed.Result{Roles: 1}, result)
+}
+
+// staleUsersRepository misses the users created by another process since the start of the seed.
+type staleUsersRepository struct {
+	repository.UsersRepository
+	checked bool
+}
+
+// GetByEmailWithDeleted reports no user on the first check.
+func (repo *staleUsersRepository) GetByEmailWithDeleted(ctx context.Context, users *entity.Users, email string) error {
+	if !repo.checked {
+		repo.checked = true
+		return gorm.ErrRecordNotFound
+	}
+	return repo.UsersRepository.GetByEmailWithDeleted(ctx, users, email)
+}
+
+func TestSeeder_WhenSeededMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	seeder, db, enforcer, argon2 := newSeeder(t)
+	fixture := &seed.Fixture{Users: []seed.User{{Username: "budi santoso", Email: "budi@gmail.com", Password: "secret-password", Roles: []string{"auditor"}}}}
+	_, err := seeder.Seed(ctx, fixture)
+	require.NoError(t, err)
+
+	// the duplicate key of a user seeded by another process since the check counts as seeded
+	logger := &log.DefaultLogger
+	usersRepository, err := repository.NewUsersRepositoryImpl(db, logger)
+	require.NoError(t, err)
+	auditRepository, err := repository.NewAuditRepositoryImpl(db, logger)
+	require.NoError(t, err)
+	unitOfWork, err := repository.NewUnitOfWork(db, logger)
+	require.NoError(t, err)
+	racing, err := seed.NewSeeder(&staleUsersRepository{UsersRepository: usersRepository}, auditRepository, unitOfWork, enforcer, argon2, logger)
+	require.NoError(t, err)
+	result, err := racing.Seed(ctx, fixture)
+	require.NoError(t, err)
+	require.Equal(t, &seed.Result{}, result)
+	var count int64
+	require.NoError(t, db.Model(&entity.Users{}).Where("email = ?", "budi@gmail.com").Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
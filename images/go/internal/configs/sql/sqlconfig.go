@@ -1,29 +1,137 @@
 package sqlconfig
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 )
 
 // SqlConfig holds the configuration for SQL database connection.
 type SqlConfig struct {
-	Driver   string `env:"DB_DRIVER,required"`          // Database driver (e.g., postgres, mysql)
-	Protocol string `env:"DB_PROTOCOL,required"`        // Protocol for the database connection
-	Name     string `env:"DB_NAME,required"`            // Database name
-	Host     string `env:"DB_HOST,required"`            // Database host address
-	Port     string `env:"DB_PORT,required"`            // Port for the database connection
-	User     string `env:"DB_USER,required"`            // Database user
-	Password string `env:"DB_PASS,required"`            // Password for the database user
-	MaxCon   int    `env:"DB_MAX_CON" envDefault:"100"` // Maximum number of connections
-	MinCon   int    `env:"DB_MIN_CON" envDefault:"10"`  // Minimum number of connections
-	MaxTime  int    `env:"DB_MAX_TIME" envDefault:"30"` // Maximum connection time in seconds
-	MinTime  int    `env:"DB_MIN_TIME" envDefault:"5"`  // Minimum connection time in seconds
+	Driver   string `env:"DB_DRIVER,required"`                    // Database driver (postgres, mysql or sqlite)
+	Protocol string `env:"DB_PROTOCOL" envDefault:"tcp"`          // Protocol for the database connection
+	Name     string `env:"DB_NAME,required"`                      // Database name, the file path (or :memory:) for sqlite
+	Host     string `env:"DB_HOST"`                               // Database host address, unused by sqlite
+	Port     string `env:"DB_PORT"`                               // Port for the database connection, unused by sqlite
+	User     string `env:"DB_USER"`                               // Database user, unused by sqlite
+	Password string `env:"DB_PASS" redact:"true"`                 // Password for the database user, unused by sqlite
+	SSLMode  string `env:"DB_SSLMODE" envDefault:"disable"`       // TLS mode (disable, require, verify-ca, verify-full), unused by sqlite
+	TimeZone string `env:"DB_TIMEZONE" envDefault:"Asia/Jakarta"` // Time zone of the session, unused by sqlite
+	MaxCon   int    `env:"DB_MAX_CON" envDefault:"100"`           // Maximum number of open connections
+	MinCon   int    `env:"DB_MIN_CON" envDefault:"10"`            // Maximum number of idle connections
+	MaxTime  int    `env:"DB_MAX_TIME" envDefault:"30"`           // Maximum connection lifetime (in minutes)
+	MinTime  int    `env:"DB_MIN_TIME" envDefault:"5"`            // Maximum idle connection time (in minutes)
+
+	Replicas       []string `env:"DB_REPLICAS" envSeparator:"," redact:"true"` // DSNs of the read replicas in the format of DB_DRIVER, comma separated, reads use the primary when empty
+	ReadYourWrites int      `env:"DB_READ_YOUR_WRITES" envDefault:"5"`         // Seconds the reads of a request go to the primary after it writes
+
+	AutoMigrate        bool `env:"DB_AUTO_MIGRATE" envDefault:"false"`      // Apply the pending migrations on startup
+	MigrateLockTimeout int  `env:"DB_MIGRATE_LOCK_TIMEOUT" envDefault:"60"` // Seconds to wait for the migration lock held by another replica
+
+	SeedProfile string `env:"DB_SEED_PROFILE"` // Embedded fixture seeded on startup (dev, e2e, demo), nothing is seeded when empty
+}
+
+// Supported database drivers.
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// sslModes holds the supported sslmode values and the tls parameter of the MySQL driver matching each of them.
+var sslModes = map[string]string{
+	"disable":     "false",
+	"allow":       "preferred",
+	"prefer":      "preferred",
+	"require":     "skip-verify",
+	"verify-ca":   "true",
+	"verify-full": "true",
+}
+
+// DSN returns the data source name of the primary database in the format of the driver.
+func (config *SqlConfig) DSN() string {
+	switch config.Driver {
+	case MySQL:
+		// the migrations hold several statements per file
+		return fmt.Sprintf("%s:%s@%s(%s:%s)/%s?parseTime=true&loc=%s&tls=%s&multiStatements=true", config.User, config.Password, config.Protocol, config.Host, config.Port, config.Name, url.QueryEscape(config.TimeZone), sslModes[config.SSLMode])
+	case SQLite:
+		return config.Name
+	default:
+		return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=%s", config.Host, config.User, config.Password, config.Name, config.Port, config.SSLMode, config.TimeZone)
+	}
+}
+
+// Validate reports every setting the driver needs that is missing or invalid, and the pool settings contradicting each other.
+func (config *SqlConfig) Validate() error {
+	var errs []error
+	if config.MaxCon <= 0 {
+		errs = append(errs, fmt.Errorf("DB_MAX_CON must be positive, got %d", config.MaxCon))
+	}
+	if config.MinCon < 0 || config.MinCon > config.MaxCon {
+		errs = append(errs, fmt.Errorf("DB_MIN_CON %d must be between 0 and DB_MAX_CON %d", config.MinCon, config.MaxCon))
+	}
+	if config.SeedProfile != "" && config.Driver != SQLite && config.MaxCon < 2 {
+		errs = append(errs, fmt.Errorf("DB_SEED_PROFILE needs DB_MAX_CON of at least 2, the seeding holds the migration lock on a connection, got %d", config.MaxCon))
+	}
+	if config.MaxTime < 0 || config.MinTime < 0 || config.ReadYourWrites < 0 || config.MigrateLockTimeout < 0 {
+		errs = append(errs, errors.New("DB_MAX_TIME, DB_MIN_TIME, DB_READ_YOUR_WRITES and DB_MIGRATE_LOCK_TIMEOUT must not be negative"))
+	}
+	switch config.Driver {
+	case SQLite:
+		// sqlite only needs the file of DB_NAME
+		return errors.Join(errs...)
+	case Postgres, MySQL:
+	default:
+		return errors.Join(append(errs, fmt.Errorf("DB_DRIVER %q is not supported, use %s, %s or %s", config.Driver, Postgres, MySQL, SQLite))...)
+	}
+	required := []struct{ key, value string }{
+		{"DB_HOST", config.Host},
+		{"DB_PORT", config.Port},
+		{"DB_USER", config.User},
+		{"DB_PASS", config.Password},
+	}
+	for _, setting := range required {
+		if setting.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required by the %s driver", setting.key, config.Driver))
+		}
+	}
+	if _, ok := sslModes[config.SSLMode]; !ok {
+		errs = append(errs, fmt.Errorf("DB_SSLMODE %q is not supported", config.SSLMode))
+	}
+	if _, err := time.LoadLocation(config.TimeZone); err != nil {
+		errs = append(errs, fmt.Errorf("DB_TIMEZONE %q is invalid: %w", config.TimeZone, err))
+	}
+	return errors.Join(errs...)
+}
+
+// ConnMaxLifetime returns the maximum lifetime of a connection.
+func (config *SqlConfig) ConnMaxLifetime() time.Duration {
+	return time.Duration(config.MaxTime) * time.Minute
+}
+
+// ConnMaxIdleTime returns the maximum time a connection stays idle.
+func (config *SqlConfig) ConnMaxIdleTime() time.Duration {
+	return time.Duration(config.MinTime) * time.Minute
+}
+
+// MigrateLockWait returns how long to wait for the migration lock held by another replica.
+func (config *SqlConfig) MigrateLockWait() time.Duration {
+	return time.Duration(config.MigrateLockTimeout) * time.Second
+}
+
+// ReadYourWritesWindow returns how long the reads of a request go to the primary after it writes.
+func (config *SqlConfig) ReadYourWritesWindow() time.Duration {
+	return time.Duration(config.ReadYourWrites) * time.Second
 }
 
 // NewConfig creates a new SqlConfig by loading configuration from environment variables.
 func NewConfig() (*SqlConfig, error) {
 	var config SqlConfig
 
-	// Load the configuration into the config object.
+	// Load the configuration into the config object, checking the settings required by the driver.
 	if err := configs.GetConfig().Load(&config); err != nil {
 		// Return nil and the error if loading configuration fails.
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNewConfig_Success(t *testing.T) {
@@ -45,3 +46,127 @@ func TestNewConfig_MissingEnvVars(t *testing.T) {
 	require.Error(t, err)
 	require.Nil(t, config)
 }
+
+func TestNewConfig_PoolAndReplicas(t *testing.T) {
+	os.Setenv("DB_DRIVER", "postgres")
+	os.Setenv("DB_PROTOCOL", "tcp")
+	os.Setenv("DB_NAME", "testdb")
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", "5432")
+	os.Setenv("DB_USER", "user")
+	os.Setenv("DB_PASS", "password")
+	os.Setenv("DB_MAX_TIME", "60")
+	os.Setenv("DB_MIN_TIME", "2")
+	os.Setenv("DB_REPLICAS", "host=replica1 dbname=testdb,host=replica2 dbname=testdb")
+	os.Setenv("DB_READ_YOUR_WRITES", "3")
+	defer os.Clearenv()
+
+	config, err := sqlconfig.NewConfig()
+
+	require.NoError(t, err)
+	require.Equal(t, 100, config.MaxCon)
+	require.Equal(t, 10, config.MinCon)
+	require.Equal(t, time.Hour, config.ConnMaxLifetime())
+	require.Equal(t, 2*time.Minute, config.ConnMaxIdleTime())
+	require.Equal(t, []string{"host=replica1 dbname=testdb", "host=replica2 dbname=testdb"}, config.Replicas)
+	require.Equal(t, 3*time.Second, config.ReadYourWritesWindow())
+	require.Equal(t, "host=localhost user=user password=password dbname=testdb port=5432 sslmode=disable TimeZone=Asia/Jakarta", config.DSN())
+}
+
+func TestNewConfig_Drivers(t *testing.T) {
+	t.Run("MySQL", func(t *testing.T) {
+		os.Setenv("DB_DRIVER", "mysql")
+		os.Setenv("DB_NAME", "testdb")
+		os.Setenv("DB_HOST", "localhost")
+		os.Setenv("DB_PORT", "3306")
+		os.Setenv("DB_USER", "user")
+		os.Setenv("DB_PASS", "password")
+		os.Setenv("DB_SSLMODE", "require")
+		os.Setenv("DB_TIMEZONE", "UTC")
+		defer os.Clearenv()
+
+		config, err := sqlconfig.NewConfig()
+
+		require.NoError(t, err)
+		require.Equal(t, "user:password@tcp(localhost:3306)/testdb?parseTime=true&loc=UTC&tls=skip-verify&multiStatements=true", config.DSN())
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		// sqlite only needs the database file
+		os.Setenv("DB_DRIVER", "sqlite")
+		os.Setenv("DB_NAME", ":memory:")
+		defer os.Clearenv()
+
+		config, err := sqlconfig.NewConfig()
+
+		require.NoError(t, err)
+		require.Equal(t, ":memory:", config.DSN())
+	})
+
+	t.Run("PostgresSettings", func(t *testing.T) {
+		os.Setenv("DB_DRIVER", "postgres")
+		os.Setenv("DB_NAME", "testdb")
+		os.Setenv("DB_HOST", "localhost")
+		os.Setenv("DB_PORT", "5432")
+		os.Setenv("DB_USER", "user")
+		os.Setenv("DB_PASS", "password")
+		os.Setenv("DB_SSLMODE", "verify-full")
+		os.Setenv("DB_TIMEZONE", "UTC")
+		defer os.Clearenv()
+
+		config, err := sqlconfig.NewConfig()
+
+		require.NoError(t, err)
+		require.Equal(t, "host=localhost user=user password=password dbname=testdb port=5432 sslmode=verify-full TimeZone=UTC", config.DSN())
+	})
+
+	t.Run("UnsupportedDriver", func(t *testing.T) {
+		os.Setenv("DB_DRIVER", "oracle")
+		os.Setenv("DB_NAME", "testdb")
+		defer os.Clearenv()
+
+		config, err := sqlconfig.NewConfig()
+
+		require.ErrorContains(t, err, `DB_DRIVER "oracle" is not supported`)
+		require.Nil(t, config)
+	})
+
+	t.Run("ReportsEveryInvalidSetting", func(t *testing.T) {
+		os.Setenv("DB_DRIVER", "postgres")
+		os.Setenv("DB_NAME", "testdb")
+		os.Setenv("DB_HOST", "localhost")
+		os.Setenv("DB_SSLMODE", "always")
+		os.Setenv("DB_TIMEZONE", "Mars/Olympus")
+		defer os.Clearenv()
+
+		config, err := sqlconfig.NewConfig()
+
+		require.Nil(t, config)
+		for _, setting := range []string{"DB_PORT", "DB_USER", "DB_PASS", "DB_SSLMODE", "DB_TIMEZONE"} {
+			require.ErrorContains(t, err, setting)
+		}
+		require.NotContains(t, err.Error(), "DB_HOST")
+	})
+
+	t.Run("SeedNeedsTwoConnections", func(t *testing.T) {
+		// the seeding holds the migration lock on a connection and seeds through another one
+		os.Setenv("DB_DRIVER", "postgres")
+		os.Setenv("DB_NAME", "app")
+		os.Setenv("DB_MAX_CON", "1")
+		os.Setenv("DB_MIN_CON", "1")
+		os.Setenv("DB_SEED_PROFILE", "dev")
+		defer os.Clearenv()
+
+		config, err := sqlconfig.NewConfig()
+
+		require.Nil(t, config)
+		require.ErrorContains(t, err, "DB_SEED_PROFILE needs DB_MAX_CON of at least 2")
+
+		// sqlite has no lock to hold, its single connection seeds
+		os.Setenv("DB_DRIVER", "sqlite")
+		os.Setenv("DB_NAME", ":memory:")
+		config, err = sqlconfig.NewConfig()
+		require.NoError(t, err)
+		require.NotNil(t, config)
+	})
+}
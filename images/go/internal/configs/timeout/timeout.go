@@ -2,9 +2,12 @@ package timeout
 
 import (
 	"context"
-	"os"
-	"strconv"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
 )
 
 type Config struct {
@@ -13,34 +16,75 @@ type Config struct {
 	DownstreamTimeout time.Duration
 }
 
-func (config Config) CreateCacheTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(ctx, config.CacheTimeout)
+// Minutes holds the timeouts as they are set, in minutes.
+type Minutes struct {
+	Cache      int `env:"CACHE_TIMEOUT" envDefault:"8"`        // Timeout of the cache operations
+	Database   int `env:"DB_TIMEOUT" envDefault:"20"`          // Timeout of the database queries
+	Downstream int `env:"DOWN_STREAM_TIMEOUT" envDefault:"30"` // Timeout of the calls to other services
+}
+
+// Validate reports every timeout that is not positive.
+func (minutes *Minutes) Validate() error {
+	var errs []error
+	settings := []struct {
+		key   string
+		value int
+	}{
+		{"CACHE_TIMEOUT", minutes.Cache},
+		{"DB_TIMEOUT", minutes.Database},
+		{"DOWN_STREAM_TIMEOUT", minutes.Downstream},
+	}
+	for _, setting := range settings {
+		if setting.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %d", setting.key, setting.value))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (config *Config) CreateCacheTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, load(&config.CacheTimeout))
+}
+
+func (config *Config) CreateDatabaseTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, load(&config.DatabaseTimeout))
+}
+
+func (config *Config) CreateDownstreamTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, load(&config.DownstreamTimeout))
+}
+
+// Downstream returns the timeout of the calls to other services.
+func (config *Config) Downstream() time.Duration {
+	return load(&config.DownstreamTimeout)
 }
 
-func (config Config) CreateDatabaseTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(ctx, config.DatabaseTimeout)
+// Store replaces the timeouts while they are used, by a reload of the configuration.
+func (config *Config) Store(next *Config) {
+	atomic.StoreInt64((*int64)(&config.CacheTimeout), int64(next.CacheTimeout))
+	atomic.StoreInt64((*int64)(&config.DatabaseTimeout), int64(next.DatabaseTimeout))
+	atomic.StoreInt64((*int64)(&config.DownstreamTimeout), int64(next.DownstreamTimeout))
 }
 
-func (config Config) CreateDownstreamTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	return context.WithTimeout(ctx, config.DownstreamTimeout)
+// load reads a timeout replaced by Store.
+func load(timeout *time.Duration) time.Duration {
+	return time.Duration(atomic.LoadInt64((*int64)(timeout)))
 }
 
+// NewConfig loads the timeouts, the unset ones keep their default.
 func NewConfig() (*Config, error) {
-	cacheTimeout, err := strconv.Atoi(os.Getenv("CACHE_TIMEOUT"))
-	if err != nil {
-		return nil, err
-	}
-	databaseTimeout, err := strconv.Atoi(os.Getenv("DB_TIMEOUT"))
-	if err != nil {
-		return nil, err
-	}
-	downstreamTimeout, err := strconv.Atoi(os.Getenv("DOWN_STREAM_TIMEOUT"))
-	if err != nil {
+	var minutes Minutes
+	if err := configs.GetConfig().Load(&minutes); err != nil {
 		return nil, err
 	}
+	return minutes.Config(), nil
+}
+
+// Config converts the minutes to the timeouts.
+func (minutes *Minutes) Config() *Config {
 	return &Config{
-		CacheTimeout:      time.Duration(cacheTimeout) * time.Minute,
-		DatabaseTimeout:   time.Duration(databaseTimeout) * time.Minute,
-		DownstreamTimeout: time.Duration(downstreamTimeout) * time.Minute,
-	}, nil
+		CacheTimeout:      time.Duration(minutes.Cache) * time.Minute,
+		DatabaseTimeout:   time.Duration(minutes.Database) * time.Minute,
+		DownstreamTimeout: time.Duration(minutes.Downstream) * time.Minute,
+	}
 }
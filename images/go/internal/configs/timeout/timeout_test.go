@@ -74,11 +74,26 @@ func TestNewConfig(t *testing.T) {
 		os.Unsetenv("DB_TIMEOUT")
 		os.Unsetenv("DOWN_STREAM_TIMEOUT")
 
+		// Call NewConfig
+		config, err := timeout.NewConfig()
+
+		// Verify the defaults are used for the missing env vars
+		require.NoError(t, err)
+		require.Equal(t, 8*time.Minute, config.CacheTimeout)
+		require.Equal(t, 20*time.Minute, config.DatabaseTimeout)
+		require.Equal(t, 30*time.Minute, config.DownstreamTimeout)
+	})
+
+	t.Run("Edge Case - Zero Timeout", func(t *testing.T) {
+		t.Setenv("CACHE_TIMEOUT", "0")
+		t.Setenv("DB_TIMEOUT", "-1")
+
 		// Call NewConfig
 		_, err := timeout.NewConfig()
 
-		// Verify an error occurred due to missing env vars
-		require.Error(t, err)
+		// Verify every invalid timeout is reported
+		require.ErrorContains(t, err, "CACHE_TIMEOUT must be positive")
+		require.ErrorContains(t, err, "DB_TIMEOUT must be positive")
 	})
 }
 
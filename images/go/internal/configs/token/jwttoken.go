@@ -35,6 +35,9 @@ func NewJWTToken() (*JWTToken, *SecretKey, error) {
 	if len(secretKey.AccessToken) < MinSecretKeySize || len(secretKey.RefreshToken) < MinSecretKeySize || len(secretKey.ForgotPasswordToken) < MinSecretKeySize {
 		return nil, nil, NewTokenError(ErrInvalidKey, fmt.Sprintf("min length secret key is %d", MinSecretKeySize))
 	}
+	if secretKey.Grace < 0 {
+		return nil, nil, fmt.Errorf("SECRET_KEY_GRACE must not be negative, got %d", secretKey.Grace)
+	}
 	return &jwtToken, &secretKey, nil
 }
 
@@ -87,6 +90,27 @@ func (jwtToken JWTToken) VerifyToken(secretKey, tokenStr string) (*Payload, erro
 	return nil, NewTokenError(ErrServerError, "invalid token")
 }
 
+// VerifyTokenWithKeys verifies a JWT token with each key in turn, like the current and the previous key of a rotation,
+// returning the payload of the first key whose signature matches or the error of the last key.
+func (jwtToken JWTToken) VerifyTokenWithKeys(secretKeys []string, tokenStr string) (*Payload, error) {
+	if len(secretKeys) == 0 {
+		return nil, NewTokenError(ErrInvalidKey, "no secret key")
+	}
+	var (
+		payload *Payload
+		err     error
+	)
+	for _, secretKey := range secretKeys {
+		payload, err = jwtToken.VerifyToken(secretKey, tokenStr)
+		var tokenErr *TokenError
+		// Only a signature of another key is worth the next key
+		if err == nil || !errors.As(err, &tokenErr) || !errors.Is(tokenErr.TypeError(), ErrTokenSignatureInvalid) {
+			break
+		}
+	}
+	return payload, err
+}
+
 // handleTokenError provides detailed error messages based on token errors.
 func (jwtToken JWTToken) handleTokenError(err error) error {
 	switch {
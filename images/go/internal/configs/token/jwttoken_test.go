@@ -185,3 +185,58 @@ func TestVerifyTokenHandlesExpiredToken(t *testing.T) {
 		t.Errorf("Expected ErrTokenMalformed, got %v", err)
 	}
 }
+
+// Rotated keys replace the loaded ones
+func TestSecretKey_Rotate(t *testing.T) {
+	secretKey := &token.SecretKey{AccessToken: "access", RefreshToken: "refresh", ForgotPasswordToken: "forgot"}
+	require.Equal(t, "access", secretKey.Access())
+
+	secretKey.Rotate(&token.SecretKey{AccessToken: "access-2", RefreshToken: "refresh-2", ForgotPasswordToken: "forgot-2"})
+	require.Equal(t, "access-2", secretKey.Access())
+	require.Equal(t, "refresh-2", secretKey.Refresh())
+	require.Equal(t, "forgot-2", secretKey.ForgotPassword())
+	require.Equal(t, "access", secretKey.AccessToken)
+	// without a grace window only the new keys verify
+	require.Equal(t, []string{"access-2"}, secretKey.AccessKeys())
+}
+
+// The replaced keys verify during the grace window, only the current ones sign
+func TestSecretKey_RotateGrace(t *testing.T) {
+	secretKey := &token.SecretKey{AccessToken: "access", RefreshToken: "refresh", ForgotPasswordToken: "forgot"}
+	require.Equal(t, []string{"access"}, secretKey.AccessKeys())
+
+	secretKey.Rotate(&token.SecretKey{AccessToken: "access-2", RefreshToken: "refresh-2", ForgotPasswordToken: "forgot", Grace: 60})
+	require.Equal(t, "access-2", secretKey.Access())
+	require.Equal(t, []string{"access-2", "access"}, secretKey.AccessKeys())
+	require.Equal(t, []string{"refresh-2", "refresh"}, secretKey.RefreshKeys())
+	// an unchanged key is listed once
+	require.Equal(t, []string{"forgot"}, secretKey.ForgotPasswordKeys())
+
+	// a second rotation keeps the keys it replaced only
+	secretKey.Rotate(&token.SecretKey{AccessToken: "access-3", RefreshToken: "refresh-3", ForgotPasswordToken: "forgot-3", Grace: 60})
+	require.Equal(t, []string{"access-3", "access-2"}, secretKey.AccessKeys())
+}
+
+// Verifies with the key whose signature matches, the other errors are not retried
+func TestJWTToken_VerifyTokenWithKeys(t *testing.T) {
+	jwtToken := &token.JWTToken{Name: "test"}
+	previous, current := "a_very_secret_previous_key_of_32_b", "a_very_secret_current_key_of_32_by"
+	id := ksuid.New()
+	signed, err := jwtToken.CreateToken(previous, jwtToken.CreatePayload(id, "user@example.com", time.Minute))
+	require.NoError(t, err)
+
+	payload, err := jwtToken.VerifyTokenWithKeys([]string{current, previous}, signed)
+	require.NoError(t, err)
+	require.Equal(t, id, payload.ID)
+
+	_, err = jwtToken.VerifyTokenWithKeys([]string{current}, signed)
+	var tokenErr *token.TokenError
+	require.ErrorAs(t, err, &tokenErr)
+	require.ErrorIs(t, tokenErr.TypeError(), token.ErrTokenSignatureInvalid)
+
+	expired, err := jwtToken.CreateToken(previous, jwtToken.CreatePayload(id, "user@example.com", -time.Minute))
+	require.NoError(t, err)
+	_, err = jwtToken.VerifyTokenWithKeys([]string{current, previous}, expired)
+	require.ErrorAs(t, err, &tokenErr)
+	require.ErrorIs(t, tokenErr.TypeError(), token.ErrTokenExpired)
+}
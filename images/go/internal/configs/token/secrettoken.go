@@ -1,11 +1,26 @@
 package token
 
-import "github.com/tirtahakimpambudhi/restful_api/internal/configs"
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+)
 
 type SecretKey struct {
 	AccessToken         string `env:"SECRET_KEY_ACCESS_TOKEN,required"`
 	RefreshToken        string `env:"SECRET_KEY_REFRESH_TOKEN,required"`
 	ForgotPasswordToken string `env:"SECRET_KEY_FP_TOKEN,required"`
+	Grace               int    `env:"SECRET_KEY_GRACE" envDefault:"604800"` // Seconds the replaced keys still verify after a rotation, the lifetime of the refresh tokens by default
+
+	rotated atomic.Pointer[rotation] // Keys replacing these ones, nil until rotated
+}
+
+// rotation holds the keys of the last rotation and the keys they replaced, which verify until the end of the grace window.
+type rotation struct {
+	current  *SecretKey
+	previous *SecretKey
+	until    time.Time
 }
 
 func NewSecretKey() (*SecretKey, error) {
@@ -15,3 +30,65 @@ func NewSecretKey() (*SecretKey, error) {
 	}
 	return &secretKey, nil
 }
+
+// Rotate replaces the keys returned by Access, Refresh and ForgotPassword, the tokens are signed with next from now on.
+// The replaced keys are still returned by AccessKeys, RefreshKeys and ForgotPasswordKeys for the Grace seconds of next,
+// so the tokens signed before the rotation verify until then.
+func (secretKey *SecretKey) Rotate(next *SecretKey) {
+	secretKey.rotated.Store(&rotation{
+		current:  next,
+		previous: secretKey.current(),
+		until:    time.Now().Add(time.Duration(next.Grace) * time.Second),
+	})
+}
+
+// Access returns the current key of the access tokens, the one signing them.
+func (secretKey *SecretKey) Access() string {
+	return secretKey.current().AccessToken
+}
+
+// Refresh returns the current key of the refresh tokens, the one signing them.
+func (secretKey *SecretKey) Refresh() string {
+	return secretKey.current().RefreshToken
+}
+
+// ForgotPassword returns the current key of the forgot password tokens, the one signing them.
+func (secretKey *SecretKey) ForgotPassword() string {
+	return secretKey.current().ForgotPasswordToken
+}
+
+// AccessKeys returns the keys verifying the access tokens, the current one first.
+func (secretKey *SecretKey) AccessKeys() []string {
+	return secretKey.verifying(func(keys *SecretKey) string { return keys.AccessToken })
+}
+
+// RefreshKeys returns the keys verifying the refresh tokens, the current one first.
+func (secretKey *SecretKey) RefreshKeys() []string {
+	return secretKey.verifying(func(keys *SecretKey) string { return keys.RefreshToken })
+}
+
+// ForgotPasswordKeys returns the keys verifying the forgot password tokens, the current one first.
+func (secretKey *SecretKey) ForgotPasswordKeys() []string {
+	return secretKey.verifying(func(keys *SecretKey) string { return keys.ForgotPasswordToken })
+}
+
+// current returns the latest keys.
+func (secretKey *SecretKey) current() *SecretKey {
+	if rotated := secretKey.rotated.Load(); rotated != nil {
+		return rotated.current
+	}
+	return secretKey
+}
+
+// verifying returns the key of the latest keys, followed by the one they replaced while in the grace window.
+func (secretKey *SecretKey) verifying(key func(keys *SecretKey) string) []string {
+	rotated := secretKey.rotated.Load()
+	if rotated == nil {
+		return []string{key(secretKey)}
+	}
+	keys := []string{key(rotated.current)}
+	if previous := key(rotated.previous); previous != keys[0] && time.Now().Before(rotated.until) {
+		keys = append(keys, previous)
+	}
+	return keys
+}
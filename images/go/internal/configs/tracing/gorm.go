@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormSpanKey is the instance key holding the span of a statement between its callbacks.
+const gormSpanKey = "tracing:span"
+
+// GormPlugin wraps every create, query, update, delete, row and raw statement in a span named gorm.<operation>,
+// holding the SQL without its variables, the table and the affected rows.
+type GormPlugin struct{}
+
+// Name implements gorm.Plugin.
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize implements gorm.Plugin, registering a callback before and after each operation.
+func (plugin GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", plugin.before("gorm.create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", plugin.after),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", plugin.before("gorm.query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", plugin.after),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", plugin.before("gorm.update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", plugin.after),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", plugin.before("gorm.delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", plugin.after),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", plugin.before("gorm.row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", plugin.after),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", plugin.before("gorm.raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", plugin.after),
+	)
+}
+
+// before starts the span of a statement, its context carries the span to the driver.
+func (GormPlugin) before(name string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		ctx, span := Start(db.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// after ends the span of a statement, failed when the statement failed with anything but a missing record.
+func (GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("db.system", db.Dialector.Name()),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		Fail(span, db.Error)
+	}
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisHook wraps every command and pipeline of a go-redis client in a span named after the command, like redis.get,
+// the arguments are left out as they hold the cached values.
+type RedisHook struct{}
+
+// DialHook implements redis.Hook, the connections are not traced.
+func (RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook implements redis.Hook.
+func (RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := Start(ctx, "redis."+cmd.Name(), trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+		span.SetAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", cmd.Name()))
+		err := next(ctx, cmd)
+		failRedis(span, err)
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook, a pipeline or transaction is a single span naming its commands.
+func (RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := Start(ctx, "redis.pipeline", trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+		span.SetAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", strings.Join(names, " ")), attribute.Int("db.redis.num_cmd", len(cmds)))
+		err := next(ctx, cmds)
+		failRedis(span, err)
+		return err
+	}
+}
+
+// failRedis marks the span failed on an error, a missing key is a regular answer.
+func failRedis(span trace.Span, err error) {
+	if err == nil || errors.Is(err, redis.Nil) {
+		return
+	}
+	Fail(span, err)
+}
@@ -0,0 +1,152 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Name names the tracer of the application.
+const Name = "github.com/tirtahakimpambudhi/restful_api"
+
+// Exporters holds the accepted values of TRACE_EXPORTER: none keeps the no-op tracer, the trace context is still propagated,
+// otlp sends the spans to an OpenTelemetry collector over OTLP/HTTP.
+var Exporters = []string{"none", "otlp"}
+
+// Config holds the settings of the tracing.
+type Config struct {
+	Exporter    string   `env:"TRACE_EXPORTER" envDefault:"none"`                  // Destination of the spans, see Exporters
+	Endpoint    string   `env:"TRACE_OTLP_ENDPOINT" envDefault:"localhost:4318"`   // Host and port of the OTLP/HTTP collector
+	Insecure    bool     `env:"TRACE_OTLP_INSECURE" envDefault:"false"`            // Sends the spans over plain HTTP
+	Headers     []string `env:"TRACE_OTLP_HEADERS" envSeparator:"," redact:"true"` // Headers of the export requests, as key=value pairs
+	ServiceName string   `env:"TRACE_SERVICE_NAME" envDefault:"restful_api"`       // service.name of the spans
+	SampleRatio float64  `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`                 // Share of the traces started here that are recorded, a sampled parent is always followed
+}
+
+// NewConfig initializes a new tracing Config by loading the configuration.
+func NewConfig() (*Config, error) {
+	var config Config
+	// Load configuration values into Config struct.
+	if err := configs.GetConfig().Load(&config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate reports an unknown exporter, a ratio outside of [0, 1] and a malformed header.
+func (config *Config) Validate() error {
+	var errs []error
+	if !slices.Contains(Exporters, config.Exporter) {
+		errs = append(errs, fmt.Errorf("TRACE_EXPORTER %q is not supported, use one of %v", config.Exporter, Exporters))
+	}
+	if config.SampleRatio < 0 || config.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("TRACE_SAMPLE_RATIO must be between 0 and 1, got %v", config.SampleRatio))
+	}
+	if config.Exporter == "otlp" && config.Endpoint == "" {
+		errs = append(errs, errors.New("TRACE_OTLP_ENDPOINT is required by the otlp exporter"))
+	}
+	if _, err := config.headers(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// headers parses the key=value pairs of TRACE_OTLP_HEADERS.
+func (config Config) headers() (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range config.Headers {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, errors.New("TRACE_OTLP_HEADERS must hold key=value pairs")
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// Setup installs the global tracer provider of the exporter and the W3C trace context and baggage propagators.
+// The returned function flushes the spans not exported yet and stops the provider.
+func Setup(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.Exporter != "otlp" {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return func(context.Context) error { return nil }, nil
+	}
+
+	headers, err := config.headers()
+	if err != nil {
+		return nil, err
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint), otlptracehttp.WithHeaders(headers)}
+	if config.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// InMemory installs a global tracer provider recording every span synchronously in the returned exporter, for the tests.
+func InMemory() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return exporter
+}
+
+// spanKey is the type of SpanKey.
+type spanKey struct{}
+
+// SpanKey is the key the span of a request is stored under in the fasthttp user values,
+// the fasthttp context handed to the use cases does not carry it otherwise.
+var SpanKey = spanKey{}
+
+// Start starts a span named name, child of the span carried by ctx or else of the span of the request.
+func Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if span, ok := ctx.Value(SpanKey).(trace.Span); ok {
+			ctx = trace.ContextWithSpan(ctx, span)
+		}
+	}
+	return otel.Tracer(Name).Start(ctx, name, options...)
+}
+
+// SpanContext returns the span context carried by ctx or else the one of the span of the request,
+// for the work outliving the request to continue its trace.
+func SpanContext(ctx context.Context) trace.SpanContext {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		return spanContext
+	}
+	if span, ok := ctx.Value(SpanKey).(trace.Span); ok {
+		return span.SpanContext()
+	}
+	return trace.SpanContext{}
+}
+
+// Fail records err on the span and marks it failed.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
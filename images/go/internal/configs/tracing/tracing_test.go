@@ -0,0 +1,134 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http/middleware"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// find returns the ended span named name.
+func find(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no span named %q in %v", name, exporter.GetSpans())
+	return tracetest.SpanStub{}
+}
+
+// attributeOf returns the value of the attribute key of a span.
+func attributeOf(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// Continues the trace of the traceparent header and parents the spans started from ctx.Context()
+func TestTracing_Middleware(t *testing.T) {
+	exporter := tracing.InMemory()
+	handler, err := middleware.Tracing()
+	require.NoError(t, err)
+	app := fiber.New()
+	app.Use(handler)
+	app.Get("/users/:id", func(ctx *fiber.Ctx) error {
+		_, span := tracing.Start(ctx.Context(), "UsersUsecase.Get")
+		span.End()
+		return ctx.SendStatus(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	res, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+	server := find(t, exporter, "GET /users/:id")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+	assert.Equal(t, codes.Error, server.Status.Code)
+	assert.Equal(t, int64(500), attributeOf(server, "http.response.status_code").AsInt64())
+	usecase := find(t, exporter, "UsersUsecase.Get")
+	assert.Equal(t, server.SpanContext.SpanID(), usecase.Parent.SpanID())
+}
+
+// Wraps the statements in spans holding their SQL
+func TestGormPlugin(t *testing.T) {
+	exporter := tracing.InMemory()
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 1, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+
+	ctx, parent := tracing.Start(context.Background(), "UsersUsecase.List")
+	require.NoError(t, db.WithContext(ctx).Exec("CREATE TABLE users (id TEXT)").Error)
+	var count int64
+	require.NoError(t, db.WithContext(ctx).Table("users").Count(&count).Error)
+	require.Error(t, db.WithContext(ctx).Exec("SELECT * FROM missing").Error)
+	parent.End()
+
+	query := find(t, exporter, "gorm.query")
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent.SpanID())
+	assert.Contains(t, attributeOf(query, "db.statement").AsString(), "SELECT count(*) FROM `users`")
+	var failed int
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "gorm.raw" && span.Status.Code == codes.Error {
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+}
+
+// Wraps the commands and pipelines in spans, a missing key is not a failure
+func TestRedisHook(t *testing.T) {
+	exporter := tracing.InMemory()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	client.AddHook(tracing.RedisHook{})
+	defer client.Close()
+
+	ctx := context.Background()
+	require.NoError(t, client.Set(ctx, "key", "value", 0).Err())
+	require.ErrorIs(t, client.Get(ctx, "missing").Err(), redis.Nil)
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, "key")
+		pipe.SAdd(ctx, "tag", "key")
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "set", attributeOf(find(t, exporter, "redis.set"), "db.operation").AsString())
+	assert.Equal(t, codes.Unset, find(t, exporter, "redis.get").Status.Code)
+	assert.Equal(t, "del sadd", attributeOf(find(t, exporter, "redis.pipeline"), "db.operation").AsString())
+}
+
+// Reports an unknown exporter, a ratio out of range and malformed headers
+func TestConfig_Validate(t *testing.T) {
+	config := &tracing.Config{Exporter: "otlp", Endpoint: "collector:4318", SampleRatio: 0.5, Headers: []string{"Authorization=Bearer token"}}
+	require.NoError(t, config.Validate())
+
+	config = &tracing.Config{Exporter: "jaeger", SampleRatio: 2, Headers: []string{"Authorization"}}
+	err := config.Validate()
+	require.ErrorContains(t, err, "TRACE_EXPORTER")
+	require.ErrorContains(t, err, "TRACE_SAMPLE_RATIO")
+	require.ErrorContains(t, err, "TRACE_OTLP_HEADERS")
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+)
+
+// binary is the name of the built binary.
+const binary = "main"
+
+// ErrUsage is returned when the arguments of a command are invalid.
+var ErrUsage = errors.New("invalid arguments")
+
+// command is a subcommand of the binary, either running something or grouping other commands.
+type command struct {
+	name        string
+	summary     string
+	run         func(ctx context.Context, cli *CLI, args []string) error
+	subcommands []*command
+}
+
+// CLI runs the subcommands of the binary.
+type CLI struct {
+	In        io.Reader                      // Input of the commands reading a secret
+	Out       io.Writer                      // Output of the commands
+	Bootstrap func() (*bootstrap.App, error) // Loads the application, bootstrap.New by default
+	commands  []*command
+}
+
+// New creates a CLI reading from in and writing to out.
+func New(in io.Reader, out io.Writer) *CLI {
+	cli := &CLI{In: in, Out: out, Bootstrap: bootstrap.New}
+	cli.commands = []*command{
+		{name: "serve", summary: "start the HTTP server and the background jobs (default)", run: serve},
+		{name: "migrate", summary: "apply or revert the embedded migrations", run: migrate},
+		{name: "seed", summary: "add the missing rules of the policy file and the entries of a fixture", run: seedFixture},
+		{name: "user", summary: "manage users", subcommands: []*command{
+			{name: "create-admin", summary: "create a user, or reuse an existing one, with the admin role", run: createAdmin},
+		}},
+		{name: "policy", summary: "manage the Casbin policy", subcommands: []*command{
+			{name: "sync", summary: "make the stored policy match the policy file", run: syncPolicy},
+		}},
+		{name: "config", summary: "check the configuration", subcommands: []*command{
+			{name: "validate", summary: "load every setting and report all the errors", run: validateConfig},
+			{name: "dump", summary: "print every setting with its source, secrets redacted", run: dumpConfig},
+		}},
+		{name: "token", summary: "debug tokens", subcommands: []*command{
+			{name: "inspect", summary: "verify a token and print its payload", run: inspectToken},
+		}},
+	}
+	return cli
+}
+
+// Run parses the global flags, then runs the command named by the remaining arguments, serve when there is none.
+func (cli *CLI) Run(ctx context.Context, args []string) error {
+	flags := cli.flagSet(binary)
+	envFile := flags.String("env-file", configs.ConfigFile, "file holding the environment variables, the process environment takes precedence")
+	yamlFile := flags.String("config", configs.YAMLFile, "optional YAML file holding the settings below the env file")
+	logLevel := flags.String("log-level", "", "minimum level of the application logs ("+strings.Join(loggerconfig.Levels, ", ")+"), LOG_LEVEL by default")
+	var settings []string
+	flags.Func("set", "set a setting as KEY=VALUE above every other source, repeatable", func(setting string) error {
+		if key, _, ok := strings.Cut(setting, "="); !ok || key == "" {
+			return fmt.Errorf("%q is not KEY=VALUE", setting)
+		}
+		settings = append(settings, setting)
+		return nil
+	})
+	flags.Usage = func() {
+		fmt.Fprintln(cli.Out, "usage: "+binary+" [flags] <command>\n\nflags:")
+		flags.PrintDefaults()
+		cli.printCommands("", cli.commands)
+	}
+	if err := flags.Parse(args); err != nil {
+		return usageError(err)
+	}
+
+	configs.ConfigFile = *envFile
+	configs.YAMLFile = *yamlFile
+	if *logLevel != "" {
+		if !slices.Contains(loggerconfig.Levels, *logLevel) {
+			return fmt.Errorf("%w: unknown log level %q", ErrUsage, *logLevel)
+		}
+		settings = append(settings, "LOG_LEVEL="+*logLevel)
+	}
+	// the flags take precedence over the other sources
+	for _, setting := range settings {
+		key, value, _ := strings.Cut(setting, "=")
+		if err := configs.Set(key, value); err != nil {
+			return err
+		}
+	}
+
+	args = flags.Args()
+	if len(args) == 0 {
+		return serve(ctx, cli, nil)
+	}
+	return cli.dispatch(ctx, "", cli.commands, args)
+}
+
+// dispatch runs the command named by the first argument among commands.
+func (cli *CLI) dispatch(ctx context.Context, parent string, commands []*command, args []string) error {
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		cli.printCommands(parent, commands)
+		return nil
+	}
+	for _, command := range commands {
+		if command.name != name {
+			continue
+		}
+		if command.run != nil {
+			return command.run(ctx, cli, args[1:])
+		}
+		if len(args) == 1 {
+			cli.printCommands(parent+name+" ", command.subcommands)
+			return fmt.Errorf("%w: %s expects a command", ErrUsage, strings.TrimSpace(parent+name))
+		}
+		return cli.dispatch(ctx, parent+name+" ", command.subcommands, args[1:])
+	}
+	return fmt.Errorf("%w: unknown command %q", ErrUsage, strings.TrimSpace(parent+name))
+}
+
+// printCommands lists the commands with their summary.
+func (cli *CLI) printCommands(parent string, commands []*command) {
+	fmt.Fprintln(cli.Out, "\ncommands:")
+	for _, command := range commands {
+		if command.run != nil {
+			fmt.Fprintf(cli.Out, "  %-22s %s\n", parent+command.name, command.summary)
+		}
+		for _, subcommand := range command.subcommands {
+			fmt.Fprintf(cli.Out, "  %-22s %s\n", parent+command.name+" "+subcommand.name, subcommand.summary)
+		}
+	}
+}
+
+// flagSet creates the flags of a command, reporting parse errors instead of exiting.
+func (cli *CLI) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(cli.Out)
+	return flags
+}
+
+// parse parses the flags of a command expecting the number of positional arguments, given after the flags.
+func parse(flags *flag.FlagSet, args []string, positional int) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != positional {
+		return fmt.Errorf("%s expects %d arguments, got %q", flags.Name(), positional, flags.Args())
+	}
+	return nil
+}
+
+// usageError wraps a flag parse error in ErrUsage, the help requested by -h is not an error.
+func usageError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrUsage, err)
+}
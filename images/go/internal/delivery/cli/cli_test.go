@@ -0,0 +1,281 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/seed"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/cli"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+)
+
+// newApp bootstraps an application on an in-memory SQLite database with the migrations applied.
+func newApp(t *testing.T) *bootstrap.App {
+	t.Helper()
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 10, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migration.New(sqlDB, sqlconfig.SQLite, &log.DefaultLogger)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	require.NoError(t, err)
+	enforcer, err := casbin.NewEnforcer("../../../resource/model/rbac_model.conf", adapter)
+	require.NoError(t, err)
+	argon2, err := hash.NewHashArgon2()
+	require.NoError(t, err)
+	return &bootstrap.App{
+		Gorm:           db,
+		CasbinEnforcer: enforcer,
+		Hash:           argon2,
+		Logger:         &loggerconfig.Logger{App: &log.DefaultLogger},
+		SQL:            config,
+	}
+}
+
+// newCLI creates a CLI bootstrapping app, reading in and writing to the returned buffer.
+func newCLI(app *bootstrap.App, in string) (*cli.CLI, *bytes.Buffer) {
+	var out bytes.Buffer
+	command := cli.New(strings.NewReader(in), &out)
+	command.Bootstrap = func() (*bootstrap.App, error) {
+		if app == nil {
+			return nil, errors.New("no application")
+		}
+		return app, nil
+	}
+	return command, &out
+}
+
+// writePolicy writes a policy file and returns its path.
+func writePolicy(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestCLI_Usage(t *testing.T) {
+	ctx := context.Background()
+	for _, args := range [][]string{
+		{"sideways"},
+		{"user"},
+		{"user", "delete"},
+		{"-log-level", "loud", "config", "validate"},
+		{"token", "inspect"},
+		{"user", "create-admin", "-email", "budi@gmail.com"},
+		{"config", "validate", "extra"},
+	} {
+		command, _ := newCLI(nil, "")
+		require.ErrorIs(t, command.Run(ctx, args), cli.ErrUsage, args)
+	}
+
+	command, out := newCLI(nil, "")
+	require.NoError(t, command.Run(ctx, []string{"help"}))
+	require.Contains(t, out.String(), "policy sync")
+	require.Contains(t, out.String(), "token inspect")
+
+	// -h prints the flags of the command without running it
+	command, out = newCLI(nil, "")
+	require.NoError(t, command.Run(ctx, []string{"user", "create-admin", "-h"}))
+	require.Contains(t, out.String(), "-email")
+}
+
+func TestCLI_Policy(t *testing.T) {
+	ctx := context.Background()
+	app := newApp(t)
+	// the migrations already hold the rules of the policy file
+	command, out := newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"seed", "-file", "../../../resource/policy/policy.csv"}))
+	require.Equal(t, "added 0 rules\n", out.String())
+
+	path := writePolicy(t, `# roles of the API
+p, admin, users, read
+p, auditor, audit, read
+
+g, budi@gmail.com, auditor
+`)
+	command, out = newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"seed", "-file", path}))
+	require.Equal(t, "+ p, auditor, audit, read\n+ g, budi@gmail.com, auditor\nadded 2 rules\n", out.String())
+	authorized, err := app.CasbinEnforcer.Enforce("budi@gmail.com", "audit", "read")
+	require.NoError(t, err)
+	require.True(t, authorized)
+
+	// a dry run changes nothing
+	command, out = newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"policy", "sync", "-file", path, "-prune", "-dry-run"}))
+	require.Contains(t, out.String(), "- p, admin, users, create\n")
+	require.Contains(t, out.String(), "would add 0 rules and remove 7 rules\n")
+	authorized, err = app.CasbinEnforcer.Enforce("admin", "users", "create")
+	require.NoError(t, err)
+	require.True(t, authorized)
+
+	// pruning keeps the role assignments
+	command, out = newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"policy", "sync", "-file", path, "-prune"}))
+	require.Contains(t, out.String(), "added 0 rules and removed 7 rules\n")
+	policies, err := app.CasbinEnforcer.GetPolicy()
+	require.NoError(t, err)
+	require.ElementsMatch(t, [][]string{{"admin", "users", "read"}, {"auditor", "audit", "read"}}, policies)
+	roles, err := app.CasbinEnforcer.GetRolesForUser("tirtanewwhakim22@gmail.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{"admin"}, roles)
+
+	command, _ = newCLI(app, "")
+	require.ErrorContains(t, command.Run(ctx, []string{"seed", "-file", writePolicy(t, "x, admin, users, read\n")}), `unknown policy type "x"`)
+}
+
+func TestCLI_Seed(t *testing.T) {
+	ctx := context.Background()
+	app := newApp(t)
+	policy := "../../../resource/policy/policy.csv"
+
+	command, out := newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"seed", "-file", policy, "-profile", "e2e"}))
+	require.Equal(t, "added 0 rules\nseeded 3 users, 2 roles and 0 policies\n", out.String())
+	authorized, err := app.CasbinEnforcer.Enforce("admin@e2e.local", "users", "delete")
+	require.NoError(t, err)
+	require.True(t, authorized)
+
+	command, out = newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"seed", "-file", policy, "-profile", "e2e"}))
+	require.Equal(t, "added 0 rules\nseeded 0 users, 0 roles and 0 policies\n", out.String())
+
+	// an invalid fixture is rejected before anything is saved
+	command, _ = newCLI(nil, "")
+	require.ErrorIs(t, command.Run(ctx, []string{"seed", "-file", policy, "-profile", "production"}), seed.ErrUnknownProfile)
+	command, _ = newCLI(nil, "")
+	require.ErrorIs(t, command.Run(ctx, []string{"seed", "-profile", "dev", "-fixtures", "fixture.yaml"}), cli.ErrUsage)
+}
+
+func TestCLI_CreateAdmin(t *testing.T) {
+	ctx := context.Background()
+	app := newApp(t)
+
+	// the password is read from the input
+	command, out := newCLI(app, "secret-password\n")
+	require.NoError(t, command.Run(ctx, []string{"user", "create-admin", "-email", "budi@gmail.com", "-username", "budi santoso"}))
+	require.Equal(t, "password: created user budi@gmail.com\ngave the role admin to user budi@gmail.com\n", out.String())
+
+	var users entity.Users
+	require.NoError(t, app.Gorm.Where("email = ?", "budi@gmail.com").First(&users).Error)
+	match, err := app.Hash.Match("secret-password", users.Password)
+	require.NoError(t, err)
+	require.True(t, match)
+	authorized, err := app.CasbinEnforcer.Enforce("budi@gmail.com", "users", "delete")
+	require.NoError(t, err)
+	require.True(t, authorized)
+	var events []entity.AuditEvents
+	require.NoError(t, app.Gorm.Order("action").Find(&events).Error)
+	require.Len(t, events, 2)
+	require.Equal(t, entity.AuditRoleChange, events[0].Action)
+	require.Equal(t, `{"roles":{"from":[],"to":["admin"]}}`, events[0].Changes)
+	require.Equal(t, users.ID, events[0].TargetID)
+	require.Equal(t, entity.AuditUserCreate, events[1].Action)
+	require.Equal(t, "cli", events[1].ActorID)
+	require.Equal(t, users.ID, events[1].TargetID)
+
+	// running it again changes nothing
+	command, out = newCLI(app, "")
+	require.NoError(t, command.Run(ctx, []string{"user", "create-admin", "-email", "budi@gmail.com", "-username", "budi santoso"}))
+	require.Equal(t, "user budi@gmail.com already exists\nuser budi@gmail.com already has the role admin\n", out.String())
+
+	command, _ = newCLI(app, "")
+	require.ErrorIs(t, command.Run(ctx, []string{"user", "create-admin", "-email", "not-an-email", "-username", "budi santoso", "-password", "secret-password"}), cli.ErrUsage)
+}
+
+func TestCLI_TokenInspect(t *testing.T) {
+	t.Setenv("TOKEN_NAME", "restful_api")
+	t.Setenv("SECRET_KEY_ACCESS_TOKEN", strings.Repeat("a", tokenconfig.MinSecretKeySize))
+	t.Setenv("SECRET_KEY_REFRESH_TOKEN", strings.Repeat("r", tokenconfig.MinSecretKeySize))
+	t.Setenv("SECRET_KEY_FP_TOKEN", strings.Repeat("f", tokenconfig.MinSecretKeySize))
+	ctx := context.Background()
+	jwtToken, secretKey, err := tokenconfig.NewJWTToken()
+	require.NoError(t, err)
+	id := ksuid.New()
+	token, err := jwtToken.CreateToken(secretKey.RefreshToken, jwtToken.CreatePayload(id, "budi@gmail.com", time.Hour))
+	require.NoError(t, err)
+
+	command, out := newCLI(nil, token+"\n")
+	require.NoError(t, command.Run(ctx, []string{"token", "inspect", "-type", "refresh", "-"}))
+	require.Contains(t, out.String(), `"id": "`+id.String()+`"`)
+	require.Contains(t, out.String(), `"email": "budi@gmail.com"`)
+
+	// an access token is signed with another key
+	command, _ = newCLI(nil, "")
+	require.Error(t, command.Run(ctx, []string{"token", "inspect", token}))
+	command, _ = newCLI(nil, "")
+	require.ErrorIs(t, command.Run(ctx, []string{"token", "inspect", "-type", "session", token}), cli.ErrUsage)
+}
+
+func TestCLI_ConfigValidate(t *testing.T) {
+	t.Setenv("DB_DRIVER", "oracle")
+	t.Setenv("TOKEN_NAME", "restful_api")
+	t.Setenv("SECRET_KEY_ACCESS_TOKEN", "short")
+	t.Setenv("SECRET_KEY_REFRESH_TOKEN", "short")
+	t.Setenv("SECRET_KEY_FP_TOKEN", "short")
+
+	command, _ := newCLI(nil, "")
+	err := command.Run(context.Background(), []string{"config", "validate"})
+	// every invalid setting is reported at once
+	require.ErrorContains(t, err, "sql:")
+	require.ErrorContains(t, err, "token:")
+	// the metrics are enabled by default, only preforking needs them disabled
+	require.NotContains(t, err.Error(), "metrics:")
+	t.Setenv("FIBER_PREFORK", "true")
+	command, _ = newCLI(nil, "")
+	require.ErrorContains(t, command.Run(context.Background(), []string{"config", "validate"}), "METRICS_ENABLED requires FIBER_PREFORK=false")
+}
+
+func TestCLI_ConfigDump(t *testing.T) {
+	t.Setenv("DB_DRIVER", "oracle")
+	t.Setenv("DB_PASS", "database-password")
+	t.Setenv("SECRET_KEY_ACCESS_TOKEN", "access-secret")
+	t.Cleanup(func() { os.Unsetenv("DB_USER") })
+	ctx := context.Background()
+
+	// the settings are printed even when invalid, secrets redacted
+	command, out := newCLI(nil, "")
+	err := command.Run(ctx, []string{"-set", "DB_USER=budi", "config", "dump"})
+	require.ErrorContains(t, err, "sql:")
+	require.Regexp(t, `DB_USER\s+budi\s+flag\n`, out.String())
+	require.Regexp(t, `DB_PASS\s+\*{6}\s+env\n`, out.String())
+	require.Regexp(t, `CACHE_TIMEOUT\s+\d+\s+`, out.String())
+	require.NotContains(t, out.String(), "database-password")
+	require.NotContains(t, out.String(), "access-secret")
+
+	command, out = newCLI(nil, "")
+	require.Error(t, command.Run(ctx, []string{"config", "dump", "-json"}))
+	var settings []configs.Setting
+	require.NoError(t, json.Unmarshal(out.Bytes(), &settings))
+	require.Contains(t, settings, configs.Setting{Key: "SECRET_KEY_ACCESS_TOKEN", Value: "******", Source: configs.SourceEnv})
+
+	command, _ = newCLI(nil, "")
+	require.ErrorIs(t, command.Run(ctx, []string{"-set", "DB_USER", "config", "dump"}), cli.ErrUsage)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+)
+
+// validateConfig loads every setting without connecting to any service, reporting all the errors together.
+func validateConfig(ctx context.Context, cli *CLI, args []string) error {
+	flags := cli.flagSet("config validate")
+	if err := parse(flags, args, 0); err != nil {
+		return usageError(err)
+	}
+	if err := bootstrap.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	fmt.Fprintln(cli.Out, "configuration is valid")
+	return nil
+}
+
+// dumpConfig prints every setting with the source it is read from, the secrets redacted.
+// The settings are printed even when invalid, the validation errors are returned afterwards.
+func dumpConfig(ctx context.Context, cli *CLI, args []string) error {
+	flags := cli.flagSet("config dump")
+	asJSON := flags.Bool("json", false, "print the settings as JSON")
+	if err := parse(flags, args, 0); err != nil {
+		return usageError(err)
+	}
+	settings, validationErr := bootstrap.Dump()
+	if *asJSON {
+		encoder := json.NewEncoder(cli.Out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(settings); err != nil {
+			return err
+		}
+	} else {
+		writer := tabwriter.NewWriter(cli.Out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE")
+		for _, setting := range settings {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+	if validationErr != nil {
+		return fmt.Errorf("invalid configuration:\n%w", validationErr)
+	}
+	return nil
+}
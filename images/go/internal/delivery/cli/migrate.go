@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+)
+
+// MigrateUsage describes the arguments of the migrate subcommand.
+const MigrateUsage = `usage: migrate <command>
+
+commands:
+  up             apply every pending migration
+  down [N|all]   revert the last N applied migrations, 1 by default
+  status         list the migrations and whether they are applied
+  version        print the current version
+  force VERSION  set the version without running any migration, 0 removes it`
+
+// migrate connects to the database of SqlConfig and runs the migrate command with its arguments.
+// It does not bootstrap the application, which needs the schema the migrations create.
+func migrate(ctx context.Context, cli *CLI, args []string) error {
+	logger, err := loggerconfig.NewLogger()
+	if err != nil {
+		return err
+	}
+	sqlConfig, err := sqlconfig.NewConfig()
+	if err != nil {
+		return err
+	}
+	gormDB, err := orm.NewGorm()
+	if err != nil {
+		return err
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migration.New(sqlDB, sqlConfig.Driver, logger.App)
+	if err != nil {
+		return err
+	}
+	return RunMigrate(ctx, migrator.WithLockTimeout(sqlConfig.MigrateLockWait()), args, cli.Out)
+}
+
+// RunMigrate runs the migrate subcommand with the migrator, writing its result to out.
+func RunMigrate(ctx context.Context, migrator *migration.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w\n%s", ErrUsage, MigrateUsage)
+	}
+
+	switch command, rest := args[0], args[1:]; command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if len(rest) > 0 {
+			if rest[0] == "all" {
+				steps = 0
+			} else if n, err := strconv.Atoi(rest[0]); err == nil && n > 0 {
+				steps = n
+			} else {
+				return fmt.Errorf("%w: down expects a positive number or all, got %q", ErrUsage, rest[0])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reverted %d migrations\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tNAME\tSTATUS")
+		for _, status := range statuses {
+			state := "pending"
+			if status.Dirty {
+				state = "dirty"
+			} else if status.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\n", status.Version, status.Name, state)
+		}
+		return writer.Flush()
+	case "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case version == 0:
+			fmt.Fprintln(out, "no migration applied")
+		case dirty:
+			fmt.Fprintf(out, "%d (dirty)\n", version)
+		default:
+			fmt.Fprintln(out, version)
+		}
+	case "force":
+		if len(rest) != 1 {
+			return fmt.Errorf("%w: force expects a version", ErrUsage)
+		}
+		version, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("%w: force expects a version, got %q", ErrUsage, rest[0])
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "forced version %d\n", version)
+	default:
+		return fmt.Errorf("%w: unknown command %q\n%s", ErrUsage, command, MigrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/cli"
+)
+
+func TestRunMigrate(t *testing.T) {
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 10, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	defer sqlDB.Close()
+	migrator, err := migration.New(sqlDB, sqlconfig.SQLite, &log.DefaultLogger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := cli.RunMigrate(ctx, migrator, args, &out)
+		return out.String(), err
+	}
+
+	out, err := run("version")
+	require.NoError(t, err)
+	require.Equal(t, "no migration applied\n", out)
+
+	out, err = run("up")
+	require.NoError(t, err)
+	require.Equal(t, "applied 6 migrations\n", out)
+
+	out, err = run("down")
+	require.NoError(t, err)
+	require.Equal(t, "reverted 1 migrations\n", out)
+
+	out, err = run("status")
+	require.NoError(t, err)
+	require.Contains(t, out, "5        add_users_version          applied\n")
+	require.Contains(t, out, "6        drop_audit_events_emails   pending\n")
+
+	out, err = run("force", "3")
+	require.NoError(t, err)
+	require.Equal(t, "forced version 3\n", out)
+	out, err = run("version")
+	require.NoError(t, err)
+	require.Equal(t, "3\n", out)
+
+	for _, args := range [][]string{{}, {"sideways"}, {"down", "-1"}, {"force"}, {"force", "three"}} {
+		_, err := run(args...)
+		require.ErrorIs(t, err, cli.ErrUsage, args)
+	}
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	casbinconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/casbin"
+)
+
+// policyFile holds the rules of a policy file, in the CSV format of Casbin.
+type policyFile struct {
+	policies [][]string // p rules, subject, object and action
+	groups   [][]string // g rules, user and role
+}
+
+// readPolicyFile reads the policy file at path, skipping blank lines and # comments.
+func readPolicyFile(path string) (*policyFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	policy := &policyFile{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return policy, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		line, _ := reader.FieldPos(0)
+		switch ptype, rule := strings.TrimSpace(record[0]), record[1:]; ptype {
+		case "p":
+			policy.policies = append(policy.policies, rule)
+		case "g":
+			policy.groups = append(policy.groups, rule)
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown policy type %q", path, line, ptype)
+		}
+	}
+}
+
+// policyFlags creates the flags of a command reading a policy file, -file defaults to the policy file of the Casbin configuration.
+func policyFlags(cli *CLI, name string) (*flag.FlagSet, *string, error) {
+	config, err := casbinconfig.NewConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	flags := cli.flagSet(name)
+	return flags, flags.String("file", config.PolicyFile(), "policy file to read"), nil
+}
+
+// addMissing adds the rules of the policy file missing from the enforcer, printing each of them, and returns how many there are.
+// Nothing is saved on a dry run.
+func addMissing(cli *CLI, enforcer *casbin.Enforcer, policy *policyFile, dryRun bool) (int, error) {
+	var policies, groups [][]string
+	for _, rule := range policy.policies {
+		exist, err := enforcer.HasPolicy(rule)
+		if err != nil {
+			return 0, err
+		}
+		if !exist && !containsRule(policies, rule) {
+			fmt.Fprintf(cli.Out, "+ p, %s\n", strings.Join(rule, ", "))
+			policies = append(policies, rule)
+		}
+	}
+	for _, rule := range policy.groups {
+		exist, err := enforcer.HasGroupingPolicy(rule)
+		if err != nil {
+			return 0, err
+		}
+		if !exist && !containsRule(groups, rule) {
+			fmt.Fprintf(cli.Out, "+ g, %s\n", strings.Join(rule, ", "))
+			groups = append(groups, rule)
+		}
+	}
+	if dryRun {
+		return len(policies) + len(groups), nil
+	}
+	if len(policies) > 0 {
+		if _, err := enforcer.AddPolicies(policies); err != nil {
+			return 0, err
+		}
+	}
+	if len(groups) > 0 {
+		if _, err := enforcer.AddGroupingPolicies(groups); err != nil {
+			return 0, err
+		}
+	}
+	return len(policies) + len(groups), nil
+}
+
+// containsRule tells whether rules holds the rule.
+func containsRule(rules [][]string, rule []string) bool {
+	return slices.ContainsFunc(rules, func(other []string) bool { return slices.Equal(other, rule) })
+}
+
+// syncPolicy makes the stored policy match the policy file. The role assignments of users are
+// given through the API and kept, -prune only removes the p rules missing from the file.
+func syncPolicy(ctx context.Context, cli *CLI, args []string) error {
+	flags, file, err := policyFlags(cli, "policy sync")
+	if err != nil {
+		return err
+	}
+	prune := flags.Bool("prune", false, "remove the stored p rules missing from the file")
+	dryRun := flags.Bool("dry-run", false, "print the changes without saving them")
+	if err := parse(flags, args, 0); err != nil {
+		return usageError(err)
+	}
+	policy, err := readPolicyFile(*file)
+	if err != nil {
+		return err
+	}
+
+	app, err := cli.Bootstrap()
+	if err != nil {
+		return err
+	}
+	defer app.Logger.Close()
+	added, err := addMissing(cli, app.CasbinEnforcer, policy, *dryRun)
+	if err != nil {
+		return err
+	}
+	removed := 0
+	if *prune {
+		stored, err := app.CasbinEnforcer.GetPolicy()
+		if err != nil {
+			return err
+		}
+		var stale [][]string
+		for _, rule := range stored {
+			if !containsRule(policy.policies, rule) {
+				fmt.Fprintf(cli.Out, "- p, %s\n", strings.Join(rule, ", "))
+				stale = append(stale, rule)
+			}
+		}
+		if len(stale) > 0 && !*dryRun {
+			if _, err := app.CasbinEnforcer.RemovePolicies(stale); err != nil {
+				return err
+			}
+		}
+		removed = len(stale)
+	}
+
+	if *dryRun {
+		fmt.Fprintf(cli.Out, "would add %d rules and remove %d rules\n", added, removed)
+		return nil
+	}
+	fmt.Fprintf(cli.Out, "added %d rules and removed %d rules\n", added, removed)
+	return nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/reload"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/security"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http/middleware"
+)
+
+// newReloader creates the Reloader of the settings changing while serving: the CORS and limiter middleware, the log levels and the timeouts.
+// Every other setting, like the addresses and the secrets, is read once on startup.
+func newReloader(app *bootstrap.App) (*reload.Reloader, error) {
+	keys := []string{"LOG_LEVEL", "LOG_ACCESS_LEVEL"}
+	for _, setting := range configs.GetConfig().Dump(&security.Cors{}, &security.Limiter{}, &timeout.Minutes{}) {
+		keys = append(keys, setting.Key)
+	}
+	return reload.NewReloader(func() error {
+		var (
+			corsConf    security.Cors
+			limiterConf security.Limiter
+			loggerConf  loggerconfig.LoggerConfig
+			minutes     timeout.Minutes
+		)
+		// Every setting is checked before any is swapped
+		if err := configs.GetConfig().Load(&corsConf, &limiterConf, &loggerConf, &minutes); err != nil {
+			return err
+		}
+		middleware.ReloadCORS(&corsConf)
+		middleware.ReloadLimiter(&limiterConf)
+		app.Logger.App.SetLevel(log.ParseLevel(loggerConf.Level))
+		app.Logger.Access.SetLevel(log.ParseLevel(loggerConf.AccessLevel))
+		app.Timeout.Store(minutes.Config())
+		return nil
+	}, app.Logger.App, keys...)
+}
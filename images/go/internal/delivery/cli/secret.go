@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"slices"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
+)
+
+// rotateSecrets returns the function applying the secrets rotated while serving. The token keys are swapped in at once
+// and the CSRF middleware reads its key on every request, the database and cache passwords apply on the next restart.
+func rotateSecrets(app *bootstrap.App) func(keys []string) error {
+	return func(keys []string) error {
+		for _, key := range []string{"DB_PASS", "CACHE_DB_PASS"} {
+			if slices.Contains(keys, key) {
+				app.Logger.App.Warn().Str("key", key).Msg("Rotated secret applies to the connections on the next restart")
+			}
+		}
+		// The keys are checked together, a rotation leaving one too short keeps the previous ones
+		_, secretKey, err := tokenconfig.NewJWTToken()
+		if err != nil {
+			return err
+		}
+		app.Secret.Rotate(secretKey)
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/seed"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+// seedFixture adds the rules of the policy file missing from the stored policy, then the entries of the fixture
+// of -profile or -fixtures missing from the database. Nothing stored is ever removed or overwritten.
+func seedFixture(ctx context.Context, cli *CLI, args []string) error {
+	flags, file, err := policyFlags(cli, "seed")
+	if err != nil {
+		return err
+	}
+	profile := flags.String("profile", "", "embedded fixture to seed ("+strings.Join(seed.Profiles, ", ")+")")
+	fixtures := flags.String("fixtures", "", "fixture file to seed, in YAML or JSON")
+	if err := parse(flags, args, 0); err != nil {
+		return usageError(err)
+	}
+	if *profile != "" && *fixtures != "" {
+		return fmt.Errorf("%w: seed expects either -profile or -fixtures", ErrUsage)
+	}
+	policy, err := readPolicyFile(*file)
+	if err != nil {
+		return err
+	}
+	// The fixture is validated before anything is saved
+	var fixture *seed.Fixture
+	switch {
+	case *profile != "":
+		fixture, err = seed.Load(*profile)
+	case *fixtures != "":
+		fixture, err = seed.Read(*fixtures)
+	}
+	if err != nil {
+		return err
+	}
+
+	app, err := cli.Bootstrap()
+	if err != nil {
+		return err
+	}
+	defer app.Logger.Close()
+	added, err := addMissing(cli, app.CasbinEnforcer, policy, false)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.Out, "added %d rules\n", added)
+	if fixture == nil {
+		return nil
+	}
+
+	seeder, err := newSeeder(app)
+	if err != nil {
+		return err
+	}
+	result, err := seeder.Seed(ctx, fixture)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.Out, "seeded %d users, %d roles and %d policies\n", result.Users, result.Roles, result.Policies)
+	return nil
+}
+
+// newSeeder creates a Seeder on the database and the enforcer of app, recording the CLI as the actor.
+func newSeeder(app *bootstrap.App) (*seed.Seeder, error) {
+	usersRepository, err := repository.NewUsersRepositoryImpl(app.Gorm, app.Logger.App)
+	if err != nil {
+		return nil, err
+	}
+	auditRepository, err := repository.NewAuditRepositoryImpl(app.Gorm, app.Logger.App)
+	if err != nil {
+		return nil, err
+	}
+	unitOfWork, err := repository.NewUnitOfWork(app.Gorm, app.Logger.App)
+	if err != nil {
+		return nil, err
+	}
+	seeder, err := seed.NewSeeder(usersRepository, auditRepository, unitOfWork, app.CasbinEnforcer, app.Hash, app.Logger.App)
+	if err != nil {
+		return nil, err
+	}
+	return seeder.WithActor("cli"), nil
+}
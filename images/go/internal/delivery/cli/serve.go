@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/reload"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/secret"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http/route"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/scheduler"
+)
+
+// serve bootstraps the application and serves the HTTP API until the server stops.
+func serve(ctx context.Context, cli *CLI, args []string) error {
+	flags := cli.flagSet("serve")
+	if err := parse(flags, args, 0); err != nil {
+		return usageError(err)
+	}
+
+	app, err := cli.Bootstrap()
+	if err != nil {
+		return err
+	}
+	// Ship the lines still buffered by the log sinks
+	defer app.Logger.Close()
+	// Export the spans to the configured exporter, flushing the ones still buffered on exit
+	tracingConfig, err := tracing.NewConfig()
+	if err != nil {
+		return err
+	}
+	shutdownTracing, err := tracing.Setup(ctx, tracingConfig)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			app.Logger.App.Error().Msgf("Failed to flush the spans: %v", err)
+		}
+	}()
+	usersController, authController, auditController, configController, cacheController, err := http.NewController(app)
+	if err != nil {
+		return err
+	}
+	routes, err := route.NewRoute(app, usersController, authController, auditController, configController, cacheController)
+	if err != nil {
+		return err
+	}
+	if err := routes.Init(app.FiberServer.App); err != nil {
+		return err
+	}
+	// Every process watches the files, SIGHUP reaches the prefork children when sent to the process group
+	reloadConfig, err := reload.NewConfig()
+	if err != nil {
+		return err
+	}
+	reloader, err := newReloader(app)
+	if err != nil {
+		return err
+	}
+	go reloader.Watch(ctx, reloadConfig.Period())
+	secretConfig, err := secret.NewConfig()
+	if err != nil {
+		return err
+	}
+	app.Secrets.OnRotate(rotateSecrets(app))
+	go app.Secrets.Watch(ctx, secretConfig.Period(), app.Logger.App)
+	// Every process, each prefork child included, reloads the policy changed by another one, like a purge run by the parent
+	go app.PolicyWatcher.Listen(ctx)
+	// Background jobs run once, in the parent process when prefork is enabled
+	if !fiber.IsChild() {
+		jobs, err := scheduler.NewScheduler(app)
+		if err != nil {
+			return err
+		}
+		jobs.Start(ctx)
+	}
+	return app.FiberServer.Serve()
+}
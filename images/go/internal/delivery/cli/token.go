@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
+)
+
+// inspectToken verifies a token with the secret key of its type and prints its payload, - reads the token from the input.
+func inspectToken(ctx context.Context, cli *CLI, args []string) error {
+	flags := cli.flagSet("token inspect")
+	tokenType := flags.String("type", "access", "type of the token (access, refresh, forgot-password)")
+	if err := parse(flags, args, 1); err != nil {
+		return usageError(err)
+	}
+	token := flags.Arg(0)
+	if token == "-" {
+		line, err := bufio.NewReader(cli.In).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read the token: %w", err)
+		}
+		token = strings.TrimSpace(line)
+	}
+
+	// The keys may be held by a secret provider
+	if _, err := bootstrap.Secrets(); err != nil {
+		return err
+	}
+	jwtToken, secretKey, err := tokenconfig.NewJWTToken()
+	if err != nil {
+		return err
+	}
+	var secret string
+	switch *tokenType {
+	case "access":
+		secret = secretKey.AccessToken
+	case "refresh":
+		secret = secretKey.RefreshToken
+	case "forgot-password":
+		secret = secretKey.ForgotPasswordToken
+	default:
+		return fmt.Errorf("%w: unknown token type %q", ErrUsage, *tokenType)
+	}
+
+	payload, err := jwtToken.VerifyToken(secret, token)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(cli.Out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(payload)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/seed"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+// createAdmin creates a user with a role, admin by default. An existing user with the email keeps its password and is given the role,
+// so the command can run on every deployment. The password is read from the input when the flag is omitted, keeping it out of the shell history.
+// The cached user lists are not invalidated and show the new user once they expire.
+func createAdmin(ctx context.Context, cli *CLI, args []string) error {
+	flags := cli.flagSet("user create-admin")
+	email := flags.String("email", "", "email of the user (required)")
+	username := flags.String("username", "", "username of the user (required)")
+	password := flags.String("password", "", "password of the user, read from the input when empty")
+	role := flags.String("role", "admin", "role given to the user")
+	if err := parse(flags, args, 0); err != nil {
+		return usageError(err)
+	}
+	if *email == "" || *username == "" || *role == "" {
+		return fmt.Errorf("%w: user create-admin expects -email, -username and -role", ErrUsage)
+	}
+
+	app, err := cli.Bootstrap()
+	if err != nil {
+		return err
+	}
+	defer app.Logger.Close()
+	usersRepository, err := repository.NewUsersRepositoryImpl(app.Gorm, app.Logger.App)
+	if err != nil {
+		return err
+	}
+	seeder, err := newSeeder(app)
+	if err != nil {
+		return err
+	}
+
+	exist, err := usersRepository.ExistByKeyValue(ctx, map[string]any{"email": *email})
+	if err != nil {
+		return err
+	}
+	if !exist {
+		if *password == "" {
+			fmt.Fprint(cli.Out, "password: ")
+			line, err := bufio.NewReader(cli.In).ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("failed to read the password: %w", err)
+			}
+			*password = strings.TrimRight(line, "\r\n")
+		}
+		if err := validator.New().Struct(request.User{Username: *username, Email: *email, Password: *password}); err != nil {
+			return fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+	}
+
+	// The password of an existing user is left as it is
+	result, err := seeder.Seed(ctx, &seed.Fixture{Users: []seed.User{{Username: *username, Email: *email, Password: *password, Roles: []string{*role}}}})
+	if err != nil {
+		return err
+	}
+	if result.Users > 0 {
+		fmt.Fprintf(cli.Out, "created user %s\n", *email)
+	} else {
+		fmt.Fprintf(cli.Out, "user %s already exists\n", *email)
+	}
+	if result.Roles > 0 {
+		fmt.Fprintf(cli.Out, "gave the role %s to user %s\n", *role, *email)
+	} else {
+		fmt.Fprintf(cli.Out, "user %s already has the role %s\n", *email, *role)
+	}
+	return nil
+}
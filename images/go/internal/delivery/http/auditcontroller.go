@@ -0,0 +1,59 @@
+// Package http provides HTTP handlers for audit trail operations
+package http
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/phuslu/log"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"github.com/tirtahakimpambudhi/restful_api/internal/usecase"
+	reflecthelper "github.com/tirtahakimpambudhi/restful_api/pkg/helper/reflect"
+)
+
+// AuditController handles requests related to the audit trail
+type AuditController struct {
+	usecases *usecase.AuditUsecase
+	logger   *log.Logger
+}
+
+// NewAuditController creates a new AuditController
+func NewAuditController(usecases *usecase.AuditUsecase, logger *log.Logger) *AuditController {
+	// Initialize AuditController with provided usecases
+	logger.Info().Msg("AuditController initialized")
+	return &AuditController{usecases: usecases, logger: logger}
+}
+
+// Index retrieves a filtered page of audit events
+func (controller AuditController) Index(ctx *fiber.Ctx) error {
+	// Log the start of the Index method
+	controller.logger.Info().Msg("Audit Index method called")
+
+	// Parse query parameters into the request struct
+	req := new(request.AuditPage)
+	if errParse := ctx.QueryParser(req); errParse != nil {
+		controller.logger.Error().Err(errParse).Msg("Failed to parse query parameters")
+		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.BAD_REQUEST, fmt.Sprintf("BAD REQUEST : %s \nREQUEST BODY \n%s", errParse.Error(), reflecthelper.KeyValueToString(*req)))}}
+	}
+
+	// Retrieve the audit events from the usecase
+	res, errors := controller.usecases.List(ctx.Context(), req)
+	if errors != nil {
+		controller.logger.Error().Err(errors).Msg("Failed to retrieve audit events")
+		return errors
+	}
+
+	// Update the links to include the full URL
+	baseURL := ctx.BaseURL() + ctx.Path()
+	for key, value := range res.Links {
+		res.Links[key] = fmt.Sprintf("%s%s", baseURL, value)
+	}
+
+	// Set the response status code
+	ctx.Status(res.Status)
+
+	// Return the response as JSON
+	return ctx.JSON(res)
+}
@@ -0,0 +1,43 @@
+// Package http provides HTTP handlers for cache operations
+package http
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/phuslu/log"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+// CacheController handles requests related to the in-process cache
+type CacheController struct {
+	stats  func() repository.LocalCacheStats // nil when the in-process cache is disabled
+	logger *log.Logger
+}
+
+// NewCacheController creates a new CacheController reporting the statistics returned by stats, nil when the in-process cache is disabled
+func NewCacheController(stats func() repository.LocalCacheStats, logger *log.Logger) *CacheController {
+	// Initialize CacheController with the provided stats
+	logger.Info().Msg("CacheController initialized")
+	return &CacheController{stats: stats, logger: logger}
+}
+
+// Show retrieves the activity of the in-process cache of this replica since it started
+func (controller CacheController) Show(ctx *fiber.Ctx) error {
+	// Log the start of the Show method
+	controller.logger.Info().Msg("Cache Show method called")
+
+	if controller.stats == nil {
+		// Only the in-process cache keeps statistics
+		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.NOT_FOUND, "The in-process cache is disabled, set CACHE_LOCAL to enable it")}}
+	}
+	res := response.Standard{Status: http.StatusOK, Code: "STATUS_OK", Data: controller.stats()}
+
+	// Set the response status code
+	ctx.Status(res.Status)
+
+	// Return the response as JSON
+	return ctx.JSON(res)
+}
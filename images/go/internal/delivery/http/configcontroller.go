@@ -0,0 +1,45 @@
+// Package http provides HTTP handlers for configuration operations
+package http
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+)
+
+// ConfigController handles requests related to the loaded configuration
+type ConfigController struct {
+	dump   func() ([]configs.Setting, error)
+	logger *log.Logger
+}
+
+// NewConfigController creates a new ConfigController listing the settings returned by dump
+func NewConfigController(dump func() ([]configs.Setting, error), logger *log.Logger) *ConfigController {
+	// Initialize ConfigController with the provided dump
+	logger.Info().Msg("ConfigController initialized")
+	return &ConfigController{dump: dump, logger: logger}
+}
+
+// Show retrieves every setting with the source it is read from, the secrets redacted
+func (controller ConfigController) Show(ctx *fiber.Ctx) error {
+	// Log the start of the Show method
+	controller.logger.Info().Msg("Config Show method called")
+
+	res := response.Standard{Status: http.StatusOK, Code: "STATUS_OK"}
+	settings, err := controller.dump()
+	if err != nil {
+		// The settings changed since startup, they are listed with the errors
+		controller.logger.Warn().Err(err).Msg("The configuration is invalid")
+		res.Meta = map[string]any{"errors": err.Error()}
+	}
+	res.Data = settings
+
+	// Set the response status code
+	ctx.Status(res.Status)
+
+	// Return the response as JSON
+	return ctx.JSON(res)
+}
@@ -1,17 +1,21 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
 	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
 	"github.com/tirtahakimpambudhi/restful_api/internal/usecase"
 	"github.com/tirtahakimpambudhi/restful_api/internal/validation"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
 )
 
-func NewController(app *bootstrap.App) (*UsersController, *AuthController, error) {
+func NewController(app *bootstrap.App) (*UsersController, *AuthController, *AuditController, *ConfigController, *CacheController, error) {
 	app.Logger.App.Info().Msg("NewController Call Function")
 	// Create a new English locale
 	english := en.New()
@@ -23,18 +27,62 @@ func NewController(app *bootstrap.App) (*UsersController, *AuthController, error
 	translator, found := universalTranslate.GetTranslator("en")
 	if !found {
 		app.Logger.App.Error().Msg("the language English not found package")
-		return nil, nil, fmt.Errorf("the language English not found package")
+		return nil, nil, nil, nil, nil, fmt.Errorf("the language English not found package")
 	}
 
 	// Create a new UsersRepository implementation
 	usersRepository, err := repository.NewUsersRepositoryImpl(app.Gorm, app.Logger.App)
 	if err != nil {
 		app.Logger.App.Error().Err(err)
-		return nil, nil, err
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Create a new AuditRepository implementation
+	auditRepository, err := repository.NewAuditRepositoryImpl(app.Gorm, app.Logger.App)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Create the UnitOfWork sharing a transaction between the repositories
+	unitOfWork, err := repository.NewUnitOfWork(app.Gorm, app.Logger.App)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, nil, nil, nil, nil, err
+	}
+
+	// Create the Redis client shared by the cache and export repositories
+	redisClient, err := app.Redis.NewClient()
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, nil, nil, nil, nil, err
 	}
 
 	// Create a new UserCacheRepository instance
-	cacheRepository := repository.NewUserCacheRepository(app.Redis.NewClient(), app.Logger.App)
+	var cacheRepository repository.CacheRepository[*entity.Users]
+	cacheRepository, err = repository.NewUserCacheRepository(redisClient, app.Logger.App, app.Redis)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, nil, nil, nil, nil, err
+	}
+	// Guard the cache with a circuit breaker, requests are served from the database while Redis is unavailable
+	cacheRepository = repository.NewBreakerUserCacheRepository(cacheRepository, breaker.New("cache", app.Redis.BreakerFailures, app.Redis.CacheBreakerOpen()), app.Logger.App)
+	var localStats func() repository.LocalCacheStats
+	if app.Redis.Local {
+		// Hold cached users in process, kept consistent with the other replicas by the invalidations published on Redis
+		localCacheRepository := repository.NewLocalUserCacheRepository(cacheRepository, redisClient, app.Logger.App, app.Redis.LocalMax, app.Redis.CacheLocalTTL())
+		go localCacheRepository.Listen(context.Background())
+		cacheRepository = localCacheRepository
+		localStats = localCacheRepository.Stats
+		// The statistics have the fields of the metrics, their JSON names aside
+		if err := metrics.RegisterLocalCache("users", func() metrics.LocalCacheStats { return metrics.LocalCacheStats(localStats()) }); err != nil {
+			app.Logger.App.Error().Err(err)
+			return nil, nil, nil, nil, nil, err
+		}
+	}
+
+	// Create a new ExportRepository instance sharing the Redis connection settings
+	exportRepository := repository.NewExportRepository(redisClient, app.Logger.App)
 
 	// Initialize the UsersController with the necessary dependencies
 	usersController := NewUsersController(usecase.NewUsersUsecaseBuilder().
@@ -44,6 +92,12 @@ func NewController(app *bootstrap.App) (*UsersController, *AuthController, error
 		WithCacheRepository(cacheRepository).
 		WithValidator(validation.NewValidator(validator.New(), translator)).
 		WithTimeoutConfig(app.Timeout).
+		WithEnforcer(app.CasbinEnforcer).
+		WithPolicyWatcher(app.PolicyWatcher).
+		WithExportRepository(exportRepository).
+		WithExportConfig(app.Export).
+		WithAuditRepository(auditRepository).
+		WithUnitOfWork(unitOfWork).
 		Build(),
 		app.Logger.App)
 	// Initialize the AuthController with the necessary dependencies
@@ -51,12 +105,28 @@ func NewController(app *bootstrap.App) (*UsersController, *AuthController, error
 		WithHashing(app.Hash).
 		WithLogger(app.Logger.App).
 		WithUsersRepository(usersRepository).
+		WithCacheRepository(cacheRepository).
 		WithValidator(validation.NewValidator(validator.New(), translator)).
 		WithTimeoutConfig(app.Timeout).
 		WithEnforcer(app.CasbinEnforcer).
+		WithPolicyWatcher(app.PolicyWatcher).
 		WithToken(app.Token).
 		WithSecretKey(app.Secret).
+		WithAuditRepository(auditRepository).
+		WithUnitOfWork(unitOfWork).
+		Build(),
+		app.Logger.App)
+	// Initialize the AuditController with the necessary dependencies
+	auditController := NewAuditController(usecase.NewAuditUsecaseBuilder().
+		WithLogger(app.Logger.App).
+		WithAuditRepository(auditRepository).
+		WithValidator(validation.NewValidator(validator.New(), translator)).
+		WithTimeoutConfig(app.Timeout).
 		Build(),
 		app.Logger.App)
-	return usersController, authController, nil
+	// Initialize the ConfigController listing the settings of the environment
+	configController := NewConfigController(bootstrap.Dump, app.Logger.App)
+	// Initialize the CacheController reporting the activity of the in-process cache
+	cacheController := NewCacheController(localStats, app.Logger.App)
+	return usersController, authController, auditController, configController, cacheController, nil
 }
@@ -4,35 +4,36 @@ import (
 	"errors"
 	"github.com/casbin/casbin/v2"
 	"github.com/gofiber/fiber/v2"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
 	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
 	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
-	"net/http"
 	"slices"
 	"strings"
 )
 
 // NewAuthenticationToken returns a middleware handler function that verifies JWT tokens
-// and handles any token-related errors. It uses the provided JWT token configuration and secret key.
-func NewAuthenticationToken(jwtToken *tokenconfig.JWTToken, secretKey string) fiber.Handler {
+// and handles any token-related errors. It uses the provided JWT token configuration and the secret keys returned by secretKeys,
+// read on every request so a rotated key applies at once while the replaced one still verifies during its grace window.
+func NewAuthenticationToken(jwtToken *tokenconfig.JWTToken, secretKeys func() []string) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
 		// Extract the Authorization header from the request.
 		authHeader := ctx.Get("Authorization")
 		if authHeader == "" {
 			// If the Authorization header is missing, return a bad request error.
-			ctx.Status(http.StatusBadRequest)
-			return ctx.JSON(response.StandardErrors{
+			return &response.StandardErrors{
 				Errors: []*response.Error{
 					errorshandler.NewError(errorshandler.BAD_REQUEST, "Error Token: Missing or Malformed Token"),
 				},
-			})
+			}
 		}
 
 		// Extract the token string from the Authorization header.
 		tokenStr := authHeader[len("Bearer "):]
 
-		// Verify the token using the provided secret key.
-		payload, err := jwtToken.VerifyToken(secretKey, tokenStr)
+		// Verify the token using the provided secret keys.
+		payload, err := jwtToken.VerifyTokenWithKeys(secretKeys(), tokenStr)
 		if err != nil {
 			var jwtError *tokenconfig.TokenError
 			// Check if the error is a TokenError.
@@ -43,50 +44,50 @@ func NewAuthenticationToken(jwtToken *tokenconfig.JWTToken, secretKey string) fi
 				switch {
 				case errors.Is(typeErr, tokenconfig.ErrTokenMalformed):
 					// Handle malformed token error.
-					ctx.Status(http.StatusBadRequest)
-					return ctx.JSON(response.StandardErrors{
+					return &response.StandardErrors{
 						Errors: []*response.Error{
 							errorshandler.NewError(errorshandler.BAD_REQUEST, "Error Token: "+jwtError.Error()),
 						},
-					})
+					}
 				case errors.Is(typeErr, tokenconfig.ErrTokenExpired):
 					// Handle expired token error.
-					ctx.Status(http.StatusForbidden)
-					return ctx.JSON(response.StandardErrors{
+					return &response.StandardErrors{
 						Errors: []*response.Error{
 							errorshandler.NewError(errorshandler.FORBIDEN, "Error Token: "+jwtError.Error()),
 						},
-					})
+					}
 				case errors.Is(typeErr, tokenconfig.ErrTokenSignatureInvalid):
 					// Handle invalid token signature error.
-					ctx.Status(http.StatusBadRequest)
-					return ctx.JSON(response.StandardErrors{
+					return &response.StandardErrors{
 						Errors: []*response.Error{
 							errorshandler.NewError(errorshandler.BAD_REQUEST, "Error Token: "+jwtError.Error()),
 						},
-					})
+					}
 				case errors.Is(typeErr, tokenconfig.ErrInvalidToken):
 					// Handle generic invalid token error.
-					ctx.Status(http.StatusBadRequest)
-					return ctx.JSON(response.StandardErrors{
+					return &response.StandardErrors{
 						Errors: []*response.Error{
 							errorshandler.NewError(errorshandler.BAD_REQUEST, "Error Token: "+jwtError.Error()),
 						},
-					})
+					}
 				default:
 					// Handle any other server errors related to tokens.
-					ctx.Status(http.StatusInternalServerError)
-					return ctx.JSON(response.StandardErrors{
+					return &response.StandardErrors{
 						Errors: []*response.Error{
 							errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Token: "+jwtError.Error()),
 						},
-					})
+					}
 				}
 			}
 		}
 
 		// Continue to the next handler if the token is valid.
 		ctx.Locals("users", payload)
+		// Record the authenticated user as the actor of the request.
+		if payload != nil {
+			meta := request.MetaFromContext(ctx.Context())
+			meta.ActorID = payload.ID.String()
+		}
 		return ctx.Next()
 	}
 }
@@ -124,6 +125,8 @@ func NewAuthorizationById(middleware *casbin.Enforcer, permission string) fiber.
 			return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Permissions must be contains ':' Actual '"+permission+"'")}}
 		}
 
+		recordDecision(permission, authorized, err)
+
 		// Handle error
 		if err != nil {
 			return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.FORBIDEN, err.Error())}}
@@ -167,6 +170,8 @@ func NewAuthorization(middleware *casbin.Enforcer, permission string) fiber.Hand
 			}
 			authorized = slices.Contains(roles, permission)
 		}
+		recordDecision(permission, authorized, err)
+
 		// Handle error
 		if err != nil {
 			return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.FORBIDEN, err.Error())}}
@@ -180,3 +185,14 @@ func NewAuthorization(middleware *casbin.Enforcer, permission string) fiber.Hand
 		}
 	}
 }
+
+// recordDecision counts an authorization decision of a permission.
+func recordDecision(permission string, authorized bool, err error) {
+	result := "deny"
+	if err != nil {
+		result = "error"
+	} else if authorized {
+		result = "allow"
+	}
+	metrics.AuthorizationDecisions.WithLabelValues(permission, result).Inc()
+}
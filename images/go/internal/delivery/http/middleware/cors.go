@@ -6,11 +6,20 @@ import (
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/security"
 )
 
+// corsHandler is the CORS middleware, rebuilt by ReloadCORS.
+var corsHandler swappable
+
 // CORS sets up CORS middleware.
 func CORS() (fiber.Handler, error) {
 	corsConf, err := security.NewCors()
 	if err != nil {
 		return nil, err
 	}
-	return cors.New(corsConf.Fiber()), nil
+	ReloadCORS(corsConf)
+	return corsHandler.handle, nil
+}
+
+// ReloadCORS rebuilds the CORS middleware with the configuration.
+func ReloadCORS(corsConf *security.Cors) {
+	corsHandler.swap(cors.New(corsConf.Fiber()))
 }
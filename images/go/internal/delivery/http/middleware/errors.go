@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RenderErrors renders the errors returned by the middlewares and handlers after it with the error handler of the app, once.
+// It runs after RequestMeta so the errors are built with the request ID, and the middlewares before it read the rendered response.
+func RenderErrors() (fiber.Handler, error) {
+	return func(ctx *fiber.Ctx) error {
+		if err := ctx.Next(); err != nil {
+			return ctx.App().ErrorHandler(ctx, err)
+		}
+		return nil
+	}, nil
+}
@@ -3,9 +3,26 @@ package middleware
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/healthcheck"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
+	"net/http"
 )
 
 // HealthCheck sets up Health Check middleware.
+// The probes answer 200 while any dependency is degraded, the state of every circuit breaker is reported in the body.
 func HealthCheck() (fiber.Handler, error) {
-	return healthcheck.New(), nil
+	return func(ctx *fiber.Ctx) error {
+		if ctx.Method() != fiber.MethodGet || (ctx.Path() != healthcheck.DefaultLivenessEndpoint && ctx.Path() != healthcheck.DefaultReadinessEndpoint) {
+			return ctx.Next()
+		}
+		status := "ok"
+		if breaker.Degraded() {
+			status = "degraded"
+		}
+		return ctx.Status(http.StatusOK).JSON(&response.Standard{
+			Status: http.StatusOK,
+			Code:   "STATUS_OK",
+			Data:   fiber.Map{"status": status, "breakers": breaker.Snapshots()},
+		})
+	}, nil
 }
@@ -1,55 +1,71 @@
 package middleware
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/gofiber/storage/redis/v3"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/security"
 	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
 	"net/http"
-	"time"
 )
 
+// limiterHandler is the rate limiting middleware, rebuilt by ReloadLimiter.
+var limiterHandler swappable
+
+// limiterStorage counts the requests, shared by the middleware rebuilt by ReloadLimiter.
+var limiterStorage fiber.Storage
+
 // Limiter sets up request rate limiting middleware.
 func Limiter() (fiber.Handler, error) {
-	var storage *redis.Storage
 	// Load Redis cache configuration
 	config, err := cache.NewConfig()
 	if err != nil {
 		return nil, err
 	}
+	// Load the thresholds of the limiter
+	limiterConf, err := security.NewLimiter()
+	if err != nil {
+		return nil, err
+	}
 
-	storage = redis.New(redis.Config{
-		Host:     config.Host,
-		Port:     config.Port,
-		Username: config.User,
-		Password: config.Password,
-		Database: config.Name,
-		Reset:    false,
-	})
+	// Share the single node, Sentinel or Cluster settings of the cache
+	client, err := config.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	// Count requests in memory while Redis is unavailable, limiting per process instead of failing every request
+	limiterStorage = &fallbackStorage{
+		primary:  &redisStorage{client: client},
+		fallback: newMemoryStorage(),
+		breaker:  breaker.New("limiter", config.BreakerFailures, config.CacheBreakerOpen()),
+	}
+	ReloadLimiter(limiterConf)
+	return limiterHandler.handle, nil
+}
 
-	// Set up limiter middleware
-	return limiter.New(limiter.Config{
+// ReloadLimiter rebuilds the rate limiting middleware with the thresholds, the requests already counted are kept.
+func ReloadLimiter(limiterConf *security.Limiter) {
+	limiterHandler.swap(limiter.New(limiter.Config{
 		Next: func(c *fiber.Ctx) bool {
 			// Allow requests from localhost without limit
 			return c.IP() == "127.0.0.1"
 		},
-		Max:        20,               // Maximum number of requests
-		Expiration: 30 * time.Second, // Time window for rate limiting
+		Max:        limiterConf.Max,      // Maximum number of requests
+		Expiration: limiterConf.Window(), // Time window for rate limiting
 		KeyGenerator: func(c *fiber.Ctx) string {
 			// Generate a key based on the client's IP address
 			return c.Get("x-forwarded-for")
 		},
 		LimitReached: func(ctx *fiber.Ctx) error {
-			// Respond with "Too Many Requests" error if limit is reached
-			ctx.Status(http.StatusTooManyRequests)
-			return ctx.JSON(&response.StandardErrors{
-				Errors: []*response.Error{
-					errorshandler.NewError(errorshandler.TO_MANY_REQUEST, "Error: Too Many Requests, wait 30 minutes"),
-				},
-			})
+			// Respond with "Too Many Requests" error if limit is reached, the code of the error has no status of its own
+			errLimit := errorshandler.NewError(errorshandler.TO_MANY_REQUEST, fmt.Sprintf("Error: Too Many Requests, wait %s", limiterConf.Window()))
+			errLimit.Status = http.StatusTooManyRequests
+			return &response.StandardErrors{Errors: []*response.Error{errLimit}}
 		},
-		Storage: storage, // Use Redis storage
-	}), nil
+		Storage: limiterStorage, // Use Redis storage
+	}))
 }
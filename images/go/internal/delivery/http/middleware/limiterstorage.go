@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
+)
+
+// redisStorage implements fiber.Storage on any Redis client, so the limiter follows the topology of the cache.
+type redisStorage struct {
+	client redis.UniversalClient
+}
+
+// Get returns the value of the key, nil when it does not exist.
+func (storage *redisStorage) Get(key string) ([]byte, error) {
+	value, err := storage.client.Get(context.Background(), key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return value, err
+}
+
+// Set stores the value of the key, expiring after exp unless it is zero.
+func (storage *redisStorage) Set(key string, value []byte, exp time.Duration) error {
+	return storage.client.Set(context.Background(), key, value, exp).Err()
+}
+
+// Delete removes the key.
+func (storage *redisStorage) Delete(key string) error {
+	return storage.client.Del(context.Background(), key).Err()
+}
+
+// Reset is not supported, the database is shared with the cache.
+func (storage *redisStorage) Reset() error {
+	return errors.New("redis storage: reset is not supported on a shared database")
+}
+
+// Close closes the client.
+func (storage *redisStorage) Close() error {
+	return storage.client.Close()
+}
+
+// memoryStorage implements fiber.Storage in process, expired keys are swept while keys are set.
+type memoryStorage struct {
+	mutex     sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+// memoryEntry is a value of the memory storage, expiring at expiresAt unless it is zero.
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// newMemoryStorage creates an empty memory storage.
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{entries: make(map[string]memoryEntry), lastSweep: time.Now()}
+}
+
+// Get returns the value of the key, nil when it does not exist or expired.
+func (storage *memoryStorage) Get(key string) ([]byte, error) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	entry, found := storage.entries[key]
+	if !found || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		return nil, nil
+	}
+	return entry.value, nil
+}
+
+// Set stores the value of the key, expiring after exp unless it is zero.
+func (storage *memoryStorage) Set(key string, value []byte, exp time.Duration) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	now := time.Now()
+	if now.Sub(storage.lastSweep) > time.Minute {
+		for cached, entry := range storage.entries {
+			if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+				delete(storage.entries, cached)
+			}
+		}
+		storage.lastSweep = now
+	}
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if exp > 0 {
+		entry.expiresAt = now.Add(exp)
+	}
+	storage.entries[key] = entry
+	return nil
+}
+
+// Delete removes the key.
+func (storage *memoryStorage) Delete(key string) error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	delete(storage.entries, key)
+	return nil
+}
+
+// Reset removes every key.
+func (storage *memoryStorage) Reset() error {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+	clear(storage.entries)
+	return nil
+}
+
+// Close does nothing, the memory is released with the storage.
+func (storage *memoryStorage) Close() error {
+	return nil
+}
+
+// fallbackStorage implements fiber.Storage on a primary storage guarded by a circuit breaker,
+// using the fallback storage while the primary fails.
+type fallbackStorage struct {
+	primary  fiber.Storage
+	fallback fiber.Storage
+	breaker  *breaker.Breaker
+}
+
+// Get returns the value of the key from the primary storage, or from the fallback while it is unavailable.
+func (storage *fallbackStorage) Get(key string) ([]byte, error) {
+	if storage.breaker.Allow() {
+		value, err := storage.primary.Get(key)
+		if storage.record(err) {
+			return value, nil
+		}
+	}
+	return storage.fallback.Get(key)
+}
+
+// Set stores the value of the key in the primary storage, or in the fallback while it is unavailable.
+func (storage *fallbackStorage) Set(key string, value []byte, exp time.Duration) error {
+	if storage.breaker.Allow() && storage.record(storage.primary.Set(key, value, exp)) {
+		return nil
+	}
+	return storage.fallback.Set(key, value, exp)
+}
+
+// Delete removes the key from both storages.
+func (storage *fallbackStorage) Delete(key string) error {
+	if storage.breaker.Allow() {
+		storage.record(storage.primary.Delete(key))
+	}
+	return storage.fallback.Delete(key)
+}
+
+// Reset resets both storages.
+func (storage *fallbackStorage) Reset() error {
+	return errors.Join(storage.primary.Reset(), storage.fallback.Reset())
+}
+
+// Close closes both storages.
+func (storage *fallbackStorage) Close() error {
+	return errors.Join(storage.primary.Close(), storage.fallback.Close())
+}
+
+// record reports the outcome of a call to the primary storage, returning whether it succeeded.
+func (storage *fallbackStorage) record(err error) bool {
+	if err != nil {
+		storage.breaker.Failure()
+		return false
+	}
+	storage.breaker.Success()
+	return true
+}
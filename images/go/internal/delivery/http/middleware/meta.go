@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+)
+
+// HeaderRequestID carries the correlation ID of a request, propagated from the client or generated.
+const HeaderRequestID = "X-Request-Id"
+
+// maxRequestIDSize bounds the length of a propagated request ID.
+const maxRequestIDSize = 128
+
+// RequestMeta stores the request ID, route, client IP and User-Agent in the request context,
+// the authentication middleware completes it with the actor.
+// The request ID is echoed in the X-Request-Id header, the error handler adds it to the meta of every error of the response body.
+func RequestMeta() (fiber.Handler, error) {
+	return func(ctx *fiber.Ctx) error {
+		requestID := ctx.Get(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		requestID = utils.CopyString(requestID)
+		ctx.Set(HeaderRequestID, requestID)
+		ctx.Context().SetUserValue(request.MetaKey, &request.Meta{
+			RequestID: requestID,
+			Route:     ctx.Method() + " " + utils.CopyString(ctx.Path()),
+			IP:        utils.CopyString(ctx.IP()),
+			UserAgent: utils.CopyString(ctx.Get(fiber.HeaderUserAgent)),
+		})
+		return ctx.Next()
+	}, nil
+}
+
+// validRequestID reports whether a propagated request ID is short and holds printable ASCII only.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDSize {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
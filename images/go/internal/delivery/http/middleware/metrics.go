@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+)
+
+// Metrics records the count, latency and error codes of every request by route pattern, like /api/v1/users/:id.
+// The responses of the middlewares and of the unmatched paths are recorded under the / route.
+func Metrics() (fiber.Handler, error) {
+	config, err := metrics.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return func(ctx *fiber.Ctx) error { return ctx.Next() }, nil
+	}
+	return func(ctx *fiber.Ctx) error {
+		start := time.Now()
+		metrics.RequestsInFlight.Inc()
+		defer metrics.RequestsInFlight.Dec()
+
+		// The errors are rendered by RenderErrors, their codes are read from the body
+		err := ctx.Next()
+
+		method := ctx.Method()
+		route := ctx.Route().Path
+		status := ctx.Response().StatusCode()
+		metrics.Requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		metrics.RequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		if status >= fiber.StatusBadRequest {
+			for _, code := range errorCodes(ctx) {
+				metrics.RequestErrors.WithLabelValues(method, route, code).Inc()
+			}
+		}
+		return err
+	}, nil
+}
+
+// errorCodes returns the codes of the errors of a JSON error response, the code of its status otherwise.
+func errorCodes(ctx *fiber.Ctx) []string {
+	codes := []string{}
+	if strings.HasPrefix(string(ctx.Response().Header.ContentType()), fiber.MIMEApplicationJSON) {
+		var body response.StandardErrors
+		if err := ctx.App().Config().JSONDecoder(ctx.Response().Body(), &body); err == nil {
+			for _, err := range body.Errors {
+				if err != nil && err.Code != "" {
+					codes = append(codes, err.Code)
+				}
+			}
+		}
+	}
+	if len(codes) == 0 {
+		codes = append(codes, errorshandler.ConvertStatusCodeToString(ctx.Response().StatusCode()))
+	}
+	return codes
+}
+
+// MetricsHandler serves the metrics in the Prometheus exposition format, guarded by METRICS_TOKEN when set.
+// When the metrics are disabled the request falls through to the not found handler.
+func MetricsHandler() (fiber.Handler, error) {
+	config, err := metrics.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return func(ctx *fiber.Ctx) error { return ctx.Next() }, nil
+	}
+	handler := adaptor.HTTPHandler(metrics.Handler())
+	return func(ctx *fiber.Ctx) error {
+		if config.Token != "" && subtle.ConstantTimeCompare([]byte(ctx.Get(fiber.HeaderAuthorization)), []byte("Bearer "+config.Token)) != 1 {
+			return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.UNAUTHORIZE, "Missing or invalid metrics token")}}
+		}
+		return handler(ctx)
+	}, nil
+}
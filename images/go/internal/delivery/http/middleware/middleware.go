@@ -8,7 +8,7 @@ import (
 func Setup(app *fiber.App) error {
 	// List of middleware to set up
 	middlewares := []func() (fiber.Handler, error){
-		CORS, GenerateUSERID, Limiter, GenerateCSRF, VerifyCSRF, HealthCheck, ETag, Swagger,
+		Metrics, Tracing, RequestMeta, RenderErrors, CORS, ReadYourWrites, GenerateUSERID, Limiter, GenerateCSRF, VerifyCSRF, HealthCheck, ETag, Swagger,
 	}
 	// Loop through each middleware and apply it to the app
 	for _, mw := range middlewares {
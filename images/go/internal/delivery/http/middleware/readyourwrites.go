@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+// ReadYourWrites stores the Writes of the request in its context,
+// so the reads following a write of the request go to the primary instead of a lagging read replica.
+func ReadYourWrites() (fiber.Handler, error) {
+	config, err := sqlconfig.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	window := config.ReadYourWritesWindow()
+	return func(ctx *fiber.Ctx) error {
+		ctx.Context().SetUserValue(repository.WritesKey, repository.NewWrites(window))
+		return ctx.Next()
+	}, nil
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// swappable is a middleware whose handler is replaced while serving, when its settings are reloaded.
+type swappable struct {
+	handler atomic.Pointer[fiber.Handler]
+}
+
+// handle runs the current handler.
+func (s *swappable) handle(ctx *fiber.Ctx) error {
+	return (*s.handler.Load())(ctx)
+}
+
+// swap replaces the handler, the requests already running keep the previous one.
+func (s *swappable) swap(handler fiber.Handler) {
+	s.handler.Store(&handler)
+}
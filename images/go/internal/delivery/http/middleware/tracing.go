@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts the server span of every request, child of the W3C trace context of the traceparent header when sent.
+// The span is stored in the user values, so the use cases reached through ctx.Context() start their spans under it,
+// and named after the route pattern once the request is served, like GET /api/v1/users/:id.
+func Tracing() (fiber.Handler, error) {
+	return func(ctx *fiber.Ctx) error {
+		parent := otel.GetTextMapPropagator().Extract(ctx.UserContext(), headerCarrier{ctx: ctx})
+		method := utils.CopyString(ctx.Method())
+		spanCtx, span := tracing.Start(parent, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.path", utils.CopyString(ctx.Path())),
+			attribute.String("user_agent.original", utils.CopyString(ctx.Get(fiber.HeaderUserAgent))),
+		))
+		defer span.End()
+		ctx.SetUserContext(spanCtx)
+		ctx.Context().SetUserValue(tracing.SpanKey, span)
+
+		// The errors are rendered by RenderErrors, the span holds their status
+		err := ctx.Next()
+
+		route := ctx.Route().Path
+		status := ctx.Response().StatusCode()
+		span.SetName(method + " " + route)
+		span.SetAttributes(attribute.String("http.route", route), attribute.Int("http.response.status_code", status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+		return err
+	}, nil
+}
+
+// headerCarrier adapts the request headers to propagation.TextMapCarrier.
+type headerCarrier struct {
+	ctx *fiber.Ctx
+}
+
+// Get implements propagation.TextMapCarrier.
+func (carrier headerCarrier) Get(key string) string {
+	return carrier.ctx.Get(key)
+}
+
+// Set implements propagation.TextMapCarrier.
+func (carrier headerCarrier) Set(key string, value string) {
+	carrier.ctx.Request().Header.Set(key, value)
+}
+
+// Keys implements propagation.TextMapCarrier.
+func (carrier headerCarrier) Keys() []string {
+	keys := []string{}
+	carrier.ctx.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
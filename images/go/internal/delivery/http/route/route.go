@@ -18,6 +18,9 @@ import (
 type Route struct {
 	UsersController  *http.UsersController
 	AuthController   *http.AuthController
+	AuditController  *http.AuditController
+	ConfigController *http.ConfigController
+	CacheController  *http.CacheController
 	Logger           *loggerconfig.Logger
 	CasbinMiddleware *casbin.Enforcer
 	Token            *tokenconfig.JWTToken
@@ -25,11 +28,11 @@ type Route struct {
 }
 
 // NewRoute initializes and returns a new Route instance
-func NewRoute(app *bootstrap.App, usersController *http.UsersController, authController *http.AuthController) (*Route, error) {
+func NewRoute(app *bootstrap.App, usersController *http.UsersController, authController *http.AuthController, auditController *http.AuditController, configController *http.ConfigController, cacheController *http.CacheController) (*Route, error) {
 	app.Logger.App.Info().Msg("NewRoute Call Function")
 
 	// Create a new Route instance with the controllers and app configuration
-	routes := &Route{UsersController: usersController, AuthController: authController, SecretKey: app.Secret, Logger: app.Logger, Token: app.Token, CasbinMiddleware: app.CasbinEnforcer}
+	routes := &Route{UsersController: usersController, AuthController: authController, AuditController: auditController, ConfigController: configController, CacheController: cacheController, SecretKey: app.Secret, Logger: app.Logger, Token: app.Token, CasbinMiddleware: app.CasbinEnforcer}
 
 	// Return the initialized Route instance
 	return routes, nil
@@ -42,6 +45,13 @@ func (r *Route) Init(app *fiber.App) error {
 		r.Logger.App.Error().Err(err)
 		return err
 	}
+	// Expose the metrics to Prometheus outside of the versioned API
+	metricsHandler, err := middleware.MetricsHandler()
+	if err != nil {
+		r.Logger.App.Error().Err(err)
+		return err
+	}
+	app.Get("/metrics", metricsHandler)
 	group := app.Group("/api/v1")
 	group.Get("/monitor", middleware.Monitor())
 	r.public(group)
@@ -51,18 +61,18 @@ func (r *Route) Init(app *fiber.App) error {
 		method := ctx.Method() // Get Method from request client
 		path := ctx.Path()     // Get Path from request client
 
-		// Set status to 404 Not Found
+		// Set status to 404 Not Found, the access log reads it before the error is rendered
 		ctx.Status(fiber.StatusNotFound)
 
 		// Create message error with specific method dan path
 		errorMessage := fmt.Sprintf("NOT FOUND: Method %s with Path %s", method, path)
 
-		// Send error with detail error
-		return ctx.JSON(&response.StandardErrors{
+		// Return the error with detail error, rendered by the RenderErrors middleware
+		return &response.StandardErrors{
 			Errors: []*response.Error{
 				errorshandler.NewError(errorshandler.NOT_FOUND, errorMessage),
 			},
-		})
+		}
 	})
 
 	return nil
@@ -85,10 +95,16 @@ func (r *Route) public(group fiber.Router) {
 func (r *Route) protected(group fiber.Router) {
 	r.Logger.App.Info().Msg("Prepare the protected routes")
 	// Define a route for resetting the password, protected by a middleware
-	group.Post("/auth/reset-password", middleware.NewAuthenticationToken(r.Token, r.SecretKey.ForgotPasswordToken), r.AuthController.ResetPassword)
-	group.Patch("/auth/role", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessToken), middleware.NewAuthorization(r.CasbinMiddleware, "admin"), r.AuthController.UpsertRole)
+	group.Post("/auth/reset-password", middleware.NewAuthenticationToken(r.Token, r.SecretKey.ForgotPasswordKeys), r.AuthController.ResetPassword)
+	group.Patch("/auth/role", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessKeys), middleware.NewAuthorization(r.CasbinMiddleware, "admin"), r.AuthController.UpsertRole)
+	// Define a route for querying the audit trail, protected by Casbin middleware
+	group.Get("/audit-events", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessKeys), middleware.NewAuthorization(r.CasbinMiddleware, "audit:read"), r.AuditController.Index)
+	// Define a route for listing the settings with their secrets redacted, restricted to admins
+	group.Get("/admin/config", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessKeys), middleware.NewAuthorization(r.CasbinMiddleware, "admin"), r.ConfigController.Show)
+	// Define a route for the activity of the in-process cache of the replica, restricted to admins
+	group.Get("/admin/cache", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessKeys), middleware.NewAuthorization(r.CasbinMiddleware, "admin"), r.CacheController.Show)
 	// Define a group of routes protected by access token authentication
-	usersProtectedRoute := group.Group("/users", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessToken))
+	usersProtectedRoute := group.Group("/users", middleware.NewAuthenticationToken(r.Token, r.SecretKey.AccessKeys))
 
 	// Define a route for getting all users with required permissions
 	usersProtectedRoute.Get("", middleware.NewAuthorizationById(r.CasbinMiddleware, "users:read"), r.UsersController.Index)
@@ -96,13 +112,16 @@ func (r *Route) protected(group fiber.Router) {
 	// Define a route for getting a specific user by ID, protected by custom and Casbin middleware
 	usersProtectedRoute.Get("/:id", middleware.NewAuthorizationById(r.CasbinMiddleware, "users:read"), r.UsersController.Show)
 
+	// Define a route for exporting the data of a user by ID, allowed for the user itself or with the export permission
+	usersProtectedRoute.Get("/:id/export", middleware.NewAuthorizationById(r.CasbinMiddleware, "users:export"), r.UsersController.Export)
+
 	// Define a route for updating a user by ID, protected by custom middleware
 	usersProtectedRoute.Put("/:id", middleware.NewAuthorizationById(r.CasbinMiddleware, "users:update"), r.UsersController.Update)
 
 	// Define a route for editing a user by ID, protected by custom middleware
 	usersProtectedRoute.Patch("/:id", middleware.NewAuthorizationById(r.CasbinMiddleware, "users:edit"), r.UsersController.Edit)
 
-	// Define a route for deleting a user by ID, protected by Casbin middleware
+	// Define a route for deleting or purging (?purge=true) a user by ID, protected by Casbin middleware
 	usersProtectedRoute.Delete("/:id", middleware.NewAuthorization(r.CasbinMiddleware, "admin"), r.UsersController.Destroy)
 
 	// Define a route for deleting a user by ID, protected by Casbin middleware
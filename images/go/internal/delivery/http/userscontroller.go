@@ -11,6 +11,7 @@ import (
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
 	"github.com/tirtahakimpambudhi/restful_api/internal/usecase"
 	reflecthelper "github.com/tirtahakimpambudhi/restful_api/pkg/helper/reflect"
+	"strings"
 )
 
 // UsersController handles requests related to user operations
@@ -92,6 +93,9 @@ func (controller UsersController) Show(ctx *fiber.Ctx) error {
 	// Log the successful retrieval of the user
 	controller.logger.Info().Msgf("Successfully retrieved user with ID: %s", ctx.Params("id"))
 
+	// Expose the version of the user for conditional requests
+	ctx.Set(fiber.HeaderETag, res.ETag)
+
 	// Set the response status code
 	ctx.Status(res.Status)
 
@@ -99,6 +103,40 @@ func (controller UsersController) Show(ctx *fiber.Ctx) error {
 	return ctx.JSON(res)
 }
 
+// Export downloads everything stored about a user as a ZIP archive,
+// or reports that the archive is still being generated in the background
+func (controller UsersController) Export(ctx *fiber.Ctx) error {
+	// Log the start of the Export method
+	controller.logger.Info().Msg("Export method called")
+
+	// Export the user data using the usecase
+	controller.logger.Info().Msg("Calling usecase Export method")
+	res, errors := controller.usecases.Export(ctx.Context(), utils.CopyString(ctx.Params("id")))
+	if errors != nil {
+		// Log the error during user export
+		controller.logger.Error().Err(errors).Msg("Failed to export user")
+		// Return any errors encountered during export
+		return errors
+	}
+
+	// Set the response status code
+	ctx.Status(res.Status)
+
+	// Return the job state as JSON while the archive is not ready
+	if res.Archive == nil {
+		controller.logger.Info().Msgf("Export of user with ID %s is pending", ctx.Params("id"))
+		return ctx.JSON(res)
+	}
+
+	// Log the successful export of the user
+	controller.logger.Info().Msgf("Successfully exported user with ID: %s", ctx.Params("id"))
+
+	// Return the archive as an attachment
+	ctx.Attachment(res.FileName)
+	ctx.Type("zip")
+	return ctx.Send(res.Archive)
+}
+
 // Store creates a new user
 func (controller UsersController) Store(ctx *fiber.Ctx) error {
 	// Log the start of the Store method
@@ -156,6 +194,9 @@ func (controller UsersController) Update(ctx *fiber.Ctx) error {
 		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.BAD_REQUEST, fmt.Sprintf("BAD REQUEST : %s \nREQUEST BODY \n%s", errParse.Error(), reflecthelper.KeyValueToString(*req)))}}
 	}
 
+	// The ETag of the user the client expects to replace
+	req.IfMatch = utils.CopyString(ctx.Get(fiber.HeaderIfMatch))
+
 	// Log the received request data
 	controller.logger.Debug().Msgf("Received request data: %+v", req)
 
@@ -172,6 +213,9 @@ func (controller UsersController) Update(ctx *fiber.Ctx) error {
 	// Log the successful update of the user
 	controller.logger.Info().Msgf("Successfully updated user with ID: %s", ctx.Params("id"))
 
+	// Expose the version of the user for conditional requests
+	ctx.Set(fiber.HeaderETag, res.ETag)
+
 	// Set the response status code
 	ctx.Status(res.Status)
 
@@ -188,14 +232,26 @@ func (controller UsersController) Edit(ctx *fiber.Ctx) error {
 	req := new(request.UserEdit)
 	controller.logger.Debug().Msg("Created new UserEdit request")
 
-	// Parse the request body into the UserEdit struct
-	if errParse := ctx.BodyParser(req); errParse != nil {
+	// Parse the request body into the UserEdit struct, as a merge patch, a JSON Patch or a plain JSON or form body
+	var errParse error
+	switch contentType, _, _ := strings.Cut(utils.ToLower(string(ctx.Request().Header.ContentType())), ";"); strings.TrimSpace(contentType) {
+	case request.MIMEApplicationMergePatchJSON:
+		errParse = request.ParseMergePatch(ctx.Body(), req)
+	case request.MIMEApplicationJSONPatchJSON:
+		errParse = request.ParseJSONPatch(ctx.Body(), req)
+	default:
+		errParse = ctx.BodyParser(req)
+	}
+	if errParse != nil {
 		// Log the error during body parsing
 		controller.logger.Error().Err(errParse).Msg("Failed to parse request body")
 		// Return a bad request error if parsing fails
 		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.BAD_REQUEST, fmt.Sprintf("BAD REQUEST : %s \nREQUEST BODY \n%s", errParse.Error(), reflecthelper.KeyValueToString(*req)))}}
 	}
 
+	// The ETag of the user the client expects to modify
+	req.IfMatch = utils.CopyString(ctx.Get(fiber.HeaderIfMatch))
+
 	// Log the received request data
 	controller.logger.Debug().Msgf("Received request data: %+v", req)
 
@@ -212,6 +268,9 @@ func (controller UsersController) Edit(ctx *fiber.Ctx) error {
 	// Log the successful editing of the user
 	controller.logger.Info().Msgf("Successfully edited user with ID: %s", ctx.Params("id"))
 
+	// Expose the version of the user for conditional requests
+	ctx.Set(fiber.HeaderETag, res.ETag)
+
 	// Set the response status code
 	ctx.Status(res.Status)
 
@@ -219,14 +278,24 @@ func (controller UsersController) Edit(ctx *fiber.Ctx) error {
 	return ctx.JSON(res)
 }
 
-// Destroy deletes a user by ID
+// Destroy deletes a user by ID, or purges it permanently when the query 'purge=true' is given
 func (controller UsersController) Destroy(ctx *fiber.Ctx) error {
 	// Log the start of the Destroy method
 	controller.logger.Info().Msg("Destroy method called")
 
-	// Delete the user using the usecase
-	controller.logger.Info().Msg("Calling usecase Delete method")
-	res, errors := controller.usecases.Delete(ctx.Context(), utils.CopyString(ctx.Params("id")))
+	var (
+		res    *response.Standard
+		errors *response.StandardErrors
+	)
+	if ctx.QueryBool("purge") {
+		// Purge the user using the usecase
+		controller.logger.Info().Msg("Calling usecase Purge method")
+		res, errors = controller.usecases.Purge(ctx.Context(), utils.CopyString(ctx.Params("id")))
+	} else {
+		// Delete the user using the usecase
+		controller.logger.Info().Msg("Calling usecase Delete method")
+		res, errors = controller.usecases.Delete(ctx.Context(), utils.CopyString(ctx.Params("id")))
+	}
 	if errors != nil {
 		// Log the error during user deletion
 		controller.logger.Error().Err(errors).Msg("Failed to delete user")
@@ -0,0 +1,64 @@
+// Package scheduler provides background jobs that run next to the HTTP server
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/retention"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/usecase"
+)
+
+// RetentionJob periodically purges users that were soft-deleted longer ago than the retention policy allows
+type RetentionJob struct {
+	usecases *usecase.UsersUsecase
+	config   *retention.Config
+	logger   *log.Logger
+}
+
+// NewRetentionJob creates a new RetentionJob
+func NewRetentionJob(usecases *usecase.UsersUsecase, config *retention.Config, logger *log.Logger) *RetentionJob {
+	logger.Info().Msg("RetentionJob initialized")
+	return &RetentionJob{usecases: usecases, config: config, logger: logger}
+}
+
+// Run executes a single retention pass and returns the number of purged users
+func (job RetentionJob) Run(ctx context.Context) int {
+	cutoff := job.config.Cutoff(time.Now())
+	job.logger.Info().Msgf("Retention run started, purging users deleted before %s", cutoff.Format(time.RFC3339))
+
+	// The purges are recorded in the audit trail with the job as the actor
+	ctx = request.WithMeta(ctx, &request.Meta{ActorID: "system:retention"})
+	purged, errors := job.usecases.PurgeExpired(ctx, cutoff, job.config.BatchSize)
+	if errors != nil {
+		// Log the error, the remaining users are picked up on the next run
+		job.logger.Error().Err(errors).Msg("Retention run failed")
+	}
+
+	job.logger.Info().Msgf("Retention run finished, %d users purged", purged)
+	return purged
+}
+
+// Start runs the job immediately and then on every interval until the context is cancelled
+func (job RetentionJob) Start(ctx context.Context) {
+	if !job.config.Enabled() {
+		job.logger.Info().Msg("Retention job disabled")
+		return
+	}
+
+	ticker := time.NewTicker(job.config.Period())
+	defer ticker.Stop()
+
+	job.Run(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			job.logger.Info().Msg("Retention job stopped")
+			return
+		case <-ticker.C:
+			job.Run(ctx)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"github.com/tirtahakimpambudhi/restful_api/internal/usecase"
+	"github.com/tirtahakimpambudhi/restful_api/internal/validation"
+)
+
+// Scheduler holds every background job of the application
+type Scheduler struct {
+	Retention *RetentionJob
+}
+
+// NewScheduler wires the background jobs with their dependencies
+func NewScheduler(app *bootstrap.App) (*Scheduler, error) {
+	app.Logger.App.Info().Msg("NewScheduler Call Function")
+	english := en.New()
+	translator, found := ut.New(english, english).GetTranslator("en")
+	if !found {
+		app.Logger.App.Error().Msg("the language English not found package")
+		return nil, fmt.Errorf("the language English not found package")
+	}
+
+	// Create a new UsersRepository implementation
+	usersRepository, err := repository.NewUsersRepositoryImpl(app.Gorm, app.Logger.App)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, err
+	}
+
+	// Create a new AuditRepository implementation
+	auditRepository, err := repository.NewAuditRepositoryImpl(app.Gorm, app.Logger.App)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, err
+	}
+
+	// Create the UnitOfWork sharing a transaction between the repositories
+	unitOfWork, err := repository.NewUnitOfWork(app.Gorm, app.Logger.App)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, err
+	}
+
+	// Create a new UserCacheRepository instance
+	redisClient, err := app.Redis.NewClient()
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, err
+	}
+	cacheRepository, err := repository.NewUserCacheRepository(redisClient, app.Logger.App, app.Redis)
+	if err != nil {
+		app.Logger.App.Error().Err(err)
+		return nil, err
+	}
+
+	usersUsecase := usecase.NewUsersUsecaseBuilder().
+		WithHashing(app.Hash).
+		WithLogger(app.Logger.App).
+		WithUsersRepository(usersRepository).
+		WithCacheRepository(cacheRepository).
+		WithValidator(validation.NewValidator(validator.New(), translator)).
+		WithTimeoutConfig(app.Timeout).
+		WithEnforcer(app.CasbinEnforcer).
+		WithPolicyWatcher(app.PolicyWatcher).
+		WithExportRepository(repository.NewExportRepository(redisClient, app.Logger.App)).
+		WithAuditRepository(auditRepository).
+		WithUnitOfWork(unitOfWork).
+		Build()
+
+	return &Scheduler{
+		Retention: NewRetentionJob(usersUsecase, app.Retention, app.Logger.App),
+	}, nil
+}
+
+// Start launches every job in its own goroutine, they stop when the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.Retention.Start(ctx)
+}
@@ -0,0 +1,40 @@
+package entity
+
+// Audit actions recorded in the audit_events table.
+const (
+	AuditLogin         = "auth.login"
+	AuditLogout        = "auth.logout"
+	AuditPasswordReset = "auth.password_reset"
+	AuditRoleChange    = "auth.role_change"
+	AuditUserCreate    = "users.create"
+	AuditUserUpdate    = "users.update"
+	AuditUserDelete    = "users.delete"
+	AuditUserRestore   = "users.restore"
+	AuditUserPurge     = "users.purge"
+)
+
+// Audit outcomes recorded in the audit_events table.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvents represents table audit_events in database, rows are only ever inserted.
+// The users are referred to by their ID only, so purging a user leaves none of its personal data in the trail,
+// the actor is the name of the process (seed, cli, system:retention) when no user acts.
+type AuditEvents struct {
+	ID        string `gorm:"primary_key;column:id"`
+	Action    string `gorm:"column:action"`
+	Outcome   string `gorm:"column:outcome"`
+	ActorID   string `gorm:"column:actor_id"`
+	TargetID  string `gorm:"column:target_id"`
+	IP        string `gorm:"column:ip"`
+	UserAgent string `gorm:"column:user_agent"`
+	Changes   string `gorm:"column:changes"`
+	CreatedAt int64  `gorm:"column:created_at;autoCreateTime:milli"`
+}
+
+// Used for implement model gorm
+func (a AuditEvents) TableName() string {
+	return "audit_events"
+}
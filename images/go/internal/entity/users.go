@@ -11,9 +11,20 @@ type Users struct {
 	CreatedAt int64                 `gorm:"column:created_at;autoCreateTime:milli"`
 	UpdatedAt int64                 `gorm:"column:updated_at;autoUpdateTime:milli"`
 	DeletedAt soft_delete.DeletedAt `gorm:"column:deleted_at;softDelete:milli;default:0"`
+	Version   int64                 `gorm:"column:version;default:1"`
 }
 
 // Used for implement model gorm
 func (u Users) TableName() string {
 	return "users"
 }
+
+// GetVersion returns the version used for optimistic concurrency control
+func (u *Users) GetVersion() int64 {
+	return u.Version
+}
+
+// SetVersion replaces the version used for optimistic concurrency control
+func (u *Users) SetVersion(version int64) {
+	u.Version = version
+}
@@ -21,6 +21,8 @@ const (
 	REQUEST_TIMEOUT       TypeErr = "REQUEST_TIMEOUT"
 	NOT_FOUND             TypeErr = "NOT_FOUND"
 	TO_MANY_REQUEST       TypeErr = "TO_MANY_REQUEST"
+	PRECONDITION_FAILED   TypeErr = "PRECONDITION_FAILED"
+	PRECONDITION_REQUIRED TypeErr = "PRECONDITION_REQUIRED"
 )
 
 // NewError creates a new response.Error based on the provided code and detail message.
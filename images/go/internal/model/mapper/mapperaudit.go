@@ -0,0 +1,36 @@
+package mapper
+
+import (
+	"encoding/json"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+)
+
+// Converts an entity.AuditEvents entity to a response.AuditEvent for response formatting.
+func EntityAuditToResponse(event *entity.AuditEvents) *response.AuditEvent {
+	changes := json.RawMessage(event.Changes)
+	if !json.Valid(changes) {
+		changes = json.RawMessage("{}") // Keep the response valid JSON even for a malformed stored diff
+	}
+	return &response.AuditEvent{
+		ID:        event.ID,        // Event ID
+		Action:    event.Action,    // Audited action
+		Outcome:   event.Outcome,   // Success or failure
+		ActorID:   event.ActorID,   // User or process performing the action
+		TargetID:  event.TargetID,  // User affected by the action
+		IP:        event.IP,        // Client IP address
+		UserAgent: event.UserAgent, // Client User-Agent
+		Changes:   changes,         // Diff of the changed fields
+		CreatedAt: event.CreatedAt, // Event timestamp
+	}
+}
+
+// Converts an []entity.AuditEvents entity to a []response.AuditEvent for response formatting.
+func EntitiesAuditToResponses(events []*entity.AuditEvents) []*response.AuditEvent {
+	responses := []*response.AuditEvent{}
+	for _, event := range events {
+		responses = append(responses, EntityAuditToResponse(event))
+	}
+	return responses
+}
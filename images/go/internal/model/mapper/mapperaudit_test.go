@@ -0,0 +1,45 @@
+package mapper_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/mapper"
+)
+
+func TestEntityAuditToResponseConversion(t *testing.T) {
+	event := &entity.AuditEvents{
+		ID:       "123",
+		Action:   entity.AuditUserUpdate,
+		Outcome:  entity.AuditSuccess,
+		ActorID:  "456",
+		TargetID: "789",
+		Changes:  `{"username":{"from":"old","to":"new"}}`,
+	}
+
+	result := mapper.EntityAuditToResponse(event)
+
+	require.Equal(t, "123", result.ID)
+	require.Equal(t, entity.AuditUserUpdate, result.Action)
+	require.JSONEq(t, event.Changes, string(result.Changes))
+}
+
+func TestEntityAuditToResponseConversion_WhenChangesMalformed(t *testing.T) {
+	event := &entity.AuditEvents{ID: "123", Changes: "not json"}
+
+	result := mapper.EntityAuditToResponse(event)
+
+	require.True(t, json.Valid(result.Changes))
+	require.Equal(t, "{}", string(result.Changes))
+}
+
+func TestEntitiesAuditToResponsesConversion(t *testing.T) {
+	events := []*entity.AuditEvents{{ID: "1", Changes: "{}"}, {ID: "2", Changes: "{}"}}
+
+	result := mapper.EntitiesAuditToResponses(events)
+
+	require.Len(t, result, 2)
+	require.Equal(t, "2", result[1].ID)
+}
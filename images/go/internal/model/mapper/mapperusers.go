@@ -16,14 +16,18 @@ func RequestUserToEntity(id string, user request.User) *entity.Users {
 	}
 }
 
-// Converts a request.UserEdit to an entity.Users entity.
-func RequestUserEditToEntity(id string, user request.UserEdit) *entity.Users {
-	return &entity.Users{
-		ID:       id,            // User ID
-		Username: user.Username, // User's username
-		Email:    user.Email,    // User's email
-		Password: user.Password, // User's password
+// Applies the fields present in a request.UserEdit to a copy of the stored entity.Users entity.
+func RequestUserEditToEntity(users entity.Users, user request.UserEdit) *entity.Users {
+	if user.Username != nil {
+		users.Username = *user.Username // User's username
+	}
+	if user.Email != nil {
+		users.Email = *user.Email // User's email
+	}
+	if user.Password != nil {
+		users.Password = *user.Password // User's password
 	}
+	return &users
 }
 
 // Converts an entity.Users entity to a response.User for response formatting.
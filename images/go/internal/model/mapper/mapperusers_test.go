@@ -75,17 +75,20 @@ func TestRequestUserToEntityConversion(t *testing.T) {
 }
 
 func TestRequestUserEditToEntityConversion(t *testing.T) {
+	username := "testuser"
+	password := "password123"
 	reqUser := request.UserEdit{
-		Username: "testuser",
-		Email:    "testuser@example.com",
-		Password: "password123",
+		Username: &username,
+		Password: &password,
 	}
 
-	id := "12345"
-	entityUser := mapper.RequestUserEditToEntity(id, reqUser)
+	stored := entity.Users{ID: "12345", Username: "olduser", Email: "olduser@example.com", Password: "oldhash", Version: 2}
+	entityUser := mapper.RequestUserEditToEntity(stored, reqUser)
 
-	require.Equal(t, id, entityUser.ID)
-	require.Equal(t, reqUser.Username, entityUser.Username)
-	require.Equal(t, reqUser.Email, entityUser.Email)
-	require.Equal(t, reqUser.Password, entityUser.Password)
+	require.Equal(t, stored.ID, entityUser.ID)
+	require.Equal(t, username, entityUser.Username)
+	require.Equal(t, stored.Email, entityUser.Email)
+	require.Equal(t, password, entityUser.Password)
+	require.Equal(t, stored.Version, entityUser.Version)
+	require.Equal(t, "olduser", stored.Username)
 }
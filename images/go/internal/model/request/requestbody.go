@@ -5,13 +5,16 @@ type User struct {
 	Username string `json:"username" form:"username" validate:"required,min=5"`   // Required username with minimum length of 5
 	Email    string `json:"email" form:"email" validate:"required,email,max=254"` // Required email with max length of 254
 	Password string `json:"password" form:"password" validate:"required,min=8"`   // Required password with minimum length of 8
+	IfMatch  string `json:"-" form:"-"`                                           // ETag of the user the client expects to replace, taken from the If-Match header
 }
 
-// Struct representing a user edit request with optional fields.
+// Struct representing a user edit request, only the fields present are validated and applied.
 type UserEdit struct {
-	Username string `json:"username" form:"username" validate:"min=5"`   // Required username with minimum length of 5
-	Email    string `json:"email" form:"email" validate:"email,max=254"` // Optional email with max length of 254
-	Password string `json:"password" form:"password" validate:"min=8"`   // Optional password with minimum length of 8
+	Username   *string          `json:"username,omitempty" form:"username" validate:"omitempty,min=5"`   // Optional username with minimum length of 5
+	Email      *string          `json:"email,omitempty" form:"email" validate:"omitempty,email,max=254"` // Optional email with max length of 254
+	Password   *string          `json:"password,omitempty" form:"password" validate:"omitempty,min=8"`   // Optional password with minimum length of 8
+	Operations []PatchOperation `json:"-" form:"-" validate:"omitempty,dive"`                            // JSON Patch operations applied to the stored user
+	IfMatch    string           `json:"-" form:"-"`                                                      // ETag of the user the client expects to modify, taken from the If-Match header
 }
 
 // Struct for authentication requests.
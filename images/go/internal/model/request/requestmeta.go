@@ -0,0 +1,31 @@
+package request
+
+import "context"
+
+// Struct describing who sends a request and from where, carried in the request context for the audit trail and the logs.
+type Meta struct {
+	RequestID string // Correlation ID of the request, echoed in the X-Request-Id header
+	Route     string // Method and path of the request
+	ActorID   string // ID of the authenticated user, the name of the process for the jobs, empty for anonymous requests
+	IP        string // Client IP address
+	UserAgent string // Client User-Agent header
+}
+
+// metaKey is the context key of the request Meta.
+type metaKey struct{}
+
+// MetaKey is the key the request Meta is stored under, usable with context.WithValue and fasthttp user values.
+var MetaKey = metaKey{}
+
+// WithMeta returns a copy of ctx carrying the request Meta.
+func WithMeta(ctx context.Context, meta *Meta) context.Context {
+	return context.WithValue(ctx, MetaKey, meta)
+}
+
+// MetaFromContext returns the request Meta carried by ctx, or an empty Meta when there is none.
+func MetaFromContext(ctx context.Context) *Meta {
+	if meta, ok := ctx.Value(MetaKey).(*Meta); ok && meta != nil {
+		return meta
+	}
+	return &Meta{}
+}
@@ -0,0 +1,47 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Media types accepted for partial updates besides application/json and form bodies.
+const (
+	MIMEApplicationMergePatchJSON = "application/merge-patch+json" // JSON Merge Patch (RFC 7396)
+	MIMEApplicationJSONPatchJSON  = "application/json-patch+json"  // JSON Patch (RFC 6902)
+)
+
+// Struct representing a single JSON Patch operation.
+type PatchOperation struct {
+	Op    string          `json:"op" validate:"required,oneof=add remove replace move copy test"`    // Operation to perform
+	Path  string          `json:"path" validate:"required,startswith=/"`                             // JSON Pointer of the target member
+	From  string          `json:"from,omitempty" validate:"required_if=Op move,required_if=Op copy"` // JSON Pointer of the source member for move and copy
+	Value json.RawMessage `json:"value,omitempty"`                                                   // Value for add, replace and test
+}
+
+// ParseMergePatch decodes a JSON Merge Patch document into the edit request.
+// A null member asks to remove the field, which no user field allows.
+func ParseMergePatch(body []byte, edit *UserEdit) error {
+	members := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &members); err != nil {
+		return err
+	}
+	for name, value := range members {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			return fmt.Errorf("member '%s' can not be removed", name)
+		}
+	}
+	return json.Unmarshal(body, edit)
+}
+
+// ParseJSONPatch decodes a JSON Patch document into the operations of the edit request.
+func ParseJSONPatch(body []byte, edit *UserEdit) error {
+	if err := json.Unmarshal(body, &edit.Operations); err != nil {
+		return err
+	}
+	if len(edit.Operations) == 0 {
+		return fmt.Errorf("patch document has no operations")
+	}
+	return nil
+}
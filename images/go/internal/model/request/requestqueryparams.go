@@ -1,6 +1,9 @@
 package request
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+)
 
 // Struct for pagination parameters in requests.
 type Page struct {
@@ -20,3 +23,60 @@ func (page Page) GetQueryParams() string {
 	}
 	return queryParams
 }
+
+// Struct for audit event query parameters in requests, events are listed newest first.
+type AuditPage struct {
+	Size     int    `query:"size" validate:"required,gt=0,lte=100"`              // Page size (required, greater than 0)
+	Before   string `query:"before" validate:"omitempty,len=27"`                 // Optional cursor, events older than this event ID
+	After    string `query:"after" validate:"omitempty,len=27"`                  // Optional cursor, events newer than this event ID
+	Action   string `query:"action" validate:"omitempty,max=64"`                 // Optional action filter, e.g. 'auth.login'
+	Outcome  string `query:"outcome" validate:"omitempty,oneof=success failure"` // Optional outcome filter
+	ActorID  string `query:"actor_id" validate:"omitempty,len=27"`               // Optional actor user ID filter
+	TargetID string `query:"target_id" validate:"omitempty,len=27"`              // Optional target user ID filter
+	From     int64  `query:"from" validate:"omitempty,gte=0"`                    // Optional lower bound of created_at in Unix milliseconds
+	To       int64  `query:"to" validate:"omitempty,gte=0"`                      // Optional upper bound of created_at in Unix milliseconds
+}
+
+// Generates query parameters string for audit event pagination, keeping the filters.
+func (page AuditPage) GetQueryParams() string {
+	queryParams := page.filterParams()
+	if page.Before != "" {
+		queryParams.Set("before", page.Before) // Add before cursor if present
+	}
+	if page.After != "" {
+		queryParams.Set("after", page.After) // Add after cursor if present
+	}
+	return "?" + queryParams.Encode()
+}
+
+// Generates the query parameters of the page size and the filters, the values escaped once encoded.
+func (page AuditPage) filterParams() url.Values {
+	queryParams := url.Values{}
+	queryParams.Set("size", fmt.Sprint(page.Size))
+	if page.Action != "" {
+		queryParams.Set("action", page.Action)
+	}
+	if page.Outcome != "" {
+		queryParams.Set("outcome", page.Outcome)
+	}
+	if page.ActorID != "" {
+		queryParams.Set("actor_id", page.ActorID)
+	}
+	if page.TargetID != "" {
+		queryParams.Set("target_id", page.TargetID)
+	}
+	if page.From != 0 {
+		queryParams.Set("from", fmt.Sprint(page.From))
+	}
+	if page.To != 0 {
+		queryParams.Set("to", fmt.Sprint(page.To))
+	}
+	return queryParams
+}
+
+// Generates query parameters string of the page following the given last event ID.
+func (page AuditPage) GetNextQueryParams(lastID string) string {
+	queryParams := page.filterParams()
+	queryParams.Set("before", lastID)
+	return "?" + queryParams.Encode()
+}
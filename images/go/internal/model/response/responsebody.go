@@ -1,6 +1,7 @@
 package response
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -10,6 +11,7 @@ type Standard struct {
 	Status int            `json:"status"`
 	Code   string         `json:"code"`
 	Data   any            `json:"data"`
+	ETag   string         `json:"-"`
 }
 
 type Error struct {
@@ -46,6 +48,16 @@ type LinksAble struct {
 	Data   any            `json:"data"`
 }
 
+// Export holds either a ready data export archive or the state of its background job.
+type Export struct {
+	Meta     map[string]any `json:"meta,omitempty"`
+	Status   int            `json:"status"`
+	Code     string         `json:"code"`
+	Data     any            `json:"data"`
+	FileName string         `json:"-"`
+	Archive  []byte         `json:"-"`
+}
+
 type User struct {
 	ID        string `json:"id"`
 	Username  string `json:"username"`
@@ -54,6 +66,18 @@ type User struct {
 	UpdatedAt int64  `json:"updated_at,omitempty"`
 }
 
+type AuditEvent struct {
+	ID        string          `json:"id"`
+	Action    string          `json:"action"`
+	Outcome   string          `json:"outcome"`
+	ActorID   string          `json:"actor_id,omitempty"`
+	TargetID  string          `json:"target_id,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	UserAgent string          `json:"user_agent,omitempty"`
+	Changes   json.RawMessage `json:"changes"`
+	CreatedAt int64           `json:"created_at"`
+}
+
 type Token struct {
 	AccessToken string `json:"access_token"`
 	ExpiredAt   int64  `json:"expired_at"`
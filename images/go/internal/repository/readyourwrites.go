@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// writesKey is the context key of the Writes of the current request.
+type writesKey struct{}
+
+// WritesKey is the key the Writes of a request are stored under, usable with context.WithValue and fasthttp user values.
+var WritesKey = writesKey{}
+
+// Writes records when a request last wrote to the database.
+// For the window after a write the reads of the request go to the primary, so it reads its own writes whatever the lag of the replicas.
+type Writes struct {
+	window time.Duration
+	last   atomic.Int64 // Unix nanoseconds of the last write, zero before any
+	now    func() time.Time
+}
+
+// NewWrites creates the Writes of a request with the read-your-writes window.
+func NewWrites(window time.Duration) *Writes {
+	return &Writes{window: window, now: time.Now}
+}
+
+// WithWrites returns a copy of ctx carrying the Writes.
+func WithWrites(ctx context.Context, writes *Writes) context.Context {
+	return context.WithValue(ctx, WritesKey, writes)
+}
+
+// writesFromContext returns the Writes carried by ctx, nil when there are none.
+func writesFromContext(ctx context.Context) *Writes {
+	writes, _ := ctx.Value(WritesKey).(*Writes)
+	return writes
+}
+
+// mark records a write happening now.
+func (writes *Writes) mark() {
+	if writes != nil {
+		writes.last.Store(writes.now().UnixNano())
+	}
+}
+
+// recent reports whether the last write is within the window.
+func (writes *Writes) recent() bool {
+	if writes == nil {
+		return false
+	}
+	last := writes.last.Load()
+	return last != 0 && writes.now().Sub(time.Unix(0, last)) < writes.window
+}
+
+// primaryKey is the context key marking the reads that go to the primary whatever the writes of the request.
+type primaryKey struct{}
+
+// readPrimary returns a copy of ctx whose reads go to the primary.
+// The cache is filled this way, an entry loaded from a lagging replica right after an invalidation would outlive the lag.
+func readPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// routeRead sends the reads of ctx to the primary while its request recently wrote or fills the cache, to a replica otherwise.
+func routeRead(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary || writesFromContext(ctx).recent() {
+		return db.Clauses(dbresolver.Write)
+	}
+	return db
+}
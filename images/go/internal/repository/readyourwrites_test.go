@@ -0,0 +1,132 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	cacheconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"gorm.io/driver/postgres"
+)
+
+func TestReadReplicaRouting(t *testing.T) {
+	primaryDriver, primary, err := sqlmock.New()
+	require.NoError(t, err)
+	defer primaryDriver.Close()
+	replicaDriver, replica, err := sqlmock.New()
+	require.NoError(t, err)
+	defer replicaDriver.Close()
+
+	config := &sqlconfig.SqlConfig{MaxCon: 7, MinCon: 3, MaxTime: 30, MinTime: 5}
+	db, err := orm.Open(
+		postgres.New(postgres.Config{Conn: primaryDriver, DriverName: "postgres"}),
+		config,
+		postgres.New(postgres.Config{Conn: replicaDriver, DriverName: "postgres"}),
+	)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	require.Equal(t, 7, sqlDB.Stats().MaxOpenConnections)
+
+	repo, err := repository.NewUsersRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	unitOfWork, err := repository.NewUnitOfWork(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	countUsers := `SELECT count\(\*\) FROM "users"`
+	insertUsers := `INSERT INTO "users" (.+) VALUES (.+)`
+
+	t.Run("ReadsGoToTheReplica", func(t *testing.T) {
+		replica.ExpectPrepare(countUsers).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		total, err := repo.Count(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(3), total)
+		require.NoError(t, replica.ExpectationsWereMet())
+		require.NoError(t, primary.ExpectationsWereMet())
+	})
+
+	t.Run("ReadYourWrites", func(t *testing.T) {
+		ctx := repository.WithWrites(context.Background(), repository.NewWrites(5*time.Second))
+		primary.ExpectBegin()
+		// The statement is prepared on the pool, then again on the connection of the transaction.
+		primary.ExpectPrepare(insertUsers)
+		primary.ExpectPrepare(insertUsers).ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+		primary.ExpectCommit()
+		primary.ExpectQuery(countUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+		require.NoError(t, repo.Create(ctx, &entity.Users{ID: ksuid.New().String(), Username: "user", Email: "user@example.com"}))
+		// The request wrote, the count must see the new user.
+		total, err := repo.Count(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), total)
+		require.NoError(t, primary.ExpectationsWereMet())
+
+		// Another request never reads from the primary.
+		replica.ExpectQuery(countUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+		total, err = repo.Count(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, int64(4), total)
+		require.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("WindowElapses", func(t *testing.T) {
+		ctx := repository.WithWrites(context.Background(), repository.NewWrites(time.Millisecond))
+		primary.ExpectBegin()
+		primary.ExpectPrepare(`DELETE FROM "users"`)
+		primary.ExpectPrepare(`DELETE FROM "users"`).ExpectExec().WillReturnResult(sqlmock.NewResult(0, 1))
+		primary.ExpectCommit()
+		replica.ExpectQuery(countUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		require.NoError(t, repo.Purge(ctx, ksuid.New().String()))
+		time.Sleep(2 * time.Millisecond)
+		total, err := repo.Count(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(3), total)
+		require.NoError(t, primary.ExpectationsWereMet())
+		require.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("TransactionsUseThePrimary", func(t *testing.T) {
+		primary.ExpectBegin()
+		primary.ExpectPrepare(countUsers)
+		primary.ExpectPrepare(countUsers).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+		primary.ExpectCommit()
+
+		err := unitOfWork.Do(context.Background(), func(ctx context.Context) error {
+			_, err := repo.Count(ctx)
+			return err
+		})
+		require.NoError(t, err)
+		require.NoError(t, primary.ExpectationsWereMet())
+		require.NoError(t, replica.ExpectationsWereMet())
+	})
+
+	t.Run("CacheFillsUseThePrimary", func(t *testing.T) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+		cache, err := repository.NewCacheRepository[int64](client, &log.DefaultLogger, &cacheconfig.RedisConfig{TTL: 30, SoftTTL: 30})
+		require.NoError(t, err)
+		primary.ExpectQuery(countUsers).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(5))
+
+		// A request that never wrote fills the cache, right after the count changed on the primary.
+		total, err := cache.FetchOne(context.Background(), "users:count", func(ctx context.Context) (int64, []string, error) {
+			total, err := repo.Count(ctx)
+			return total, nil, err
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(5), total)
+		require.NoError(t, primary.ExpectationsWereMet())
+		require.NoError(t, replica.ExpectationsWereMet())
+	})
+}
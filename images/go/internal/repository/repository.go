@@ -2,11 +2,22 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
 	"gorm.io/gorm"
 )
 
+// ErrVersionConflict is returned by Update when the stored version of a Versioned entity no longer matches the expected one.
+var ErrVersionConflict = errors.New("version conflict")
+
+// Versioned is implemented by entities which use optimistic concurrency control.
+type Versioned interface {
+	GetVersion() int64
+	SetVersion(version int64)
+}
+
 // Repository is a generic repository struct for handling database operations.
 type Repository[T any] struct {
 	Logger *log.Logger // Logger for logging operations.
@@ -18,127 +29,198 @@ func NewRepository[T any](logger *log.Logger, db *gorm.DB) *Repository[T] {
 	return &Repository[T]{Logger: logger, DB: db}
 }
 
-// CommitOrRollback handles transaction commit or rollback based on whether an error occurred.
-func (r *Repository[T]) CommitOrRollback(tx *gorm.DB) {
-	if err := recover(); err != nil {
-		// Log error and rollback transaction if an error occurred.
-		r.Logger.Error().Msgf("Failed to commit Database : %v", err)
-		tx.Rollback()
-	} else {
-		// Commit transaction if no error occurred.
-		tx.Commit()
-	}
-}
-
-// Transaction starts a new database transaction and returns the transaction and a function to commit or rollback.
-func (r *Repository[T]) Transaction(ctx context.Context) (*gorm.DB, func(tx *gorm.DB)) {
-	tx := r.DB.Begin().WithContext(ctx).Model(new(T))
-	return tx, r.CommitOrRollback
-}
-
 // Create attempts to create a new entity in the database.
 func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
-	r.Logger.Info().Msg("Attempting to create a new entity")
-	tx, closeTx := r.Transaction(ctx) // Start transaction
-	defer closeTx(tx)                 // Ensure transaction is committed or rolled back
-
-	// Create entity in the database
-	err := tx.Create(entity).Error
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msg("Attempting to create a new entity")
+	// Create entity in the database, joining the unit of work of ctx if any
+	err := transaction(ctx, r.DB, func(tx *gorm.DB) error {
+		return tx.Model(new(T)).Create(entity).Error
+	})
 	if err != nil {
-		// Log error and rollback transaction if creation failed.
-		tx.Rollback()
-		r.Logger.Error().Msgf("Failed to create entity: %v", err)
+		logger.Error().Msgf("Failed to create entity: %v", err)
 		return err
 	}
 
 	// Log success if entity creation was successful.
-	r.Logger.Info().Msg("Entity successfully created")
+	logger.Info().Msg("Entity successfully created")
 	return nil
 }
 
 // Update attempts to update an existing entity in the database.
 func (r *Repository[T]) Update(ctx context.Context, entity *T, id any) error {
-	r.Logger.Info().Msg("Attempting to update an entity")
-	tx, closeTx := r.Transaction(ctx) // Start transaction
-	defer closeTx(tx)                 // Ensure transaction is committed or rolled back
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msg("Attempting to update an entity")
+
+	// A versioned entity only updates when the stored version still matches the expected one,
+	// bumping the version in the same statement so the check and the write are atomic.
+	versioned, isVersioned := any(entity).(Versioned)
+	expected := int64(0)
+	if isVersioned && versioned.GetVersion() > 0 {
+		expected = versioned.GetVersion()
+		versioned.SetVersion(expected + 1)
+	}
 
-	// Update entity in the database
-	err := tx.Where("id = ?", id).Updates(entity).Error
+	// Update entity in the database, joining the unit of work of ctx if any
+	err := transaction(ctx, r.DB, func(tx *gorm.DB) error {
+		query := tx.Model(new(T)).Where("id = ?", id)
+		if expected > 0 {
+			query = query.Where("version = ?", expected)
+		}
+		result := query.Updates(entity)
+		if result.Error != nil {
+			return result.Error
+		}
+		if expected > 0 && result.RowsAffected == 0 {
+			// Nothing matched the expected version, someone else updated the entity first.
+			return ErrVersionConflict
+		}
+		return nil
+	})
 	if err != nil {
-		// Log error and rollback transaction if update failed.
-		tx.Rollback()
-		r.Logger.Error().Msgf("Failed to update entity: %v", err)
+		// Keep the expected version when the update did not happen.
+		if expected > 0 {
+			versioned.SetVersion(expected)
+		}
+		logger.Error().Msgf("Failed to update entity: %v", err)
 		return err
 	}
 
 	// Log success if entity update was successful.
-	r.Logger.Info().Msg("Entity successfully updated")
+	logger.Info().Msg("Entity successfully updated")
 	return nil
 }
 
 // Delete attempts to delete an entity from the database.
 func (r *Repository[T]) Delete(ctx context.Context, id any) error {
-	r.Logger.Info().Msg("Attempting to delete an entity")
-	tx, closeTx := r.Transaction(ctx) // Start transaction
-	defer closeTx(tx)                 // Ensure transaction is committed or rolled back
-
-	// Delete entity from the database
-	err := tx.Where("id = ?", id).Delete(new(T)).Error
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msg("Attempting to delete an entity")
+	// Delete entity from the database, joining the unit of work of ctx if any.
+	// A versioned entity gets a new version, so a version read before the deletion no longer matches once it is restored.
+	_, isVersioned := any(new(T)).(Versioned)
+	err := transaction(ctx, r.DB, func(tx *gorm.DB) error {
+		if isVersioned {
+			if err := tx.Model(new(T)).Where("id = ?", id).UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", id).Delete(new(T)).Error
+	})
 	if err != nil {
-		// Log error and rollback transaction if deletion failed.
-		tx.Rollback()
-		r.Logger.Error().Msgf("Failed to delete entity: %v", err)
+		logger.Error().Msgf("Failed to delete entity: %v", err)
 		return err
 	}
 
 	// Log success if entity deletion was successful.
-	r.Logger.Info().Msg("Entity successfully deleted")
+	logger.Info().Msg("Entity successfully deleted")
 	return nil
 }
 
 // CountById counts the number of entities with the specified ID.
 func (r Repository[T]) CountById(ctx context.Context, id any) (int64, error) {
-	r.Logger.Info().Msgf("Counting entities with ID: %v", id)
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msgf("Counting entities with ID: %v", id)
 	var total int64
 	// Count entities by ID in the database
-	err := r.DB.Model(new(T)).WithContext(ctx).Where("id = ?", id).Count(&total).Error
+	err := session(ctx, r.DB).Model(new(T)).Where("id = ?", id).Count(&total).Error
 	if err != nil {
 		// Log error if counting failed.
-		r.Logger.Error().Msgf("Failed to count entities by ID: %v", err)
+		logger.Error().Msgf("Failed to count entities by ID: %v", err)
 		return 0, err
 	}
 	// Log success with the total count of entities.
-	r.Logger.Info().Msgf("Total entities counted: %d", total)
+	logger.Info().Msgf("Total entities counted: %d", total)
 	return total, nil
 }
 
 // Count counts the total number of entities in the database.
 func (r Repository[T]) Count(ctx context.Context) (int64, error) {
-	r.Logger.Info().Msg("Counting entities")
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msg("Counting entities")
 	var total int64
 	// Count total entities in the database
-	err := r.DB.Model(new(T)).WithContext(ctx).Count(&total).Error
+	err := session(ctx, r.DB).Model(new(T)).Count(&total).Error
 	if err != nil {
 		// Log error if counting failed.
-		r.Logger.Error().Msg("Failed to count entities")
+		logger.Error().Msg("Failed to count entities")
 		return 0, err
 	}
 	// Log success with the total count of entities.
-	r.Logger.Info().Msgf("Total entities counted: %d", total)
+	logger.Info().Msgf("Total entities counted: %d", total)
 	return total, nil
 }
 
 // GetById retrieves an entity by its ID from the database.
 func (r Repository[T]) GetById(ctx context.Context, entity *T, id any) error {
-	r.Logger.Info().Msgf("Retrieving entity by ID: %v", id)
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msgf("Retrieving entity by ID: %v", id)
 	// Retrieve entity by ID from the database
-	err := r.DB.Model(new(T)).WithContext(ctx).Where("id = ?", id).Take(entity).Error
+	err := session(ctx, r.DB).Model(new(T)).Where("id = ?", id).Take(entity).Error
 	if err != nil {
 		// Log error if retrieval failed.
-		r.Logger.Error().Msgf("Failed to retrieve entity by ID: %v", err)
+		logger.Error().Msgf("Failed to retrieve entity by ID: %v", err)
 		return err
 	}
 	// Log success if entity retrieval was successful.
-	r.Logger.Info().Msg("Entity successfully retrieved")
+	logger.Info().Msg("Entity successfully retrieved")
 	return nil
 }
+
+// FindAll retrieves the entities matching the specification.
+func (r Repository[T]) FindAll(ctx context.Context, spec *Spec[T]) ([]*T, error) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msg("Retrieving entities by specification")
+	query, err := spec.apply(session(ctx, r.DB))
+	if err != nil {
+		logger.Error().Msgf("Failed to build specification: %v", err)
+		return nil, err
+	}
+	entities := []*T{}
+	// Retrieve the matching entities from the database
+	if err := query.Find(&entities).Error; err != nil {
+		logger.Error().Msgf("Failed to retrieve entities by specification: %v", err)
+		return nil, err
+	}
+	logger.Info().Msgf("Total entities retrieved: %d", len(entities))
+	return entities, nil
+}
+
+// FindOne retrieves the first entity matching the specification, gorm.ErrRecordNotFound is returned when none does.
+func (r Repository[T]) FindOne(ctx context.Context, entity *T, spec *Spec[T]) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msg("Retrieving entity by specification")
+	query, err := spec.apply(session(ctx, r.DB))
+	if err != nil {
+		logger.Error().Msgf("Failed to build specification: %v", err)
+		return err
+	}
+	// Retrieve the first matching entity from the database
+	if err := query.Take(entity).Error; err != nil {
+		logger.Error().Msgf("Failed to retrieve entity by specification: %v", err)
+		return err
+	}
+	logger.Info().Msg("Entity successfully retrieved")
+	return nil
+}
+
+// Exists reports whether any entity matches the specification, its ordering and limit are ignored.
+func (r Repository[T]) Exists(ctx context.Context, spec *Spec[T]) (bool, error) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	filter := &Spec[T]{}
+	if spec != nil {
+		filter = &Spec[T]{conditions: spec.conditions, unscoped: spec.unscoped}
+	}
+	query, err := filter.apply(session(ctx, r.DB))
+	if err != nil {
+		logger.Error().Msgf("Failed to build specification: %v", err)
+		return false, err
+	}
+	var total int64
+	// Count the matching entities in the database
+	if err := query.Count(&total).Error; err != nil {
+		logger.Error().Msgf("Failed to count entities by specification: %v", err)
+		return false, err
+	}
+	logger.Info().Msgf("Total entities counted: %d", total)
+	return total > 0, nil
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"time"
 )
 
 // UsersRepositoryMock is an autogenerated mock type for the UsersRepository type
@@ -81,6 +82,36 @@ func (m *UsersRepositoryMock) Restore(ctx context.Context, id any) error {
 	return args.Error(0)
 }
 
+// Purge provides a mock function with given fields: ctx, id
+func (m *UsersRepositoryMock) Purge(ctx context.Context, id any) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// GetByIdWithDeleted provides a mock function with given fields: ctx, entity, id
+func (m *UsersRepositoryMock) GetByIdWithDeleted(ctx context.Context, entity *entity.Users, id any) error {
+	args := m.Called(ctx, entity, id)
+	if args.Get(0) == nil {
+		return nil
+	}
+	return args.Error(0)
+}
+
+// GetByEmailWithDeleted provides a mock function with given fields: ctx, entity, email
+func (m *UsersRepositoryMock) GetByEmailWithDeleted(ctx context.Context, entity *entity.Users, email string) error {
+	args := m.Called(ctx, entity, email)
+	return args.Error(0)
+}
+
+// GetDeletedBefore provides a mock function with given fields: ctx, before, limit
+func (m *UsersRepositoryMock) GetDeletedBefore(ctx context.Context, before int64, limit int) ([]*entity.Users, error) {
+	args := m.Called(ctx, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.Users), args.Error(1)
+}
+
 // MockCacheRepository is an autogenerated mock type for the CacheRepository interface
 type MockCacheRepository[T any] struct {
 	mock.Mock
@@ -95,9 +126,59 @@ func (m *MockCacheRepository[T]) GetFromCache(ctx context.Context, key string) (
 	return args.Get(0).([]T), args.Error(1)
 }
 
-// SetToCache provides a mock function with given fields: ctx, key, entities
-func (m *MockCacheRepository[T]) SetToCache(ctx context.Context, key string, entities []T) error {
-	args := m.Called(ctx, key, entities)
+// SetToCache provides a mock function with given fields: ctx, key, entities, tags
+func (m *MockCacheRepository[T]) SetToCache(ctx context.Context, key string, entities []T, tags ...string) error {
+	args := m.Called(ctx, key, entities, tags)
+	return args.Error(0)
+}
+
+// GetOneFromCache provides a mock function with given fields: ctx, key
+func (m *MockCacheRepository[T]) GetOneFromCache(ctx context.Context, key string) (T, bool, error) {
+	args := m.Called(ctx, key)
+	var entity T
+	if args.Get(0) != nil {
+		entity = args.Get(0).(T)
+	}
+	return entity, args.Bool(1), args.Error(2)
+}
+
+// SetOneToCache provides a mock function with given fields: ctx, key, entity, tags
+func (m *MockCacheRepository[T]) SetOneToCache(ctx context.Context, key string, entity T, tags ...string) error {
+	args := m.Called(ctx, key, entity, tags)
+	return args.Error(0)
+}
+
+// Fetch reads through the mocked GetFromCache and SetToCache, calling load on a miss
+func (m *MockCacheRepository[T]) Fetch(ctx context.Context, key string, load Loader[[]T]) ([]T, error) {
+	entities, err := m.GetFromCache(ctx, key)
+	if err != nil || entities != nil {
+		return entities, err
+	}
+	entities, tags, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = m.SetToCache(ctx, key, entities, tags...)
+	return entities, nil
+}
+
+// FetchOne reads through the mocked GetOneFromCache and SetOneToCache, calling load on a miss
+func (m *MockCacheRepository[T]) FetchOne(ctx context.Context, key string, load Loader[T]) (T, error) {
+	entity, found, err := m.GetOneFromCache(ctx, key)
+	if err != nil || found {
+		return entity, err
+	}
+	entity, tags, err := load(ctx)
+	if err != nil {
+		return entity, err
+	}
+	_ = m.SetOneToCache(ctx, key, entity, tags...)
+	return entity, nil
+}
+
+// InvalidateTags provides a mock function with given fields: ctx, tags
+func (m *MockCacheRepository[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	args := m.Called(ctx, tags)
 	return args.Error(0)
 }
 
@@ -112,3 +193,61 @@ func (m *MockCacheRepository[T]) DeleteToCache(ctx context.Context, key string)
 	args := m.Called(ctx, key)
 	return args.Error(0)
 }
+
+// ExportRepositoryMock is an autogenerated mock type for the ExportRepository interface
+type ExportRepositoryMock struct {
+	mock.Mock
+}
+
+// GetArchive provides a mock function with given fields: ctx, id
+func (m *ExportRepositoryMock) GetArchive(ctx context.Context, id string) ([]byte, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+// SetArchive provides a mock function with given fields: ctx, id, archive, expiration
+func (m *ExportRepositoryMock) SetArchive(ctx context.Context, id string, archive []byte, expiration time.Duration) error {
+	args := m.Called(ctx, id, archive, expiration)
+	return args.Error(0)
+}
+
+// DeleteArchive provides a mock function with given fields: ctx, id
+func (m *ExportRepositoryMock) DeleteArchive(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// MarkPending provides a mock function with given fields: ctx, id, expiration
+func (m *ExportRepositoryMock) MarkPending(ctx context.Context, id string, expiration time.Duration) (bool, error) {
+	args := m.Called(ctx, id, expiration)
+	return args.Bool(0), args.Error(1)
+}
+
+// ClearPending provides a mock function with given fields: ctx, id
+func (m *ExportRepositoryMock) ClearPending(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+// AuditRepositoryMock is an autogenerated mock type for the AuditRepository interface
+type AuditRepositoryMock struct {
+	mock.Mock
+}
+
+// Create provides a mock function with given fields: ctx, event
+func (m *AuditRepositoryMock) Create(ctx context.Context, event *entity.AuditEvents) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+// GetAll provides a mock function with given fields: ctx, queryParams
+func (m *AuditRepositoryMock) GetAll(ctx context.Context, queryParams *request.AuditPage) ([]*entity.AuditEvents, error) {
+	args := m.Called(ctx, queryParams)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*entity.AuditEvents), args.Error(1)
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"gorm.io/gorm"
+)
+
+// AuditRepository defines the methods for the append-only audit events repository.
+// There is deliberately no update or delete.
+type AuditRepository interface {
+	Create(ctx context.Context, event *entity.AuditEvents) error
+	GetAll(ctx context.Context, queryParams *request.AuditPage) ([]*entity.AuditEvents, error)
+}
+
+// AuditRepositoryImpl implements the AuditRepository interface.
+type AuditRepositoryImpl struct {
+	DB     *gorm.DB    // Database connection
+	Logger *log.Logger // Logger for logging messages
+}
+
+// NewAuditRepositoryImpl creates a new instance of AuditRepositoryImpl.
+func NewAuditRepositoryImpl(DB *gorm.DB, logger *log.Logger) (*AuditRepositoryImpl, error) {
+	// Check if DB or logger is nil
+	if DB == nil || logger == nil {
+		return nil, errors.New("DB or Logger is nil")
+	}
+	return &AuditRepositoryImpl{DB: DB, Logger: logger}, nil
+}
+
+// Create appends a new audit event.
+func (repo AuditRepositoryImpl) Create(ctx context.Context, event *entity.AuditEvents) error {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Appending audit event %s", event.Action)
+	if err := session(ctx, repo.DB).Create(event).Error; err != nil {
+		logger.Error().Msgf("Failed to append audit event: %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetAll retrieves audit events newest first, based on the filters and the cursor of the query parameters.
+func (repo AuditRepositoryImpl) GetAll(ctx context.Context, queryParams *request.AuditPage) ([]*entity.AuditEvents, error) {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msg("Starting GetAll audit events method")
+	spec := Query[entity.AuditEvents]().Limit(queryParams.Size)
+
+	// Apply the filters
+	if queryParams.Action != "" {
+		spec.Where(Eq("action", queryParams.Action))
+	}
+	if queryParams.Outcome != "" {
+		spec.Where(Eq("outcome", queryParams.Outcome))
+	}
+	if queryParams.ActorID != "" {
+		spec.Where(Eq("actor_id", queryParams.ActorID))
+	}
+	if queryParams.TargetID != "" {
+		spec.Where(Eq("target_id", queryParams.TargetID))
+	}
+	if queryParams.From != 0 {
+		spec.Where(Gte("created_at", queryParams.From))
+	}
+	if queryParams.To != 0 {
+		spec.Where(Lte("created_at", queryParams.To))
+	}
+
+	// Event IDs are KSUIDs, so ordering by ID orders by time.
+	// The After cursor walks towards newer events, the page is reversed back to newest first below.
+	spec.Before("id", queryParams.Before).After("id", queryParams.After)
+	if queryParams.After != "" {
+		spec.OrderBy("id", Asc)
+	} else {
+		spec.OrderBy("id", Desc)
+	}
+
+	// The generic repository is only used for reading, the audit events stay append-only.
+	events, err := NewRepository[entity.AuditEvents](repo.Logger, repo.DB).FindAll(ctx, spec)
+	if err != nil {
+		logger.Error().Msgf("Error occurred while fetching audit events: %v", err)
+		return nil, err
+	}
+	if queryParams.After != "" {
+		slices.Reverse(events)
+	}
+
+	logger.Info().Msgf("Successfully retrieved %d audit events", len(events))
+	return events, nil
+}
@@ -0,0 +1,72 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+// Returns error when DB is nil and Log is nil
+func TestNewAuditRepositoryImpl_DBIsNil_LogIsNil(t *testing.T) {
+	repo, err := repository.NewAuditRepositoryImpl(nil, nil)
+
+	require.Error(t, err)
+
+	require.Nil(t, repo)
+
+	require.Equal(t, "DB or Logger is nil", err.Error())
+}
+
+func TestAuditRepositoryMethods(t *testing.T) {
+	ctx := context.Background()
+	repo, err := repository.NewAuditRepositoryImpl(DB, &log.DefaultLogger)
+	require.NoError(t, err)
+
+	t.Run("Create", func(t *testing.T) {
+		event := &entity.AuditEvents{ID: ksuid.New().String(), Action: entity.AuditLogin, Outcome: entity.AuditSuccess, Changes: "{}"}
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "audit_events"`)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := repo.Create(ctx, event)
+		require.NoError(t, err)
+		require.NotZero(t, event.CreatedAt)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetAllWithFilters", func(t *testing.T) {
+		before := ksuid.New().String()
+		rows := sqlmock.NewRows([]string{"id", "action", "outcome"}).
+			AddRow(ksuid.New().String(), entity.AuditLogin, entity.AuditFailure)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_events" WHERE action = $1 AND outcome = $2 AND created_at >= $3 AND id < $4 ORDER BY id DESC LIMIT $5`)).
+			WithArgs(entity.AuditLogin, entity.AuditFailure, int64(1000), before, 10).
+			WillReturnRows(rows)
+
+		events, err := repo.GetAll(ctx, &request.AuditPage{Size: 10, Before: before, Action: entity.AuditLogin, Outcome: entity.AuditFailure, From: 1000})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetAllAfterCursor", func(t *testing.T) {
+		after := ksuid.New().String()
+		older, newer := ksuid.New().String(), ksuid.New().String()
+		rows := sqlmock.NewRows([]string{"id"}).AddRow(older).AddRow(newer)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_events" WHERE id > $1 ORDER BY id ASC LIMIT $2`)).
+			WithArgs(after, 2).
+			WillReturnRows(rows)
+
+		events, err := repo.GetAll(ctx, &request.AuditPage{Size: 2, After: after})
+		require.NoError(t, err)
+		require.Equal(t, newer, events[0].ID)
+		require.Equal(t, older, events[1].ID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -4,103 +4,449 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
 	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"sync"
 	"time"
 
 	"github.com/phuslu/log"
 	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	cacheconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
+// CacheInvalidationChannel is the Redis pub/sub channel announcing the keys deleted from the cache, as a JSON array.
+const CacheInvalidationChannel = "cache:invalidate"
+
+// cacheGenerationKey counts the invalidations of the cache, an entry loaded while it changed is not kept as it may predate the invalidation.
+const cacheGenerationKey = "cache:generation"
+
+// Loader loads a value missing from the cache, returning it with the tag sets it is registered in.
+type Loader[V any] func(ctx context.Context) (V, []string, error)
+
 // CacheRepository defines the interface for cache operations.
+// Cached keys can be registered in tag sets, invalidating a tag deletes every key registered in it without scanning the keyspace.
 type CacheRepository[T any] interface {
-	GetFromCache(ctx context.Context, key string) ([]T, error)      // Fetch data from cache
-	SetToCache(ctx context.Context, key string, entities []T) error // Store data in cache
-	DeleteToCacheByRegexKey(ctx context.Context, key string) error  // Delete cache entries by regex key
-	DeleteToCache(ctx context.Context, key string) error            // Delete a specific cache entry
+	GetFromCache(ctx context.Context, key string) ([]T, error)                      // Fetch data from cache
+	SetToCache(ctx context.Context, key string, entities []T, tags ...string) error // Store data in cache, registered in the tag sets
+	GetOneFromCache(ctx context.Context, key string) (T, bool, error)               // Fetch a single entity from cache
+	SetOneToCache(ctx context.Context, key string, entity T, tags ...string) error  // Store a single entity in cache, registered in the tag sets
+	Fetch(ctx context.Context, key string, load Loader[[]T]) ([]T, error)           // Fetch data from cache, loading it once on a miss
+	FetchOne(ctx context.Context, key string, load Loader[T]) (T, error)            // Fetch a single entity from cache, loading it once on a miss
+	InvalidateTags(ctx context.Context, tags ...string) error                       // Delete every cache entry registered in the tag sets
+	DeleteToCacheByRegexKey(ctx context.Context, key string) error                  // Delete cache entries by regex key
+	DeleteToCache(ctx context.Context, key string) error                            // Delete a specific cache entry
 }
 
 // CacheRepositoryImpl implements the CacheRepository interface using Redis.
 type CacheRepositoryImpl[T any] struct {
-	Cache  *redis.Client // Redis client for cache operations
-	Logger *log.Logger   // Logger for logging cache operations
+	Cache  redis.UniversalClient    // Redis client for cache operations
+	Logger *log.Logger              // Logger for logging cache operations
+	Config *cacheconfig.RedisConfig // Expiration and reload settings of cache entries
+	group  *singleflight.Group      // Coalesces concurrent reloads of a key within the process
+	codec  *cacheCodec              // Encodes entries with the configured codec and compression
 }
 
-// NewCacheRepository creates a new CacheRepositoryImpl instance.
-func NewCacheRepository[T any](cache *redis.Client, logger *log.Logger) *CacheRepositoryImpl[T] {
-	return &CacheRepositoryImpl[T]{Cache: cache, Logger: logger}
+// NewCacheRepository creates a new CacheRepositoryImpl instance, failing on an unknown codec or compression.
+func NewCacheRepository[T any](cache redis.UniversalClient, logger *log.Logger, config *cacheconfig.RedisConfig) (*CacheRepositoryImpl[T], error) {
+	codec, err := newCacheCodec(config)
+	if err != nil {
+		return nil, err
+	}
+	return &CacheRepositoryImpl[T]{Cache: cache, Logger: logger, Config: config, group: new(singleflight.Group), codec: codec}, nil
 }
 
-func NewUserCacheRepository(cache *redis.Client, logger *log.Logger) *CacheRepositoryImpl[*entity.Users] {
-	return NewCacheRepository[*entity.Users](cache, logger)
+func NewUserCacheRepository(cache redis.UniversalClient, logger *log.Logger, config *cacheconfig.RedisConfig) (*CacheRepositoryImpl[*entity.Users], error) {
+	return NewCacheRepository[*entity.Users](cache, logger, config)
 }
 
 // GetFromCache retrieves data from the cache using the provided key.
 func (r CacheRepositoryImpl[T]) GetFromCache(ctx context.Context, key string) ([]T, error) {
-	r.Logger.Info().Msgf("Fetching from cache: %s", key) // Log cache fetch attempt
-	cachedData, err := r.Cache.Get(ctx, key).Result()    // Get data from cache
+	var entities []T
+	found, stale, err := r.get(ctx, key, &entities)
+	recordLookup(found, stale, err)
+	if err != nil || !found {
+		return nil, err // Return nil on cache miss, error on cache issue
+	}
+	return entities, nil
+}
+
+// SetToCache stores data in the cache with the provided key, registering the key in the tag sets.
+func (r *CacheRepositoryImpl[T]) SetToCache(ctx context.Context, key string, entities []T, tags ...string) error {
+	return r.set(ctx, key, entities, tags)
+}
+
+// GetOneFromCache retrieves a single entity from the cache using the provided key, reporting whether it was found.
+func (r CacheRepositoryImpl[T]) GetOneFromCache(ctx context.Context, key string) (T, bool, error) {
+	var entity T
+	found, stale, err := r.get(ctx, key, &entity)
+	recordLookup(found, stale, err)
+	return entity, found, err
+}
+
+// SetOneToCache stores a single entity in the cache with the provided key, registering the key in the tag sets.
+func (r *CacheRepositoryImpl[T]) SetOneToCache(ctx context.Context, key string, entity T, tags ...string) error {
+	return r.set(ctx, key, entity, tags)
+}
+
+// Fetch retrieves data from the cache, loading it on a miss.
+// Concurrent misses of the key share a single load, and stale entries are served while one caller refreshes them.
+func (r *CacheRepositoryImpl[T]) Fetch(ctx context.Context, key string, load Loader[[]T]) ([]T, error) {
+	return fetch(ctx, r, key, load)
+}
+
+// FetchOne retrieves a single entity from the cache, loading it on a miss.
+// Concurrent misses of the key share a single load, and stale entries are served while one caller refreshes them.
+func (r *CacheRepositoryImpl[T]) FetchOne(ctx context.Context, key string, load Loader[T]) (T, error) {
+	return fetch(ctx, r, key, load)
+}
+
+// DeleteFromCache removes a specific cache entry using the provided key.
+func (r *CacheRepositoryImpl[T]) DeleteToCache(ctx context.Context, key string) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if err := r.advanceGeneration(ctx); err != nil {
+		return err
+	}
+	err := r.Cache.Del(ctx, key).Err() // Delete data from cache
+	if err != nil {
+		logger.Error().Msgf("Failed to delete cache for key %s: %v", key, err) // Log cache delete error
+		return err                                                             // Return error on cache delete failure
+	}
+	r.publishInvalidation(ctx, key)
+	return nil
+}
+
+// InvalidateTags deletes every cache entry registered in the tag sets together with the tag sets themselves.
+func (r *CacheRepositoryImpl[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if err := r.advanceGeneration(ctx); err != nil {
+		return err
+	}
+	keys := []string{}
+	for _, tag := range tags {
+		// The tag set is read and deleted at once, a key registered meanwhile lands in a new set instead of being dropped untracked.
+		members, err := drainTagScript.Run(ctx, r.Cache, []string{tag}).StringSlice()
+		if err != nil {
+			logger.Error().Msgf("Failed to read tag %s: %v", tag, err) // Log tag read error
+			return err
+		}
+		keys = append(keys, members...)
+	}
+
+	// Keys are deleted one by one so they are not required to share a hash slot with their tag.
+	_, err := r.Cache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error().Msgf("Failed to invalidate tags %v: %v", tags, err) // Log delete error
+		return err
+	}
+
+	logger.Info().Msgf("Successfully invalidated %d cache entries for tags %v", len(keys), tags) // Log successful invalidation
+	r.publishInvalidation(ctx, keys...)
+	return nil
+}
+
+// DeleteToCacheByRegexKey deletes cache entries that match the provided regex key.
+func (r *CacheRepositoryImpl[T]) DeleteToCacheByRegexKey(ctx context.Context, key string) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if err := r.advanceGeneration(ctx); err != nil {
+		return err
+	}
+	keys := []string{} // Initialize list to hold keys
+	var mutex sync.Mutex
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, key, 0).Iterator() // Scan for keys matching regex
+		for iter.Next(ctx) {
+			mutex.Lock()
+			keys = append(keys, iter.Val()) // Collect matching keys
+			mutex.Unlock()
+		}
+		return iter.Err()
+	}
+	var err error
+	if cluster, ok := r.Cache.(*redis.ClusterClient); ok {
+		// Every master holds a share of the keyspace.
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, r.Cache)
+	}
+	if err != nil {
+		logger.Error().Msgf("Failed to scan keys: %v", err) // Log scan error
+		return err                                          // Return error on scan failure
+	}
+	if len(keys) > 0 {
+		// Keys are deleted one by one so they are not required to share a hash slot.
+		_, err := r.Cache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Del(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error().Msgf("Failed to delete for keys %p: %v", keys, err) // Log delete error
+			return err                                                         // Return error on delete failure
+		}
+		r.publishInvalidation(ctx, keys...)
+	}
+	logger.Info().Msgf("Successfully deleted cache for keys %p", keys) // Log successful cache delete
+	return nil
+}
+
+// get decodes the entry of the key into value, reporting whether it was found and whether it is stale.
+// Entries which can not be decoded, such as those written before the envelope or by a newer version, are treated as a miss.
+func (r CacheRepositoryImpl[T]) get(ctx context.Context, key string, value any) (bool, bool, error) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msgf("Fetching from cache: %s", key) // Log cache fetch attempt
+	cachedData, err := r.Cache.Get(ctx, key).Bytes()   // Get data from cache
 	if errors.Is(err, redis.Nil) {
-		r.Logger.Warn().Msgf("Cache miss: %s", key) // Log cache miss
-		return nil, nil                             // Return nil on cache miss
+		logger.Warn().Msgf("Cache miss: %s", key) // Log cache miss
+		return false, false, nil
 	} else if err != nil {
-		r.Logger.Error().Msgf("Cache error: %v", err) // Log cache error
-		return nil, err                               // Return error on cache issue
+		logger.Error().Msgf("Cache error: %v", err) // Log cache error
+		return false, false, err
 	}
 
-	var entities []T
-	if err := json.Unmarshal([]byte(cachedData), &entities); err != nil {
-		r.Logger.Error().Msgf("Error unmarshalling cached data: %v", err) // Log unmarshalling error
-		return nil, err                                                   // Return error on unmarshalling failure
+	staleAt, err := r.codec.decode(cachedData, value)
+	if err != nil {
+		logger.Warn().Msgf("Ignoring undecodable cache entry %s: %v", key, err) // Log unreadable entry
+		return false, false, nil
 	}
 
-	r.Logger.Info().Msgf("Cache hit: %s", key) // Log successful cache fetch
-	return entities, nil
+	stale := time.Now().UnixMilli() >= staleAt
+	logger.Info().Msgf("Cache hit: %s, stale: %t", key, stale) // Log successful cache fetch
+	return true, stale, nil
 }
 
-// SetToCache stores data in the cache with the provided key.
-func (r *CacheRepositoryImpl[T]) SetToCache(ctx context.Context, key string, entities []T) error {
-	data, err := json.Marshal(entities) // Convert data to JSON
+// recordLookup counts a lookup of the cache, the polls of waitForEntry are left out as they repeat a single miss.
+func recordLookup(found bool, stale bool, err error) {
+	result := "miss"
+	switch {
+	case err != nil:
+		result = "error"
+	case found && stale:
+		result = "stale"
+	case found:
+		result = "hit"
+	}
+	metrics.CacheLookups.WithLabelValues(result).Inc()
+}
+
+// set stores the value under the key and adds the key to every tag set.
+// Both TTLs are spread by the jitter, a tag set expires along with the entries it references and is refreshed whenever an entry is added.
+func (r *CacheRepositoryImpl[T]) set(ctx context.Context, key string, value any, tags []string) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	ttl := r.Config.CacheJitter(r.Config.CacheTTL())
+	staleAt := time.Now().Add(min(r.Config.CacheJitter(r.Config.CacheSoftTTL()), ttl))
+	entry, err := r.codec.encode(value, staleAt.UnixMilli()) // Encode data with the configured codec
 	if err != nil {
-		r.Logger.Error().Msgf("Error marshalling data: %v", err) // Log marshalling error
-		return err                                               // Return error on marshalling failure
+		logger.Error().Msgf("Error marshalling data: %v", err) // Log marshalling error
+		return err
 	}
 
-	err = r.Cache.Set(ctx, key, data, time.Minute*30).Err() // Set data in cache with expiration
+	_, err = r.Cache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, entry, ttl) // Set data in cache with expiration
+		for _, tag := range tags {
+			pipe.SAdd(ctx, tag, key)
+			pipe.Expire(ctx, tag, r.Config.CacheTTL()+r.Config.CacheTTL()*time.Duration(r.Config.Jitter)/100)
+		}
+		return nil
+	})
 	if err != nil {
-		r.Logger.Error().Msgf("Error setting cache: %v", err) // Log cache set error
-		return err                                            // Return error on cache set failure
+		logger.Error().Msgf("Error setting cache: %v", err) // Log cache set error
+		return err
 	}
 
-	r.Logger.Info().Msgf("Data cached: %s", key) // Log successful cache set
+	logger.Info().Msgf("Data cached: %s", key) // Log successful cache set
 	return nil
 }
 
-// DeleteFromCache removes a specific cache entry using the provided key.
-func (r *CacheRepositoryImpl[T]) DeleteToCache(ctx context.Context, key string) error {
-	err := r.Cache.Del(ctx, key).Err() // Delete data from cache
+// fetch implements the read-through of Fetch and FetchOne.
+func fetch[T, V any](ctx context.Context, r *CacheRepositoryImpl[T], key string, load Loader[V]) (V, error) {
+	var value V
+	found, stale, err := r.get(ctx, key, &value)
+	recordLookup(found, stale, err)
 	if err != nil {
-		r.Logger.Error().Msgf("Failed to delete cache for key %s: %v", key, err) // Log cache delete error
-		return err                                                               // Return error on cache delete failure
+		return value, err
+	}
+	// The loads may outlive the request, whose context is recycled once the handler returns.
+	// They read from the primary, and an entry a load stores is discarded when an invalidation ran during the load,
+	// so the entries kept are not older than the last invalidation.
+	background := readPrimary(detach(ctx))
+	if found {
+		if stale {
+			// Serve the stale entry, a single caller refreshes it without holding up the request.
+			go func() {
+				_, _, _ = r.group.Do(key, func() (any, error) {
+					return reload(background, r, key, load, false)
+				})
+			}()
+		}
+		return value, nil
+	}
+
+	// Concurrent misses of the key wait for a single load, which outlives any one of the callers.
+	result := r.group.DoChan(key, func() (any, error) {
+		return reload(background, r, key, load, true)
+	})
+	select {
+	case <-ctx.Done():
+		return value, ctx.Err()
+	case shared := <-result:
+		if shared.Err != nil {
+			return value, shared.Err
+		}
+		return shared.Val.(V), nil
+	}
+}
+
+// detach returns a context for a load outliving the request, carrying a copy of the request meta and its span context only.
+// ctx may be the fasthttp context of the request, whose values must not be read once the handler returned.
+func detach(ctx context.Context) context.Context {
+	meta := *request.MetaFromContext(ctx)
+	return trace.ContextWithSpanContext(request.WithMeta(context.Background(), &meta), tracing.SpanContext(ctx))
+}
+
+// reload loads the value of the key and stores it.
+// With the lock enabled only one replica loads the key at a time. A caller that must answer waits for the
+// holder to store the entry and loads it itself when the lock expires first, a background refresh simply gives up.
+func reload[T, V any](ctx context.Context, r *CacheRepositoryImpl[T], key string, load Loader[V], wait bool) (V, error) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if r.Config.Lock {
+		token := ksuid.New().String()
+		acquired, err := r.Cache.SetNX(ctx, lockKey(key), token, r.Config.CacheLockTTL()).Result()
+		if err != nil {
+			logger.Error().Msgf("Failed to acquire reload lock of %s: %v", key, err) // Log lock error, the load continues without it
+		}
+		if acquired {
+			defer r.unlock(ctx, key, token)
+		} else if err == nil {
+			if !wait {
+				var value V
+				return value, nil
+			}
+			if value, found := waitForEntry[T, V](ctx, r, key); found {
+				return value, nil
+			}
+		}
+	}
+
+	// The generation is read before the load, which may return data older than an invalidation running meanwhile.
+	generation, errGeneration := r.generation(ctx)
+	value, tags, err := load(ctx)
+	if err != nil {
+		return value, err
+	}
+	if errGeneration != nil {
+		// Without the generation the entry could not be told apart from one predating an invalidation, it is not stored.
+		logger.Error().Msgf("Failed to read the cache generation, not storing %s: %v", key, errGeneration)
+		return value, nil
+	}
+	if errSet := r.set(ctx, key, value, tags); errSet != nil {
+		// Log the error if caching fails but continue with the loaded value.
+		logger.Error().Msgf("Failed to store reloaded cache entry %s: %v", key, errSet)
+	} else if r.discardIfInvalidated(ctx, key, generation) {
+		logger.Info().Msgf("Discarded reloaded cache entry %s, the cache was invalidated during the load", key)
+	} else if !wait {
+		// Copies of the refreshed entry held in process by the replicas are outdated now.
+		r.publishInvalidation(ctx, key)
+	}
+	return value, nil
+}
+
+// generation returns the count of invalidations of the cache, none yet when the key is missing.
+func (r *CacheRepositoryImpl[T]) generation(ctx context.Context) (int64, error) {
+	generation, err := r.Cache.Get(ctx, cacheGenerationKey).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return generation, err
+}
+
+// advanceGeneration counts an invalidation before it deletes the entries, the loads running meanwhile discard what they store.
+func (r *CacheRepositoryImpl[T]) advanceGeneration(ctx context.Context) error {
+	if err := r.Cache.Incr(ctx, cacheGenerationKey).Err(); err != nil {
+		loggerconfig.FromContext(ctx, r.Logger).Error().Msgf("Failed to advance the cache generation: %v", err)
+		return err
 	}
 	return nil
 }
 
-// DeleteToCacheByRegexKey deletes cache entries that match the provided regex key.
-func (r *CacheRepositoryImpl[T]) DeleteToCacheByRegexKey(ctx context.Context, key string) error {
-	keys := []string{}                              // Initialize list to hold keys
-	iter := r.Cache.Scan(ctx, 0, key, 0).Iterator() // Scan for keys matching regex
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val()) // Collect matching keys
+// discardIfInvalidated deletes the entry of the key stored by a load when the cache was invalidated since the load started,
+// and tells whether it did. An invalidation starting after the check finds the entry in its tag sets and deletes it itself.
+// The generation is checked apart from the write, so neither has to share a hash slot with the other keys.
+func (r *CacheRepositoryImpl[T]) discardIfInvalidated(ctx context.Context, key string, generation int64) bool {
+	current, err := r.generation(ctx)
+	if err == nil && current == generation {
+		return false
 	}
-	if err := iter.Err(); err != nil {
-		r.Logger.Error().Msgf("Failed to scan keys: %v", err) // Log scan error
-		return err                                            // Return error on scan failure
+	if errDel := r.Cache.Del(ctx, key).Err(); errDel != nil {
+		loggerconfig.FromContext(ctx, r.Logger).Error().Msgf("Failed to discard reloaded cache entry %s: %v", key, errDel)
 	}
-	if len(keys) > 0 {
-		if err := r.Cache.Del(ctx, keys...).Err(); err != nil {
-			r.Logger.Error().Msgf("Failed to delete for keys %p: %v", keys, err) // Log delete error
-			return err                                                           // Return error on delete failure
+	// The replicas may have copied the entry in the meantime.
+	r.publishInvalidation(ctx, key)
+	return true
+}
+
+// waitForEntry polls the key until the replica holding the reload lock stores it, or the lock expires.
+func waitForEntry[T, V any](ctx context.Context, r *CacheRepositoryImpl[T], key string) (V, bool) {
+	var value V
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(r.Config.CacheLockTTL())
+	for {
+		select {
+		case <-deadline:
+			return value, false
+		case <-ticker.C:
+			if found, _, err := r.get(ctx, key, &value); err != nil || found {
+				return value, found
+			}
 		}
 	}
-	r.Logger.Info().Msgf("Successfully deleted cache for keys %p", keys) // Log successful cache delete
-	return nil
+}
+
+// publishInvalidation announces deleted keys on the invalidation channel, so the replicas drop their in-process copies.
+// Failing to publish is only logged, the in-process copies then expire with their own TTL.
+func (r *CacheRepositoryImpl[T]) publishInvalidation(ctx context.Context, keys ...string) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if len(keys) == 0 {
+		return
+	}
+	message, err := json.Marshal(keys)
+	if err == nil {
+		err = r.Cache.Publish(ctx, CacheInvalidationChannel, message).Err()
+	}
+	if err != nil {
+		logger.Error().Msgf("Failed to publish invalidation of keys %v: %v", keys, err)
+	}
+}
+
+// drainTagScript returns the keys of a tag set and deletes it, touching the tag key alone so it runs on any cluster node.
+var drainTagScript = redis.NewScript(`local keys = redis.call("SMEMBERS", KEYS[1]) redis.call("DEL", KEYS[1]) return keys`)
+
+// unlockScript releases a reload lock only while it is still held by the same token.
+var unlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`)
+
+// unlock releases the reload lock of the key.
+func (r *CacheRepositoryImpl[T]) unlock(ctx context.Context, key, token string) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if err := unlockScript.Run(ctx, r.Cache, []string{lockKey(key)}, token).Err(); err != nil {
+		logger.Error().Msgf("Failed to release reload lock of %s: %v", key, err)
+	}
+}
+
+// lockKey returns the key of the reload lock of a cache key.
+func lockKey(key string) string {
+	return "lock:" + key
 }
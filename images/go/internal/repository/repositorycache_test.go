@@ -0,0 +1,220 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	cacheconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"github.com/valyala/fasthttp"
+)
+
+// newClusterStandIn starts in-memory Redis nodes and returns a Cluster client spreading the hash slots over them.
+// It stands in for a multi-node Redis Cluster, so keys and tag sets of the tests land on different nodes.
+func newClusterStandIn(t *testing.T, nodes int) (*redis.ClusterClient, []*miniredis.Miniredis) {
+	servers := make([]*miniredis.Miniredis, nodes)
+	slots := make([]redis.ClusterSlot, nodes)
+	size := 16384 / nodes
+	for i := range servers {
+		servers[i] = miniredis.RunT(t)
+		slots[i] = redis.ClusterSlot{Start: i * size, End: (i+1)*size - 1, Nodes: []redis.ClusterNode{{Addr: servers[i].Addr()}}}
+	}
+	slots[nodes-1].End = 16383
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return slots, nil
+		},
+	})
+	t.Cleanup(func() { _ = client.Close() })
+	return client, servers
+}
+
+func newClusterCacheRepository(t *testing.T, client redis.UniversalClient) *repository.CacheRepositoryImpl[*entity.Users] {
+	repo, err := repository.NewUserCacheRepository(client, &log.DefaultLogger, &cacheconfig.RedisConfig{TTL: 30, SoftTTL: 25})
+	require.NoError(t, err)
+	return repo
+}
+
+func TestCacheRepositoryOnCluster(t *testing.T) {
+	ctx := context.Background()
+	client, servers := newClusterStandIn(t, 3)
+	repo := newClusterCacheRepository(t, client)
+
+	users := make([]*entity.Users, 12)
+	for i := range users {
+		users[i] = &entity.Users{ID: ksuid.New().String(), Username: "cluster"}
+	}
+	var loads atomic.Int32
+	loadOne := func(users *entity.Users) repository.Loader[*entity.Users] {
+		return func(ctx context.Context) (*entity.Users, []string, error) {
+			loads.Add(1)
+			return users, []string{"users:tag:id:" + users.ID, "users:tag:all"}, nil
+		}
+	}
+
+	t.Run("FetchSpreadsOverNodes", func(t *testing.T) {
+		for _, user := range users {
+			result, err := repo.FetchOne(ctx, "users:id:"+user.ID, loadOne(user))
+			require.NoError(t, err)
+			require.Equal(t, user.ID, result.ID)
+		}
+		require.Equal(t, int32(len(users)), loads.Load())
+
+		used := 0
+		for _, server := range servers {
+			if len(server.Keys()) > 0 {
+				used++
+			}
+		}
+		require.Greater(t, used, 1)
+
+		for _, user := range users {
+			_, err := repo.FetchOne(ctx, "users:id:"+user.ID, loadOne(user))
+			require.NoError(t, err)
+		}
+		require.Equal(t, int32(len(users)), loads.Load())
+	})
+
+	t.Run("InvalidateTagsAcrossNodes", func(t *testing.T) {
+		require.NoError(t, repo.InvalidateTags(ctx, "users:tag:all"))
+		for _, user := range users {
+			_, found, err := repo.GetOneFromCache(ctx, "users:id:"+user.ID)
+			require.NoError(t, err)
+			require.False(t, found)
+		}
+		exists, err := client.Exists(ctx, "users:tag:all").Result()
+		require.NoError(t, err)
+		require.Zero(t, exists)
+		// The drained tag invalidates nothing the second time
+		require.NoError(t, repo.InvalidateTags(ctx, "users:tag:all"))
+	})
+
+	t.Run("DeleteByPatternOnEveryMaster", func(t *testing.T) {
+		for _, user := range users {
+			require.NoError(t, repo.SetOneToCache(ctx, "users:id:"+user.ID, user))
+		}
+		require.NoError(t, repo.DeleteToCacheByRegexKey(ctx, "users:id:*"))
+		for _, server := range servers {
+			for _, key := range server.Keys() {
+				require.NotContains(t, key, "users:id:")
+			}
+		}
+	})
+
+	t.Run("ConcurrentMissesLoadOnce", func(t *testing.T) {
+		loads.Store(0)
+		var wait sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wait.Add(1)
+			go func() {
+				defer wait.Done()
+				_, err := repo.FetchOne(ctx, "users:id:shared", func(ctx context.Context) (*entity.Users, []string, error) {
+					loads.Add(1)
+					time.Sleep(50 * time.Millisecond)
+					return users[0], nil, nil
+				})
+				assert.NoError(t, err)
+			}()
+		}
+		wait.Wait()
+		require.Equal(t, int32(1), loads.Load())
+	})
+}
+
+func TestLocalCacheRepositoryInvalidationOnCluster(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client, _ := newClusterStandIn(t, 3)
+	user := &entity.Users{ID: ksuid.New().String(), Username: "replica"}
+
+	// Two replicas share the cluster, each with its own in-process cache.
+	writer := repository.NewLocalUserCacheRepository(newClusterCacheRepository(t, client), client, &log.DefaultLogger, 10, time.Minute)
+	reader := repository.NewLocalUserCacheRepository(newClusterCacheRepository(t, client), client, &log.DefaultLogger, 10, time.Minute)
+	go reader.Listen(ctx)
+	time.Sleep(100 * time.Millisecond) // Let the subscription start
+
+	require.NoError(t, writer.SetOneToCache(ctx, "users:id:"+user.ID, user, "users:tag:id:"+user.ID))
+	_, found, err := reader.GetOneFromCache(ctx, "users:id:"+user.ID)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 1, reader.Stats().Entries)
+
+	require.NoError(t, writer.InvalidateTags(ctx, "users:tag:id:"+user.ID))
+	require.Eventually(t, func() bool {
+		return reader.Stats().Entries == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// Refreshes a stale entry with the meta of the request once its fasthttp context is reset
+func TestCacheRepositoryDetachesBackgroundLoads(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	// A zero soft TTL makes every entry stale once stored
+	repo, err := repository.NewUserCacheRepository(client, &log.DefaultLogger, &cacheconfig.RedisConfig{TTL: 30})
+	require.NoError(t, err)
+	user := &entity.Users{ID: ksuid.New().String(), Username: "stale"}
+	require.NoError(t, repo.SetOneToCache(context.Background(), "users:id:"+user.ID, user))
+
+	requestCtx := new(fasthttp.RequestCtx)
+	requestCtx.Init(new(fasthttp.Request), nil, nil)
+	requestCtx.SetUserValue(request.MetaKey, &request.Meta{RequestID: "abc-123"})
+	reset := make(chan struct{})
+	loaded := make(chan string, 1)
+	result, err := repo.FetchOne(requestCtx, "users:id:"+user.ID, func(ctx context.Context) (*entity.Users, []string, error) {
+		<-reset
+		loaded <- request.MetaFromContext(ctx).RequestID
+		return user, nil, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "stale", result.Username)
+
+	// The handler returned, fasthttp recycles the context before the refresh reads it
+	requestCtx.ResetUserValues()
+	requestCtx.SetUserValue(request.MetaKey, &request.Meta{RequestID: "next-request"})
+	close(reset)
+	select {
+	case requestID := <-loaded:
+		assert.Equal(t, "abc-123", requestID)
+	case <-time.After(time.Second):
+		t.Fatal("the stale entry was not refreshed")
+	}
+}
+
+func TestCacheRepositoryDiscardsLoadsOverlappingInvalidation(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	repo, err := repository.NewUserCacheRepository(client, &log.DefaultLogger, &cacheconfig.RedisConfig{TTL: 30, SoftTTL: 30})
+	require.NoError(t, err)
+	ctx := context.Background()
+	key := "users:id:" + ksuid.New().String()
+
+	// The user is updated and its tag invalidated while the load reads the previous data
+	result, err := repo.FetchOne(ctx, key, func(ctx context.Context) (*entity.Users, []string, error) {
+		require.NoError(t, repo.InvalidateTags(ctx, "users:tag:all"))
+		return &entity.Users{Username: "before"}, []string{"users:tag:all"}, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "before", result.Username)
+	require.False(t, server.Exists(key))
+
+	// A load not overlapping any invalidation is kept
+	_, err = repo.FetchOne(ctx, key, func(ctx context.Context) (*entity.Users, []string, error) {
+		return &entity.Users{Username: "after"}, []string{"users:tag:all"}, nil
+	})
+	require.NoError(t, err)
+	require.True(t, server.Exists(key))
+}
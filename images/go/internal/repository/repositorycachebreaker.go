@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
+)
+
+// UsersCacheKeys matches every key the users are cached under, their tag sets included.
+const UsersCacheKeys = "users:*"
+
+// BreakerCacheRepositoryImpl implements the CacheRepository interface with a circuit breaker in front of another cache.
+// Failures of the cache never reach the caller: reads fall through to the loader or report a miss, writes are skipped and
+// invalidations are recorded, then replayed before the cache is used again.
+//
+// The recorded invalidations live in the memory of the process only, those of a process stopped during the outage are lost,
+// and so are those of the other replicas. Once the cache is reachable after the breaker opened, the keys matching the flush
+// patterns are deleted as well, as the outage may have hidden any invalidation. A replica whose cache calls failed without
+// this process seeing an outage, and which stopped before replaying them, leaves its entries until they expire.
+type BreakerCacheRepositoryImpl[T any] struct {
+	Next    CacheRepository[T] // Cache guarded by the breaker
+	Breaker *breaker.Breaker   // Breaker opening after consecutive cache failures
+	Logger  *log.Logger        // Logger for logging cache operations
+	Flush   []string           // Key patterns deleted once the cache is reachable after an outage
+
+	mutex       sync.Mutex
+	outage      bool                // The breaker opened since the last flush
+	pendingTags map[string]struct{} // Tags to invalidate once the cache is reachable
+	pendingKeys map[string]struct{} // Keys to delete once the cache is reachable
+	pendingRegx map[string]struct{} // Key patterns to delete once the cache is reachable
+}
+
+// loaderError marks the errors of a loader, which are not failures of the cache.
+type loaderError struct {
+	err error
+}
+
+func (e *loaderError) Error() string { return e.err.Error() }
+func (e *loaderError) Unwrap() error { return e.err }
+
+// NewBreakerCacheRepository creates a new BreakerCacheRepositoryImpl instance in front of next,
+// deleting the keys matching the flush patterns once the cache is reachable after an outage.
+func NewBreakerCacheRepository[T any](next CacheRepository[T], cacheBreaker *breaker.Breaker, logger *log.Logger, flush ...string) *BreakerCacheRepositoryImpl[T] {
+	return &BreakerCacheRepositoryImpl[T]{
+		Next:        next,
+		Breaker:     cacheBreaker,
+		Logger:      logger,
+		Flush:       flush,
+		pendingTags: make(map[string]struct{}),
+		pendingKeys: make(map[string]struct{}),
+		pendingRegx: make(map[string]struct{}),
+	}
+}
+
+func NewBreakerUserCacheRepository(next CacheRepository[*entity.Users], cacheBreaker *breaker.Breaker, logger *log.Logger) *BreakerCacheRepositoryImpl[*entity.Users] {
+	return NewBreakerCacheRepository[*entity.Users](next, cacheBreaker, logger, UsersCacheKeys)
+}
+
+// GetFromCache retrieves data from the next cache, reporting a miss while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) GetFromCache(ctx context.Context, key string) ([]T, error) {
+	if !r.allow(ctx) {
+		return nil, nil
+	}
+	entities, err := r.Next.GetFromCache(ctx, key)
+	if r.record(err) {
+		return nil, nil
+	}
+	return entities, nil
+}
+
+// SetToCache stores data in the next cache, skipped while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) SetToCache(ctx context.Context, key string, entities []T, tags ...string) error {
+	if r.allow(ctx) {
+		r.record(r.Next.SetToCache(ctx, key, entities, tags...))
+	}
+	return nil
+}
+
+// GetOneFromCache retrieves a single entity from the next cache, reporting a miss while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) GetOneFromCache(ctx context.Context, key string) (T, bool, error) {
+	var entity T
+	if !r.allow(ctx) {
+		return entity, false, nil
+	}
+	entity, found, err := r.Next.GetOneFromCache(ctx, key)
+	if r.record(err) {
+		var zero T
+		return zero, false, nil
+	}
+	return entity, found, nil
+}
+
+// SetOneToCache stores a single entity in the next cache, skipped while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) SetOneToCache(ctx context.Context, key string, entity T, tags ...string) error {
+	if r.allow(ctx) {
+		r.record(r.Next.SetOneToCache(ctx, key, entity, tags...))
+	}
+	return nil
+}
+
+// Fetch retrieves data through the next cache, loading it directly while the cache is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) Fetch(ctx context.Context, key string, load Loader[[]T]) ([]T, error) {
+	return fetchThroughBreaker(ctx, r, load, func(load Loader[[]T]) ([]T, error) {
+		return r.Next.Fetch(ctx, key, load)
+	})
+}
+
+// FetchOne retrieves a single entity through the next cache, loading it directly while the cache is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) FetchOne(ctx context.Context, key string, load Loader[T]) (T, error) {
+	return fetchThroughBreaker(ctx, r, load, func(load Loader[T]) (T, error) {
+		return r.Next.FetchOne(ctx, key, load)
+	})
+}
+
+// InvalidateTags invalidates the tags in the next cache, recording them while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	if r.allow(ctx) && !r.record(r.Next.InvalidateTags(ctx, tags...)) {
+		return nil
+	}
+	r.postpone(r.pendingTags, tags...)
+	return nil
+}
+
+// DeleteToCacheByRegexKey deletes the matching entries from the next cache, recording the pattern while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) DeleteToCacheByRegexKey(ctx context.Context, key string) error {
+	if r.allow(ctx) && !r.record(r.Next.DeleteToCacheByRegexKey(ctx, key)) {
+		return nil
+	}
+	r.postpone(r.pendingRegx, key)
+	return nil
+}
+
+// DeleteToCache deletes the entry from the next cache, recording the key while it is unavailable.
+func (r *BreakerCacheRepositoryImpl[T]) DeleteToCache(ctx context.Context, key string) error {
+	if r.allow(ctx) && !r.record(r.Next.DeleteToCache(ctx, key)) {
+		return nil
+	}
+	r.postpone(r.pendingKeys, key)
+	return nil
+}
+
+// fetchThroughBreaker implements Fetch and FetchOne, telling the errors of the loader apart from those of the cache.
+func fetchThroughBreaker[T, V any](ctx context.Context, r *BreakerCacheRepositoryImpl[T], load Loader[V], fetch func(Loader[V]) (V, error)) (V, error) {
+	if r.allow(ctx) {
+		value, err := fetch(func(ctx context.Context) (V, []string, error) {
+			value, tags, err := load(ctx)
+			if err != nil {
+				return value, tags, &loaderError{err: err}
+			}
+			return value, tags, nil
+		})
+		var errLoader *loaderError
+		if errors.As(err, &errLoader) {
+			r.Breaker.Success()
+			return value, errLoader.err
+		}
+		if !r.record(err) {
+			return value, nil
+		}
+	}
+	value, _, err := load(ctx)
+	return value, err
+}
+
+// allow reports whether the next cache may be called, replaying the recorded invalidations first.
+func (r *BreakerCacheRepositoryImpl[T]) allow(ctx context.Context) bool {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if !r.Breaker.Allow() {
+		return false
+	}
+	if err := r.replay(ctx); err != nil {
+		logger.Error().Msgf("Failed to replay cache invalidations: %v", err)
+		r.failure()
+		return false
+	}
+	return true
+}
+
+// record reports the outcome of a call to the breaker, returning whether the cache failed.
+// Cancellations of the caller are not failures of the cache.
+func (r *BreakerCacheRepositoryImpl[T]) record(err error) bool {
+	if err == nil {
+		r.Breaker.Success()
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		r.Breaker.Release()
+		return true
+	}
+	r.Logger.Error().Msgf("Cache call failed, breaker %s recorded a failure: %v", r.Breaker.Name(), err)
+	r.failure()
+	return true
+}
+
+// failure reports a failure to the breaker, marking an outage once it opens.
+func (r *BreakerCacheRepositoryImpl[T]) failure() {
+	r.Breaker.Failure()
+	if r.Breaker.State() != breaker.Closed {
+		r.mutex.Lock()
+		r.outage = true
+		r.mutex.Unlock()
+	}
+}
+
+// postpone records invalidations to replay once the cache is reachable.
+func (r *BreakerCacheRepositoryImpl[T]) postpone(pending map[string]struct{}, values ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, value := range values {
+		pending[value] = struct{}{}
+	}
+	r.Logger.Warn().Msgf("Cache unavailable, deferred invalidation of %v", values)
+}
+
+// replay applies the recorded invalidations, keeping those which failed, after flushing the keys matching the patterns
+// when the breaker opened since the last flush.
+func (r *BreakerCacheRepositoryImpl[T]) replay(ctx context.Context) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.outage {
+		for _, pattern := range r.Flush {
+			if err := r.Next.DeleteToCacheByRegexKey(ctx, pattern); err != nil {
+				return err
+			}
+		}
+		r.outage = false
+		logger.Info().Msgf("Flushed cache keys %v after an outage", r.Flush)
+	}
+	if len(r.pendingTags)+len(r.pendingKeys)+len(r.pendingRegx) == 0 {
+		return nil
+	}
+	if len(r.pendingTags) > 0 {
+		if err := r.Next.InvalidateTags(ctx, keysOf(r.pendingTags)...); err != nil {
+			return err
+		}
+		clear(r.pendingTags)
+	}
+	for key := range r.pendingKeys {
+		if err := r.Next.DeleteToCache(ctx, key); err != nil {
+			return err
+		}
+		delete(r.pendingKeys, key)
+	}
+	for pattern := range r.pendingRegx {
+		if err := r.Next.DeleteToCacheByRegexKey(ctx, pattern); err != nil {
+			return err
+		}
+		delete(r.pendingRegx, pattern)
+	}
+	logger.Info().Msg("Replayed deferred cache invalidations")
+	return nil
+}
+
+// keysOf returns the members of a set.
+func keysOf(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	return keys
+}
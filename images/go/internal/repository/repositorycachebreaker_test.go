@@ -0,0 +1,103 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	tmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"github.com/tirtahakimpambudhi/restful_api/pkg/breaker"
+)
+
+func TestBreakerCacheRepositoryMethods(t *testing.T) {
+	ctx := context.Background()
+	errRedis := errors.New("dial tcp: connection refused")
+	users := &entity.Users{ID: ksuid.New().String(), Username: "breaker"}
+	load := func(ctx context.Context) (*entity.Users, []string, error) {
+		return users, []string{"users:tag:id:" + users.ID}, nil
+	}
+
+	t.Run("ReadsFallThroughWhenCacheFails", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		cache := repository.NewBreakerUserCacheRepository(next, breaker.New("test:reads", 1, time.Minute), &log.DefaultLogger)
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(nil, false, errRedis).Once()
+
+		result, err := cache.FetchOne(ctx, "users:id:1", load)
+		require.NoError(t, err)
+		require.Equal(t, users, result)
+		require.Equal(t, breaker.Open, cache.Breaker.State())
+
+		// The breaker is open, the cache is not called anymore.
+		result, err = cache.FetchOne(ctx, "users:id:1", load)
+		require.NoError(t, err)
+		require.Equal(t, users, result)
+		list, err := cache.GetFromCache(ctx, "users:all")
+		require.NoError(t, err)
+		require.Nil(t, list)
+		next.AssertExpectations(t)
+	})
+
+	t.Run("LoaderErrorsAreNotCacheFailures", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		cache := repository.NewBreakerUserCacheRepository(next, breaker.New("test:loader", 1, time.Minute), &log.DefaultLogger)
+		errDB := errors.New("database down")
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(nil, false, nil).Once()
+
+		_, err := cache.FetchOne(ctx, "users:id:1", func(ctx context.Context) (*entity.Users, []string, error) {
+			return nil, nil, errDB
+		})
+		require.ErrorIs(t, err, errDB)
+		require.Equal(t, breaker.Closed, cache.Breaker.State())
+		next.AssertExpectations(t)
+	})
+
+	t.Run("InvalidationsReplayedOnRecovery", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		cacheBreaker := breaker.New("test:replay", 1, time.Millisecond)
+		cache := repository.NewBreakerUserCacheRepository(next, cacheBreaker, &log.DefaultLogger)
+		next.On("InvalidateTags", tmock.Anything, []string{"users:tag:all"}).Return(errRedis).Once()
+
+		// The failed invalidation is recorded instead of failing the write.
+		require.NoError(t, cache.InvalidateTags(ctx, "users:tag:all"))
+		require.NoError(t, cache.DeleteToCache(ctx, "users:id:1"))
+		require.Equal(t, breaker.Open, cacheBreaker.State())
+
+		// The invalidations of the other processes are unknown, the keys of the users are flushed before the replay.
+		time.Sleep(2 * time.Millisecond)
+		next.On("DeleteToCacheByRegexKey", tmock.Anything, repository.UsersCacheKeys).Return(nil).Once()
+		next.On("InvalidateTags", tmock.Anything, []string{"users:tag:all"}).Return(nil).Once()
+		next.On("DeleteToCache", tmock.Anything, "users:id:1").Return(nil).Once()
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(users, true, nil).Once()
+
+		result, found, err := cache.GetOneFromCache(ctx, "users:id:1")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, users, result)
+		require.Equal(t, breaker.Closed, cacheBreaker.State())
+		next.AssertExpectations(t)
+
+		// The flush runs once per outage.
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(users, true, nil).Once()
+		_, _, err = cache.GetOneFromCache(ctx, "users:id:1")
+		require.NoError(t, err)
+		next.AssertNumberOfCalls(t, "DeleteToCacheByRegexKey", 1)
+	})
+
+	t.Run("WritesSkippedWhileOpen", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		cacheBreaker := breaker.New("test:writes", 1, time.Minute)
+		cache := repository.NewBreakerUserCacheRepository(next, cacheBreaker, &log.DefaultLogger)
+		cacheBreaker.Failure()
+
+		require.NoError(t, cache.SetOneToCache(ctx, "users:id:1", users))
+		require.NoError(t, cache.SetToCache(ctx, "users:all", []*entity.Users{users}))
+		next.AssertNotCalled(t, "SetOneToCache", tmock.Anything, tmock.Anything, tmock.Anything, tmock.Anything)
+		next.AssertNotCalled(t, "SetToCache", tmock.Anything, tmock.Anything, tmock.Anything, tmock.Anything)
+	})
+}
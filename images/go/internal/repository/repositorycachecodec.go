@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	goJson "github.com/goccy/go-json"
+	"github.com/klauspost/compress/zstd"
+	cacheconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Envelope layout of a cache entry, each field is recorded so entries stay readable after the settings change:
+//
+//	version (1 byte) | format (1 byte) | compression (1 byte) | stale at in unix milliseconds (8 bytes) | payload
+//
+// Entries of the first version are JSON objects holding the stale time and the data.
+const (
+	envelopeVersion    byte = 2
+	envelopeHeaderSize      = 11
+)
+
+// Formats of the payload.
+const (
+	formatJSON byte = iota + 1
+	formatMsgpack
+)
+
+// Compressions of the payload.
+const (
+	compressionNone byte = iota
+	compressionGzip
+	compressionZstd
+)
+
+// errUnknownEnvelope reports an entry written in a layout this version can not read, the entry is treated as a miss.
+var errUnknownEnvelope = errors.New("unknown cache envelope")
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// cacheCodec encodes cache entries with the codec and compression of the configuration.
+type cacheCodec struct {
+	format         byte
+	compression    byte
+	compressionMin int
+	marshalJSON    func(any) ([]byte, error)
+	unmarshalJSON  func([]byte, any) error
+}
+
+// newCacheCodec creates the codec selected by the configuration.
+func newCacheCodec(config *cacheconfig.RedisConfig) (*cacheCodec, error) {
+	codec := &cacheCodec{format: formatJSON, compressionMin: config.CompressionMin, marshalJSON: json.Marshal, unmarshalJSON: json.Unmarshal}
+	switch config.Codec {
+	case "", "json":
+	case "go-json":
+		codec.marshalJSON, codec.unmarshalJSON = goJson.Marshal, goJson.Unmarshal
+	case "msgpack":
+		codec.format = formatMsgpack
+	default:
+		return nil, fmt.Errorf("unknown cache codec %q", config.Codec)
+	}
+	switch config.Compression {
+	case "", "none":
+		codec.compression = compressionNone
+	case "gzip":
+		codec.compression = compressionGzip
+	case "zstd":
+		codec.compression = compressionZstd
+	default:
+		return nil, fmt.Errorf("unknown cache compression %q", config.Compression)
+	}
+	return codec, nil
+}
+
+// encode wraps the value in an envelope, compressing payloads of at least the configured size.
+func (codec *cacheCodec) encode(value any, staleAt int64) ([]byte, error) {
+	var payload []byte
+	var err error
+	if codec.format == formatMsgpack {
+		payload, err = msgpack.Marshal(value)
+	} else {
+		payload, err = codec.marshalJSON(value)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	compression := compressionNone
+	if codec.compression != compressionNone && len(payload) >= codec.compressionMin {
+		if payload, err = compress(codec.compression, payload); err != nil {
+			return nil, err
+		}
+		compression = codec.compression
+	}
+
+	entry := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(payload))
+	entry[0], entry[1], entry[2] = envelopeVersion, codec.format, compression
+	binary.BigEndian.PutUint64(entry[3:envelopeHeaderSize], uint64(staleAt))
+	return append(entry, payload...), nil
+}
+
+// decode reads an envelope of any known version into value, returning the stale time of the entry.
+// The recorded format and compression are used, whatever the configuration is now.
+func (codec *cacheCodec) decode(entry []byte, value any) (int64, error) {
+	if len(entry) > 0 && entry[0] == '{' {
+		return codec.decodeLegacy(entry, value)
+	}
+	if len(entry) < envelopeHeaderSize || entry[0] != envelopeVersion {
+		return 0, errUnknownEnvelope
+	}
+	staleAt := int64(binary.BigEndian.Uint64(entry[3:envelopeHeaderSize]))
+	payload, err := decompress(entry[2], entry[envelopeHeaderSize:])
+	if err != nil {
+		return 0, err
+	}
+	switch entry[1] {
+	case formatJSON:
+		return staleAt, codec.unmarshalJSON(payload, value)
+	case formatMsgpack:
+		return staleAt, msgpack.Unmarshal(payload, value)
+	default:
+		return 0, errUnknownEnvelope
+	}
+}
+
+// legacyEntry is the envelope of the first version.
+type legacyEntry struct {
+	StaleAt int64           `json:"stale_at"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decodeLegacy reads an envelope of the first version, values cached before any envelope have no data and are unknown.
+func (codec *cacheCodec) decodeLegacy(entry []byte, value any) (int64, error) {
+	var legacy legacyEntry
+	if err := json.Unmarshal(entry, &legacy); err != nil {
+		return 0, err
+	}
+	if legacy.Data == nil {
+		return 0, errUnknownEnvelope
+	}
+	return legacy.StaleAt, codec.unmarshalJSON(legacy.Data, value)
+}
+
+// compress compresses the payload with the compression.
+func compress(compression byte, payload []byte) ([]byte, error) {
+	if compression == compressionZstd {
+		return zstdEncoder.EncodeAll(payload, nil), nil
+	}
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// decompress restores a payload compressed with the compression.
+func decompress(compression byte, payload []byte) ([]byte, error) {
+	switch compression {
+	case compressionNone:
+		return payload, nil
+	case compressionZstd:
+		return zstdDecoder.DecodeAll(payload, nil)
+	case compressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	default:
+		return nil, errUnknownEnvelope
+	}
+}
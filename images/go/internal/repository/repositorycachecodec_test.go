@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	cacheconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/cache"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+)
+
+func TestCacheCodecRoundTrip(t *testing.T) {
+	users := []*entity.Users{{ID: ksuid.New().String(), Username: "codec", Email: "codec@example.com", CreatedAt: 1700000000000, Version: 3}}
+	for _, codecName := range []string{"json", "go-json", "msgpack"} {
+		for _, compression := range []string{"none", "gzip", "zstd"} {
+			t.Run(codecName+"/"+compression, func(t *testing.T) {
+				codec, err := newCacheCodec(&cacheconfig.RedisConfig{Codec: codecName, Compression: compression})
+				require.NoError(t, err)
+
+				entry, err := codec.encode(users, 42)
+				require.NoError(t, err)
+
+				var decoded []*entity.Users
+				staleAt, err := codec.decode(entry, &decoded)
+				require.NoError(t, err)
+				require.Equal(t, int64(42), staleAt)
+				require.Equal(t, users, decoded)
+			})
+		}
+	}
+}
+
+func TestCacheCodecCompressionThreshold(t *testing.T) {
+	codec, err := newCacheCodec(&cacheconfig.RedisConfig{Codec: "json", Compression: "gzip", CompressionMin: 1 << 20})
+	require.NoError(t, err)
+
+	entry, err := codec.encode("small", 0)
+	require.NoError(t, err)
+	require.Equal(t, compressionNone, entry[2])
+}
+
+func TestCacheCodecDecodesEntriesOfOtherSettings(t *testing.T) {
+	writer, err := newCacheCodec(&cacheconfig.RedisConfig{Codec: "msgpack", Compression: "zstd"})
+	require.NoError(t, err)
+	reader, err := newCacheCodec(&cacheconfig.RedisConfig{Codec: "json"})
+	require.NoError(t, err)
+
+	entry, err := writer.encode(&entity.Users{Username: "old"}, 7)
+	require.NoError(t, err)
+
+	var decoded *entity.Users
+	staleAt, err := reader.decode(entry, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), staleAt)
+	require.Equal(t, "old", decoded.Username)
+}
+
+func TestCacheCodecLegacyEntries(t *testing.T) {
+	codec, err := newCacheCodec(&cacheconfig.RedisConfig{})
+	require.NoError(t, err)
+
+	t.Run("FirstVersionIsDecoded", func(t *testing.T) {
+		var decoded *entity.Users
+		staleAt, err := codec.decode([]byte(`{"stale_at":9,"data":{"Username":"legacy"}}`), &decoded)
+		require.NoError(t, err)
+		require.Equal(t, int64(9), staleAt)
+		require.Equal(t, "legacy", decoded.Username)
+	})
+
+	t.Run("BeforeEnvelopeIsIgnored", func(t *testing.T) {
+		var decoded *entity.Users
+		_, err := codec.decode([]byte(`{"Username":"bare"}`), &decoded)
+		require.ErrorIs(t, err, errUnknownEnvelope)
+	})
+
+	t.Run("NewerVersionIsIgnored", func(t *testing.T) {
+		var decoded *entity.Users
+		_, err := codec.decode(append([]byte{envelopeVersion + 1, formatJSON, compressionNone}, make([]byte, 8)...), &decoded)
+		require.ErrorIs(t, err, errUnknownEnvelope)
+	})
+}
+
+func TestNewCacheCodecUnknownSettings(t *testing.T) {
+	_, err := newCacheCodec(&cacheconfig.RedisConfig{Codec: "xml"})
+	require.Error(t, err)
+
+	_, err = newCacheCodec(&cacheconfig.RedisConfig{Compression: "lz4"})
+	require.Error(t, err)
+}
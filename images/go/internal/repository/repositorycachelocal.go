@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+)
+
+// LocalCacheStats reports the activity of an in-process cache.
+type LocalCacheStats struct {
+	Hits          uint64 `json:"hits"`          // Reads answered in process
+	Misses        uint64 `json:"misses"`        // Reads passed on to the next cache
+	Evictions     uint64 `json:"evictions"`     // Entries dropped to stay within the size bound
+	Invalidations uint64 `json:"invalidations"` // Entries dropped by invalidations of this or another replica
+	Entries       int    `json:"entries"`       // Entries currently held
+}
+
+// LocalCacheRepositoryImpl implements the CacheRepository interface with an in-process LRU in front of another cache.
+// Values are shared between readers as they are, callers must not modify them.
+// Invalidations announced on CacheInvalidationChannel drop the matching entries, Listen has to run for the copies of the replicas to stay consistent.
+type LocalCacheRepositoryImpl[T any] struct {
+	Next   CacheRepository[T]    // Cache answering the reads missed in process
+	Cache  redis.UniversalClient // Redis client receiving the invalidations
+	Logger *log.Logger           // Logger for logging cache operations
+	Size   int                   // Maximum number of entries held
+	TTL    time.Duration         // Expiration of the entries held
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List                     // Entries from the most to the least recently used
+	tags    map[string]map[string]struct{} // Keys held by tag, known for the entries loaded or stored by this replica
+	// generation counts the invalidations, values read before an invalidation are not held as they may predate it.
+	generation uint64
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// localEntry is an entry held in process.
+type localEntry struct {
+	key       string
+	value     any
+	tags      []string
+	expiresAt time.Time
+}
+
+// NewLocalCacheRepository creates a new LocalCacheRepositoryImpl instance in front of next.
+func NewLocalCacheRepository[T any](next CacheRepository[T], cache redis.UniversalClient, logger *log.Logger, size int, ttl time.Duration) *LocalCacheRepositoryImpl[T] {
+	return &LocalCacheRepositoryImpl[T]{
+		Next:    next,
+		Cache:   cache,
+		Logger:  logger,
+		Size:    size,
+		TTL:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+func NewLocalUserCacheRepository(next CacheRepository[*entity.Users], cache redis.UniversalClient, logger *log.Logger, size int, ttl time.Duration) *LocalCacheRepositoryImpl[*entity.Users] {
+	return NewLocalCacheRepository[*entity.Users](next, cache, logger, size, ttl)
+}
+
+// GetFromCache retrieves data in process, falling back to the next cache.
+func (r *LocalCacheRepositoryImpl[T]) GetFromCache(ctx context.Context, key string) ([]T, error) {
+	value, found, generation := r.load(key)
+	if found {
+		return value.([]T), nil
+	}
+	entities, err := r.Next.GetFromCache(ctx, key)
+	if err != nil || entities == nil {
+		return entities, err
+	}
+	r.store(key, entities, nil, generation)
+	return entities, nil
+}
+
+// SetToCache stores data in the next cache and in process.
+func (r *LocalCacheRepositoryImpl[T]) SetToCache(ctx context.Context, key string, entities []T, tags ...string) error {
+	generation := r.currentGeneration()
+	if err := r.Next.SetToCache(ctx, key, entities, tags...); err != nil {
+		return err
+	}
+	r.store(key, entities, tags, generation)
+	return nil
+}
+
+// GetOneFromCache retrieves a single entity in process, falling back to the next cache.
+func (r *LocalCacheRepositoryImpl[T]) GetOneFromCache(ctx context.Context, key string) (T, bool, error) {
+	value, found, generation := r.load(key)
+	if found {
+		return value.(T), true, nil
+	}
+	entity, found, err := r.Next.GetOneFromCache(ctx, key)
+	if err != nil || !found {
+		return entity, found, err
+	}
+	r.store(key, entity, nil, generation)
+	return entity, true, nil
+}
+
+// SetOneToCache stores a single entity in the next cache and in process.
+func (r *LocalCacheRepositoryImpl[T]) SetOneToCache(ctx context.Context, key string, entity T, tags ...string) error {
+	generation := r.currentGeneration()
+	if err := r.Next.SetOneToCache(ctx, key, entity, tags...); err != nil {
+		return err
+	}
+	r.store(key, entity, tags, generation)
+	return nil
+}
+
+// Fetch retrieves data in process, falling back to the next cache and the loader.
+func (r *LocalCacheRepositoryImpl[T]) Fetch(ctx context.Context, key string, load Loader[[]T]) ([]T, error) {
+	value, found, generation := r.load(key)
+	if found {
+		return value.([]T), nil
+	}
+	var tags []string
+	entities, err := r.Next.Fetch(ctx, key, func(ctx context.Context) ([]T, []string, error) {
+		entities, loadedTags, err := load(ctx)
+		tags = loadedTags
+		return entities, loadedTags, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.store(key, entities, tags, generation)
+	return entities, nil
+}
+
+// FetchOne retrieves a single entity in process, falling back to the next cache and the loader.
+func (r *LocalCacheRepositoryImpl[T]) FetchOne(ctx context.Context, key string, load Loader[T]) (T, error) {
+	value, found, generation := r.load(key)
+	if found {
+		return value.(T), nil
+	}
+	var tags []string
+	entity, err := r.Next.FetchOne(ctx, key, func(ctx context.Context) (T, []string, error) {
+		entity, loadedTags, err := load(ctx)
+		tags = loadedTags
+		return entity, loadedTags, err
+	})
+	if err != nil {
+		return entity, err
+	}
+	r.store(key, entity, tags, generation)
+	return entity, nil
+}
+
+// InvalidateTags invalidates the tags in the next cache.
+// Entries of the tags known in process are dropped at once, the others when the invalidation is received.
+func (r *LocalCacheRepositoryImpl[T]) InvalidateTags(ctx context.Context, tags ...string) error {
+	r.mutex.Lock()
+	r.generation++
+	for _, tag := range tags {
+		for key := range r.tags[tag] {
+			r.invalidate(key)
+		}
+	}
+	r.mutex.Unlock()
+	err := r.Next.InvalidateTags(ctx, tags...)
+	r.advance()
+	return err
+}
+
+// DeleteToCacheByRegexKey deletes the entries matching the pattern in process and in the next cache.
+func (r *LocalCacheRepositoryImpl[T]) DeleteToCacheByRegexKey(ctx context.Context, key string) error {
+	r.mutex.Lock()
+	r.generation++
+	for cached := range r.entries {
+		if matched, _ := path.Match(key, cached); matched {
+			r.invalidate(cached)
+		}
+	}
+	r.mutex.Unlock()
+	err := r.Next.DeleteToCacheByRegexKey(ctx, key)
+	r.advance()
+	return err
+}
+
+// DeleteToCache deletes a specific entry in process and in the next cache.
+func (r *LocalCacheRepositoryImpl[T]) DeleteToCache(ctx context.Context, key string) error {
+	r.mutex.Lock()
+	r.generation++
+	r.invalidate(key)
+	r.mutex.Unlock()
+	err := r.Next.DeleteToCache(ctx, key)
+	r.advance()
+	return err
+}
+
+// Listen drops the entries announced on CacheInvalidationChannel until the context is cancelled.
+func (r *LocalCacheRepositoryImpl[T]) Listen(ctx context.Context) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	subscription := r.Cache.Subscribe(ctx, CacheInvalidationChannel)
+	defer subscription.Close()
+	logger.Info().Msgf("Listening for cache invalidations on %s", CacheInvalidationChannel)
+
+	messages := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			var keys []string
+			if err := json.Unmarshal([]byte(message.Payload), &keys); err != nil {
+				logger.Warn().Msgf("Ignoring malformed cache invalidation %q: %v", message.Payload, err)
+				continue
+			}
+			r.mutex.Lock()
+			r.generation++
+			for _, key := range keys {
+				r.invalidate(key)
+			}
+			r.mutex.Unlock()
+		}
+	}
+}
+
+// Stats returns the activity of the cache since it was created.
+func (r *LocalCacheRepositoryImpl[T]) Stats() LocalCacheStats {
+	r.mutex.Lock()
+	entries := r.order.Len()
+	r.mutex.Unlock()
+	return LocalCacheStats{
+		Hits:          r.hits.Load(),
+		Misses:        r.misses.Load(),
+		Evictions:     r.evictions.Load(),
+		Invalidations: r.invalidations.Load(),
+		Entries:       entries,
+	}
+}
+
+// load returns the unexpired entry of the key, marking it as the most recently used.
+// On a miss it returns the generation to store the value read from the next cache with.
+func (r *LocalCacheRepositoryImpl[T]) load(key string) (any, bool, uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	element, found := r.entries[key]
+	if !found {
+		r.misses.Add(1)
+		return nil, false, r.generation
+	}
+	entry := element.Value.(*localEntry)
+	if time.Now().After(entry.expiresAt) {
+		r.remove(element)
+		r.misses.Add(1)
+		return nil, false, r.generation
+	}
+	r.order.MoveToFront(element)
+	r.hits.Add(1)
+	return entry.value, true, r.generation
+}
+
+// advance starts a new generation once the next cache is invalidated, values read from it meanwhile are not held.
+func (r *LocalCacheRepositoryImpl[T]) advance() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.generation++
+}
+
+// currentGeneration returns the number of invalidations so far.
+func (r *LocalCacheRepositoryImpl[T]) currentGeneration() uint64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.generation
+}
+
+// store holds the value of the key read at the generation, evicting the least recently used entries beyond the size bound.
+// The value is not held when an invalidation happened since it was read.
+func (r *LocalCacheRepositoryImpl[T]) store(key string, value any, tags []string, generation uint64) {
+	if r.Size <= 0 {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.generation != generation {
+		return
+	}
+	if element, found := r.entries[key]; found {
+		r.remove(element)
+	}
+	r.entries[key] = r.order.PushFront(&localEntry{key: key, value: value, tags: tags, expiresAt: time.Now().Add(r.TTL)})
+	for _, tag := range tags {
+		if r.tags[tag] == nil {
+			r.tags[tag] = make(map[string]struct{})
+		}
+		r.tags[tag][key] = struct{}{}
+	}
+	for r.order.Len() > r.Size {
+		r.remove(r.order.Back())
+		r.evictions.Add(1)
+	}
+}
+
+// invalidate drops the entry of the key if it is held, the mutex must be held.
+func (r *LocalCacheRepositoryImpl[T]) invalidate(key string) {
+	if element, found := r.entries[key]; found {
+		r.remove(element)
+		r.invalidations.Add(1)
+	}
+}
+
+// remove drops an entry together with its tag registrations, the mutex must be held.
+func (r *LocalCacheRepositoryImpl[T]) remove(element *list.Element) {
+	entry := r.order.Remove(element).(*localEntry)
+	delete(r.entries, entry.key)
+	for _, tag := range entry.tags {
+		delete(r.tags[tag], entry.key)
+		if len(r.tags[tag]) == 0 {
+			delete(r.tags, tag)
+		}
+	}
+}
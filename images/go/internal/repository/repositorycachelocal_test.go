@@ -0,0 +1,112 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	tmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+func TestLocalCacheRepositoryMethods(t *testing.T) {
+	ctx := context.Background()
+	users := &entity.Users{ID: ksuid.New().String(), Username: "local"}
+	load := func(ctx context.Context) (*entity.Users, []string, error) {
+		return users, []string{"users:tag:id:" + users.ID}, nil
+	}
+
+	t.Run("FetchOneHoldsInProcess", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		local := repository.NewLocalUserCacheRepository(next, nil, &log.DefaultLogger, 10, time.Minute)
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(nil, false, nil).Once()
+		next.On("SetOneToCache", tmock.Anything, "users:id:1", users, []string{"users:tag:id:" + users.ID}).Return(nil).Once()
+
+		for i := 0; i < 3; i++ {
+			result, err := local.FetchOne(ctx, "users:id:1", load)
+			require.NoError(t, err)
+			require.Equal(t, users, result)
+		}
+
+		next.AssertExpectations(t)
+		stats := local.Stats()
+		require.Equal(t, uint64(2), stats.Hits)
+		require.Equal(t, uint64(1), stats.Misses)
+		require.Equal(t, 1, stats.Entries)
+	})
+
+	t.Run("InvalidateTagsDropsKnownEntries", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		local := repository.NewLocalUserCacheRepository(next, nil, &log.DefaultLogger, 10, time.Minute)
+		tags := []string{"users:tag:id:" + users.ID}
+		next.On("SetOneToCache", tmock.Anything, "users:id:1", users, tags).Return(nil).Once()
+		next.On("InvalidateTags", tmock.Anything, tags).Return(nil).Once()
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(nil, false, nil).Once()
+
+		require.NoError(t, local.SetOneToCache(ctx, "users:id:1", users, tags...))
+		require.NoError(t, local.InvalidateTags(ctx, tags...))
+		_, found, err := local.GetOneFromCache(ctx, "users:id:1")
+		require.NoError(t, err)
+		require.False(t, found)
+
+		next.AssertExpectations(t)
+		require.Equal(t, uint64(1), local.Stats().Invalidations)
+	})
+
+	t.Run("EvictsLeastRecentlyUsed", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		local := repository.NewLocalUserCacheRepository(next, nil, &log.DefaultLogger, 2, time.Minute)
+		next.On("SetToCache", tmock.Anything, tmock.Anything, tmock.Anything, []string(nil)).Return(nil)
+		next.On("GetFromCache", tmock.Anything, "users:all:a").Return(nil, nil).Once()
+
+		require.NoError(t, local.SetToCache(ctx, "users:all:a", []*entity.Users{users}))
+		require.NoError(t, local.SetToCache(ctx, "users:all:b", []*entity.Users{users}))
+		_, err := local.GetFromCache(ctx, "users:all:b")
+		require.NoError(t, err)
+		require.NoError(t, local.SetToCache(ctx, "users:all:c", []*entity.Users{users}))
+
+		result, err := local.GetFromCache(ctx, "users:all:a")
+		require.NoError(t, err)
+		require.Nil(t, result)
+
+		next.AssertExpectations(t)
+		stats := local.Stats()
+		require.Equal(t, uint64(1), stats.Evictions)
+		require.Equal(t, 2, stats.Entries)
+	})
+
+	t.Run("ExpiredEntryIsMissed", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		local := repository.NewLocalUserCacheRepository(next, nil, &log.DefaultLogger, 10, time.Nanosecond)
+		next.On("SetOneToCache", tmock.Anything, "users:id:1", users, []string(nil)).Return(nil).Once()
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(users, true, nil).Once()
+
+		require.NoError(t, local.SetOneToCache(ctx, "users:id:1", users))
+		time.Sleep(time.Millisecond)
+		result, found, err := local.GetOneFromCache(ctx, "users:id:1")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, users, result)
+
+		next.AssertExpectations(t)
+	})
+
+	t.Run("LoaderErrorIsNotHeld", func(t *testing.T) {
+		next := new(repository.MockCacheRepository[*entity.Users])
+		local := repository.NewLocalUserCacheRepository(next, nil, &log.DefaultLogger, 10, time.Minute)
+		next.On("GetOneFromCache", tmock.Anything, "users:id:1").Return(nil, false, nil).Once()
+
+		_, err := local.FetchOne(ctx, "users:id:1", func(ctx context.Context) (*entity.Users, []string, error) {
+			return nil, nil, errors.New("database down")
+		})
+		require.Error(t, err)
+
+		next.AssertExpectations(t)
+		require.Equal(t, 0, local.Stats().Entries)
+	})
+}
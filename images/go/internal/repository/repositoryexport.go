@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+)
+
+// ExportRepository defines the interface for storing generated data export archives.
+type ExportRepository interface {
+	GetArchive(ctx context.Context, id string) ([]byte, error)                                 // Fetch a generated archive, nil when absent
+	SetArchive(ctx context.Context, id string, archive []byte, expiration time.Duration) error // Store a generated archive, unless deleted since its export started
+	DeleteArchive(ctx context.Context, id string) error                                        // Remove the archive and stop the running export from storing its own
+	MarkPending(ctx context.Context, id string, expiration time.Duration) (bool, error)        // Mark an export as running, false when it already is
+	ClearPending(ctx context.Context, id string) error                                         // Remove the running mark of an export
+}
+
+// ExportRepositoryImpl implements the ExportRepository interface using Redis.
+type ExportRepositoryImpl struct {
+	Cache  redis.UniversalClient // Redis client shared by every process, so any of them can serve the archive
+	Logger *log.Logger           // Logger for logging export operations
+}
+
+// NewExportRepository creates a new ExportRepositoryImpl instance.
+func NewExportRepository(cache redis.UniversalClient, logger *log.Logger) *ExportRepositoryImpl {
+	return &ExportRepositoryImpl{Cache: cache, Logger: logger}
+}
+
+// archiveKey returns the key holding the archive of the user id.
+// The keys live outside the 'users:' prefix so user cache invalidation does not drop them,
+// the hash tag keeps both keys of a user on the same cluster node for storeArchiveScript and DeleteArchive.
+func archiveKey(id string) string {
+	return "export:users:{" + id + "}:archive"
+}
+
+// pendingKey returns the key marking a running export of the user id.
+func pendingKey(id string) string {
+	return "export:users:{" + id + "}:pending"
+}
+
+// storeArchiveScript stores an archive only while its export is still marked as running.
+var storeArchiveScript = redis.NewScript(`if redis.call("EXISTS", KEYS[2]) == 0 then return 0 end redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2]) return 1`)
+
+// GetArchive retrieves the generated archive of the user id.
+func (r ExportRepositoryImpl) GetArchive(ctx context.Context, id string) ([]byte, error) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	logger.Info().Msgf("Fetching export archive: %s", id)
+	archive, err := r.Cache.Get(ctx, archiveKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		logger.Info().Msgf("Export archive not found: %s", id)
+		return nil, nil
+	} else if err != nil {
+		logger.Error().Msgf("Export archive error: %v", err)
+		return nil, err
+	}
+	return archive, nil
+}
+
+// SetArchive stores the generated archive of the user id for the given expiration.
+// The archive is discarded when DeleteArchive removed the running mark of its export meanwhile, as it holds data changed or purged since.
+func (r ExportRepositoryImpl) SetArchive(ctx context.Context, id string, archive []byte, expiration time.Duration) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	stored, err := storeArchiveScript.Run(ctx, r.Cache, []string{archiveKey(id), pendingKey(id)}, archive, expiration.Milliseconds()).Bool()
+	if err != nil {
+		logger.Error().Msgf("Error storing export archive: %v", err)
+		return err
+	}
+	if !stored {
+		logger.Info().Msgf("Export archive discarded, the user changed since the export started: %s", id)
+		return nil
+	}
+	logger.Info().Msgf("Export archive stored: %s", id)
+	return nil
+}
+
+// DeleteArchive removes the archive of the user id along with the running mark of its export, so a running job discards its archive.
+func (r ExportRepositoryImpl) DeleteArchive(ctx context.Context, id string) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if err := r.Cache.Del(ctx, archiveKey(id), pendingKey(id)).Err(); err != nil {
+		logger.Error().Msgf("Failed to delete export archive %s: %v", id, err)
+		return err
+	}
+	logger.Info().Msgf("Export archive deleted: %s", id)
+	return nil
+}
+
+// MarkPending marks the export of the user id as running, it reports false when an export is already running.
+func (r ExportRepositoryImpl) MarkPending(ctx context.Context, id string, expiration time.Duration) (bool, error) {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	marked, err := r.Cache.SetNX(ctx, pendingKey(id), time.Now().UnixMilli(), expiration).Result()
+	if err != nil {
+		logger.Error().Msgf("Error marking export as pending: %v", err)
+		return false, err
+	}
+	return marked, nil
+}
+
+// ClearPending removes the running mark of the export of the user id.
+func (r ExportRepositoryImpl) ClearPending(ctx context.Context, id string) error {
+	logger := loggerconfig.FromContext(ctx, r.Logger)
+	if err := r.Cache.Del(ctx, pendingKey(id)).Err(); err != nil {
+		logger.Error().Msgf("Failed to clear pending export %s: %v", id, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+func TestExportRepositoryMethods(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := repository.NewExportRepository(client, &log.DefaultLogger)
+
+	t.Run("SetArchiveOfRunningExport", func(t *testing.T) {
+		id := ksuid.New().String()
+		marked, err := repo.MarkPending(ctx, id, time.Minute)
+		require.NoError(t, err)
+		require.True(t, marked)
+
+		require.NoError(t, repo.SetArchive(ctx, id, []byte("archive"), time.Minute))
+		archive, err := repo.GetArchive(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, []byte("archive"), archive)
+	})
+
+	t.Run("DeleteArchive", func(t *testing.T) {
+		id := ksuid.New().String()
+		_, err := repo.MarkPending(ctx, id, time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, repo.SetArchive(ctx, id, []byte("archive"), time.Minute))
+
+		require.NoError(t, repo.DeleteArchive(ctx, id))
+		archive, err := repo.GetArchive(ctx, id)
+		require.NoError(t, err)
+		require.Nil(t, archive)
+		// the running mark is gone as well, a new export may start
+		marked, err := repo.MarkPending(ctx, id, time.Minute)
+		require.NoError(t, err)
+		require.True(t, marked)
+	})
+
+	t.Run("SetArchiveDiscardedAfterDelete", func(t *testing.T) {
+		// the user changes while its export is running, the archive built from the previous data is never stored
+		id := ksuid.New().String()
+		_, err := repo.MarkPending(ctx, id, time.Minute)
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteArchive(ctx, id))
+
+		require.NoError(t, repo.SetArchive(ctx, id, []byte("stale"), time.Minute))
+		archive, err := repo.GetArchive(ctx, id)
+		require.NoError(t, err)
+		require.Nil(t, archive)
+	})
+}
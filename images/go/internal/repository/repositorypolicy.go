@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/ksuid"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+)
+
+// PolicyChannel is the Redis pub/sub channel announcing that a process changed the Casbin policy, with the ID of the process.
+const PolicyChannel = "casbin:policy"
+
+// PolicyWatcher implements the Casbin Watcher interface over Redis pub/sub, so the enforcers of the other processes,
+// the prefork children and the replicas alike, reload the policy changed by one of them.
+// Listen has to run for the enforcer of the process to receive the changes of the others,
+// a change announced while Redis is unreachable is missed until the next one.
+type PolicyWatcher struct {
+	Cache  redis.UniversalClient // Redis client publishing and receiving the announcements
+	Logger *log.Logger           // Logger for logging the announcements
+
+	id       string // Tells the announcements of this process apart from the others
+	mutex    sync.Mutex
+	callback func(string)
+}
+
+// NewPolicyWatcher creates a new PolicyWatcher instance.
+func NewPolicyWatcher(cache redis.UniversalClient, logger *log.Logger) *PolicyWatcher {
+	return &PolicyWatcher{Cache: cache, Logger: logger, id: ksuid.New().String()}
+}
+
+// SetUpdateCallback sets the function called when another process changed the policy, Enforcer.LoadPolicy usually.
+func (w *PolicyWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update announces that this process changed the policy.
+// Failing to publish is only logged, the change is saved already and the other processes pick it up with the next one.
+func (w *PolicyWatcher) Update() error {
+	if err := w.Cache.Publish(context.Background(), PolicyChannel, w.id).Err(); err != nil {
+		w.Logger.Error().Msgf("Failed to announce the policy change on %s: %v", PolicyChannel, err)
+	}
+	return nil
+}
+
+// Close does nothing, Listen stops with its context.
+func (w *PolicyWatcher) Close() {}
+
+// Listen calls the update callback for every policy change announced by another process until the context is cancelled.
+func (w *PolicyWatcher) Listen(ctx context.Context) {
+	logger := loggerconfig.FromContext(ctx, w.Logger)
+	subscription := w.Cache.Subscribe(ctx, PolicyChannel)
+	defer subscription.Close()
+	logger.Info().Msgf("Listening for policy changes on %s", PolicyChannel)
+
+	messages := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			if message.Payload == w.id {
+				continue
+			}
+			w.mutex.Lock()
+			callback := w.callback
+			w.mutex.Unlock()
+			if callback != nil {
+				callback(message.Payload)
+			}
+		}
+	}
+}
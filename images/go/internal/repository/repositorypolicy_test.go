@@ -0,0 +1,50 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/phuslu/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+func TestPolicyWatcher(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	listener := repository.NewPolicyWatcher(client, &log.DefaultLogger)
+	other := repository.NewPolicyWatcher(client, &log.DefaultLogger)
+	received := make(chan string, 100)
+	require.NoError(t, listener.SetUpdateCallback(func(id string) { received <- id }))
+	go listener.Listen(ctx)
+
+	// the subscription starts in the background, the announcements are repeated until one is received
+	var first string
+	require.Eventually(t, func() bool {
+		require.NoError(t, listener.Update())
+		require.NoError(t, other.Update())
+		select {
+		case first = <-received:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// only the changes of the other process are reloaded, never its own
+	require.NoError(t, listener.Update())
+	require.NoError(t, other.Update())
+	require.Eventually(t, func() bool { return len(received) > 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, first, <-received)
+
+	// a failed announcement is logged, the policy change it follows is saved already
+	server.Close()
+	require.NoError(t, other.Update())
+}
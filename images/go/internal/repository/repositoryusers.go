@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
 	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
 	"gorm.io/gorm"
@@ -15,12 +16,16 @@ type UsersRepository interface {
 	Update(ctx context.Context, entity *entity.Users, id any) error
 	Delete(ctx context.Context, id any) error
 	Restore(ctx context.Context, id any) error
+	Purge(ctx context.Context, id any) error
 	CountById(ctx context.Context, id any) (int64, error)
 	Count(ctx context.Context) (int64, error)
 	ExistByKeyValue(ctx context.Context, keyvalue map[string]any) (bool, error)
 	GetById(ctx context.Context, entity *entity.Users, id any) error
+	GetByIdWithDeleted(ctx context.Context, entity *entity.Users, id any) error
 	GetByEmail(ctx context.Context, entity *entity.Users, email string) error
+	GetByEmailWithDeleted(ctx context.Context, entity *entity.Users, email string) error
 	GetAll(ctx context.Context, queryParams *request.Page) ([]*entity.Users, error)
+	GetDeletedBefore(ctx context.Context, before int64, limit int) ([]*entity.Users, error)
 }
 
 // UsersRepositoryImpl implements the UsersRepository interface.
@@ -42,82 +47,135 @@ func NewUsersRepositoryImpl(DB *gorm.DB, logger *log.Logger) (*UsersRepositoryIm
 
 // GetAll retrieves all users based on the query parameters.
 func (repo UsersRepositoryImpl) GetAll(ctx context.Context, queryParams *request.Page) ([]*entity.Users, error) {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
 	// Log the start of the GetAll method
-	repo.Logger.Info().Msg("Starting GetAll method")
-	var users []*entity.Users
-
-	// Prepare the query with context and default limit
-	queryDB := repo.DB.Unscoped().WithContext(ctx).Model(&entity.Users{}).Limit(queryParams.Size)
-
-	// Apply cursor pagination if Before parameter is provided
-	if queryParams.Before != "" {
-		repo.Logger.Info().Msgf("Applying cursor pagination with Before ID: %s", queryParams.Before)
-		queryDB = queryDB.Where("id < ?", queryParams.Before)
-	}
-	// Apply cursor pagination if After parameter is provided
-	if queryParams.After != "" {
-		repo.Logger.Info().Msgf("Applying cursor pagination with After ID: %s", queryParams.After)
-		queryDB = queryDB.Where("id > ?", queryParams.After)
-	}
-	// Apply default ordering by ID in ascending order
-	repo.Logger.Info().Msg("Applying default order by ID ascending")
-	queryDB = queryDB.Order("id ASC")
+	logger.Info().Msg("Starting GetAll method")
 
-	// Execute the query and fetch users
-	err := queryDB.Find(&users).Error
+	// Deleted users are listed too, ordered by ID with cursor pagination
+	spec := Query[entity.Users]().WithDeleted().
+		Before("id", queryParams.Before).
+		After("id", queryParams.After).
+		OrderBy("id", Asc).
+		Limit(queryParams.Size)
+	users, err := repo.FindAll(ctx, spec)
 	if err != nil {
 		// Log error if fetching users fails
-		repo.Logger.Error().Msgf("Error occurred while fetching users: %v", err)
+		logger.Error().Msgf("Error occurred while fetching users: %v", err)
 		return nil, err
 	}
 
 	// Log successful retrieval of users
-	repo.Logger.Info().Msgf("Successfully retrieved %d users", len(users))
+	logger.Info().Msgf("Successfully retrieved %d users", len(users))
 	return users, nil
 }
 
 // ExistByKeyValue checks if a user exists based on key-value conditions.
 func (repo UsersRepositoryImpl) ExistByKeyValue(ctx context.Context, keyvalue map[string]any) (bool, error) {
-	var countResult int64
-	query := repo.DB.WithContext(ctx).Model(&entity.Users{})
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	spec := Query[entity.Users]()
 	// Apply where conditions for each key-value pair
 	for key, value := range keyvalue {
-		repo.Logger.Info().Msgf("Applying where conditions column %s value %v", key, value)
-		query = query.Where(key+" = ?", value)
+		logger.Info().Msgf("Applying where conditions column %s value %v", key, value)
+		spec = spec.Where(Eq(key, value))
 	}
-	// Count the number of records matching the conditions
-	err := query.Count(&countResult).Error
-	repo.Logger.Info().Msgf("Count result: %d", countResult)
-	return countResult == 1, err
+	return repo.Exists(ctx, spec)
 }
 
 func (repo UsersRepositoryImpl) GetByEmail(ctx context.Context, users *entity.Users, email string) error {
-	repo.Logger.Info().Msgf("Retrieving entity by Email: %v", email)
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Retrieving entity by Email: %v", email)
 	// Retrieve entity by ID from the database
-	err := repo.DB.Model(&entity.Users{}).WithContext(ctx).Where("email = ?", email).Take(users).Error
+	err := session(ctx, repo.DB).Model(&entity.Users{}).Where("email = ?", email).Take(users).Error
 	if err != nil {
 		// Log error if retrieval failed.
-		repo.Logger.Error().Msgf("Failed to retrieve entity by Email: %v", err)
+		logger.Error().Msgf("Failed to retrieve entity by Email: %v", err)
 		return err
 	}
 	// Log success if entity retrieval was successful.
-	repo.Logger.Info().Msg("Entity successfully retrieved")
+	logger.Info().Msg("Entity successfully retrieved")
 	return nil
 }
 
 func (repo UsersRepositoryImpl) Restore(ctx context.Context, id any) error {
-	repo.Logger.Info().Msgf("Retrieving entity by ID: %v", id)
-	tx, closeTx := repo.Transaction(ctx) // Start transaction
-	defer closeTx(tx)                    // Ensure transaction is committed or rolled back
-	err := tx.Unscoped().Model(&entity.Users{}).WithContext(ctx).Where("id = ?", id).Not("deleted_at", 0).Update("deleted_at", 0).Error
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Retrieving entity by ID: %v", id)
+	// Clear the deletion mark and bump the version, joining the unit of work of ctx if any
+	err := transaction(ctx, repo.DB, func(tx *gorm.DB) error {
+		return tx.Unscoped().Model(&entity.Users{}).Where("id = ?", id).Not("deleted_at", 0).Updates(map[string]any{"deleted_at": 0, "version": gorm.Expr("version + 1")}).Error
+	})
+	if err != nil {
+		// Log error if retrieval failed.
+		logger.Error().Msgf("Failed to restore entity by ID: %v", err)
+		return err
+	}
+	// Log success if entity retrieval was successful.
+	logger.Info().Msg("Entity successfully restore")
+	return nil
+}
+
+// GetByIdWithDeleted retrieves a user by ID, including users that have been soft-deleted.
+func (repo UsersRepositoryImpl) GetByIdWithDeleted(ctx context.Context, users *entity.Users, id any) error {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Retrieving entity by ID including deleted: %v", id)
+	// Retrieve entity by ID from the database without the soft delete scope
+	err := session(ctx, repo.DB).Unscoped().Model(&entity.Users{}).Where("id = ?", id).Take(users).Error
 	if err != nil {
-		//Rollback Transaction
-		tx.Rollback()
 		// Log error if retrieval failed.
-		repo.Logger.Error().Msgf("Failed to restore entity by ID: %v", err)
+		logger.Error().Msgf("Failed to retrieve entity by ID including deleted: %v", err)
 		return err
 	}
 	// Log success if entity retrieval was successful.
-	repo.Logger.Info().Msg("Entity successfully restore")
+	logger.Info().Msg("Entity successfully retrieved")
+	return nil
+}
+
+// GetByEmailWithDeleted retrieves a user by email, including users that have been soft-deleted.
+func (repo UsersRepositoryImpl) GetByEmailWithDeleted(ctx context.Context, users *entity.Users, email string) error {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Retrieving entity by Email including deleted: %v", email)
+	// Retrieve entity by email from the database without the soft delete scope
+	err := session(ctx, repo.DB).Unscoped().Model(&entity.Users{}).Where("email = ?", email).Take(users).Error
+	if err != nil {
+		// Log error if retrieval failed.
+		logger.Error().Msgf("Failed to retrieve entity by Email including deleted: %v", err)
+		return err
+	}
+	// Log success if entity retrieval was successful.
+	logger.Info().Msg("Entity successfully retrieved")
+	return nil
+}
+
+// GetDeletedBefore retrieves users soft-deleted before the given unix milli timestamp, oldest first.
+func (repo UsersRepositoryImpl) GetDeletedBefore(ctx context.Context, before int64, limit int) ([]*entity.Users, error) {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Retrieving users deleted before: %d", before)
+	var users []*entity.Users
+	err := session(ctx, repo.DB).Unscoped().Model(&entity.Users{}).
+		Where("deleted_at <> 0 AND deleted_at < ?", before).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		// Log error if fetching users fails
+		logger.Error().Msgf("Error occurred while fetching deleted users: %v", err)
+		return nil, err
+	}
+	logger.Info().Msgf("Successfully retrieved %d deleted users", len(users))
+	return users, nil
+}
+
+// Purge permanently removes a user row, bypassing the soft delete.
+func (repo UsersRepositoryImpl) Purge(ctx context.Context, id any) error {
+	logger := loggerconfig.FromContext(ctx, repo.Logger)
+	logger.Info().Msgf("Purging entity by ID: %v", id)
+	// Remove the row, joining the unit of work of ctx if any
+	err := transaction(ctx, repo.DB, func(tx *gorm.DB) error {
+		return tx.Unscoped().Where("id = ?", id).Delete(&entity.Users{}).Error
+	})
+	if err != nil {
+		logger.Error().Msgf("Failed to purge entity by ID: %v", err)
+		return err
+	}
+	logger.Info().Msg("Entity successfully purged")
 	return nil
 }
@@ -148,7 +148,7 @@ func TestUsersRepositoryMethods(t *testing.T) {
 	t.Run("Create Users Case", func(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).
-			WithArgs(user.ID, user.Username, user.Email, user.Password, user.CreatedAt, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WithArgs(user.ID, user.Username, user.Email, user.Password, user.CreatedAt, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1)).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -160,7 +160,7 @@ func TestUsersRepositoryMethods(t *testing.T) {
 	t.Run("Failure Create Users Case Because Already Exist", func(t *testing.T) {
 		mock.ExpectBegin()
 		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).
-			WithArgs(user.ID, user.Username, user.Email, user.Password, user.CreatedAt, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WithArgs(user.ID, user.Username, user.Email, user.Password, user.CreatedAt, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(1)).
 			WillReturnError(gorm.ErrDuplicatedKey)
 		mock.ExpectRollback()
 
@@ -174,14 +174,28 @@ func TestUsersRepositoryMethods(t *testing.T) {
 
 		// Update the regular expression to match the actual SQL query format
 
-		mock.ExpectExec(`UPDATE "users" SET .+ WHERE .+`).
-			WithArgs(user.Username, user.Email, user.Password, sqlmock.AnyArg(), sqlmock.AnyArg(), user.ID, sqlmock.AnyArg()).
+		mock.ExpectExec(`UPDATE "users" SET .+ WHERE id = .+ AND version = .+`).
+			WithArgs(user.Username, user.Email, user.Password, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(2), user.ID, int64(1), sqlmock.AnyArg()).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 		id := user.ID
 		user.ID = ""
 		err := repo.Update(ctx, user, id)
 		require.NoError(t, err)
+		require.Equal(t, int64(2), user.Version)
+		// Verify that all expectations were met
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Failure Update Users Case Because Version Conflict", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "users" SET .+ WHERE id = .+ AND version = .+`).
+			WithArgs(user.Username, user.Email, user.Password, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(3), sqlmock.AnyArg(), int64(2), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		err := repo.Update(ctx, user, ksuid.New().String())
+		require.ErrorIs(t, err, repository.ErrVersionConflict)
+		require.Equal(t, int64(2), user.Version)
 		// Verify that all expectations were met
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
@@ -192,7 +206,7 @@ func TestUsersRepositoryMethods(t *testing.T) {
 		// Update the regular expression to match the actual SQL query format
 
 		mock.ExpectExec(`UPDATE "users" SET .+ WHERE .+`).
-			WithArgs(user.Username, user.Email, user.Password, sqlmock.AnyArg(), sqlmock.AnyArg(), user.ID, sqlmock.AnyArg()).
+			WithArgs(user.Username, user.Email, user.Password, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(3), user.ID, int64(2), sqlmock.AnyArg()).
 			WillReturnError(gorm.ErrRecordNotFound)
 		mock.ExpectRollback()
 		id := user.ID
@@ -205,6 +219,7 @@ func TestUsersRepositoryMethods(t *testing.T) {
 
 	t.Run("Delete Users Case", func(t *testing.T) {
 		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "users" SET "version"=version \+ 1 WHERE .+`).WithArgs(user.ID, 0).WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectExec(`UPDATE "users" SET .+ WHERE .+`).WithArgs(sqlmock.AnyArg(), user.ID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 
@@ -215,7 +230,7 @@ func TestUsersRepositoryMethods(t *testing.T) {
 
 	t.Run("Failure Delete Users Case Because Record Not Found", func(t *testing.T) {
 		mock.ExpectBegin()
-		mock.ExpectExec(`UPDATE "users" SET .+ WHERE .+`).WithArgs(sqlmock.AnyArg(), user.ID, sqlmock.AnyArg()).WillReturnError(gorm.ErrRecordNotFound)
+		mock.ExpectExec(`UPDATE "users" SET "version"=version \+ 1 WHERE .+`).WithArgs(user.ID, 0).WillReturnError(gorm.ErrRecordNotFound)
 		mock.ExpectRollback()
 
 		err := repo.Delete(ctx, user.ID)
@@ -341,4 +356,55 @@ func TestUsersRepositoryMethods(t *testing.T) {
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	t.Run("Purge Users Case", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "users" WHERE .+`).WithArgs(user.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := repo.Purge(ctx, user.ID)
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Failure Purge Users Case Because Invalid Value", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`DELETE FROM "users" WHERE .+`).WithArgs(user.ID).WillReturnError(gorm.ErrInvalidValue)
+		mock.ExpectRollback()
+
+		err := repo.Purge(ctx, user.ID)
+		require.Error(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetByIdWithDeleted Users Case", func(t *testing.T) {
+		var result entity.Users
+		mock.ExpectQuery(`SELECT .+ FROM "users" WHERE id = .+ LIMIT .+`).WithArgs(user.ID, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "deleted_at"}).AddRow(user.ID, user.Username, user.Email, 1))
+
+		err := repo.GetByIdWithDeleted(ctx, &result, user.ID)
+		require.NoError(t, err)
+		require.Equal(t, user.Email, result.Email)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetByEmailWithDeleted Users Case", func(t *testing.T) {
+		var result entity.Users
+		mock.ExpectQuery(`SELECT .+ FROM "users" WHERE email = .+ LIMIT .+`).WithArgs(user.Email, 1).WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "deleted_at"}).AddRow(user.ID, user.Username, user.Email, 1))
+
+		err := repo.GetByEmailWithDeleted(ctx, &result, user.Email)
+		require.NoError(t, err)
+		require.Equal(t, user.ID, result.ID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("GetDeletedBefore Users Case", func(t *testing.T) {
+		before := time.Now().UnixMilli()
+		mock.ExpectQuery(`SELECT .+ FROM "users" WHERE deleted_at <> 0 AND deleted_at < .+ ORDER BY deleted_at ASC LIMIT .+`).WithArgs(before, 10).WillReturnRows(sqlmock.NewRows([]string{"id", "email", "deleted_at"}).AddRow(user.ID, user.Email, before-1))
+
+		users, err := repo.GetDeletedBefore(ctx, before, 10)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		require.Equal(t, user.ID, users[0].ID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
 }
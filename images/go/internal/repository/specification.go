@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownColumn is returned when a specification refers to a column which is not part of the entity schema.
+var ErrUnknownColumn = errors.New("unknown column")
+
+// Condition is a filter of a specification, its columns are checked against the entity schema when the query runs.
+type Condition interface {
+	build(columns func(string) (string, error)) (string, []any, error)
+}
+
+// comparison compares a column with a single value.
+type comparison struct {
+	column   string
+	operator string
+	value    any
+}
+
+func (c comparison) build(columns func(string) (string, error)) (string, []any, error) {
+	column, err := columns(c.column)
+	if err != nil {
+		return "", nil, err
+	}
+	return column + " " + c.operator + " ?", []any{c.value}, nil
+}
+
+// Eq matches rows whose column equals the value.
+func Eq(column string, value any) Condition { return comparison{column, "=", value} }
+
+// NotEq matches rows whose column differs from the value.
+func NotEq(column string, value any) Condition { return comparison{column, "<>", value} }
+
+// Gt matches rows whose column is greater than the value.
+func Gt(column string, value any) Condition { return comparison{column, ">", value} }
+
+// Gte matches rows whose column is greater than or equal to the value.
+func Gte(column string, value any) Condition { return comparison{column, ">=", value} }
+
+// Lt matches rows whose column is less than the value.
+func Lt(column string, value any) Condition { return comparison{column, "<", value} }
+
+// Lte matches rows whose column is less than or equal to the value.
+func Lte(column string, value any) Condition { return comparison{column, "<=", value} }
+
+// Like matches rows whose column matches the LIKE pattern, the pattern is used as is.
+func Like(column string, pattern string) Condition { return comparison{column, "LIKE", pattern} }
+
+// membership matches a column against a list of values.
+type membership struct {
+	column string
+	values []any
+}
+
+func (m membership) build(columns func(string) (string, error)) (string, []any, error) {
+	column, err := columns(m.column)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(m.values) == 0 {
+		// Nothing is a member of an empty list.
+		return "1 = 0", nil, nil
+	}
+	return column + " IN ?", []any{m.values}, nil
+}
+
+// In matches rows whose column equals one of the values.
+func In[V any](column string, values ...V) Condition {
+	members := make([]any, len(values))
+	for i, value := range values {
+		members[i] = value
+	}
+	return membership{column, members}
+}
+
+// Between matches rows whose column lies within the inclusive range.
+func Between(column string, from, to any) Condition {
+	return And(Gte(column, from), Lte(column, to))
+}
+
+// group joins conditions with a logical operator.
+type group struct {
+	operator   string
+	conditions []Condition
+}
+
+func (g group) build(columns func(string) (string, error)) (string, []any, error) {
+	clauses := make([]string, 0, len(g.conditions))
+	args := []any{}
+	for _, condition := range g.conditions {
+		clause, conditionArgs, err := condition.build(columns)
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, clause)
+		args = append(args, conditionArgs...)
+	}
+	if len(clauses) == 0 {
+		return "1 = 1", nil, nil
+	}
+	if len(clauses) == 1 {
+		return clauses[0], args, nil
+	}
+	return "(" + strings.Join(clauses, " "+g.operator+" ") + ")", args, nil
+}
+
+// And matches rows matching every condition.
+func And(conditions ...Condition) Condition { return group{"AND", conditions} }
+
+// Or matches rows matching any of the conditions.
+func Or(conditions ...Condition) Condition { return group{"OR", conditions} }
+
+// Direction is the direction of an ordering.
+type Direction string
+
+const (
+	Asc  Direction = "ASC"
+	Desc Direction = "DESC"
+)
+
+// order orders the rows by a column.
+type order struct {
+	column    string
+	direction Direction
+}
+
+// Spec describes a query over the entities T: filters, ordering, cursor and limit.
+// Column names are the database names or the field names of the GORM schema of T, any other name fails the query with ErrUnknownColumn.
+type Spec[T any] struct {
+	conditions []Condition
+	orders     []order
+	limit      int
+	unscoped   bool
+}
+
+// Query creates an empty specification over the entities T.
+func Query[T any]() *Spec[T] {
+	return &Spec[T]{}
+}
+
+// Where adds conditions all rows must match.
+func (spec *Spec[T]) Where(conditions ...Condition) *Spec[T] {
+	spec.conditions = append(spec.conditions, conditions...)
+	return spec
+}
+
+// OrderBy adds an ordering, applied after the previous ones.
+func (spec *Spec[T]) OrderBy(column string, direction Direction) *Spec[T] {
+	spec.orders = append(spec.orders, order{column, direction})
+	return spec
+}
+
+// After keeps the rows whose column is greater than the cursor, an empty cursor keeps every row.
+func (spec *Spec[T]) After(column string, cursor string) *Spec[T] {
+	if cursor != "" {
+		spec.conditions = append(spec.conditions, Gt(column, cursor))
+	}
+	return spec
+}
+
+// Before keeps the rows whose column is less than the cursor, an empty cursor keeps every row.
+func (spec *Spec[T]) Before(column string, cursor string) *Spec[T] {
+	if cursor != "" {
+		spec.conditions = append(spec.conditions, Lt(column, cursor))
+	}
+	return spec
+}
+
+// Limit caps the number of rows, zero or less means no limit.
+func (spec *Spec[T]) Limit(limit int) *Spec[T] {
+	spec.limit = limit
+	return spec
+}
+
+// WithDeleted includes the soft deleted rows.
+func (spec *Spec[T]) WithDeleted() *Spec[T] {
+	spec.unscoped = true
+	return spec
+}
+
+// apply adds the specification to the query, resolving its columns from the schema of T.
+func (spec *Spec[T]) apply(db *gorm.DB) (*gorm.DB, error) {
+	statement := &gorm.Statement{DB: db}
+	if err := statement.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	columns := func(name string) (string, error) {
+		if field := statement.Schema.LookUpField(name); field != nil && field.DBName != "" {
+			return field.DBName, nil
+		}
+		return "", fmt.Errorf("%w: %s", ErrUnknownColumn, name)
+	}
+
+	query := db.Model(new(T))
+	if spec == nil {
+		return query, nil
+	}
+	if spec.unscoped {
+		query = query.Unscoped()
+	}
+	for _, condition := range spec.conditions {
+		clause, args, err := condition.build(columns)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(clause, args...)
+	}
+	for _, order := range spec.orders {
+		column, err := columns(order.column)
+		if err != nil {
+			return nil, err
+		}
+		direction := Asc
+		if order.direction == Desc {
+			direction = Desc
+		}
+		query = query.Order(column + " " + string(direction))
+	}
+	if spec.limit > 0 {
+		query = query.Limit(spec.limit)
+	}
+	return query, nil
+}
@@ -0,0 +1,103 @@
+package repository_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/phuslu/log"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"gorm.io/gorm"
+)
+
+func TestSpecification(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewRepository[entity.Users](&log.DefaultLogger, DB)
+
+	t.Run("FindAll", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "username", "email"}).
+			AddRow("2", "budi", "budi@gmail.com").
+			AddRow("3", "bagus", "bagus@gmail.com")
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE ((username LIKE $1 OR email IN ($2,$3))) AND ((created_at >= $4 AND created_at <= $5)) AND id > $6 AND "users"."deleted_at" = $7 ORDER BY created_at DESC,id ASC LIMIT $8`)).
+			WithArgs("b%", "budi@gmail.com", "bagus@gmail.com", int64(10), int64(20), "1", sqlmock.AnyArg(), 5).
+			WillReturnRows(rows)
+
+		spec := repository.Query[entity.Users]().
+			Where(repository.Or(repository.Like("username", "b%"), repository.In("Email", "budi@gmail.com", "bagus@gmail.com"))).
+			Where(repository.Between("created_at", int64(10), int64(20))).
+			After("id", "1").
+			Before("id", "").
+			OrderBy("CreatedAt", repository.Desc).
+			OrderBy("id", repository.Asc).
+			Limit(5)
+		users, err := repo.FindAll(ctx, spec)
+		require.NoError(t, err)
+		require.Len(t, users, 2)
+		require.Equal(t, "budi", users[0].Username)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("FindOne", func(t *testing.T) {
+		rows := sqlmock.NewRows([]string{"id", "username", "email"}).AddRow("1", "budi", "budi@gmail.com")
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE email = $1 LIMIT $2`)).
+			WithArgs("budi@gmail.com", 1).
+			WillReturnRows(rows)
+
+		var users entity.Users
+		err := repo.FindOne(ctx, &users, repository.Query[entity.Users]().WithDeleted().Where(repository.Eq("email", "budi@gmail.com")))
+		require.NoError(t, err)
+		require.Equal(t, "1", users.ID)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("FindOneNotFound", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE id = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		var users entity.Users
+		err := repo.FindOne(ctx, &users, repository.Query[entity.Users]().Where(repository.Eq("id", "1")))
+		require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users" WHERE username <> $1 AND "users"."deleted_at" = $2`)).
+			WithArgs("budi", sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		// The ordering and the limit do not apply to a count.
+		exist, err := repo.Exists(ctx, repository.Query[entity.Users]().Where(repository.NotEq("username", "budi")).OrderBy("id", repository.Asc).Limit(1))
+		require.NoError(t, err)
+		require.True(t, exist)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("EmptyInMatchesNothing", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users" WHERE 1 = 0 AND "users"."deleted_at" = $1`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		exist, err := repo.Exists(ctx, repository.Query[entity.Users]().Where(repository.In[string]("id")))
+		require.NoError(t, err)
+		require.False(t, exist)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("UnknownColumn", func(t *testing.T) {
+		// Columns outside the schema never reach the database, whatever they contain.
+		specs := []*repository.Spec[entity.Users]{
+			repository.Query[entity.Users]().Where(repository.Eq("id = id OR 1", 1)),
+			repository.Query[entity.Users]().Where(repository.Or(repository.Eq("id", "1"), repository.Like("secret", "%"))),
+			repository.Query[entity.Users]().OrderBy("random()", repository.Asc),
+		}
+		for _, spec := range specs {
+			_, err := repo.FindAll(ctx, spec)
+			require.ErrorIs(t, err, repository.ErrUnknownColumn)
+		}
+		_, err := repo.Exists(ctx, repository.Query[entity.Users]().Where(repository.Gt("deleted", 0)))
+		require.ErrorIs(t, err, repository.ErrUnknownColumn)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,126 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"gorm.io/gorm"
+)
+
+// openSQLite opens an in-memory SQLite database with the sqlite migrations applied.
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 10, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := migration.New(sqlDB, sqlconfig.SQLite, &log.DefaultLogger)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func TestSQLite(t *testing.T) {
+	db := openSQLite(t)
+	logger := &log.DefaultLogger
+	ctx := context.Background()
+	usersRepository, err := repository.NewUsersRepositoryImpl(db, logger)
+	require.NoError(t, err)
+	auditRepository, err := repository.NewAuditRepositoryImpl(db, logger)
+	require.NoError(t, err)
+	unitOfWork, err := repository.NewUnitOfWork(db, logger)
+	require.NoError(t, err)
+
+	t.Run("Users", func(t *testing.T) {
+		user := &entity.Users{ID: ksuid.New().String(), Username: "budi", Email: "budi@gmail.com", Password: "password"}
+		require.NoError(t, usersRepository.Create(ctx, user))
+
+		var saved entity.Users
+		require.NoError(t, usersRepository.GetByEmail(ctx, &saved, "budi@gmail.com"))
+		require.Equal(t, user.ID, saved.ID)
+		require.Equal(t, int64(1), saved.Version)
+
+		saved.Username = "budi santoso"
+		require.NoError(t, usersRepository.Update(ctx, &saved, saved.ID))
+		require.ErrorIs(t, usersRepository.Update(ctx, &entity.Users{Username: "stale", Version: 1}, saved.ID), repository.ErrVersionConflict)
+
+		require.NoError(t, usersRepository.Delete(ctx, saved.ID))
+		total, err := usersRepository.CountById(ctx, saved.ID)
+		require.NoError(t, err)
+		require.Zero(t, total)
+		require.NoError(t, usersRepository.Restore(ctx, saved.ID))
+		// the deletion and the restoration each bump the version, a version read before them no longer matches
+		var restored entity.Users
+		require.NoError(t, usersRepository.GetById(ctx, &restored, saved.ID))
+		require.Equal(t, saved.Version+2, restored.Version)
+		require.ErrorIs(t, usersRepository.Update(ctx, &entity.Users{Username: "stale", Version: saved.Version}, saved.ID), repository.ErrVersionConflict)
+		require.NoError(t, usersRepository.Purge(ctx, saved.ID))
+	})
+
+	t.Run("UnitOfWorkRollback", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		user := &entity.Users{ID: ksuid.New().String(), Username: "bagus", Email: "bagus@gmail.com", Password: "password"}
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := usersRepository.Create(ctx, user); err != nil {
+				return err
+			}
+			// the savepoint is rolled back alone, the enclosing transaction goes on
+			errNested := unitOfWork.Do(ctx, func(ctx context.Context) error {
+				return usersRepository.Create(ctx, &entity.Users{ID: ksuid.New().String(), Username: "bagus", Email: user.Email, Password: "password"})
+			})
+			require.Error(t, errNested)
+			total, err := usersRepository.CountById(ctx, user.ID)
+			require.NoError(t, err)
+			require.Equal(t, int64(1), total)
+			return errAbort
+		})
+		require.ErrorIs(t, err, errAbort)
+		exist, err := usersRepository.ExistByKeyValue(ctx, map[string]any{"email": user.Email})
+		require.NoError(t, err)
+		require.False(t, exist)
+	})
+
+	t.Run("AuditEventsAreAppendOnly", func(t *testing.T) {
+		event := &entity.AuditEvents{ID: ksuid.New().String(), Action: entity.AuditUserCreate, Outcome: entity.AuditSuccess, Changes: "{}"}
+		require.NoError(t, auditRepository.Create(ctx, event))
+		err := db.Model(&entity.AuditEvents{}).Where("id = ?", event.ID).Update("outcome", entity.AuditFailure).Error
+		require.ErrorContains(t, err, "audit_events is append-only")
+		err = db.Where("id = ?", event.ID).Delete(&entity.AuditEvents{}).Error
+		require.ErrorContains(t, err, "audit_events is append-only")
+	})
+
+	t.Run("CasbinAdapter", func(t *testing.T) {
+		adapter, err := gormadapter.NewAdapterByDB(db)
+		require.NoError(t, err)
+		enforcer, err := casbin.NewEnforcer(filepath.Join("..", "..", "resource", "model", "rbac_model.conf"), adapter)
+		require.NoError(t, err)
+
+		allowed, err := enforcer.Enforce("tirtanewwhakim22@gmail.com", "audit", "read")
+		require.NoError(t, err)
+		require.True(t, allowed)
+
+		_, err = enforcer.AddRoleForUser("budi@gmail.com", "moderator")
+		require.NoError(t, err)
+		require.NoError(t, enforcer.LoadPolicy())
+		allowed, err = enforcer.Enforce("budi@gmail.com", "users", "read")
+		require.NoError(t, err)
+		require.True(t, allowed)
+	})
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"gorm.io/gorm"
+)
+
+// UnitOfWork runs several repository calls in a single database transaction.
+type UnitOfWork interface {
+	// Do runs fn in a transaction carried by the context given to fn, committed when fn returns nil and rolled back otherwise.
+	// A Do within fn runs in a savepoint of the enclosing transaction, its failure only rolls back to the savepoint.
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// txKey is the context key of the transaction of the current unit of work.
+type txKey struct{}
+
+// WithTx returns a copy of ctx carrying the transaction, joined by every repository call made with it.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction of the unit of work ctx belongs to, if any.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// session returns the connection repository calls made with ctx go through,
+// the transaction of the current unit of work or db otherwise, whose reads go to a replica unless the request recently wrote.
+func session(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.WithContext(ctx)
+	}
+	return routeRead(ctx, db.WithContext(ctx))
+}
+
+// transaction runs fn in the transaction of the current unit of work, or in a transaction of its own outside of one.
+// Its own transaction is rolled back when fn fails or panics.
+// Transactions always run on the primary, the request reads from it for the read-your-writes window afterwards.
+func transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	writesFromContext(ctx).mark()
+	if tx, ok := TxFromContext(ctx); ok {
+		// The unit of work decides whether to commit.
+		return fn(tx.WithContext(ctx))
+	}
+	return db.WithContext(ctx).Transaction(fn)
+}
+
+// UnitOfWorkImpl implements UnitOfWork with GORM transactions.
+type UnitOfWorkImpl struct {
+	DB     *gorm.DB    // Database connection
+	Logger *log.Logger // Logger for logging messages
+}
+
+// NewUnitOfWork creates a new instance of UnitOfWorkImpl.
+func NewUnitOfWork(DB *gorm.DB, logger *log.Logger) (*UnitOfWorkImpl, error) {
+	// Check if DB or logger is nil
+	if DB == nil || logger == nil {
+		return nil, errors.New("DB or Logger is nil")
+	}
+	return &UnitOfWorkImpl{DB: DB, Logger: logger}, nil
+}
+
+// Do runs fn in a transaction, or in a savepoint when ctx already belongs to a unit of work.
+// A panic in fn rolls back and is raised again.
+func (uow UnitOfWorkImpl) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	logger := loggerconfig.FromContext(ctx, uow.Logger)
+	writesFromContext(ctx).mark()
+	// GORM opens a savepoint instead of a transaction when the connection already is one.
+	db, nested := TxFromContext(ctx)
+	if !nested {
+		db = uow.DB
+	}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+	if err != nil {
+		if nested {
+			logger.Error().Msgf("Unit of work rolled back to savepoint: %v", err)
+		} else {
+			logger.Error().Msgf("Unit of work rolled back: %v", err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	logger := &log.DefaultLogger
+	usersRepository, err := repository.NewUsersRepositoryImpl(DB, logger)
+	require.NoError(t, err)
+	auditRepository, err := repository.NewAuditRepositoryImpl(DB, logger)
+	require.NoError(t, err)
+	unitOfWork, err := repository.NewUnitOfWork(DB, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	newUser := func() *entity.Users {
+		return &entity.Users{ID: ksuid.New().String(), Username: "user", Email: "user@example.com", Password: "password"}
+	}
+	newEvent := func() *entity.AuditEvents {
+		return &entity.AuditEvents{ID: ksuid.New().String(), Action: entity.AuditUserCreate, Outcome: entity.AuditSuccess, Changes: "{}"}
+	}
+
+	t.Run("RepositoriesJoinTheTransaction", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE id = (.+)`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec(`INSERT INTO "audit_events" (.+) VALUES (.+)`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			_, inTx := repository.TxFromContext(ctx)
+			require.True(t, inTx)
+			user := newUser()
+			if err := usersRepository.Create(ctx, user); err != nil {
+				return err
+			}
+			if _, err := usersRepository.CountById(ctx, user.ID); err != nil {
+				return err
+			}
+			return auditRepository.Create(ctx, newEvent())
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("RollbackOnError", func(t *testing.T) {
+		errAudit := errors.New("audit_events is unavailable")
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`INSERT INTO "audit_events" (.+) VALUES (.+)`).WillReturnError(errAudit)
+		mock.ExpectRollback()
+
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := usersRepository.Create(ctx, newUser()); err != nil {
+				return err
+			}
+			return auditRepository.Create(ctx, newEvent())
+		})
+		require.ErrorIs(t, err, errAudit)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("NestedSavepoint", func(t *testing.T) {
+		errAudit := errors.New("audit_events is unavailable")
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec(`SAVEPOINT sp.+`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO "audit_events" (.+) VALUES (.+)`).WillReturnError(errAudit)
+		mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp.+`).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		err := unitOfWork.Do(ctx, func(ctx context.Context) error {
+			if err := usersRepository.Create(ctx, newUser()); err != nil {
+				return err
+			}
+			// Only the savepoint is rolled back, the user is still committed.
+			errNested := unitOfWork.Do(ctx, func(ctx context.Context) error {
+				return auditRepository.Create(ctx, newEvent())
+			})
+			require.ErrorIs(t, errNested, errAudit)
+			return nil
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("RollbackOnPanic", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectRollback()
+
+		require.Panics(t, func() {
+			_ = unitOfWork.Do(ctx, func(ctx context.Context) error {
+				if err := usersRepository.Create(ctx, newUser()); err != nil {
+					return err
+				}
+				panic("unexpected")
+			})
+		})
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("OwnTransactionOutsideUnitOfWork", func(t *testing.T) {
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO "users" (.+) VALUES (.+)`).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		require.NoError(t, usersRepository.Create(ctx, newUser()))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
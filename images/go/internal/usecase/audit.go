@@ -0,0 +1,106 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/phuslu/log"
+	"github.com/segmentio/ksuid"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+)
+
+// auditRecorder appends security-relevant actions to the audit trail.
+// Outside of a unit of work a failed write is logged and never fails the audited action,
+// within one recordInWork writes the event in its transaction and a failed write rolls the action back with it.
+type auditRecorder struct {
+	auditRepository repository.AuditRepository
+	timeoutConfig   *timeout.Config
+	logger          *log.Logger
+}
+
+// record appends an audit event for the target user, the actor, IP and user agent are taken from the request Meta in ctx.
+// A failed write is logged only, see recordInWork for the actions run in a unit of work.
+func (recorder auditRecorder) record(ctx context.Context, action string, outcome string, target *entity.Users, changes map[string]any) {
+	if err := recorder.write(ctx, action, outcome, target, changes); err != nil {
+		loggerconfig.FromContext(ctx, recorder.logger).Error().Msgf("Failed to record audit event %s: %v", action, err)
+	}
+}
+
+// recordInWork appends the audit event in the unit of work of ctx, returning a failed write so the unit of work is rolled back
+// and the action never commits without its event. Outside of a unit of work it behaves as record.
+func (recorder auditRecorder) recordInWork(ctx context.Context, action string, outcome string, target *entity.Users, changes map[string]any) error {
+	if _, inTx := repository.TxFromContext(ctx); !inTx {
+		recorder.record(ctx, action, outcome, target, changes)
+		return nil
+	}
+	return recorder.write(ctx, action, outcome, target, changes)
+}
+
+// write appends the audit event, in the transaction of the unit of work ctx belongs to if any.
+func (recorder auditRecorder) write(ctx context.Context, action string, outcome string, target *entity.Users, changes map[string]any) error {
+	logger := loggerconfig.FromContext(ctx, recorder.logger)
+	if recorder.auditRepository == nil {
+		return nil
+	}
+	meta := request.MetaFromContext(ctx)
+	event := &entity.AuditEvents{
+		ID:        ksuid.New().String(),
+		Action:    action,
+		Outcome:   outcome,
+		ActorID:   meta.ActorID,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+		Changes:   "{}",
+	}
+	if target != nil {
+		event.TargetID = target.ID
+	}
+	if len(changes) > 0 {
+		if encoded, err := json.Marshal(changes); err == nil {
+			event.Changes = string(encoded)
+		} else {
+			logger.Error().Msgf("Failed to encode audit changes of %s: %v", action, err)
+		}
+	}
+
+	ctxDB, cancel := recorder.timeoutConfig.CreateDatabaseTimeout(ctx)
+	defer cancel()
+	return recorder.auditRepository.Create(ctxDB, event)
+}
+
+// auditChanges returns the changed user fields as {"field": {"from": old, "to": new}}.
+// Password hashes and emails are never written to the audit trail, which outlives a purge, only the fact that they changed.
+func auditChanges(before *entity.Users, after *entity.Users) map[string]any {
+	changes := map[string]any{}
+	if before == nil {
+		before = &entity.Users{}
+	}
+	if after == nil {
+		after = &entity.Users{}
+	}
+	if before.Username != after.Username {
+		changes["username"] = map[string]any{"from": before.Username, "to": after.Username}
+	}
+	if before.Email != after.Email {
+		changes["email"] = map[string]any{"changed": true}
+	}
+	if before.Password != after.Password {
+		changes["password"] = map[string]any{"changed": true}
+	}
+	if before.DeletedAt != after.DeletedAt {
+		changes["deleted_at"] = map[string]any{"from": before.DeletedAt, "to": after.DeletedAt}
+	}
+	return changes
+}
+
+// withActor returns a copy of ctx whose request Meta names the user as the actor,
+// used when the user authenticates within the request itself.
+func withActor(ctx context.Context, users *entity.Users) context.Context {
+	meta := *request.MetaFromContext(ctx)
+	meta.ActorID = users.ID
+	return request.WithMeta(ctx, &meta)
+}
@@ -2,7 +2,9 @@ package usecase
 
 import (
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/phuslu/log"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/export"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
 	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
@@ -21,6 +23,10 @@ type AuthUsecaseBuilder struct {
 	secretKey       *tokenconfig.SecretKey
 	logger          *log.Logger
 	enforcer        *casbin.Enforcer
+	watcher         persist.Watcher
+	auditRepository repository.AuditRepository
+	cacheRepository repository.CacheRepository[*entity.Users]
+	unitOfWork      repository.UnitOfWork
 }
 
 // NewAuthUsecaseBuilder creates a new instance of AuthUsecaseBuilder.
@@ -76,6 +82,30 @@ func (b *AuthUsecaseBuilder) WithEnforcer(enforcer *casbin.Enforcer) *AuthUsecas
 	return b
 }
 
+// WithPolicyWatcher sets the watcher announcing the policy changes committed by a unit of work to the other processes.
+func (b *AuthUsecaseBuilder) WithPolicyWatcher(watcher persist.Watcher) *AuthUsecaseBuilder {
+	b.watcher = watcher
+	return b
+}
+
+// WithAuditRepository sets the AuditRepository.
+func (b *AuthUsecaseBuilder) WithAuditRepository(repo repository.AuditRepository) *AuthUsecaseBuilder {
+	b.auditRepository = repo
+	return b
+}
+
+// WithCacheRepository sets the CacheRepository invalidated when a password reset changes a user.
+func (b *AuthUsecaseBuilder) WithCacheRepository(cacheRepo repository.CacheRepository[*entity.Users]) *AuthUsecaseBuilder {
+	b.cacheRepository = cacheRepo
+	return b
+}
+
+// WithUnitOfWork sets the UnitOfWork making the role changes and their audit events atomic.
+func (b *AuthUsecaseBuilder) WithUnitOfWork(unitOfWork repository.UnitOfWork) *AuthUsecaseBuilder {
+	b.unitOfWork = unitOfWork
+	return b
+}
+
 // Build creates the AuthUsecase instance.
 func (b *AuthUsecaseBuilder) Build() *AuthUsecase {
 	return &AuthUsecase{
@@ -86,6 +116,11 @@ func (b *AuthUsecaseBuilder) Build() *AuthUsecase {
 		token:           b.token,
 		secretKey:       b.secretKey,
 		logger:          b.logger,
+		enforcer:        b.enforcer,
+		watcher:         b.watcher,
+		cacheRepository: b.cacheRepository,
+		unitOfWork:      b.unitOfWork,
+		audit:           auditRecorder{auditRepository: b.auditRepository, timeoutConfig: b.timeoutConfig, logger: b.logger},
 	}
 }
 
@@ -97,6 +132,12 @@ type UsersUsecaseBuilder struct {
 	validator       *validation.Validator
 	hashing         *hash.Argon2
 	logger          *log.Logger
+	enforcer        *casbin.Enforcer
+	watcher         persist.Watcher
+	exportRepo      repository.ExportRepository
+	exportConfig    *export.Config
+	auditRepository repository.AuditRepository
+	unitOfWork      repository.UnitOfWork
 }
 
 // NewUsersUsecaseBuilder creates a new instance of UsersUsecaseBuilder.
@@ -140,6 +181,42 @@ func (b *UsersUsecaseBuilder) WithLogger(logger *log.Logger) *UsersUsecaseBuilde
 	return b
 }
 
+// WithEnforcer sets the enforcer
+func (b *UsersUsecaseBuilder) WithEnforcer(enforcer *casbin.Enforcer) *UsersUsecaseBuilder {
+	b.enforcer = enforcer
+	return b
+}
+
+// WithPolicyWatcher sets the watcher announcing the policy changes committed by a unit of work to the other processes.
+func (b *UsersUsecaseBuilder) WithPolicyWatcher(watcher persist.Watcher) *UsersUsecaseBuilder {
+	b.watcher = watcher
+	return b
+}
+
+// WithExportRepository sets the ExportRepository.
+func (b *UsersUsecaseBuilder) WithExportRepository(exportRepo repository.ExportRepository) *UsersUsecaseBuilder {
+	b.exportRepo = exportRepo
+	return b
+}
+
+// WithExportConfig sets the export Config.
+func (b *UsersUsecaseBuilder) WithExportConfig(exportConfig *export.Config) *UsersUsecaseBuilder {
+	b.exportConfig = exportConfig
+	return b
+}
+
+// WithAuditRepository sets the AuditRepository.
+func (b *UsersUsecaseBuilder) WithAuditRepository(repo repository.AuditRepository) *UsersUsecaseBuilder {
+	b.auditRepository = repo
+	return b
+}
+
+// WithUnitOfWork sets the UnitOfWork making the user changes, their roles and their audit events atomic.
+func (b *UsersUsecaseBuilder) WithUnitOfWork(unitOfWork repository.UnitOfWork) *UsersUsecaseBuilder {
+	b.unitOfWork = unitOfWork
+	return b
+}
+
 // Build creates the UsersUsecase instance.
 func (b *UsersUsecaseBuilder) Build() *UsersUsecase {
 	return &UsersUsecase{
@@ -149,5 +226,58 @@ func (b *UsersUsecaseBuilder) Build() *UsersUsecase {
 		validator:       b.validator,
 		hashing:         b.hashing,
 		logger:          b.logger,
+		enforcer:        b.enforcer,
+		watcher:         b.watcher,
+		exportRepo:      b.exportRepo,
+		exportConfig:    b.exportConfig,
+		unitOfWork:      b.unitOfWork,
+		audit:           auditRecorder{auditRepository: b.auditRepository, timeoutConfig: b.timeoutConfig, logger: b.logger},
+	}
+}
+
+// AuditUsecaseBuilder is the builder for AuditUsecase.
+type AuditUsecaseBuilder struct {
+	auditRepository repository.AuditRepository
+	timeoutConfig   *timeout.Config
+	validator       *validation.Validator
+	logger          *log.Logger
+}
+
+// NewAuditUsecaseBuilder creates a new instance of AuditUsecaseBuilder.
+func NewAuditUsecaseBuilder() *AuditUsecaseBuilder {
+	return &AuditUsecaseBuilder{}
+}
+
+// WithAuditRepository sets the AuditRepository.
+func (b *AuditUsecaseBuilder) WithAuditRepository(repo repository.AuditRepository) *AuditUsecaseBuilder {
+	b.auditRepository = repo
+	return b
+}
+
+// WithTimeoutConfig sets the TimeoutConfig.
+func (b *AuditUsecaseBuilder) WithTimeoutConfig(timeout *timeout.Config) *AuditUsecaseBuilder {
+	b.timeoutConfig = timeout
+	return b
+}
+
+// WithValidator sets the Validator.
+func (b *AuditUsecaseBuilder) WithValidator(validator *validation.Validator) *AuditUsecaseBuilder {
+	b.validator = validator
+	return b
+}
+
+// WithLogger sets the Logger.
+func (b *AuditUsecaseBuilder) WithLogger(logger *log.Logger) *AuditUsecaseBuilder {
+	b.logger = logger
+	return b
+}
+
+// Build creates the AuditUsecase instance.
+func (b *AuditUsecaseBuilder) Build() *AuditUsecase {
+	return &AuditUsecase{
+		auditRepository: b.auditRepository,
+		timeoutConfig:   b.timeoutConfig,
+		validator:       b.validator,
+		logger:          b.logger,
 	}
 }
@@ -0,0 +1,67 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+)
+
+// applyUserPatch applies the JSON Patch operations of the edit request to the stored user,
+// recording every member it touches in the fields of the edit request so only those are validated and saved.
+func applyUserPatch(users *entity.Users, edit *request.UserEdit) *response.StandardErrors {
+	// The document the operations are evaluated against, the password is write-only and never readable.
+	username, email := users.Username, users.Email
+	document := map[string]*string{"/username": &username, "/email": &email}
+	targets := map[string]**string{"/username": &edit.Username, "/email": &edit.Email, "/password": &edit.Password}
+
+	for index, operation := range edit.Operations {
+		target, ok := targets[operation.Path]
+		if !ok {
+			return patchError(errorshandler.UNPROCESS_ENITITY, index, fmt.Sprintf("path '%s' does not exist", operation.Path))
+		}
+
+		switch operation.Op {
+		case "add", "replace":
+			value := ""
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return patchError(errorshandler.UNPROCESS_ENITITY, index, fmt.Sprintf("value of '%s' must be a string", operation.Path))
+			}
+			*target = &value
+		case "copy":
+			source, readable := document[operation.From]
+			if !readable {
+				return patchError(errorshandler.UNPROCESS_ENITITY, index, fmt.Sprintf("path '%s' can not be copied", operation.From))
+			}
+			value := *source
+			*target = &value
+		case "test":
+			current, readable := document[operation.Path]
+			if !readable {
+				return patchError(errorshandler.UNPROCESS_ENITITY, index, fmt.Sprintf("path '%s' can not be tested", operation.Path))
+			}
+			expected := ""
+			if err := json.Unmarshal(operation.Value, &expected); err != nil || expected != *current {
+				return patchError(errorshandler.CONFLICT, index, fmt.Sprintf("test of '%s' failed", operation.Path))
+			}
+			continue
+		default:
+			// remove and move would leave a required member without a value
+			return patchError(errorshandler.UNPROCESS_ENITITY, index, fmt.Sprintf("path '%s' can not be removed", operation.Path))
+		}
+
+		// Later operations observe the value written by earlier ones.
+		if current, readable := document[operation.Path]; readable {
+			*current = **target
+		}
+	}
+	return nil
+}
+
+// patchError reports which operation of the patch document could not be applied.
+func patchError(code errorshandler.TypeErr, index int, detail string) *response.StandardErrors {
+	return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(code, fmt.Sprintf("Operation %d of the patch could not be applied : %s", index, detail))}}
+}
@@ -0,0 +1,210 @@
+package usecase
+
+import (
+	"context"
+	"slices"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/constant"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"gorm.io/gorm"
+)
+
+// policyChangesKey is the context key of the policy changes saved by a unit of work.
+type policyChangesKey struct{}
+
+// policyEditor changes the policy, implemented by the enforcer saving the changes through its adapter
+// and by policyTx saving them in the transaction of a unit of work.
+type policyEditor interface {
+	AddRoleForUser(user string, role string, domain ...string) (bool, error)
+	DeleteRoleForUser(user string, role string, domain ...string) (bool, error)
+	DeleteUser(user string) (bool, error)
+}
+
+// inUnitOfWork runs fn in the unit of work, or directly when the usecase has none.
+// The policy changes of the unit of work are applied to the enforcer rule by rule once committed,
+// then announced through the watcher so the other processes reload theirs,
+// the enforcer is left untouched when the unit of work is rolled back.
+func inUnitOfWork(ctx context.Context, unitOfWork repository.UnitOfWork, enforcer *casbin.Enforcer, watcher persist.Watcher, logger *log.Logger, fn func(ctx context.Context) error) error {
+	if unitOfWork == nil {
+		return fn(ctx)
+	}
+	if _, nested := repository.TxFromContext(ctx); nested {
+		// The enclosing unit of work applies the policy changes once committed.
+		return unitOfWork.Do(ctx, fn)
+	}
+	changes := &policyChanges{}
+	err := unitOfWork.Do(context.WithValue(ctx, policyChangesKey{}, changes), fn)
+	if err == nil && len(changes.changes) > 0 && enforcer != nil {
+		if errApply := changes.apply(enforcer); errApply != nil {
+			loggerconfig.FromContext(ctx, logger).Error().Msgf("Failed to apply the policy changes after commit, reloading the policy: %v", errApply)
+			if errLoad := enforcer.LoadPolicy(); errLoad != nil {
+				loggerconfig.FromContext(ctx, logger).Error().Msgf("Failed to reload policy after commit: %v", errLoad)
+			}
+		}
+		if watcher != nil {
+			if errUpdate := watcher.Update(); errUpdate != nil {
+				loggerconfig.FromContext(ctx, logger).Error().Msgf("Failed to announce the policy change: %v", errUpdate)
+			}
+		}
+	}
+	return err
+}
+
+// withPolicyTx runs fn with a policyEditor saving its changes in the transaction of ctx,
+// so the shared enforcer only sees the committed changes.
+// Outside of a unit of work fn gets enforcer itself, which saves the changes through its adapter as usual.
+func withPolicyTx(ctx context.Context, enforcer *casbin.Enforcer, fn func(policy policyEditor) error) error {
+	tx, ok := repository.TxFromContext(ctx)
+	if !ok {
+		return fn(enforcer)
+	}
+	changes, tracked := ctx.Value(policyChangesKey{}).(*policyChanges)
+	if !tracked {
+		// A transaction not started by inUnitOfWork, the enforcer picks the changes up with its next reload.
+		changes = &policyChanges{}
+	}
+	// The casbin_rule table already exists, the adapter bound to the transaction must not migrate it.
+	session := tx.Session(&gorm.Session{NewDB: true, Context: ctx})
+	gormadapter.TurnOffAutoMigrate(session)
+	adapter, err := gormadapter.NewAdapterByDB(session)
+	if err != nil {
+		return err
+	}
+	return fn(&policyTx{enforcer: enforcer, adapter: adapter, changes: changes})
+}
+
+// policyChange is a rule added to or removed from the policy by a unit of work.
+type policyChange struct {
+	sec   string
+	ptype string
+	rule  []string
+	add   bool
+}
+
+// policyChanges holds the policy changes saved by a unit of work, in the order they were saved.
+type policyChanges struct {
+	changes []policyChange
+}
+
+// has tells whether the policy holds the rule, with the changes saved so far on top of the enforcer.
+func (changes *policyChanges) has(enforcer *casbin.Enforcer, sec string, ptype string, rule []string) (bool, error) {
+	for i := len(changes.changes) - 1; i >= 0; i-- {
+		change := changes.changes[i]
+		if change.sec == sec && change.ptype == ptype && slices.Equal(change.rule, rule) {
+			return change.add, nil
+		}
+	}
+	return enforcer.GetModel().HasPolicy(sec, ptype, rule)
+}
+
+// apply applies the committed changes to the policy of the enforcer, without saving them through its adapter again,
+// and updates the role links of the grouping rules.
+func (changes *policyChanges) apply(enforcer *casbin.Enforcer) error {
+	policy := enforcer.GetModel()
+	for _, change := range changes.changes {
+		var op model.PolicyOp
+		if change.add {
+			// The enforcer may have reloaded the committed rule already.
+			exist, err := policy.HasPolicy(change.sec, change.ptype, change.rule)
+			if err != nil {
+				return err
+			}
+			if exist {
+				continue
+			}
+			if err := policy.AddPolicy(change.sec, change.ptype, change.rule); err != nil {
+				return err
+			}
+			op = model.PolicyAdd
+		} else {
+			removed, err := policy.RemovePolicy(change.sec, change.ptype, change.rule)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				continue
+			}
+			op = model.PolicyRemove
+		}
+		if change.sec == "g" {
+			if err := enforcer.BuildIncrementalRoleLinks(op, change.ptype, [][]string{change.rule}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// policyTx saves the policy changes in the transaction of a unit of work through an adapter bound to it.
+// The rules are read from the shared enforcer with the changes saved by the unit of work on top.
+type policyTx struct {
+	enforcer *casbin.Enforcer
+	adapter  persist.Adapter
+	changes  *policyChanges
+}
+
+// AddRoleForUser gives the role to the user, and tells whether the user did not have it.
+func (policyTx *policyTx) AddRoleForUser(user string, role string, domain ...string) (bool, error) {
+	return policyTx.save("g", "g", append([]string{user, role}, domain...), true)
+}
+
+// DeleteRoleForUser takes the role from the user, and tells whether the user had it.
+func (policyTx *policyTx) DeleteRoleForUser(user string, role string, domain ...string) (bool, error) {
+	return policyTx.save("g", "g", append([]string{user, role}, domain...), false)
+}
+
+// DeleteUser removes the grouping and policy rules of the user, and tells whether it had any.
+func (policyTx *policyTx) DeleteUser(user string) (bool, error) {
+	subIndex, err := policyTx.enforcer.GetFieldIndex("p", constant.SubjectIndex)
+	if err != nil {
+		return false, err
+	}
+	deleted := false
+	for _, filter := range []struct {
+		sec        string
+		fieldIndex int
+	}{{sec: "g", fieldIndex: 0}, {sec: "p", fieldIndex: subIndex}} {
+		sec, fieldIndex := filter.sec, filter.fieldIndex
+		rules, err := policyTx.enforcer.GetModel().GetFilteredPolicy(sec, sec, fieldIndex, user)
+		if err != nil {
+			return deleted, err
+		}
+		for _, change := range policyTx.changes.changes {
+			if change.add && change.sec == sec && len(change.rule) > fieldIndex && change.rule[fieldIndex] == user {
+				rules = append(rules, change.rule)
+			}
+		}
+		for _, rule := range rules {
+			removed, err := policyTx.save(sec, sec, rule, false)
+			if err != nil {
+				return deleted, err
+			}
+			deleted = deleted || removed
+		}
+	}
+	return deleted, nil
+}
+
+// save adds or removes the rule in the transaction when it changes the policy, and tells whether it did.
+func (policyTx *policyTx) save(sec string, ptype string, rule []string, add bool) (bool, error) {
+	exist, err := policyTx.changes.has(policyTx.enforcer, sec, ptype, rule)
+	if err != nil || exist == add {
+		return false, err
+	}
+	if add {
+		err = policyTx.adapter.AddPolicy(sec, ptype, rule)
+	} else {
+		err = policyTx.adapter.RemovePolicy(sec, ptype, rule)
+	}
+	if err != nil {
+		return false, err
+	}
+	policyTx.changes.changes = append(policyTx.changes.changes, policyChange{sec: sec, ptype: ptype, rule: rule, add: add})
+	return true, nil
+}
@@ -1,7 +1,10 @@
 package usecase_test
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
@@ -11,6 +14,10 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/glebarez/sqlite"
 	"github.com/go-playground/locales/en"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
@@ -22,25 +29,55 @@ import (
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/export"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/migration"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/orm"
+	sqlconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/sql"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
 	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
 	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
 	"github.com/tirtahakimpambudhi/restful_api/internal/usecase"
 	"github.com/tirtahakimpambudhi/restful_api/internal/validation"
+	"gorm.io/gorm"
 )
 
 var (
-	usersusecase  *usecase.UsersUsecase
-	authusecase   *usecase.AuthUsecase
-	usersRepoMock *repository.UsersRepositoryMock
-	cacheRepoMock *repository.MockCacheRepository[*entity.Users]
-	jwtToken      *token.JWTToken
-	secretKey     *token.SecretKey
-	argon2id      *hash.Argon2
+	usersusecase   *usecase.UsersUsecase
+	authusecase    *usecase.AuthUsecase
+	usersRepoMock  *repository.UsersRepositoryMock
+	cacheRepoMock  *repository.MockCacheRepository[*entity.Users]
+	exportRepoMock *repository.ExportRepositoryMock
+	auditRepoMock  *repository.AuditRepositoryMock
+	auditusecase   *usecase.AuditUsecase
+	auditedUsers   *usecase.UsersUsecase
+	auditedAuth    *usecase.AuthUsecase
+	exportConfig   *export.Config
+	jwtToken       *token.JWTToken
+	secretKey      *token.SecretKey
+	argon2id       *hash.Argon2
+	enforcer       *casbin.Enforcer
 )
 
+// rbacModel mirrors resource/model/rbac_model.conf for an in-memory enforcer.
+const rbacModel = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+`
+
 func SetEnv() func() {
 	os.Setenv("DB_TIMEOUT", "4")
 	os.Setenv("CACHE_TIMEOUT", "4")
@@ -69,12 +106,20 @@ func TestMain(m *testing.M) {
 	translator, _ := universalTranslate.GetTranslator("en")
 	usersRepoMock = new(repository.UsersRepositoryMock)
 	cacheRepoMock = new(repository.MockCacheRepository[*entity.Users])
+	exportRepoMock = new(repository.ExportRepositoryMock)
+	auditRepoMock = new(repository.AuditRepositoryMock)
+	exportConfig = &export.Config{SyncLimit: 3, Expiration: 60}
 	validator := validation.NewValidator(validate, translator)
 	timeoutConfig, _ := timeout.NewConfig()
 	argon2id, _ = hash.NewHashArgon2()
 	jwtToken, secretKey, _ = token.NewJWTToken()
-	usersusecase = usecase.NewUsersUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepoMock).WithCacheRepository(cacheRepoMock).WithHashing(argon2id).WithTimeoutConfig(timeoutConfig).WithValidator(validator).Build()
+	rbac, _ := model.NewModelFromString(rbacModel)
+	enforcer, _ = casbin.NewEnforcer(rbac)
+	usersusecase = usecase.NewUsersUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepoMock).WithCacheRepository(cacheRepoMock).WithHashing(argon2id).WithTimeoutConfig(timeoutConfig).WithValidator(validator).WithEnforcer(enforcer).WithExportRepository(exportRepoMock).WithExportConfig(exportConfig).Build()
 	authusecase = usecase.NewAuthUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepoMock).WithToken(jwtToken).WithSecretKey(secretKey).WithHashing(argon2id).WithTimeoutConfig(timeoutConfig).WithValidator(validator).Build()
+	auditusecase = usecase.NewAuditUsecaseBuilder().WithLogger(&log.DefaultLogger).WithAuditRepository(auditRepoMock).WithTimeoutConfig(timeoutConfig).WithValidator(validator).Build()
+	auditedUsers = usecase.NewUsersUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepoMock).WithCacheRepository(cacheRepoMock).WithHashing(argon2id).WithTimeoutConfig(timeoutConfig).WithValidator(validator).WithEnforcer(enforcer).WithAuditRepository(auditRepoMock).WithExportRepository(exportRepoMock).WithExportConfig(exportConfig).Build()
+	auditedAuth = usecase.NewAuthUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepoMock).WithToken(jwtToken).WithSecretKey(secretKey).WithHashing(argon2id).WithTimeoutConfig(timeoutConfig).WithValidator(validator).WithEnforcer(enforcer).WithAuditRepository(auditRepoMock).Build()
 	m.Run()
 }
 
@@ -119,7 +164,7 @@ func TestUsersUsecase_List_WhenCacheMiss(t *testing.T) {
 	// Define the behavior of the mocked methods
 	cacheRepoMock.On("GetFromCache", mock.Anything, expectedKey).Return(nil, nil).Once()
 	usersRepoMock.On("GetAll", mock.Anything, req).Return(expectedUsers, nil).Once()
-	cacheRepoMock.On("SetToCache", mock.Anything, expectedKey, expectedUsers).Return(nil).Once()
+	cacheRepoMock.On("SetToCache", mock.Anything, expectedKey, expectedUsers, []string{"users:tag:all", "users:tag:id:1"}).Return(nil).Once()
 	usersRepoMock.On("Count", mock.Anything).Return(int64(1), nil).Once()
 
 	// Call the List method
@@ -172,7 +217,7 @@ func TestUsersUsecase_List_WhenCacheMiss_AndSetCacheErr(t *testing.T) {
 	// Define the behavior of the mocked methods
 	cacheRepoMock.On("GetFromCache", mock.Anything, expectedKey).Return(nil, nil).Once()
 	usersRepoMock.On("GetAll", mock.Anything, req).Return(expectedUsers, nil).Once()
-	cacheRepoMock.On("SetToCache", mock.Anything, expectedKey, expectedUsers).Return(context.DeadlineExceeded).Once()
+	cacheRepoMock.On("SetToCache", mock.Anything, expectedKey, expectedUsers, []string{"users:tag:all", "users:tag:id:1"}).Return(context.DeadlineExceeded).Once()
 	usersRepoMock.On("Count", mock.Anything).Return(int64(1), nil).Once()
 
 	// Call the List method
@@ -203,7 +248,7 @@ func TestUsersUsecase_List_WhenCacheMiss_AndCountTimeout(t *testing.T) {
 	// Define the behavior of the mocked methods
 	cacheRepoMock.On("GetFromCache", mock.Anything, expectedKey).Return(nil, nil).Once()
 	usersRepoMock.On("GetAll", mock.Anything, req).Return(expectedUsers, nil).Once()
-	cacheRepoMock.On("SetToCache", mock.Anything, expectedKey, expectedUsers).Return(nil).Once()
+	cacheRepoMock.On("SetToCache", mock.Anything, expectedKey, expectedUsers, []string{"users:tag:all", "users:tag:id:1"}).Return(nil).Once()
 	usersRepoMock.On("Count", mock.Anything).Return(int64(0), context.DeadlineExceeded).Once()
 
 	// Call the List method
@@ -259,7 +304,7 @@ func TestUsersUsecase_Create(t *testing.T) {
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("ExistByKeyValue", mock.Anything, map[string]any{"email": req.Email}).Return(false, nil).Once()
 	usersRepoMock.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:all"}).Return(nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
 
 	// Call the Create method
@@ -343,7 +388,8 @@ func TestUsersUsecase_Create_WhenDeleteCacheErr(t *testing.T) {
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("ExistByKeyValue", mock.Anything, map[string]any{"email": req.Email}).Return(false, nil).Once()
 	usersRepoMock.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(errors.New("internal server")).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:all"}).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Create(context.Background(), req)
@@ -365,7 +411,7 @@ func TestUsersUsecase_Create_WhenGetByIdErr(t *testing.T) {
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("ExistByKeyValue", mock.Anything, map[string]any{"email": req.Email}).Return(false, nil).Once()
 	usersRepoMock.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:all"}).Return(nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -389,11 +435,13 @@ func TestUsersUsecase_Update(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 
 	// Call the Create method
@@ -407,13 +455,14 @@ func TestUsersUsecase_Update(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
 func TestUsersUsecase_Update_WhenReqErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.User{Username: "john doe", Email: "john@example.com"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 
 	// Call the Create method
@@ -433,7 +482,7 @@ func TestUsersUsecase_Update_WhenInvalidID(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := "1"
-	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 
 	// Call the Create method
@@ -452,7 +501,7 @@ func TestUsersUsecase_Update_WhenNotExist(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
 
@@ -472,9 +521,10 @@ func TestUsersUsecase_Update_WhenUpdateErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -494,11 +544,13 @@ func TestUsersUsecase_Update_WhenDeleteCacheErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Twice()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(context.DeadlineExceeded).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(context.DeadlineExceeded).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Update(context.Background(), req, id)
@@ -511,17 +563,20 @@ func TestUsersUsecase_Update_WhenDeleteCacheErr(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
 func TestUsersUsecase_Update_WhenGetErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -535,6 +590,72 @@ func TestUsersUsecase_Update_WhenGetErr(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Update_WhenIfMatchMissing(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
+
+	// Call the Update method
+	resp, err := usersusecase.Update(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusPreconditionRequired, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Update_WhenIfMatchStale(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: `"1", W/"2"`}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Version: 2}
+	}).Return(nil).Once()
+
+	// Call the Update method
+	resp, err := usersusecase.Update(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusPreconditionFailed, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Update_WhenVersionConflict(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: `"2"`}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Version: 2}
+	}).Return(nil).Once()
+	usersRepoMock.On("Update", mock.Anything, mock.MatchedBy(func(users *entity.Users) bool { return users.Version == 2 }), id).Return(repository.ErrVersionConflict).Once()
+
+	// Call the Update method, another request updated the user after it was retrieved
+	resp, err := usersusecase.Update(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusPreconditionFailed, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
 }
 
 // =============================================== END UPDATE CASES ================================================================
@@ -546,8 +667,10 @@ func TestUsersUsecase_Get(t *testing.T) {
 	id := ksuid.New().String()
 	expectedUsers := new(entity.Users)
 	// Define the behavior of the mocked methods
+	cacheRepoMock.On("GetOneFromCache", mock.Anything, "users:id:"+id).Return(nil, false, nil).Once()
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, expectedUsers, id).Return(nil).Once()
+	cacheRepoMock.On("SetOneToCache", mock.Anything, "users:id:"+id, expectedUsers, []string{"users:tag:id:" + id}).Return(nil).Once()
 
 	// Call the Get method
 	resp, err := usersusecase.Get(context.Background(), id)
@@ -584,6 +707,7 @@ func TestUsersUsecase_Get_WhenNotExist(t *testing.T) {
 	// Initialize id and users to find users
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
+	cacheRepoMock.On("GetOneFromCache", mock.Anything, "users:id:"+id).Return(nil, false, nil).Once()
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
 
 	// Call the Get method
@@ -602,6 +726,7 @@ func TestUsersUsecase_Get_WhenGetErr(t *testing.T) {
 	id := ksuid.New().String()
 	expectedUsers := new(entity.Users)
 	// Define the behavior of the mocked methods
+	cacheRepoMock.On("GetOneFromCache", mock.Anything, "users:id:"+id).Return(nil, false, nil).Once()
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, expectedUsers, id).Return(context.DeadlineExceeded).Once()
 
@@ -616,19 +741,59 @@ func TestUsersUsecase_Get_WhenGetErr(t *testing.T) {
 	usersRepoMock.AssertExpectations(t)
 }
 
+func TestUsersUsecase_Get_WhenCache(t *testing.T) {
+	// Initialize id and users to find users
+	id := ksuid.New().String()
+	cachedUsers := &entity.Users{ID: id, Username: "john doe", Email: "john@example.com", Version: 7}
+	// Define the behavior of the mocked methods, the database is not queried on a cache hit
+	cacheRepoMock.On("GetOneFromCache", mock.Anything, "users:id:"+id).Return(cachedUsers, true, nil).Once()
+
+	// Call the Get method
+	resp, err := usersusecase.Get(context.Background(), id)
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, mapper.EntityUserToResponse(cachedUsers), resp.Data)
+	require.Equal(t, `"7"`, resp.ETag)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+}
+func TestUsersUsecase_Get_WhenCacheTimeout(t *testing.T) {
+	// Initialize id and users to find users
+	id := ksuid.New().String()
+	// Define the behavior of the mocked methods
+	cacheRepoMock.On("GetOneFromCache", mock.Anything, "users:id:"+id).Return(nil, false, context.DeadlineExceeded).Once()
+
+	// Call the Get method
+	resp, err := usersusecase.Get(context.Background(), id)
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusRequestTimeout, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	cacheRepoMock.AssertExpectations(t)
+}
+
 // ================================================ END GET CASES ===================================================================
 
 // ================================================ EDIT CASES =====================================================================
 
+func stringPointer(value string) *string {
+	return &value
+}
+
 func TestUsersUsecase_Edit(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.UserEdit{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 
 	// Call the Create method
@@ -642,13 +807,14 @@ func TestUsersUsecase_Edit(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
 func TestUsersUsecase_Edit_WhenInvalidID(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := "1"
-	req := &request.UserEdit{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: "*"}
 	// Define the behavior of the mocked methods
 
 	// Call the Create method
@@ -667,7 +833,7 @@ func TestUsersUsecase_Edit_WhenNotExist(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.UserEdit{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
 
@@ -687,9 +853,10 @@ func TestUsersUsecase_Edit_WhenUpdateErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.UserEdit{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -709,11 +876,13 @@ func TestUsersUsecase_Edit_WhenDeleteCacheErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.UserEdit{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Twice()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(context.DeadlineExceeded).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(context.DeadlineExceeded).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Edit(context.Background(), req, id)
@@ -726,17 +895,20 @@ func TestUsersUsecase_Edit_WhenDeleteCacheErr(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
 func TestUsersUsecase_Edit_WhenGetErr(t *testing.T) {
 
 	// Prepare the request and expected response
 	id := ksuid.New().String()
-	req := &request.UserEdit{Username: "john doe", Email: "john@example.com", Password: "password123"}
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: "*"}
 	// Define the behavior of the mocked methods
 	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
 	usersRepoMock.On("Update", mock.Anything, mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
 	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -750,6 +922,146 @@ func TestUsersUsecase_Edit_WhenGetErr(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Edit_WhenIfMatchStale(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	req := &request.UserEdit{Username: stringPointer("john doe"), Email: stringPointer("john@example.com"), Password: stringPointer("password123"), IfMatch: `"1"`}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Version: 5}
+	}).Return(nil).Once()
+
+	// Call the Edit method
+	resp, err := usersusecase.Edit(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusPreconditionFailed, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Edit_MergePatch(t *testing.T) {
+
+	// Prepare the request, only the email is present in the merge patch
+	id := ksuid.New().String()
+	req := &request.UserEdit{Email: stringPointer("new@example.com"), IfMatch: `"1"`}
+	stored := entity.Users{ID: id, Username: "john doe", Email: "old@example.com", Password: "stored hash", Version: 1}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = stored
+	}).Return(nil).Once()
+	usersRepoMock.On("Update", mock.Anything, mock.MatchedBy(func(users *entity.Users) bool {
+		return users.Username == "john doe" && users.Email == "new@example.com" && users.Password == "stored hash" && users.Version == 1
+	}), id).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
+
+	// Call the Edit method
+	resp, err := usersusecase.Edit(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Edit_WhenMergePatchEmptyValue(t *testing.T) {
+
+	// Prepare the request, an explicit empty username is validated unlike an absent one
+	id := ksuid.New().String()
+	req := &request.UserEdit{Username: stringPointer(""), IfMatch: "*"}
+
+	// Call the Edit method
+	resp, err := usersusecase.Edit(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusUnprocessableEntity, err.Errors[0].Status)
+}
+
+func TestUsersUsecase_Edit_JSONPatch(t *testing.T) {
+
+	// Prepare the request with JSON Patch operations
+	id := ksuid.New().String()
+	req := &request.UserEdit{IfMatch: "*", Operations: []request.PatchOperation{
+		{Op: "test", Path: "/username", Value: json.RawMessage(`"john doe"`)},
+		{Op: "replace", Path: "/username", Value: json.RawMessage(`"jane doe"`)},
+		{Op: "test", Path: "/username", Value: json.RawMessage(`"jane doe"`)},
+		{Op: "copy", From: "/email", Path: "/password"},
+	}}
+	stored := entity.Users{ID: id, Username: "john doe", Email: "john@example.com", Password: "stored hash"}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = stored
+	}).Return(nil).Once()
+	usersRepoMock.On("Update", mock.Anything, mock.MatchedBy(func(users *entity.Users) bool {
+		return users.Username == "jane doe" && users.Email == "john@example.com" && users.Password != "stored hash" && users.Password != "john@example.com"
+	}), id).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(nil).Once()
+
+	// Call the Edit method
+	resp, err := usersusecase.Edit(context.Background(), req, id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Edit_WhenJSONPatchErr(t *testing.T) {
+	testCases := []struct {
+		name       string
+		operations []request.PatchOperation
+		status     int
+	}{
+		{name: "test failed", operations: []request.PatchOperation{{Op: "test", Path: "/username", Value: json.RawMessage(`"someone else"`)}}, status: http.StatusConflict},
+		{name: "remove required member", operations: []request.PatchOperation{{Op: "remove", Path: "/email"}}, status: http.StatusUnprocessableEntity},
+		{name: "unknown path", operations: []request.PatchOperation{{Op: "replace", Path: "/role", Value: json.RawMessage(`"admin"`)}}, status: http.StatusUnprocessableEntity},
+		{name: "test password", operations: []request.PatchOperation{{Op: "test", Path: "/password", Value: json.RawMessage(`"secret"`)}}, status: http.StatusUnprocessableEntity},
+		{name: "invalid value", operations: []request.PatchOperation{{Op: "replace", Path: "/email", Value: json.RawMessage(`"not an email"`)}}, status: http.StatusUnprocessableEntity},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			// Prepare the request with JSON Patch operations
+			id := ksuid.New().String()
+			req := &request.UserEdit{IfMatch: "*", Operations: testCase.operations}
+			// Define the behavior of the mocked methods
+			usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+			usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+				*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "john doe", Email: "john@example.com"}
+			}).Return(nil).Once()
+
+			// Call the Edit method
+			resp, err := usersusecase.Edit(context.Background(), req, id)
+
+			// Assertions
+			require.Nil(t, resp)
+			require.Equal(t, testCase.status, err.Errors[0].Status)
+
+			// Assert that all expectations were met
+			usersRepoMock.AssertExpectations(t)
+		})
+	}
 }
 
 // ================================================ END EDIT CASES =================================================================
@@ -761,9 +1073,12 @@ func TestUsersUsecase_Delete(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com"}
+	}).Return(nil).Once()
 	usersRepoMock.On("Delete", mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(nil).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Delete(context.Background(), id)
@@ -776,6 +1091,7 @@ func TestUsersUsecase_Delete(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
 func TestUsersUsecase_Delete_WhenInvalidID(t *testing.T) {
@@ -801,7 +1117,7 @@ func TestUsersUsecase_Delete_WhenNotExist(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Return(gorm.ErrRecordNotFound).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Delete(context.Background(), id)
@@ -820,7 +1136,9 @@ func TestUsersUsecase_Delete_WhenDeleteErr(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com"}
+	}).Return(nil).Once()
 	usersRepoMock.On("Delete", mock.Anything, id).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -841,9 +1159,12 @@ func TestUsersUsecase_Delete_WhenDeleteCacheErr(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com"}
+	}).Return(nil).Once()
 	usersRepoMock.On("Delete", mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(context.DeadlineExceeded).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(context.DeadlineExceeded).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Delete(context.Background(), id)
@@ -856,6 +1177,7 @@ func TestUsersUsecase_Delete_WhenDeleteCacheErr(t *testing.T) {
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
 // ================================================== END DELETE CASES =============================================================
@@ -866,9 +1188,11 @@ func TestUsersUsecase_Restore(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com", DeletedAt: 1}
+	}).Return(nil).Once()
 	usersRepoMock.On("Restore", mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(nil).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Restore(context.Background(), id)
@@ -906,7 +1230,9 @@ func TestUsersUsecase_Restore_WhenExist(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com"}
+	}).Return(nil).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Restore(context.Background(), id)
@@ -925,7 +1251,9 @@ func TestUsersUsecase_Restore_WhenDeleteErr(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com", DeletedAt: 1}
+	}).Return(nil).Once()
 	usersRepoMock.On("Restore", mock.Anything, id).Return(errors.New("internal server")).Once()
 
 	// Call the Create method
@@ -946,9 +1274,11 @@ func TestUsersUsecase_Restore_WhenDeleteCacheErr(t *testing.T) {
 	// Prepare the request and expected response
 	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com", DeletedAt: 1}
+	}).Return(nil).Once()
 	usersRepoMock.On("Restore", mock.Anything, id).Return(nil).Once()
-	cacheRepoMock.On("DeleteToCacheByRegexKey", mock.Anything, "users:*").Return(context.DeadlineExceeded).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(context.DeadlineExceeded).Once()
 
 	// Call the Create method
 	resp, err := usersusecase.Restore(context.Background(), id)
@@ -965,34 +1295,78 @@ func TestUsersUsecase_Restore_WhenDeleteCacheErr(t *testing.T) {
 
 // ==================================================== END RESTORE CASES ==============================================================
 
-// ===================================================== LOGIN CASES ===================================================================
+// ===================================================== PURGE CASES ===================================================================
 
-func TestAuthUsecase_Login_WhenInvalidReq(t *testing.T) {
-	// Prepare Request and mock arguments
-	req := request.Auth{Email: "", Password: ""}
+func TestUsersUsecase_Purge(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	email := "purge@example.com"
+	_, errAdd := enforcer.AddGroupingPolicy(email, "admin")
+	require.NoError(t, errAdd)
 	// Define the behavior of the mocked methods
-	// Call the Login methods
-	resp, token, err := authusecase.Login(context.Background(), &req)
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: email}
+	}).Return(nil).Once()
+	usersRepoMock.On("Purge", mock.Anything, id).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(nil).Once()
+
+	// Call the Purge method
+	resp, err := usersusecase.Purge(context.Background(), id)
+
 	// Assertions
-	require.Empty(t, token)
-	require.Nil(t, resp)
-	require.Error(t, err)
-	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
+	require.Nil(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusOK, resp.Status)
+	roles, errRoles := enforcer.GetRolesForUser(email)
+	require.NoError(t, errRoles)
+	require.Empty(t, roles)
 
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
 }
 
-func TestAuthUsecase_Login_WhenGetErr(t *testing.T) {
-	// Prepare Request and mock arguments
-	req := request.Auth{Email: "john@example.com", Password: "password123"}
+func TestUsersUsecase_Purge_RecordsTargetID(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("ExistByKeyValue", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Once()
-	usersRepoMock.On("GetByEmail", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
-	// Call the Login methods
-	resp, token, err := authusecase.Login(context.Background(), &req)
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "john doe", Email: "purged@example.com", DeletedAt: 1}
+	}).Return(nil).Once()
+	usersRepoMock.On("Purge", mock.Anything, id).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	auditRepoMock.On("Create", mock.Anything, mock.MatchedBy(func(event *entity.AuditEvents) bool {
+		return event.Action == entity.AuditUserPurge && event.TargetID == id && event.Changes == "{}"
+	})).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(nil).Once()
+
+	// Call the Purge method
+	resp, err := auditedUsers.Purge(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Purge_WhenInvalidID(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := "1"
+
+	// Call the Purge method
+	resp, err := usersusecase.Purge(context.Background(), id)
+
 	// Assertions
-	require.Empty(t, token)
 	require.Nil(t, resp)
 	require.Error(t, err)
 	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
@@ -1001,54 +1375,429 @@ func TestAuthUsecase_Login_WhenGetErr(t *testing.T) {
 	usersRepoMock.AssertExpectations(t)
 }
 
-func TestAuthUsecase_Login_WhenNotFound(t *testing.T) {
-	// Prepare Request and mock arguments
-	req := request.Auth{Email: "john@example.com", Password: "password123"}
+func TestUsersUsecase_Purge_WhenNotExist(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("ExistByKeyValue", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Once()
-	// Call the Login methods
-	resp, token, err := authusecase.Login(context.Background(), &req)
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Return(gorm.ErrRecordNotFound).Once()
+
+	// Call the Purge method
+	resp, err := usersusecase.Purge(context.Background(), id)
+
 	// Assertions
-	require.Empty(t, token)
 	require.Nil(t, resp)
 	require.Error(t, err)
-	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
+	require.Equal(t, http.StatusNotFound, err.Errors[0].Status)
 
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
 }
 
-// ===================================================== END LOGIN CASES ===============================================================
-
-// ===================================================== RESET PASSWORD CASES ==========================================================
+func TestUsersUsecase_Purge_WhenPurgeErr(t *testing.T) {
 
-func TestAuthUsecase_ResetPassword(t *testing.T) {
-	// Prepare Request and mock arguments
-	req := request.ResetPassword{Password: "password123", Confirm: "password123"}
-	payload := token.NewTokenPayloadBuilder().WithEmail("john@example.com").WithUserID(ksuid.New()).WithExpiration(time.Now().Add(5 * time.Minute)).Build()
+	// Prepare the request and expected response
+	id := ksuid.New().String()
 	// Define the behavior of the mocked methods
-	usersRepoMock.On("GetByEmail", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
-	usersRepoMock.On("Update", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
-	// Call the Login methods
-	resp, errResp := authusecase.ResetPassword(context.Background(), payload, &req)
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "purge-failed@example.com"}
+	}).Return(nil).Once()
+	usersRepoMock.On("Purge", mock.Anything, id).Return(errors.New("internal server")).Once()
+
+	// Call the Purge method
+	resp, err := usersusecase.Purge(context.Background(), id)
+
 	// Assertions
-	require.NotNil(t, resp)
-	require.Nil(t, errResp)
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, err.Errors[0].Status)
+
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
 }
 
-func TestAuthUsecase_ResetPassword_WhenInvalidReq(t *testing.T) {
-	// Prepare Request and mock arguments
-	req := request.ResetPassword{Password: "password12", Confirm: ""}
-	payload := token.NewTokenPayloadBuilder().WithEmail("john@example.com").WithUserID(ksuid.New()).WithExpiration(time.Now().Add(5 * time.Minute)).Build()
+func TestUsersUsecase_PurgeExpired(t *testing.T) {
+
+	// Prepare the request and expected response
+	before := time.Now().AddDate(0, 0, -30)
+	expired := []*entity.Users{
+		{ID: ksuid.New().String(), Email: "expired1@example.com", DeletedAt: 1},
+		{ID: ksuid.New().String(), Email: "expired2@example.com", DeletedAt: 2},
+	}
 	// Define the behavior of the mocked methods
-	// Call the Login methods
-	resp, errResp := authusecase.ResetPassword(context.Background(), payload, &req)
+	usersRepoMock.On("GetDeletedBefore", mock.Anything, before.UnixMilli(), 2).Return(expired, nil).Once()
+	usersRepoMock.On("Purge", mock.Anything, expired[0].ID).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, expired[0].ID).Return(nil).Once()
+	usersRepoMock.On("Purge", mock.Anything, expired[1].ID).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, expired[1].ID).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + expired[0].ID, "users:tag:all"}).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + expired[1].ID, "users:tag:all"}).Return(nil).Once()
+	usersRepoMock.On("GetDeletedBefore", mock.Anything, before.UnixMilli(), 2).Return([]*entity.Users{}, nil).Once()
+
+	// Call the PurgeExpired method
+	purged, err := usersusecase.PurgeExpired(context.Background(), before, 2)
+
 	// Assertions
-	require.Nil(t, resp)
-	require.Error(t, errResp)
-	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(errResp).String())
+	require.Nil(t, err)
+	require.Equal(t, 2, purged)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_PurgeExpired_WhenPurgeErr(t *testing.T) {
+
+	// Prepare the request and expected response
+	before := time.Now().AddDate(0, 0, -30)
+	expired := []*entity.Users{
+		{ID: ksuid.New().String(), Email: "expired1@example.com", DeletedAt: 1},
+		{ID: ksuid.New().String(), Email: "expired2@example.com", DeletedAt: 2},
+	}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("GetDeletedBefore", mock.Anything, before.UnixMilli(), 2).Return(expired, nil).Once()
+	usersRepoMock.On("Purge", mock.Anything, expired[0].ID).Return(errors.New("internal server")).Once()
+	usersRepoMock.On("Purge", mock.Anything, expired[1].ID).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, expired[1].ID).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + expired[1].ID, "users:tag:all"}).Return(nil).Once()
+
+	// Call the PurgeExpired method, the failed user stops the loop until the next run
+	purged, err := usersusecase.PurgeExpired(context.Background(), before, 2)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, 1, purged)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_PurgeExpired_WhenGetErr(t *testing.T) {
+
+	// Prepare the request and expected response
+	before := time.Now().AddDate(0, 0, -30)
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("GetDeletedBefore", mock.Anything, before.UnixMilli(), 10).Return(nil, context.DeadlineExceeded).Once()
+
+	// Call the PurgeExpired method
+	purged, err := usersusecase.PurgeExpired(context.Background(), before, 10)
+
+	// Assertions
+	require.Error(t, err)
+	require.Equal(t, 0, purged)
+	require.Equal(t, http.StatusRequestTimeout, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+// ===================================================== END PURGE CASES ===============================================================
+
+// ===================================================== EXPORT CASES ==================================================================
+
+func TestUsersUsecase_Export(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	email := "export@example.com"
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	exportRepoMock.On("GetArchive", mock.Anything, id).Return(nil, nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "export", Email: email}
+	}).Return(nil).Once()
+
+	// Call the Export method
+	resp, err := usersusecase.Export(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Equal(t, fmt.Sprintf("user-%s-export.zip", id), resp.FileName)
+	archive, errZip := zip.NewReader(bytes.NewReader(resp.Archive), int64(len(resp.Archive)))
+	require.NoError(t, errZip)
+	names := []string{}
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	require.Equal(t, []string{"user.json", "profile.json", "roles.json", "audit.json"}, names)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Export_WhenArchiveReady(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	archive := []byte("archive")
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	exportRepoMock.On("GetArchive", mock.Anything, id).Return(archive, nil).Once()
+
+	// Call the Export method
+	resp, err := usersusecase.Export(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Equal(t, archive, resp.Archive)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Export_WhenLargeAccount(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	email := "export-large@example.com"
+	_, errAdd := enforcer.AddGroupingPolicy(email, "exporter")
+	require.NoError(t, errAdd)
+	_, errPolicy := enforcer.AddPolicies([][]string{{"exporter", "users", "read"}, {"exporter", "users", "export"}})
+	require.NoError(t, errPolicy)
+	done := make(chan struct{})
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	exportRepoMock.On("GetArchive", mock.Anything, id).Return(nil, nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "export", Email: email}
+	}).Return(nil).Once()
+	exportRepoMock.On("MarkPending", mock.Anything, id, mock.Anything).Return(true, nil).Once()
+	exportRepoMock.On("SetArchive", mock.Anything, id, mock.Anything, exportConfig.TTL()).Return(nil).Once()
+	exportRepoMock.On("ClearPending", mock.Anything, id).Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+
+	// Call the Export method
+	resp, err := usersusecase.Export(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusAccepted, resp.Status)
+	require.Equal(t, "STATUS_ACCEPTED", resp.Code)
+	require.Nil(t, resp.Archive)
+	require.Equal(t, 5, resp.Meta["records"])
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export job did not finish")
+	}
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Export_WithAuditEvents(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	events := make([]*entity.AuditEvents, 101)
+	for i := range events {
+		events[i] = &entity.AuditEvents{ID: ksuid.New().String(), Action: entity.AuditUserUpdate, TargetID: id, Changes: "{}"}
+	}
+	archives := make(chan []byte, 1)
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	exportRepoMock.On("GetArchive", mock.Anything, id).Return(nil, nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "export", Email: "export-audit@example.com"}
+	}).Return(nil).Once()
+	auditRepoMock.On("GetAll", mock.Anything, &request.AuditPage{Size: 100, TargetID: id}).Return(events[:100], nil).Once()
+	auditRepoMock.On("GetAll", mock.Anything, &request.AuditPage{Size: 100, TargetID: id, Before: events[99].ID}).Return(events[100:], nil).Once()
+	exportRepoMock.On("MarkPending", mock.Anything, id, mock.Anything).Return(true, nil).Once()
+	exportRepoMock.On("SetArchive", mock.Anything, id, mock.Anything, exportConfig.TTL()).Run(func(args mock.Arguments) {
+		archives <- args.Get(2).([]byte)
+	}).Return(nil).Once()
+	done := make(chan struct{})
+	exportRepoMock.On("ClearPending", mock.Anything, id).Run(func(args mock.Arguments) {
+		close(done)
+	}).Return(nil).Once()
+
+	// Call the Export method, the audit events make the account large
+	resp, err := auditedUsers.Export(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusAccepted, resp.Status)
+	require.Equal(t, 103, resp.Meta["records"])
+	var archive []byte
+	select {
+	case archive = <-archives:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export job did not store the archive")
+	}
+	reader, errZip := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	require.NoError(t, errZip)
+	file, errOpen := reader.Open("audit.json")
+	require.NoError(t, errOpen)
+	exported := []*response.AuditEvent{}
+	require.NoError(t, json.NewDecoder(file).Decode(&exported))
+	require.Len(t, exported, 101)
+	require.Equal(t, events[0].ID, exported[0].ID)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export job did not finish")
+	}
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Export_WhenJobAlreadyRunning(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	email := "export-running@example.com"
+	_, errAdd := enforcer.AddGroupingPolicy(email, "exporter")
+	require.NoError(t, errAdd)
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	exportRepoMock.On("GetArchive", mock.Anything, id).Return(nil, nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "export", Email: email}
+	}).Return(nil).Once()
+	exportRepoMock.On("MarkPending", mock.Anything, id, mock.Anything).Return(false, nil).Once()
+
+	// Call the Export method
+	resp, err := usersusecase.Export(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusAccepted, resp.Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Export_WhenNotExist(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(0), nil).Once()
+
+	// Call the Export method
+	resp, err := usersusecase.Export(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, http.StatusNotFound, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Export_WhenInvalidID(t *testing.T) {
+
+	// Call the Export method
+	resp, err := usersusecase.Export(context.Background(), "1")
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
+}
+
+// ===================================================== END EXPORT CASES ==============================================================
+
+// ===================================================== LOGIN CASES ===================================================================
+
+func TestAuthUsecase_Login_WhenInvalidReq(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.Auth{Email: "", Password: ""}
+	// Define the behavior of the mocked methods
+	// Call the Login methods
+	resp, token, err := authusecase.Login(context.Background(), &req)
+	// Assertions
+	require.Empty(t, token)
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestAuthUsecase_Login_WhenGetErr(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.Auth{Email: "john@example.com", Password: "password123"}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("ExistByKeyValue", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Once()
+	usersRepoMock.On("GetByEmail", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	// Call the Login methods
+	resp, token, err := authusecase.Login(context.Background(), &req)
+	// Assertions
+	require.Empty(t, token)
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestAuthUsecase_Login_WhenNotFound(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.Auth{Email: "john@example.com", Password: "password123"}
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("ExistByKeyValue", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Once()
+	// Call the Login methods
+	resp, token, err := authusecase.Login(context.Background(), &req)
+	// Assertions
+	require.Empty(t, token)
+	require.Nil(t, resp)
+	require.Error(t, err)
+	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(err).String())
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+// ===================================================== END LOGIN CASES ===============================================================
+
+// ===================================================== RESET PASSWORD CASES ==========================================================
+
+func TestAuthUsecase_ResetPassword(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.ResetPassword{Password: "password123", Confirm: "password123"}
+	payload := token.NewTokenPayloadBuilder().WithEmail("john@example.com").WithUserID(ksuid.New()).WithExpiration(time.Now().Add(5 * time.Minute)).Build()
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("GetByEmail", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	usersRepoMock.On("Update", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+	// Call the Login methods
+	resp, errResp := authusecase.ResetPassword(context.Background(), payload, &req)
+	// Assertions
+	require.NotNil(t, resp)
+	require.Nil(t, errResp)
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+}
+
+func TestAuthUsecase_ResetPassword_WhenInvalidReq(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.ResetPassword{Password: "password12", Confirm: ""}
+	payload := token.NewTokenPayloadBuilder().WithEmail("john@example.com").WithUserID(ksuid.New()).WithExpiration(time.Now().Add(5 * time.Minute)).Build()
+	// Define the behavior of the mocked methods
+	// Call the Login methods
+	resp, errResp := authusecase.ResetPassword(context.Background(), payload, &req)
+	// Assertions
+	require.Nil(t, resp)
+	require.Error(t, errResp)
+	require.Equal(t, reflect.TypeOf(new(response.StandardErrors)).String(), reflect.TypeOf(errResp).String())
 
 	// Assert that all expectations were met
 	usersRepoMock.AssertExpectations(t)
@@ -1091,3 +1840,468 @@ func TestAuthUsecase_ResetPassword_WhenUpdateErr(t *testing.T) {
 }
 
 // ===================================================== END RESET PASSWORD CASES =======================================================
+
+// ===================================================== AUDIT CASES ===================================================================
+
+func TestUsersUsecase_Update_RecordsAudit(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	actorID := ksuid.New().String()
+	req := &request.User{Username: "john doe", Email: "john@example.com", Password: "password123", IfMatch: `"3"`}
+	ctx := request.WithMeta(context.Background(), &request.Meta{ActorID: actorID, IP: "10.0.0.1", UserAgent: "test-agent"})
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("CountById", mock.Anything, id).Return(int64(1), nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "old name", Email: "old@example.com", Password: "old hash", Version: 3}
+	}).Return(nil).Once()
+	usersRepoMock.On("Update", mock.Anything, mock.MatchedBy(func(users *entity.Users) bool { return users.Version == 3 }), id).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id}).Return(nil).Once()
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Username: "john doe", Email: "john@example.com", Password: "new hash", Version: 4}
+	}).Return(nil).Once()
+	auditRepoMock.On("Create", mock.Anything, mock.MatchedBy(func(event *entity.AuditEvents) bool {
+		return event.Action == entity.AuditUserUpdate &&
+			event.Outcome == entity.AuditSuccess &&
+			event.ActorID == actorID &&
+			event.TargetID == id &&
+			event.IP == "10.0.0.1" &&
+			event.UserAgent == "test-agent" &&
+			event.Changes == `{"email":{"changed":true},"password":{"changed":true},"username":{"from":"old name","to":"john doe"}}`
+	})).Return(nil).Once()
+
+	// Call the Update method
+	resp, err := auditedUsers.Update(ctx, req, id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Equal(t, `"4"`, resp.ETag)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Delete_WhenAuditErr(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("GetById", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com"}
+	}).Return(nil).Once()
+	usersRepoMock.On("Delete", mock.Anything, id).Return(nil).Once()
+	exportRepoMock.On("DeleteArchive", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(nil).Once()
+	auditRepoMock.On("Create", mock.Anything, mock.MatchedBy(func(event *entity.AuditEvents) bool {
+		return event.Action == entity.AuditUserDelete && event.TargetID == id
+	})).Return(errors.New("internal server")).Once()
+
+	// Call the Delete method, a failed audit write does not fail the deletion
+	resp, err := auditedUsers.Delete(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	exportRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestUsersUsecase_Restore_RecordsTargetID(t *testing.T) {
+
+	// Prepare the request and expected response
+	id := ksuid.New().String()
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("GetByIdWithDeleted", mock.Anything, mock.Anything, id).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: "john@example.com", DeletedAt: 1}
+	}).Return(nil).Once()
+	usersRepoMock.On("Restore", mock.Anything, id).Return(nil).Once()
+	cacheRepoMock.On("InvalidateTags", mock.Anything, []string{"users:tag:id:" + id, "users:tag:all"}).Return(nil).Once()
+	auditRepoMock.On("Create", mock.Anything, mock.MatchedBy(func(event *entity.AuditEvents) bool {
+		return event.Action == entity.AuditUserRestore && event.TargetID == id
+	})).Return(nil).Once()
+
+	// Call the Restore method
+	resp, err := auditedUsers.Restore(context.Background(), id)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	cacheRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestAuthUsecase_Login_WhenNotFound_RecordsFailure(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.Auth{Email: "unknown@example.com", Password: "password123"}
+	ctx := request.WithMeta(context.Background(), &request.Meta{IP: "10.0.0.2"})
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("ExistByKeyValue", mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Once()
+	auditRepoMock.On("Create", mock.Anything, mock.MatchedBy(func(event *entity.AuditEvents) bool {
+		return event.Action == entity.AuditLogin &&
+			event.Outcome == entity.AuditFailure &&
+			event.TargetID == "" &&
+			event.Changes == `{"reason":"unknown email"}` &&
+			event.IP == "10.0.0.2"
+	})).Return(nil).Once()
+
+	// Call the Login methods
+	resp, _, err := auditedAuth.Login(ctx, &req)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Error(t, err)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestAuthUsecase_UpsertRole_RecordsAudit(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := request.UpdateRole{Email: "role-change@example.com", RoleName: "moderator"}
+	_, errAdd := enforcer.AddGroupingPolicy(req.Email, "viewer")
+	require.NoError(t, errAdd)
+	id := ksuid.New().String()
+	// Define the behavior of the mocked methods
+	usersRepoMock.On("GetByEmailWithDeleted", mock.Anything, mock.Anything, req.Email).Run(func(args mock.Arguments) {
+		*args.Get(1).(*entity.Users) = entity.Users{ID: id, Email: req.Email}
+	}).Return(nil).Once()
+	auditRepoMock.On("Create", mock.Anything, mock.MatchedBy(func(event *entity.AuditEvents) bool {
+		return event.Action == entity.AuditRoleChange &&
+			event.TargetID == id &&
+			event.Changes == `{"roles":{"from":["viewer"],"to":["moderator"]}}`
+	})).Return(nil).Once()
+
+	// Call the UpsertRole methods
+	resp, err := auditedAuth.UpsertRole(context.Background(), &req)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+	roles, errRoles := enforcer.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"moderator"}, roles)
+
+	// Assert that all expectations were met
+	usersRepoMock.AssertExpectations(t)
+	auditRepoMock.AssertExpectations(t)
+}
+
+// openSQLite opens an in-memory SQLite database with the sqlite migrations applied.
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	config := &sqlconfig.SqlConfig{Driver: sqlconfig.SQLite, Name: ":memory:", MaxCon: 10, MinCon: 1}
+	db, err := orm.Open(sqlite.Open(config.DSN()), config)
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	migrator, err := migration.New(sqlDB, sqlconfig.SQLite, &log.DefaultLogger)
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+// countingWatcher counts the policy changes announced to the other processes.
+type countingWatcher struct{ updates int }
+
+func (w *countingWatcher) SetUpdateCallback(func(string)) error { return nil }
+func (w *countingWatcher) Update() error                        { w.updates++; return nil }
+func (w *countingWatcher) Close()                               {}
+
+// newAuthInUnitOfWork builds an AuthUsecase whose policy changes and audit events are saved in a unit of work on db,
+// with an enforcer loaded from its casbin_rule table and a watcher counting the announced changes.
+func newAuthInUnitOfWork(t *testing.T, db *gorm.DB, auditRepository repository.AuditRepository) (*usecase.AuthUsecase, *casbin.Enforcer, *countingWatcher) {
+	t.Helper()
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	require.NoError(t, err)
+	shared, err := casbin.NewEnforcer("../../resource/model/rbac_model.conf", adapter)
+	require.NoError(t, err)
+	unitOfWork, err := repository.NewUnitOfWork(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	usersRepository, err := repository.NewUsersRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	timeoutConfig, err := timeout.NewConfig()
+	require.NoError(t, err)
+	english := en.New()
+	translator, _ := ut.New(english, english).GetTranslator("en")
+	watcher := &countingWatcher{}
+	auth := usecase.NewAuthUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepository).WithTimeoutConfig(timeoutConfig).WithValidator(validation.NewValidator(validator.New(), translator)).
+		WithEnforcer(shared).WithPolicyWatcher(watcher).WithAuditRepository(auditRepository).WithUnitOfWork(unitOfWork).Build()
+	return auth, shared, watcher
+}
+
+func TestAuthUsecase_UpsertRole_InUnitOfWork(t *testing.T) {
+	// Prepare the database and a user holding a role
+	db := openSQLite(t)
+	auditRepository, err := repository.NewAuditRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	auth, shared, watcher := newAuthInUnitOfWork(t, db, auditRepository)
+	adapter := shared.GetAdapter()
+	req := request.UpdateRole{Email: "uow@example.com", RoleName: "moderator"}
+	_, errAdd := shared.AddGroupingPolicy(req.Email, "viewer")
+	require.NoError(t, errAdd)
+
+	// Call the UpsertRole methods
+	resp, errUpsert := auth.UpsertRole(context.Background(), &req)
+
+	// The change is committed, applied to the shared enforcer, whose adapter is left as is, and announced once
+	require.Nil(t, errUpsert)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Same(t, adapter, shared.GetAdapter())
+	require.Equal(t, 1, watcher.updates)
+	roles, errRoles := shared.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"moderator"}, roles)
+	reloaded, err := casbin.NewEnforcer("../../resource/model/rbac_model.conf", adapter)
+	require.NoError(t, err)
+	roles, errRoles = reloaded.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"moderator"}, roles)
+}
+
+func TestAuthUsecase_UpsertRole_InUnitOfWork_WhenAuditErr(t *testing.T) {
+	// Prepare the database, a user holding a role and an audit trail failing to write
+	db := openSQLite(t)
+	failingAudit := new(repository.AuditRepositoryMock)
+	failingAudit.On("Create", mock.Anything, mock.Anything).Return(errors.New("audit trail unavailable")).Once()
+	auth, shared, watcher := newAuthInUnitOfWork(t, db, failingAudit)
+	req := request.UpdateRole{Email: "uow@example.com", RoleName: "moderator"}
+	_, errAdd := shared.AddGroupingPolicy(req.Email, "viewer")
+	require.NoError(t, errAdd)
+
+	// Call the UpsertRole methods
+	resp, errUpsert := auth.UpsertRole(context.Background(), &req)
+
+	// The role change is rolled back with its audit event, in the database and in the shared enforcer, and never announced
+	require.Nil(t, resp)
+	require.NotNil(t, errUpsert)
+	require.Zero(t, watcher.updates)
+	roles, errRoles := shared.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"viewer"}, roles)
+	reloaded, err := casbin.NewEnforcer("../../resource/model/rbac_model.conf", shared.GetAdapter())
+	require.NoError(t, err)
+	roles, errRoles = reloaded.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"viewer"}, roles)
+	failingAudit.AssertExpectations(t)
+}
+
+func TestAuthUsecase_UpsertRole_InUnitOfWork_SameRole(t *testing.T) {
+	// Prepare the database and a user already holding the role
+	db := openSQLite(t)
+	auditRepository, err := repository.NewAuditRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	auth, shared, watcher := newAuthInUnitOfWork(t, db, auditRepository)
+	req := request.UpdateRole{Email: "uow@example.com", RoleName: "moderator"}
+	_, errAdd := shared.AddGroupingPolicy(req.Email, req.RoleName)
+	require.NoError(t, errAdd)
+
+	// Call the UpsertRole methods
+	resp, errUpsert := auth.UpsertRole(context.Background(), &req)
+
+	// The role removed then given again in the unit of work is kept, in the database and in the shared enforcer
+	require.Nil(t, errUpsert)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Equal(t, 1, watcher.updates)
+	roles, errRoles := shared.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"moderator"}, roles)
+	reloaded, err := casbin.NewEnforcer("../../resource/model/rbac_model.conf", shared.GetAdapter())
+	require.NoError(t, err)
+	roles, errRoles = reloaded.GetRolesForUser(req.Email)
+	require.NoError(t, errRoles)
+	require.Equal(t, []string{"moderator"}, roles)
+}
+
+func TestUsersUsecase_Purge_InUnitOfWork(t *testing.T) {
+	// Prepare the database, a user holding a role and a policy, and another user holding the same role
+	db := openSQLite(t)
+	_, shared, watcher := newAuthInUnitOfWork(t, db, nil)
+	unitOfWork, err := repository.NewUnitOfWork(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	usersRepository, err := repository.NewUsersRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	auditRepository, err := repository.NewAuditRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	timeoutConfig, err := timeout.NewConfig()
+	require.NoError(t, err)
+	english := en.New()
+	translator, _ := ut.New(english, english).GetTranslator("en")
+	cacheRepository := new(repository.MockCacheRepository[*entity.Users])
+	cacheRepository.On("InvalidateTags", mock.Anything, mock.Anything).Return(nil)
+	exportRepository := new(repository.ExportRepositoryMock)
+	exportRepository.On("DeleteArchive", mock.Anything, mock.Anything).Return(nil)
+	users := usecase.NewUsersUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepository).WithCacheRepository(cacheRepository).WithTimeoutConfig(timeoutConfig).
+		WithValidator(validation.NewValidator(validator.New(), translator)).WithEnforcer(shared).WithPolicyWatcher(watcher).WithAuditRepository(auditRepository).
+		WithExportRepository(exportRepository).WithUnitOfWork(unitOfWork).Build()
+	id := ksuid.New().String()
+	email := "purge-uow@example.com"
+	require.NoError(t, usersRepository.Create(context.Background(), &entity.Users{ID: id, Username: "uow", Email: email, Password: "password"}))
+	_, errAdd := shared.AddGroupingPolicy(email, "moderator")
+	require.NoError(t, errAdd)
+	_, errAdd = shared.AddPolicy(email, "/api/v1/users", "GET")
+	require.NoError(t, errAdd)
+	_, errAdd = shared.AddGroupingPolicy("other@example.com", "moderator")
+	require.NoError(t, errAdd)
+
+	// Call the Purge methods
+	resp, errPurge := users.Purge(context.Background(), id)
+
+	// The rules of the user are removed, in the database and in the shared enforcer, the others are kept
+	require.Nil(t, errPurge)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Equal(t, 1, watcher.updates)
+	reloaded, err := casbin.NewEnforcer("../../resource/model/rbac_model.conf", shared.GetAdapter())
+	require.NoError(t, err)
+	for _, enforcer := range []*casbin.Enforcer{shared, reloaded} {
+		roles, errRoles := enforcer.GetRolesForUser(email)
+		require.NoError(t, errRoles)
+		require.Empty(t, roles)
+		policies, errPolicies := enforcer.GetFilteredPolicy(0, email)
+		require.NoError(t, errPolicies)
+		require.Empty(t, policies)
+		roles, errRoles = enforcer.GetRolesForUser("other@example.com")
+		require.NoError(t, errRoles)
+		require.Equal(t, []string{"moderator"}, roles)
+	}
+}
+
+func TestUsersUsecase_Delete_InUnitOfWork_WhenAuditErr(t *testing.T) {
+	// Prepare the database, a user and an audit trail failing to write
+	db := openSQLite(t)
+	unitOfWork, err := repository.NewUnitOfWork(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	usersRepository, err := repository.NewUsersRepositoryImpl(db, &log.DefaultLogger)
+	require.NoError(t, err)
+	failingAudit := new(repository.AuditRepositoryMock)
+	failingAudit.On("Create", mock.Anything, mock.Anything).Return(errors.New("audit trail unavailable")).Once()
+	timeoutConfig, err := timeout.NewConfig()
+	require.NoError(t, err)
+	english := en.New()
+	translator, _ := ut.New(english, english).GetTranslator("en")
+	cacheRepository := new(repository.MockCacheRepository[*entity.Users])
+	cacheRepository.On("InvalidateTags", mock.Anything, mock.Anything).Return(nil)
+	exportRepository := new(repository.ExportRepositoryMock)
+	exportRepository.On("DeleteArchive", mock.Anything, mock.Anything).Return(nil)
+	users := usecase.NewUsersUsecaseBuilder().WithLogger(&log.DefaultLogger).WithUsersRepository(usersRepository).WithCacheRepository(cacheRepository).WithTimeoutConfig(timeoutConfig).
+		WithValidator(validation.NewValidator(validator.New(), translator)).WithAuditRepository(failingAudit).WithExportRepository(exportRepository).WithUnitOfWork(unitOfWork).Build()
+	id := ksuid.New().String()
+	require.NoError(t, usersRepository.Create(context.Background(), &entity.Users{ID: id, Username: "uow", Email: "uow@example.com", Password: "password"}))
+
+	// Call the Delete methods
+	resp, errDelete := users.Delete(context.Background(), id)
+
+	// The deletion is rolled back with its audit event
+	require.Nil(t, resp)
+	require.NotNil(t, errDelete)
+	var stored entity.Users
+	require.NoError(t, usersRepository.GetById(context.Background(), &stored, id))
+	require.Zero(t, stored.DeletedAt)
+	failingAudit.AssertExpectations(t)
+}
+
+func TestAuditUsecase_List(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := &request.AuditPage{Size: 2, Action: entity.AuditLogin}
+	events := []*entity.AuditEvents{
+		{ID: ksuid.New().String(), Action: entity.AuditLogin, Changes: "{}"},
+		{ID: ksuid.New().String(), Action: entity.AuditLogin, Changes: "{}"},
+	}
+	// Define the behavior of the mocked methods
+	auditRepoMock.On("GetAll", mock.Anything, req).Return(events, nil).Once()
+
+	// Call the List method
+	resp, err := auditusecase.List(context.Background(), req)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.Status)
+	require.Equal(t, mapper.EntitiesAuditToResponses(events), resp.Data)
+	require.Equal(t, "?action=auth.login&size=2", resp.Links["self"])
+	require.Equal(t, "?action=auth.login&before="+events[1].ID+"&size=2", resp.Links["next"])
+
+	// Assert that all expectations were met
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestAuditUsecase_List_EscapesFilters(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := &request.AuditPage{Size: 1, Action: "auth.login&outcome=failure"}
+	events := []*entity.AuditEvents{{ID: ksuid.New().String(), Action: entity.AuditLogin, Changes: "{}"}}
+	// Define the behavior of the mocked methods
+	auditRepoMock.On("GetAll", mock.Anything, req).Return(events, nil).Once()
+
+	// Call the List method
+	resp, err := auditusecase.List(context.Background(), req)
+
+	// Assertions
+	require.Nil(t, err)
+	require.Equal(t, "?action=auth.login%26outcome%3Dfailure&size=1", resp.Links["self"])
+	require.Equal(t, "?action=auth.login%26outcome%3Dfailure&before="+events[0].ID+"&size=1", resp.Links["next"])
+
+	// Assert that all expectations were met
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestAuditUsecase_List_WhenLastPage(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := &request.AuditPage{Size: 10}
+	// Define the behavior of the mocked methods
+	auditRepoMock.On("GetAll", mock.Anything, req).Return([]*entity.AuditEvents{}, nil).Once()
+
+	// Call the List method
+	resp, err := auditusecase.List(context.Background(), req)
+
+	// Assertions
+	require.Nil(t, err)
+	require.NotContains(t, resp.Links, "next")
+
+	// Assert that all expectations were met
+	auditRepoMock.AssertExpectations(t)
+}
+
+func TestAuditUsecase_List_WhenInvalidReq(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := &request.AuditPage{Size: 10, Outcome: "unknown"}
+
+	// Call the List method
+	resp, err := auditusecase.List(context.Background(), req)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Error(t, err)
+}
+
+func TestAuditUsecase_List_WhenDBTimeout(t *testing.T) {
+	// Prepare Request and mock arguments
+	req := &request.AuditPage{Size: 5}
+	// Define the behavior of the mocked methods
+	auditRepoMock.On("GetAll", mock.Anything, req).Return(nil, context.DeadlineExceeded).Once()
+
+	// Call the List method
+	resp, err := auditusecase.List(context.Background(), req)
+
+	// Assertions
+	require.Nil(t, resp)
+	require.Equal(t, http.StatusRequestTimeout, err.Errors[0].Status)
+
+	// Assert that all expectations were met
+	auditRepoMock.AssertExpectations(t)
+}
+
+// ===================================================== END AUDIT CASES ===============================================================
@@ -0,0 +1,72 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/phuslu/log"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/mapper"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
+	"github.com/tirtahakimpambudhi/restful_api/internal/validation"
+)
+
+// AuditUsecase handles querying the audit trail.
+type AuditUsecase struct {
+	auditRepository repository.AuditRepository // Repository to access audit events.
+	timeoutConfig   *timeout.Config            // Configuration for handling timeouts.
+	validator       *validation.Validator      // Validator for request validation.
+	logger          *log.Logger                // Logger for logging messages.
+}
+
+// List retrieves a page of audit events, newest first, matching the filters of the request.
+func (a AuditUsecase) List(ctx context.Context, req *request.AuditPage) (*response.LinksAble, *response.StandardErrors) {
+	ctx, span := tracing.Start(ctx, "AuditUsecase.List")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("List audit events method called")
+
+	// Validate the incoming request parameters.
+	if errValidate := a.validator.Validate(req); errValidate != nil {
+		logger.Error().Msgf("Validation error: %v", errValidate)
+		return nil, &response.StandardErrors{Errors: errValidate}
+	}
+
+	// Set a timeout context for database operations.
+	ctxDB, cancel := a.timeoutConfig.CreateDatabaseTimeout(ctx)
+	defer cancel()
+
+	events, errDB := a.auditRepository.GetAll(ctxDB, req)
+	if errDB != nil {
+		logger.Error().Msgf("Failed to fetch audit events from database: %v", errDB)
+		if errors.Is(errDB, context.DeadlineExceeded) {
+			return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.REQUEST_TIMEOUT, "Request timed out: "+errDB.Error())}}
+		}
+		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Failed to fetch audit events from database: "+errDB.Error())}}
+	}
+
+	// Link to the next, older, page only when this page is full.
+	links := map[string]any{
+		"self": req.GetQueryParams(),
+	}
+	if len(events) == req.Size {
+		links["next"] = req.GetNextQueryParams(events[len(events)-1].ID)
+	}
+
+	logger.Info().Msgf("List audit events completed with %d events", len(events))
+	return &response.LinksAble{
+		Status: http.StatusOK,
+		Code:   "STATUS_OK",
+		Data:   mapper.EntitiesAuditToResponses(events),
+		Meta: map[string]any{
+			"size": req.Size,
+		},
+		Links: links,
+	}, nil
+}
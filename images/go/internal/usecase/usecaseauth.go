@@ -5,43 +5,55 @@ import (
 	"errors"
 	"fmt"
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/phuslu/log"
 	"github.com/segmentio/ksuid"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/metrics"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
 	tokenconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/token"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
 	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
 	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
 	"github.com/tirtahakimpambudhi/restful_api/internal/repository"
 	"github.com/tirtahakimpambudhi/restful_api/internal/validation"
+	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
 
 // AuthUsecase handles the authentication logic.
 type AuthUsecase struct {
-	usersRepository repository.UsersRepository // Repository to access user data.
-	timeoutConfig   *timeout.Config            // Configuration for handling timeouts.
-	validator       *validation.Validator      // Validator for request validation.
-	hashing         *hash.Argon2               // Password hashing utility.
-	token           *tokenconfig.JWTToken      // Token generation and verification utility.
-	secretKey       *tokenconfig.SecretKey     // Secret key for Token secret
-	logger          *log.Logger                // Logger for logging messages.
-	enforcer        *casbin.Enforcer
+	usersRepository repository.UsersRepository                // Repository to access user data.
+	timeoutConfig   *timeout.Config                           // Configuration for handling timeouts.
+	validator       *validation.Validator                     // Validator for request validation.
+	hashing         *hash.Argon2                              // Password hashing utility.
+	token           *tokenconfig.JWTToken                     // Token generation and verification utility.
+	secretKey       *tokenconfig.SecretKey                    // Secret key for Token secret
+	logger          *log.Logger                               // Logger for logging messages.
+	enforcer        *casbin.Enforcer                          // Casbin enforcer holding the user roles.
+	watcher         persist.Watcher                           // Watcher announcing the role changes to the other processes.
+	cacheRepository repository.CacheRepository[*entity.Users] // Cache of users, invalidated when a password reset changes a user.
+	unitOfWork      repository.UnitOfWork                     // Unit of work making the role changes and their audit events atomic.
+	audit           auditRecorder                             // Recorder of the audit trail.
 }
 
 // Login used for users login logic.
 func (a AuthUsecase) Login(ctx context.Context, req *request.Auth) (*response.Standard, string, *response.StandardErrors) {
-	a.logger.Info().Msg("Login method called") // Log the method call.
+	ctx, span := tracing.Start(ctx, "AuthUsecase.Login")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("Login method called") // Log the method call.
 
 	// Validate the incoming request data.
 	if errValidate := a.validator.Validate(req); errValidate != nil {
-		a.logger.Error().Msgf("Validation error: %v", errValidate)    // Log validation error.
+		logger.Error().Msgf("Validation error: %v", errValidate)      // Log validation error.
 		return nil, "", &response.StandardErrors{Errors: errValidate} // Return validation errors.
 	}
-	a.logger.Info().Msg("Request validated successfully") // Log successful validation.
+	logger.Info().Msg("Request validated successfully") // Log successful validation.
 
 	// Set a timeout context for database existence check.
 	ctxCount, cancelCount := a.timeoutConfig.CreateDatabaseTimeout(ctx)
@@ -50,13 +62,15 @@ func (a AuthUsecase) Login(ctx context.Context, req *request.Auth) (*response.St
 	// Check if a user with the same email already exists.
 	exist, errExist := a.usersRepository.ExistByKeyValue(ctxCount, map[string]any{"email": req.Email})
 	if errExist != nil {
-		a.logger.Error().Msgf("Failed to count users in database: %v", errExist)                 // Log error.
-		return nil, "", a.handleErrFromRepository(errExist, "Failed to count users in database") // Handle repository error.
+		logger.Error().Msgf("Failed to count users in database: %v", errExist)                        // Log error.
+		return nil, "", a.handleErrFromRepository(ctx, errExist, "Failed to count users in database") // Handle repository error.
 	}
 
 	// Return conflict error if the user not exists.
 	if !exist {
-		a.logger.Info().Msgf("User with email '%s' not exists", req.Email)                                                                                                  // Log user not exists.
+		logger.Info().Msgf("User with email '%s' not exists", req.Email) // Log user not exists.
+		a.audit.record(ctx, entity.AuditLogin, entity.AuditFailure, nil, map[string]any{"reason": "unknown email"})
+		metrics.Logins.WithLabelValues("failure", "unknown_email").Inc()
 		return nil, "", &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.NOT_FOUND, "Users with email '"+req.Email+"' not exists")}} // Return not found error.
 	}
 
@@ -67,51 +81,57 @@ func (a AuthUsecase) Login(ctx context.Context, req *request.Auth) (*response.St
 	// Retrieve the user by email.
 	users, errGet := a.handleGetByEmail(ctxGet, req.Email)
 	if errGet != nil {
-		a.logger.Error().Msgf("Failed to get by email users in database: %v", errGet) // Log error.
-		return nil, "", errGet                                                        // Return the error.
+		logger.Error().Msgf("Failed to get by email users in database: %v", errGet) // Log error.
+		return nil, "", errGet                                                      // Return the error.
 	}
 	// Match the request password with users database.
 	match, errMatch := a.hashing.Match(req.Password, users.Password)
 	if errMatch != nil {
-		a.logger.Error().Msgf("Failed to match by hashing users in database: %v", errMatch)                                                                                                                         // Log hashing error.
+		logger.Error().Msgf("Failed to match by hashing users in database: %v", errMatch)                                                                                                                           // Log hashing error.
 		return nil, "", &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, fmt.Sprintf("Failed to match by hashing users in database: %v", errMatch))}} // Return hashing error.
 	}
 
 	// Handle password mismatch.
 	if !match {
-		a.logger.Error().Msg("Password users not match")                                                                                                  // Log password mismatch.
+		logger.Error().Msg("Password users not match") // Log password mismatch.
+		a.audit.record(ctx, entity.AuditLogin, entity.AuditFailure, users, map[string]any{"reason": "wrong password"})
+		metrics.Logins.WithLabelValues("failure", "wrong_password").Inc()
 		return nil, "", &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.UNAUTHORIZE, "email or password wrong")}} // Return unauthorized error.
 	}
 
 	// Parse user ID.
 	userId, errParse := ksuid.Parse(users.ID)
 	if errParse != nil {
-		a.logger.Error().Msgf("Failed to parse user ID: %v", errParse)                                                                                                         // Log parsing error.
+		logger.Error().Msgf("Failed to parse user ID: %v", errParse)                                                                                                           // Log parsing error.
 		return nil, "", &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Parse ID : "+errParse.Error())}} // Return parsing error.
 	}
 
 	// Set token expiration time.
 	expiredAt := time.Now().Add(5 * time.Minute)
-	a.logger.Info().Msgf("Successfully authenticated user with email '%s'", req.Email) // Log successful authentication.
+	logger.Info().Msgf("Successfully authenticated user with email '%s'", req.Email) // Log successful authentication.
 
 	// Create access token.
 	payload := tokenconfig.NewTokenPayloadBuilder().WithEmail(users.Email).WithUserID(userId).WithExpiration(expiredAt).Build()
-	accessToken, errToken := a.handleCreateToken(a.secretKey.AccessToken, *payload)
+	accessToken, errToken := a.handleCreateToken(ctx, a.secretKey.Access(), *payload)
 	if errToken != nil {
-		a.logger.Error().Msgf("Failed to create access token: %v", errToken) // Log token creation error.
-		return nil, "", errToken                                             // Return token creation error.
+		logger.Error().Msgf("Failed to create access token: %v", errToken) // Log token creation error.
+		return nil, "", errToken                                           // Return token creation error.
 	}
 
 	// Set refresh token expiration time.
 	expiredRefreshToken := time.Now().Add(7 * 24 * time.Hour)
 	payloadRefreshToken := tokenconfig.NewTokenPayloadBuilder().WithEmail(users.Email).WithUserID(userId).WithExpiration(expiredRefreshToken).Build()
 	// Create refresh token.
-	refreshToken, errRefreshToken := a.handleCreateToken(a.secretKey.RefreshToken, *payloadRefreshToken)
+	refreshToken, errRefreshToken := a.handleCreateToken(ctx, a.secretKey.Refresh(), *payloadRefreshToken)
 	if errRefreshToken != nil {
-		a.logger.Error().Msgf("Failed to create access token: %v", errRefreshToken) // Log refresh token creation error.
-		return nil, "", errToken                                                    // Return refresh token creation error.
+		logger.Error().Msgf("Failed to create access token: %v", errRefreshToken) // Log refresh token creation error.
+		return nil, "", errToken                                                  // Return refresh token creation error.
 	}
 
+	// Record the login in the audit trail, the user authenticated itself so it is also the actor.
+	a.audit.record(withActor(ctx, users), entity.AuditLogin, entity.AuditSuccess, users, nil)
+	metrics.Logins.WithLabelValues("success", "").Inc()
+
 	// Return the generated tokens.
 	return &response.Standard{
 		Status: http.StatusOK,
@@ -125,13 +145,22 @@ func (a AuthUsecase) Login(ctx context.Context, req *request.Auth) (*response.St
 
 // Logout handles user logout logic.
 func (a AuthUsecase) Logout(ctx context.Context, token string) (*response.Standard, *response.StandardErrors) {
-	a.logger.Info().Msg("Logout method called") // Log the method call.
+	ctx, span := tracing.Start(ctx, "AuthUsecase.Logout")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("Logout method called") // Log the method call.
 
 	// Parse the token.
-	_, standardErrors := a.handleParseToken(a.secretKey.RefreshToken, token)
+	payload, standardErrors := a.handleParseToken(ctx, a.secretKey.RefreshKeys(), token)
 	if standardErrors != nil {
-		a.logger.Error().Msgf("Failed to parse token: %v", standardErrors) // Log token parsing error.
-		return nil, standardErrors                                         // Return the token parsing error.
+		logger.Error().Msgf("Failed to parse token: %v", standardErrors) // Log token parsing error.
+		return nil, standardErrors                                       // Return the token parsing error.
+	}
+
+	// Record the logout in the audit trail.
+	if payload != nil {
+		users := &entity.Users{ID: payload.ID.String(), Email: payload.Email}
+		a.audit.record(withActor(ctx, users), entity.AuditLogout, entity.AuditSuccess, users, nil)
 	}
 
 	// Return successful logout response.
@@ -144,23 +173,26 @@ func (a AuthUsecase) Logout(ctx context.Context, token string) (*response.Standa
 
 // RefreshToken handles the logic for refreshing a user's token.
 func (a AuthUsecase) RefreshToken(ctx context.Context, token string) (*response.Standard, *response.StandardErrors) {
-	a.logger.Info().Msg("RefreshToken method called") // Log the method call.
+	ctx, span := tracing.Start(ctx, "AuthUsecase.RefreshToken")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("RefreshToken method called") // Log the method call.
 
 	// Parse the token.
-	payload, standardErrors := a.handleParseToken(a.secretKey.RefreshToken, token)
+	payload, standardErrors := a.handleParseToken(ctx, a.secretKey.RefreshKeys(), token)
 	if standardErrors != nil {
-		a.logger.Error().Msgf("Failed to parse token: %v", standardErrors) // Log token parsing error.
-		return nil, standardErrors                                         // Return token parsing error.
+		logger.Error().Msgf("Failed to parse token: %v", standardErrors) // Log token parsing error.
+		return nil, standardErrors                                       // Return token parsing error.
 	}
 
 	// Set the new token expiration time.
 	expiredAt := time.Now().Add(5 * time.Minute)
 
 	// Create new access token.
-	accessToken, errToken := a.handleCreateToken(a.secretKey.AccessToken, *tokenconfig.NewTokenPayloadBuilder().WithEmail(payload.Email).WithUserID(payload.ID).WithExpiration(expiredAt).Build())
+	accessToken, errToken := a.handleCreateToken(ctx, a.secretKey.Access(), *tokenconfig.NewTokenPayloadBuilder().WithEmail(payload.Email).WithUserID(payload.ID).WithExpiration(expiredAt).Build())
 	if errToken != nil {
-		a.logger.Error().Msgf("Failed to create access token: %v", errToken) // Log token creation error.
-		return nil, errToken                                                 // Return token creation error.
+		logger.Error().Msgf("Failed to create access token: %v", errToken) // Log token creation error.
+		return nil, errToken                                               // Return token creation error.
 	}
 
 	// Return the new access token.
@@ -176,11 +208,14 @@ func (a AuthUsecase) RefreshToken(ctx context.Context, token string) (*response.
 
 // ForgotPassword handles the logic for the forgot password functionality.
 func (a AuthUsecase) ForgotPassword(ctx context.Context, req *request.ForgotPassword) (*response.Standard, *response.StandardErrors) {
-	a.logger.Info().Msg("ForgotPassword method called") // Log the method call.
+	ctx, span := tracing.Start(ctx, "AuthUsecase.ForgotPassword")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("ForgotPassword method called") // Log the method call.
 
 	// Validate the incoming request data
 	if errValidate := a.validator.Validate(req); errValidate != nil {
-		a.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
 
@@ -191,17 +226,17 @@ func (a AuthUsecase) ForgotPassword(ctx context.Context, req *request.ForgotPass
 	// Check if a user with the same email already exists.
 	exist, errExist := a.usersRepository.ExistByKeyValue(ctxCount, map[string]any{"email": req.Email})
 	if errExist != nil {
-		a.logger.Error().Msgf("Failed to count users in database: %v", errExist)             // Log counting error.
-		return nil, a.handleErrFromRepository(errExist, "Failed to count users in database") // Handle repository error.
+		logger.Error().Msgf("Failed to count users in database: %v", errExist)                    // Log counting error.
+		return nil, a.handleErrFromRepository(ctx, errExist, "Failed to count users in database") // Handle repository error.
 	}
 
 	// Return conflict error if the user not exists.
 	if !exist {
-		a.logger.Info().Msgf("User with email '%s' not exists", req.Email)                                                                                             // Log user not exists.
+		logger.Info().Msgf("User with email '%s' not exists", req.Email)                                                                                               // Log user not exists.
 		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.NOT_FOUND, "Users with email '"+req.Email+"' not exist")}} // Return not found error.
 	}
 
-	a.logger.Info().Msgf("Successfully forgot password for user '%s' with email", req.Email) // Log successful forgot password.
+	logger.Info().Msgf("Successfully forgot password for user '%s' with email", req.Email) // Log successful forgot password.
 	// TODO : Publish in Downstream. and response token
 
 	// Return success response.
@@ -215,14 +250,17 @@ func (a AuthUsecase) ForgotPassword(ctx context.Context, req *request.ForgotPass
 // ResetPassword handles the reset password process by validating the request, parsing the token,
 // and updating the user's password in the database.
 func (a AuthUsecase) ResetPassword(ctx context.Context, payload *tokenconfig.Payload, req *request.ResetPassword) (*response.Standard, *response.StandardErrors) {
+	ctx, span := tracing.Start(ctx, "AuthUsecase.ResetPassword")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
 	var errHash error
 
 	// Log the method call
-	a.logger.Info().Msg("ResetPassword method called")
+	logger.Info().Msg("ResetPassword method called")
 
 	// Validate the incoming request data
 	if errValidate := a.validator.Validate(req); errValidate != nil {
-		a.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
 
@@ -233,7 +271,7 @@ func (a AuthUsecase) ResetPassword(ctx context.Context, payload *tokenconfig.Pay
 	// Fetch the user by email from the database
 	users, errGet := a.handleGetByEmail(ctxGet, payload.Email)
 	if errGet != nil {
-		a.logger.Error().Msgf("Failed to get user by email in database: %v", errGet)
+		logger.Error().Msgf("Failed to get user by email in database: %v", errGet)
 		return nil, errGet
 	}
 
@@ -243,16 +281,28 @@ func (a AuthUsecase) ResetPassword(ctx context.Context, payload *tokenconfig.Pay
 
 	// Hash the new password
 	if users.Password, errHash = a.hashing.Create(users.Password); errHash != nil {
-		a.logger.Error().Msgf("Failed to hash password: %v", errHash)
+		logger.Error().Msgf("Failed to hash password: %v", errHash)
 		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Internal Server Error: "+errHash.Error())}}
 	}
 
 	// Update the user in the database
 	if errDB := a.usersRepository.Update(ctxUpdate, users, users.ID); errDB != nil {
-		a.logger.Error().Msgf("Failed to update user in database: %v", errDB)
-		return nil, a.handleErrFromRepository(errDB, "Failed to update user in database")
+		logger.Error().Msgf("Failed to update user in database: %v", errDB)
+		return nil, a.handleErrFromRepository(ctx, errDB, "Failed to update user in database")
+	}
+
+	// Invalidate the cached user, its version changed with the new password.
+	if a.cacheRepository != nil {
+		ctxCache, cancelCache := a.timeoutConfig.CreateCacheTimeout(ctx)
+		defer cancelCache()
+		if errCache := a.cacheRepository.InvalidateTags(ctxCache, usersTag(users.ID)); errCache != nil {
+			logger.Error().Msgf("Failed to invalidate cached user: %v", errCache)
+		}
 	}
 
+	// Record the password reset in the audit trail, the reset token identifies the user as the actor.
+	a.audit.record(withActor(ctx, users), entity.AuditPasswordReset, entity.AuditSuccess, users, map[string]any{"password": map[string]any{"changed": true}})
+
 	// Return success response
 	return &response.Standard{
 		Status: http.StatusOK,
@@ -263,7 +313,8 @@ func (a AuthUsecase) ResetPassword(ctx context.Context, payload *tokenconfig.Pay
 
 // handleGetByEmail retrieves a user by their email from the database.
 func (a AuthUsecase) handleGetByEmail(ctx context.Context, email string) (*entity.Users, *response.StandardErrors) {
-	a.logger.Info().Msg("handleGetByEmail method called")
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("handleGetByEmail method called")
 
 	// Set a timeout context for the database retrieval operation
 	ctxDB, cancel := a.timeoutConfig.CreateDatabaseTimeout(ctx)
@@ -273,29 +324,31 @@ func (a AuthUsecase) handleGetByEmail(ctx context.Context, email string) (*entit
 	user := entity.Users{}
 	err := a.usersRepository.GetByEmail(ctxDB, &user, email)
 	if err != nil {
-		a.logger.Error().Msgf("Failed to fetch user by email from database: %v", err)
-		return nil, a.handleErrFromRepository(err, "Failed to fetch user by email from database")
+		logger.Error().Msgf("Failed to fetch user by email from database: %v", err)
+		return nil, a.handleErrFromRepository(ctx, err, "Failed to fetch user by email from database")
 	}
 
 	// Log success message
-	a.logger.Info().Msgf("User with Email '%s' retrieved successfully", email)
+	logger.Info().Msgf("User with Email '%s' retrieved successfully", email)
 	return &user, nil
 }
 
 // handleErrFromRepository handles errors from the repository, including context.DeadlineExceeded, and logs them.
-func (a AuthUsecase) handleErrFromRepository(err error, message string) *response.StandardErrors {
+func (a AuthUsecase) handleErrFromRepository(ctx context.Context, err error, message string) *response.StandardErrors {
+	logger := loggerconfig.FromContext(ctx, a.logger)
 	if errors.Is(err, context.DeadlineExceeded) {
-		a.logger.Error().Msgf("%s: operation timed out: %v", message, err)
+		logger.Error().Msgf("%s: operation timed out: %v", message, err)
 		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.REQUEST_TIMEOUT, "Request timed out: "+err.Error())}}
 	}
 
-	a.logger.Error().Msgf("%s: %v", message, err)
+	logger.Error().Msgf("%s: %v", message, err)
 	return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, message+err.Error())}}
 }
 
 // handleCreateToken generates a new token based on the provided payload.
-func (a AuthUsecase) handleCreateToken(secretKey string, payload tokenconfig.Payload) (string, *response.StandardErrors) {
-	a.logger.Info().Msg("handleCreateToken method called")
+func (a AuthUsecase) handleCreateToken(ctx context.Context, secretKey string, payload tokenconfig.Payload) (string, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("handleCreateToken method called")
 
 	// Create a new token
 	createToken, err := a.token.CreateToken(secretKey, &payload)
@@ -307,14 +360,15 @@ func (a AuthUsecase) handleCreateToken(secretKey string, payload tokenconfig.Pay
 	return createToken, nil
 }
 
-// handleParseToken parses and verifies a token and returns its payload.
-func (a AuthUsecase) handleParseToken(secretKey string, token string) (*tokenconfig.Payload, *response.StandardErrors) {
-	a.logger.Info().Msg("handleParseToken method called")
+// handleParseToken parses and verifies a token with the current or the previous key of a rotation and returns its payload.
+func (a AuthUsecase) handleParseToken(ctx context.Context, secretKeys []string, token string) (*tokenconfig.Payload, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("handleParseToken method called")
 
 	// Parse the token
-	payload, errParse := a.token.VerifyToken(secretKey, token)
+	payload, errParse := a.token.VerifyTokenWithKeys(secretKeys, token)
 	if errParse != nil {
-		a.logger.Error().Msgf("Failed to parse token: %v", errParse)
+		logger.Error().Msgf("Failed to parse token: %v", errParse)
 
 		var tokenErr *tokenconfig.TokenError
 		if errors.As(errParse, &tokenErr) {
@@ -361,11 +415,14 @@ func (a AuthUsecase) handleParseToken(secretKey string, token string) (*tokencon
 
 // UpdateRole used for update or insert role in grouping
 func (a AuthUsecase) UpsertRole(ctx context.Context, req *request.UpdateRole) (*response.Standard, *response.StandardErrors) {
-	a.logger.Info().Msg("UpsertRole method called")
+	ctx, span := tracing.Start(ctx, "AuthUsecase.UpsertRole")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, a.logger)
+	logger.Info().Msg("UpsertRole method called")
 
 	// Validate the incoming request data
 	if errValidate := a.validator.Validate(req); errValidate != nil {
-		a.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
 
@@ -374,31 +431,60 @@ func (a AuthUsecase) UpsertRole(ctx context.Context, req *request.UpdateRole) (*
 	if errRole != nil {
 		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errRole.Error())}}
 	}
-	// check the role if greater than 0 remove it
-	if len(roles) > 0 {
-		for _, role := range roles {
-			removed, errRemoved := a.enforcer.DeleteRoleForUser(req.Email, role)
-			if errRemoved != nil {
-				return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errRemoved.Error())}}
+
+	// The audit trail refers to the user by its ID, a subject which is not a user has none.
+	target, errTarget := a.handleGetRoleTarget(ctx, req.Email)
+	if errTarget != nil {
+		return nil, errTarget
+	}
+
+	// Replace the roles and record the change in one unit of work, so the user never ends up without a role.
+	errWork := inUnitOfWork(ctx, a.unitOfWork, a.enforcer, a.watcher, a.logger, func(ctx context.Context) error {
+		errPolicy := withPolicyTx(ctx, a.enforcer, func(policy policyEditor) error {
+			// check the role if greater than 0 remove it
+			for _, role := range roles {
+				removed, errRemoved := policy.DeleteRoleForUser(req.Email, role)
+				if errRemoved != nil {
+					return errRemoved
+				}
+				if removed {
+					logger.Info().Msgf("Removed existing role %s from user %s", role, req.Email)
+				}
 			}
-			if removed {
-				a.logger.Info().Msgf("Removed existing role %s from user %s", role, req.Email)
+
+			// Add role in user
+			added, errAdd := policy.AddRoleForUser(req.Email, req.RoleName)
+			if added {
+				logger.Info().Msgf("Role %s added to user %s", req.RoleName, req.Email)
 			}
+			return errAdd
+		})
+		if errPolicy != nil {
+			return errPolicy
 		}
-	}
 
-	// Add role in user
-	added, errAdd := a.enforcer.AddGroupingPolicy(req.Email, req.RoleName)
-	if added {
-		a.logger.Info().Msgf("Role %s added to user %s", req.RoleName, req.Email)
+		// Record the role change in the audit trail.
+		return a.audit.recordInWork(ctx, entity.AuditRoleChange, entity.AuditSuccess, target, map[string]any{"roles": map[string]any{"from": roles, "to": []string{req.RoleName}}})
+	})
+	if errWork != nil {
+		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errWork.Error())}}
 	}
 
-	if errAdd != nil {
-		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errAdd.Error())}}
-	}
 	return &response.Standard{
 		Status: http.StatusOK,
 		Code:   "STATUS_OK",
 		Data:   map[string]any{"message": "Successfully Upsert Role"},
 	}, nil
 }
+
+// handleGetRoleTarget returns the user holding the email, soft-deleted or not, with its ID only, empty when no user holds it.
+func (a AuthUsecase) handleGetRoleTarget(ctx context.Context, email string) (*entity.Users, *response.StandardErrors) {
+	ctxDB, cancel := a.timeoutConfig.CreateDatabaseTimeout(ctx)
+	defer cancel()
+	var users entity.Users
+	err := a.usersRepository.GetByEmailWithDeleted(ctxDB, &users, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, a.handleErrFromRepository(ctx, err, "Failed to get user by email from database: ")
+	}
+	return &entity.Users{ID: users.ID}, nil
+}
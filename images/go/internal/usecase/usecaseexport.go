@@ -0,0 +1,284 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
+	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
+	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/mapper"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
+	"github.com/tirtahakimpambudhi/restful_api/internal/model/response"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// auditExportPageSize is the number of audit events read at once when exporting a user, the largest page of the audit API.
+const auditExportPageSize = 100
+
+// exportFile is a single JSON document inside a data export archive.
+type exportFile struct {
+	name    string
+	content any
+	records int
+}
+
+// Export gathers everything stored about a user into a ZIP of JSON files.
+// Large accounts are exported by a background job: the first call answers 202 Accepted
+// and a later call returns the archive once it is ready. An account is large when its roles,
+// permissions and audit events, the documents growing with its activity, exceed the sync limit.
+func (usersUsecase UsersUsecase) Export(ctx context.Context, id string) (*response.Export, *response.StandardErrors) {
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Export")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Export method called")
+
+	// Validate the ID format
+	if errValidateVars := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidateVars != nil {
+		logger.Error().Msgf("ID validation error: %v", errValidateVars)
+		return nil, &response.StandardErrors{Errors: errValidateVars}
+	}
+
+	// Check if the user exists by ID
+	if errCount := usersUsecase.handleCountById(ctx, id); errCount != nil {
+		logger.Error().Msgf("User existence check failed: %v", errCount)
+		return nil, errCount
+	}
+
+	// Serve the archive built by a previous background job
+	archive, errArchive := usersUsecase.handleGetArchive(ctx, id)
+	if errArchive != nil {
+		return nil, errArchive
+	}
+	if archive != nil {
+		return usersUsecase.exportReady(id, archive), nil
+	}
+
+	// Retrieve user details by ID
+	users, standardErrors := usersUsecase.handleGetById(ctx, id)
+	if standardErrors != nil {
+		logger.Error().Msgf("Failed to retrieve get user by ID: %v", standardErrors)
+		return nil, standardErrors
+	}
+
+	// Collect the export documents
+	files, errFiles := usersUsecase.handleExportFiles(ctx, users)
+	if errFiles != nil {
+		return nil, errFiles
+	}
+
+	// Small accounts are archived within the request
+	records := 0
+	for _, file := range files {
+		records += file.records
+	}
+	if !usersUsecase.exportConfig.Background(records) {
+		archive, errZip := zipExportFiles(files)
+		if errZip != nil {
+			logger.Error().Msgf("Failed to build export archive: %v", errZip)
+			return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errZip.Error())}}
+		}
+		logger.Info().Msgf("Export of user '%s' built with %d records", id, records)
+		return usersUsecase.exportReady(id, archive), nil
+	}
+
+	// Large accounts are archived by a background job, started once per user
+	ctxCache, cancel := usersUsecase.timeoutConfig.CreateCacheTimeout(ctx)
+	defer cancel()
+	started, errPending := usersUsecase.exportRepo.MarkPending(ctxCache, id, usersUsecase.timeoutConfig.Downstream())
+	if errPending != nil {
+		return nil, usersUsecase.handleErrFromRepository(ctx, errPending, "Failed to start export job: ")
+	}
+	if started {
+		logger.Info().Msgf("Export of user '%s' with %d records moved to background job", id, records)
+		// The job logs with the fields of the request starting it and continues its trace
+		meta := *request.MetaFromContext(ctx)
+		go usersUsecase.handleExportJob(trace.ContextWithSpanContext(request.WithMeta(context.Background(), &meta), span.SpanContext()), id, files)
+	}
+
+	return &response.Export{
+		Status: http.StatusAccepted,
+		Code:   "STATUS_ACCEPTED",
+		Data:   nil,
+		Meta: map[string]any{
+			"records": records,
+			"message": "The export is being generated, retry the request later to download it",
+		},
+	}, nil
+}
+
+// exportReady wraps a finished archive into the export response.
+func (usersUsecase UsersUsecase) exportReady(id string, archive []byte) *response.Export {
+	return &response.Export{
+		Status:   http.StatusOK,
+		Code:     "STATUS_OK",
+		FileName: fmt.Sprintf("user-%s-export.zip", id),
+		Archive:  archive,
+	}
+}
+
+// handleGetArchive retrieves the archive built by a previous background job, if any.
+func (usersUsecase UsersUsecase) handleGetArchive(ctx context.Context, id string) ([]byte, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	ctxCache, cancel := usersUsecase.timeoutConfig.CreateCacheTimeout(ctx)
+	defer cancel()
+
+	archive, err := usersUsecase.exportRepo.GetArchive(ctxCache, id)
+	if err != nil {
+		logger.Error().Msgf("Failed to fetch export archive: %v", err)
+		return nil, usersUsecase.handleErrFromRepository(ctx, err, "Failed to fetch export archive: ")
+	}
+	return archive, nil
+}
+
+// handleDeleteArchive drops the archive of a previous export once the user changed or was purged, stopping a running export from storing its own.
+// A failure is logged only, the archive then expires with the export TTL.
+func (usersUsecase UsersUsecase) handleDeleteArchive(ctx context.Context, id string) {
+	if usersUsecase.exportRepo == nil {
+		return
+	}
+	ctxCache, cancel := usersUsecase.timeoutConfig.CreateCacheTimeout(ctx)
+	defer cancel()
+	if err := usersUsecase.exportRepo.DeleteArchive(ctxCache, id); err != nil {
+		loggerconfig.FromContext(ctx, usersUsecase.logger).Error().Msgf("Failed to delete export archive of user '%s': %v", id, err)
+	}
+}
+
+// handleExportFiles collects every document of the user export.
+// Refresh tokens are stateless, so there are no server-side sessions to export.
+func (usersUsecase UsersUsecase) handleExportFiles(ctx context.Context, users *entity.Users) ([]exportFile, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msgf("handleExportFiles method called for user '%s'", users.ID)
+
+	roles, errRoles := usersUsecase.enforcer.GetImplicitRolesForUser(users.Email)
+	if errRoles != nil {
+		logger.Error().Msgf("Failed to fetch roles of user '%s': %v", users.ID, errRoles)
+		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errRoles.Error())}}
+	}
+	permissions, errPermissions := usersUsecase.enforcer.GetImplicitPermissionsForUser(users.Email)
+	if errPermissions != nil {
+		logger.Error().Msgf("Failed to fetch permissions of user '%s': %v", users.ID, errPermissions)
+		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errPermissions.Error())}}
+	}
+
+	events, errEvents := usersUsecase.handleGetAuditEvents(ctx, users.ID)
+	if errEvents != nil {
+		return nil, errEvents
+	}
+
+	return []exportFile{
+		{
+			// The password hash is a credential, not personal data, so it is left out.
+			name: "user.json",
+			content: map[string]any{
+				"id":         users.ID,
+				"created_at": users.CreatedAt,
+				"updated_at": users.UpdatedAt,
+				"deleted_at": users.DeletedAt,
+			},
+			records: 1,
+		},
+		{
+			name: "profile.json",
+			content: map[string]any{
+				"username": users.Username,
+				"email":    users.Email,
+			},
+			records: 1,
+		},
+		{
+			name: "roles.json",
+			content: map[string]any{
+				"roles":       roles,
+				"permissions": permissions,
+			},
+			records: len(roles) + len(permissions),
+		},
+		{
+			name:    "audit.json",
+			content: mapper.EntitiesAuditToResponses(events),
+			records: len(events),
+		},
+	}, nil
+}
+
+// handleGetAuditEvents retrieves every audit event targeting the user, newest first, page by page.
+func (usersUsecase UsersUsecase) handleGetAuditEvents(ctx context.Context, id string) ([]*entity.AuditEvents, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	events := []*entity.AuditEvents{}
+	if usersUsecase.audit.auditRepository == nil {
+		return events, nil
+	}
+
+	page := &request.AuditPage{Size: auditExportPageSize, TargetID: id}
+	for {
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		batch, err := usersUsecase.audit.auditRepository.GetAll(ctxDB, page)
+		cancel()
+		if err != nil {
+			logger.Error().Msgf("Failed to fetch audit events of user '%s': %v", id, err)
+			return nil, usersUsecase.handleErrFromRepository(ctx, err, "Failed to fetch audit events: ")
+		}
+		events = append(events, batch...)
+		if len(batch) < page.Size {
+			return events, nil
+		}
+		page = &request.AuditPage{Size: auditExportPageSize, TargetID: id, Before: batch[len(batch)-1].ID}
+	}
+}
+
+// handleExportJob builds the archive outside the request and stores it for later download,
+// parent holds the meta and the span of the request but is never canceled.
+func (usersUsecase UsersUsecase) handleExportJob(parent context.Context, id string, files []exportFile) {
+	parent, span := tracing.Start(parent, "UsersUsecase.handleExportJob")
+	defer span.End()
+	ctx, cancel := usersUsecase.timeoutConfig.CreateDownstreamTimeout(parent)
+	defer cancel()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	defer func() {
+		if err := usersUsecase.exportRepo.ClearPending(ctx, id); err != nil {
+			logger.Error().Msgf("Failed to clear pending export of user '%s': %v", id, err)
+		}
+	}()
+
+	start := time.Now()
+	archive, err := zipExportFiles(files)
+	if err != nil {
+		logger.Error().Msgf("Failed to build export archive of user '%s': %v", id, err)
+		return
+	}
+	if err := usersUsecase.exportRepo.SetArchive(ctx, id, archive, usersUsecase.exportConfig.TTL()); err != nil {
+		logger.Error().Msgf("Failed to store export archive of user '%s': %v", id, err)
+		return
+	}
+	logger.Info().Msgf("Export of user '%s' generated in %s", id, time.Since(start))
+}
+
+// zipExportFiles writes every export document as an indented JSON file into a ZIP archive.
+func zipExportFiles(files []exportFile) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer := zip.NewWriter(buffer)
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.content, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		entry, err := writer.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := entry.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
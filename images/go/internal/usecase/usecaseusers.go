@@ -4,14 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	loggerconfig "github.com/tirtahakimpambudhi/restful_api/internal/configs/logger"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/mapper"
+	"gorm.io/gorm"
 	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
 	"github.com/phuslu/log"
 	"github.com/segmentio/ksuid"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/export"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/hash"
 	"github.com/tirtahakimpambudhi/restful_api/internal/configs/timeout"
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs/tracing"
 	"github.com/tirtahakimpambudhi/restful_api/internal/entity"
 	errorshandler "github.com/tirtahakimpambudhi/restful_api/internal/errors"
 	"github.com/tirtahakimpambudhi/restful_api/internal/model/request"
@@ -29,22 +38,40 @@ type UsersUsecase struct {
 	validator       *validation.Validator
 	hashing         *hash.Argon2
 	logger          *log.Logger
+	enforcer        *casbin.Enforcer
+	watcher         persist.Watcher
+	exportRepo      repository.ExportRepository
+	exportConfig    *export.Config
+	unitOfWork      repository.UnitOfWork
+	audit           auditRecorder
+}
+
+// usersListTag is the cache tag of every list page of users.
+const usersListTag = "users:tag:all"
+
+// usersIDKey returns the cache key of a single user.
+func usersIDKey(id string) string {
+	return "users:id:" + id
+}
+
+// usersTag returns the cache tag of a user, referencing the user itself and every list page containing it.
+func usersTag(id string) string {
+	return "users:tag:id:" + id
 }
 
 // List retrieves a list of users based on the provided request parameters.
 func (usersUsecase UsersUsecase) List(ctx context.Context, request *request.Page) (*response.LinksAble, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("List method called")
+	ctx, span := tracing.Start(ctx, "UsersUsecase.List")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("List method called")
 
 	// Validate the incoming request parameters.
 	if errValidate := usersUsecase.validator.Validate(request); errValidate != nil {
-		usersUsecase.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
-	usersUsecase.logger.Info().Msg("Request validated successfully")
-
-	// Set a timeout context for cache operations.
-	ctxTimeout, cancel := usersUsecase.timeoutConfig.CreateCacheTimeout(ctx)
-	defer cancel() // Ensure context cancellation after function completes.
+	logger.Info().Msg("Request validated successfully")
 
 	// Generate a cache key based on request parameters.
 	key := fmt.Sprintf("users:all:size[%d]", request.Size)
@@ -54,40 +81,36 @@ func (usersUsecase UsersUsecase) List(ctx context.Context, request *request.Page
 	if request.After != "" {
 		key += fmt.Sprintf(":after[%s]", request.After)
 	}
-	usersUsecase.logger.Info().Msgf("Cache key generated: %s", key)
+	logger.Info().Msgf("Cache key generated: %s", key)
 
-	// Attempt to retrieve the users list from the cache.
-	users, errCache := usersUsecase.cacheRepository.GetFromCache(ctxTimeout, key)
-	usersUsecase.logger.Info().Msgf("Errors: %v", errCache)
-	if errCache != nil {
-		usersUsecase.logger.Error().Msgf("Failed to fetch users from cache: %v", errCache)
-		return nil, usersUsecase.handleErrFromRepository(errCache, "Failed to fetch users from cache: ")
-	}
-	if users == nil {
-		usersUsecase.logger.Info().Msg("Cache miss, fetching from database")
+	// The load may outlive the request, it reads a copy of the cursors which alias the buffers of the request.
+	page := *request
+	page.Before, page.After = strings.Clone(request.Before), strings.Clone(request.After)
 
-		// If cache miss, retrieve users from the database.
+	// Retrieve the users list from the cache, concurrent misses share a single database read.
+	users, errFetch := usersUsecase.cacheRepository.Fetch(ctx, key, func(ctx context.Context) ([]*entity.Users, []string, error) {
+		logger.Info().Msg("Cache miss, fetching from database")
+
+		// Set a timeout context for database operations.
 		ctxTimeoutDB, cancelDB := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
 		defer cancelDB()
 
-		usersFromDB, errDB := usersUsecase.usersRepository.GetAll(ctxTimeoutDB, request)
+		usersFromDB, errDB := usersUsecase.usersRepository.GetAll(ctxTimeoutDB, &page)
 		if errDB != nil {
-			usersUsecase.logger.Error().Msgf("Failed to fetch users from database: %v", errDB)
-			return nil, usersUsecase.handleErrFromRepository(errDB, "Failed to fetch users from database: ")
+			logger.Error().Msgf("Failed to fetch users from database: %v", errDB)
+			return nil, nil, usersUsecase.handleErrFromRepository(ctx, errDB, "Failed to fetch users from database: ")
 		}
 
-		// Set a timeout context for cache operations.
-		ctxTimeoutSet, cancelSet := usersUsecase.timeoutConfig.CreateCacheTimeout(ctx)
-		defer cancelSet() // Ensure context cancellation after function completes.
-
-		// Cache the retrieved users data.
-		errCacheSet := usersUsecase.cacheRepository.SetToCache(ctxTimeoutSet, key, usersFromDB)
-		if errCacheSet != nil {
-			// Log the error if caching fails but continue processing.
-			usersUsecase.logger.Error().Msgf("Failed to set users to cache: %v", errCacheSet)
+		// Cache the retrieved users data, registered in the tag of every user on the page.
+		tags := []string{usersListTag}
+		for _, user := range usersFromDB {
+			tags = append(tags, usersTag(user.ID))
 		}
-		// Use data retrieved from the database.
-		users = usersFromDB
+		return usersFromDB, tags, nil
+	})
+	if errFetch != nil {
+		logger.Error().Msgf("Failed to fetch users: %v", errFetch)
+		return nil, usersUsecase.handleErrFromFetch(ctx, errFetch, "Failed to fetch users from cache: ")
 	}
 
 	// Set a timeout context for database count operation.
@@ -97,8 +120,8 @@ func (usersUsecase UsersUsecase) List(ctx context.Context, request *request.Page
 	// Count the total number of users in the database.
 	totalData, errCount := usersUsecase.usersRepository.Count(ctxTimeoutDB)
 	if errCount != nil {
-		usersUsecase.logger.Error().Msgf("Failed to count total number of users: %v", errCount)
-		return nil, usersUsecase.handleErrFromRepository(errCount, "Failed to count total number of users: ")
+		logger.Error().Msgf("Failed to count total number of users: %v", errCount)
+		return nil, usersUsecase.handleErrFromRepository(ctx, errCount, "Failed to count total number of users: ")
 	}
 
 	// Calculate the total number of pages.
@@ -119,22 +142,25 @@ func (usersUsecase UsersUsecase) List(ctx context.Context, request *request.Page
 		},
 	}
 
-	usersUsecase.logger.Info().Msg("List method completed successfully")
+	logger.Info().Msg("List method completed successfully")
 	// Return the response data and any encountered errors.
 	return responseData, nil
 }
 
 // Create handles the creation of a new user and associated operations.
 func (usersUsecase UsersUsecase) Create(ctx context.Context, request *request.User) (*response.Standard, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("Create method called")
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Create")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Create method called")
 
 	var errHash error
 	// Validate the incoming request data.
 	if errValidate := usersUsecase.validator.Validate(request); errValidate != nil {
-		usersUsecase.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
-	usersUsecase.logger.Info().Msg("Request validated successfully")
+	logger.Info().Msg("Request validated successfully")
 
 	// Set a timeout context for database existence check.
 	ctxCount, cancelCount := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
@@ -143,49 +169,71 @@ func (usersUsecase UsersUsecase) Create(ctx context.Context, request *request.Us
 	// Check if a user with the same email already exists.
 	exist, errExist := usersUsecase.usersRepository.ExistByKeyValue(ctxCount, map[string]any{"email": request.Email})
 	if errExist != nil {
-		usersUsecase.logger.Error().Msgf("Failed to count users in database: %v", errExist)
-		return nil, usersUsecase.handleErrFromRepository(errExist, "Failed to count users in database")
+		logger.Error().Msgf("Failed to count users in database: %v", errExist)
+		return nil, usersUsecase.handleErrFromRepository(ctx, errExist, "Failed to count users in database")
 	}
 	// Return conflict error if the user already exists.
 	if exist {
-		usersUsecase.logger.Info().Msgf("User with email '%s' already exists", request.Email)
+		logger.Info().Msgf("User with email '%s' already exists", request.Email)
 		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.CONFLICT, "Users with email '"+request.Email+"' is exist")}}
 	}
 
 	// Generate a unique ID for the new user.
 	id := ksuid.New().String()
-	usersUsecase.logger.Info().Msgf("Generated new user ID: %s", id)
+	logger.Info().Msgf("Generated new user ID: %s", id)
 
 	// Hash the user's password.
 	if request.Password, errHash = usersUsecase.hashing.Create(request.Password); errHash != nil {
-		usersUsecase.logger.Error().Msgf("Failed to hash password: %v", errHash)
+		logger.Error().Msgf("Failed to hash password: %v", errHash)
 		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Internal Server Error : "+errHash.Error())}}
 	}
-	// Set a timeout context for database creation operation.
-	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
-	defer cancel()
+	// Save the new user and record its creation in one unit of work, so the user is never saved without its audit event.
+	var users *entity.Users
+	saved := false
+	errWork := inUnitOfWork(ctx, usersUsecase.unitOfWork, usersUsecase.enforcer, usersUsecase.watcher, usersUsecase.logger, func(ctx context.Context) error {
+		// Set a timeout context for database creation operation.
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		defer cancel()
+
+		// Save the new user to the database.
+		if errDB := usersUsecase.usersRepository.Create(ctxDB, mapper.RequestUserToEntity(id, *request)); errDB != nil {
+			logger.Error().Msgf("Failed to save in database: %v", errDB)
+			return usersUsecase.handleErrFromRepository(ctx, errDB, "Failed to save in database")
+		}
+		saved = true
 
-	// Save the new user to the database.
-	if errDB := usersUsecase.usersRepository.Create(ctxDB, mapper.RequestUserToEntity(id, *request)); errDB != nil {
-		usersUsecase.logger.Error().Msgf("Failed to save in database: %v", errDB)
-		return nil, usersUsecase.handleErrFromRepository(errDB, "Failed to save in database")
-	}
+		// Retrieve the created user by ID for the response.
+		created, standardErrors := usersUsecase.handleGetById(ctx, id)
+		if standardErrors != nil {
+			logger.Error().Msgf("Failed to retrieve created user by ID: %v", standardErrors)
+			return standardErrors
+		}
+		users = created
 
-	// Invalidate related cache entries after database changes.
-	if errCache := usersUsecase.handleDeleteCache(ctx, "users:*"); errCache != nil {
-		usersUsecase.logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
-		return nil, errCache
-	}
+		// Record the creation in the audit trail.
+		if errAudit := usersUsecase.audit.recordInWork(ctx, entity.AuditUserCreate, entity.AuditSuccess, users, auditChanges(nil, users)); errAudit != nil {
+			logger.Error().Msgf("Failed to record the creation in the audit trail: %v", errAudit)
+			return usersUsecase.handleErrFromRepository(ctx, errAudit, "Failed to record the creation in the audit trail")
+		}
+		return nil
+	})
 
-	// Retrieve the created user by ID for the response.
-	users, standardErrors := usersUsecase.handleGetById(ctx, id)
-	if standardErrors != nil {
-		usersUsecase.logger.Error().Msgf("Failed to retrieve created user by ID: %v", standardErrors)
-		return nil, standardErrors
+	// Invalidate related cache entries once the changes are committed,
+	// also when a step after the save failed since without a unit of work the user is saved anyway.
+	var errCache *response.StandardErrors
+	if saved {
+		errCache = usersUsecase.handleInvalidateCache(ctx, usersListTag)
+	}
+	if errWork != nil {
+		return nil, usersUsecase.handleErrFromFetch(ctx, errWork, "Failed to save in database: ")
+	}
+	if errCache != nil {
+		logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
+		return nil, errCache
 	}
 
 	// Return a successful response with the created user data.
-	usersUsecase.logger.Info().Msg("User created successfully")
+	logger.Info().Msg("User created successfully")
 	return &response.Standard{
 		Status: http.StatusCreated,
 		Code:   "STATUS_CREATED",
@@ -195,165 +243,208 @@ func (usersUsecase UsersUsecase) Create(ctx context.Context, request *request.Us
 
 // Update handles updating an existing user's information.
 func (usersUsecase UsersUsecase) Update(ctx context.Context, request *request.User, id string) (*response.Standard, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("Update method called")
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Update")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Update method called")
 
 	var errHash error
 
 	// Validate the user ID and request data.
 	if errValidateVars := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidateVars != nil {
-		usersUsecase.logger.Error().Msgf("ID validation error: %v", errValidateVars)
+		logger.Error().Msgf("ID validation error: %v", errValidateVars)
 		return nil, &response.StandardErrors{Errors: errValidateVars}
 	}
 	if errValidate := usersUsecase.validator.Validate(request); errValidate != nil {
-		usersUsecase.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
-	usersUsecase.logger.Info().Msg("Request validated successfully")
+	logger.Info().Msg("Request validated successfully")
 
 	// Check if the user exists by ID.
 	if errCount := usersUsecase.handleCountById(ctx, id); errCount != nil {
-		usersUsecase.logger.Error().Msgf("User existence check failed: %v", errCount)
+		logger.Error().Msgf("User existence check failed: %v", errCount)
 		return nil, errCount
 	}
 
+	// Retrieve the current state of the user for the precondition check and the audit trail.
+	before, errBefore := usersUsecase.handleGetById(ctx, id)
+	if errBefore != nil {
+		logger.Error().Msgf("Failed to retrieve user by ID before update: %v", errBefore)
+		return nil, errBefore
+	}
+
+	// Compare the If-Match header against the current version of the user.
+	version, errPrecondition := usersUsecase.handlePrecondition(ctx, request.IfMatch, before)
+	if errPrecondition != nil {
+		logger.Error().Msgf("Precondition check failed: %v", errPrecondition)
+		return nil, errPrecondition
+	}
+
 	// Hash the user's password if provided.
 	if request.Password != "" {
 		if request.Password, errHash = usersUsecase.hashing.Create(request.Password); errHash != nil {
-			usersUsecase.logger.Error().Msgf("Failed to hash password: %v", errHash)
+			logger.Error().Msgf("Failed to hash password: %v", errHash)
 			return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Internal Server Error : "+errHash.Error())}}
 		}
 	}
 
-	// Set a timeout context for database update operation.
-	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
-	defer cancel()
-
-	// Update the user in the database.
-	if errDB := usersUsecase.usersRepository.Update(ctxDB, mapper.RequestUserToEntity(id, *request), id); errDB != nil {
-		usersUsecase.logger.Error().Msgf("Failed to update in database: %v", errDB)
-		return nil, usersUsecase.handleErrFromRepository(errDB, "Failed to update in database")
-	}
-
-	// Invalidate related cache entries after database changes.
-	if errCache := usersUsecase.handleDeleteCache(ctx, "users:*"); errCache != nil {
-		usersUsecase.logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
-		return nil, errCache
-	}
-
-	// Retrieve the updated user by ID for the response.
-	users, standardErrors := usersUsecase.handleGetById(ctx, id)
-	if standardErrors != nil {
-		usersUsecase.logger.Error().Msgf("Failed to retrieve updated user by ID: %v", standardErrors)
-		return nil, standardErrors
+	// Update the user, only when it still has the version the client expects.
+	updated := mapper.RequestUserToEntity(id, *request)
+	updated.Version = version
+	users, errUpdate := usersUsecase.handleUpdate(ctx, id, before, updated)
+	if errUpdate != nil {
+		return nil, errUpdate
 	}
 
 	// Return a successful response with the updated user data.
-	usersUsecase.logger.Info().Msg("User updated successfully")
+	logger.Info().Msg("User updated successfully")
 	return &response.Standard{
 		Status: http.StatusOK,
 		Code:   "STATUS_OK",
 		Data:   mapper.EntityUserToResponse(users),
+		ETag:   userETag(users),
 	}, nil
 }
 
 // Edit handles editing an existing user's information.
 func (usersUsecase UsersUsecase) Edit(ctx context.Context, request *request.UserEdit, id string) (*response.Standard, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("Update method called")
-
-	var errHash error
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Edit")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Update method called")
 
 	// Validate the user ID and request data.
 	if errValidateVars := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidateVars != nil {
-		usersUsecase.logger.Error().Msgf("ID validation error: %v", errValidateVars)
+		logger.Error().Msgf("ID validation error: %v", errValidateVars)
 		return nil, &response.StandardErrors{Errors: errValidateVars}
 	}
 	if errValidate := usersUsecase.validator.Validate(request); errValidate != nil {
-		usersUsecase.logger.Error().Msgf("Validation error: %v", errValidate)
+		logger.Error().Msgf("Validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
-	usersUsecase.logger.Info().Msg("Request validated successfully")
+	logger.Info().Msg("Request validated successfully")
 
 	// Check if the user exists by ID.
 	if errCount := usersUsecase.handleCountById(ctx, id); errCount != nil {
-		usersUsecase.logger.Error().Msgf("User existence check failed: %v", errCount)
+		logger.Error().Msgf("User existence check failed: %v", errCount)
 		return nil, errCount
 	}
 
-	// Hash the user's password if provided.
-	if request.Password != "" {
-		if request.Password, errHash = usersUsecase.hashing.Create(request.Password); errHash != nil {
-			usersUsecase.logger.Error().Msgf("Failed to hash password: %v", errHash)
-			return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Internal Server Error : "+errHash.Error())}}
-		}
+	// Retrieve the current state of the user for the precondition check and the audit trail.
+	before, errBefore := usersUsecase.handleGetById(ctx, id)
+	if errBefore != nil {
+		logger.Error().Msgf("Failed to retrieve user by ID before update: %v", errBefore)
+		return nil, errBefore
 	}
 
-	// Set a timeout context for database update operation.
-	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
-	defer cancel()
+	// Compare the If-Match header against the current version of the user.
+	version, errPrecondition := usersUsecase.handlePrecondition(ctx, request.IfMatch, before)
+	if errPrecondition != nil {
+		logger.Error().Msgf("Precondition check failed: %v", errPrecondition)
+		return nil, errPrecondition
+	}
 
-	// Update the user in the database.
-	if errDB := usersUsecase.usersRepository.Update(ctxDB, mapper.RequestUserEditToEntity(id, *request), id); errDB != nil {
-		usersUsecase.logger.Error().Msgf("Failed to update in database: %v", errDB)
-		return nil, usersUsecase.handleErrFromRepository(errDB, "Failed to update in database")
+	// Apply the JSON Patch operations to the stored user, then validate the fields they touched.
+	if len(request.Operations) > 0 {
+		if errPatch := applyUserPatch(before, request); errPatch != nil {
+			logger.Error().Msgf("Failed to apply patch: %v", errPatch)
+			return nil, errPatch
+		}
+		if errValidate := usersUsecase.validator.Validate(request); errValidate != nil {
+			logger.Error().Msgf("Validation error after patch: %v", errValidate)
+			return nil, &response.StandardErrors{Errors: errValidate}
+		}
 	}
 
-	// Invalidate related cache entries after database changes.
-	if errCache := usersUsecase.handleDeleteCache(ctx, "users:*"); errCache != nil {
-		usersUsecase.logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
-		return nil, errCache
+	// Hash the user's password if provided.
+	if request.Password != nil {
+		hashed, errHash := usersUsecase.hashing.Create(*request.Password)
+		if errHash != nil {
+			logger.Error().Msgf("Failed to hash password: %v", errHash)
+			return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Internal Server Error : "+errHash.Error())}}
+		}
+		request.Password = &hashed
 	}
 
-	// Retrieve the updated user by ID for the response.
-	users, standardErrors := usersUsecase.handleGetById(ctx, id)
-	if standardErrors != nil {
-		usersUsecase.logger.Error().Msgf("Failed to retrieve updated user by ID: %v", standardErrors)
-		return nil, standardErrors
+	// Update the user with the edit applied to the stored user, only when it still has the version the client expects.
+	updated := mapper.RequestUserEditToEntity(*before, *request)
+	updated.Version = version
+	users, errUpdate := usersUsecase.handleUpdate(ctx, id, before, updated)
+	if errUpdate != nil {
+		return nil, errUpdate
 	}
 
 	// Return a successful response with the updated user data.
-	usersUsecase.logger.Info().Msg("User updated successfully")
+	logger.Info().Msg("User updated successfully")
 	return &response.Standard{
 		Status: http.StatusOK,
 		Code:   "STATUS_OK",
 		Data:   mapper.EntityUserToResponse(users),
+		ETag:   userETag(users),
 	}, nil
 }
 
 // Delete removes a user by their ID.
 func (usersUsecase UsersUsecase) Delete(ctx context.Context, id string) (*response.Standard, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("Delete method called")
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Delete")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Delete method called")
 
 	// Validate the user ID.
 	if errValidate := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidate != nil {
-		usersUsecase.logger.Error().Msgf("ID validation error: %v", errValidate)
+		logger.Error().Msgf("ID validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
-	usersUsecase.logger.Info().Msg("User ID validated successfully")
+	logger.Info().Msg("User ID validated successfully")
 
-	// Check if the user exists by ID.
-	if errCount := usersUsecase.handleCountById(ctx, id); errCount != nil {
-		usersUsecase.logger.Error().Msgf("User existence check failed: %v", errCount)
-		return nil, errCount
+	// Load the user, checking it exists and keeping it for the audit trail.
+	users, errGet := usersUsecase.handleGetById(ctx, id)
+	if errGet != nil {
+		logger.Error().Msgf("User existence check failed: %v", errGet)
+		return nil, errGet
 	}
 
-	// Set a timeout context for database deletion operation.
-	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
-	defer cancel()
+	// Delete the user and record the deletion in one unit of work, so the user is never deleted without its audit event.
+	saved := false
+	errWork := inUnitOfWork(ctx, usersUsecase.unitOfWork, usersUsecase.enforcer, usersUsecase.watcher, usersUsecase.logger, func(ctx context.Context) error {
+		// Set a timeout context for database deletion operation.
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		defer cancel()
 
-	// Delete the user from the database.
-	if errDB := usersUsecase.usersRepository.Delete(ctxDB, id); errDB != nil {
-		usersUsecase.logger.Error().Msgf("Failed to delete from database: %v", errDB)
-		return nil, usersUsecase.handleErrFromRepository(errDB, "Failed to delete from database")
-	}
+		// Delete the user from the database.
+		if errDB := usersUsecase.usersRepository.Delete(ctxDB, id); errDB != nil {
+			logger.Error().Msgf("Failed to delete from database: %v", errDB)
+			return usersUsecase.handleErrFromRepository(ctx, errDB, "Failed to delete from database")
+		}
+		saved = true
 
-	// Invalidate related cache entries after database changes.
-	if errCache := usersUsecase.handleDeleteCache(ctx, "users:*"); errCache != nil {
-		usersUsecase.logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
+		// Record the deletion in the audit trail.
+		if errAudit := usersUsecase.audit.recordInWork(ctx, entity.AuditUserDelete, entity.AuditSuccess, users, nil); errAudit != nil {
+			logger.Error().Msgf("Failed to record the deletion in the audit trail: %v", errAudit)
+			return usersUsecase.handleErrFromRepository(ctx, errAudit, "Failed to record the deletion in the audit trail")
+		}
+		return nil
+	})
+
+	// Drop the export archive, a deleted user is not exported, and invalidate related cache entries once the changes are committed,
+	// also when a step after the deletion failed since without a unit of work the user is deleted anyway.
+	var errCache *response.StandardErrors
+	if saved {
+		usersUsecase.handleDeleteArchive(ctx, id)
+		errCache = usersUsecase.handleInvalidateCache(ctx, usersTag(id), usersListTag)
+	}
+	if errWork != nil {
+		return nil, usersUsecase.handleErrFromFetch(ctx, errWork, "Failed to delete from database: ")
+	}
+	if errCache != nil {
+		logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
 		return nil, errCache
 	}
 
 	// Return a successful response indicating the user was deleted.
-	usersUsecase.logger.Info().Msg("User deleted successfully")
+	logger.Info().Msg("User deleted successfully")
 	return &response.Standard{
 		Status: http.StatusOK,
 		Code:   "STATUS_OK",
@@ -362,75 +453,185 @@ func (usersUsecase UsersUsecase) Delete(ctx context.Context, id string) (*respon
 
 // Restore restore a user by their ID.
 func (usersUsecase UsersUsecase) Restore(ctx context.Context, id string) (*response.Standard, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("Restore method called")
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Restore")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Restore method called")
 
 	// Validate the user ID.
 	if errValidate := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidate != nil {
-		usersUsecase.logger.Error().Msgf("ID validation error: %v", errValidate)
+		logger.Error().Msgf("ID validation error: %v", errValidate)
 		return nil, &response.StandardErrors{Errors: errValidate}
 	}
-	usersUsecase.logger.Info().Msg("User ID validated successfully")
+	logger.Info().Msg("User ID validated successfully")
 
-	// Check if the user exists by ID
-	// Set a timeout context for database count operation.
-	ctxDBCount, cancelCount := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
-	defer cancelCount()
-
-	count, err := usersUsecase.usersRepository.CountById(ctxDBCount, id)
-	if err != nil {
-		usersUsecase.logger.Error().Msgf("Failed to count user by ID: %v", err)
-		return nil, usersUsecase.handleErrFromRepository(err, "Failed to count user by ID: ")
+	// Load the user including the deleted ones, keeping it for the audit trail.
+	users, errGet := usersUsecase.handleGetByIdWithDeleted(ctx, id)
+	if errGet != nil {
+		logger.Error().Msgf("User existence check failed: %v", errGet)
+		return nil, errGet
 	}
-	if count == 1 {
-		usersUsecase.logger.Info().Msgf("User with ID '%s' is exist cannot restore", id)
+	if users.DeletedAt == 0 {
+		logger.Info().Msgf("User with ID '%s' is exist cannot restore", id)
 		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.NOT_FOUND, "User with ID '"+id+"' not found")}}
 	}
 
-	// Set a timeout context for database deletion operation.
-	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
-	defer cancel()
+	// Restore the user and record the restoration in one unit of work, so the user is never restored without its audit event.
+	saved := false
+	errWork := inUnitOfWork(ctx, usersUsecase.unitOfWork, usersUsecase.enforcer, usersUsecase.watcher, usersUsecase.logger, func(ctx context.Context) error {
+		// Set a timeout context for database restoration operation.
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		defer cancel()
 
-	// Delete the user from the database.
-	if errDB := usersUsecase.usersRepository.Restore(ctxDB, id); errDB != nil {
-		usersUsecase.logger.Error().Msgf("Failed to delete from database: %v", errDB)
-		return nil, usersUsecase.handleErrFromRepository(errDB, "Failed to delete from database")
-	}
+		// Restore the user in the database.
+		if errDB := usersUsecase.usersRepository.Restore(ctxDB, id); errDB != nil {
+			logger.Error().Msgf("Failed to delete from database: %v", errDB)
+			return usersUsecase.handleErrFromRepository(ctx, errDB, "Failed to delete from database")
+		}
+		saved = true
 
-	// Invalidate related cache entries after database changes.
-	if errCache := usersUsecase.handleDeleteCache(ctx, "users:*"); errCache != nil {
-		usersUsecase.logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
+		// Record the restoration in the audit trail.
+		if errAudit := usersUsecase.audit.recordInWork(ctx, entity.AuditUserRestore, entity.AuditSuccess, users, nil); errAudit != nil {
+			logger.Error().Msgf("Failed to record the restoration in the audit trail: %v", errAudit)
+			return usersUsecase.handleErrFromRepository(ctx, errAudit, "Failed to record the restoration in the audit trail")
+		}
+		return nil
+	})
+
+	// Invalidate related cache entries once the changes are committed,
+	// also when a step after the restoration failed since without a unit of work the user is restored anyway.
+	var errCache *response.StandardErrors
+	if saved {
+		errCache = usersUsecase.handleInvalidateCache(ctx, usersTag(id), usersListTag)
+	}
+	if errWork != nil {
+		return nil, usersUsecase.handleErrFromFetch(ctx, errWork, "Failed to delete from database: ")
+	}
+	if errCache != nil {
+		logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
 		return nil, errCache
 	}
 
 	// Return a successful response indicating the user was deleted.
-	usersUsecase.logger.Info().Msg("User deleted successfully")
+	logger.Info().Msg("User deleted successfully")
+	return &response.Standard{
+		Status: http.StatusOK,
+		Code:   "STATUS_OK",
+	}, nil
+}
+
+// Purge permanently removes a user by their ID, including soft-deleted users.
+func (usersUsecase UsersUsecase) Purge(ctx context.Context, id string) (*response.Standard, *response.StandardErrors) {
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Purge")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Purge method called")
+
+	// Validate the user ID.
+	if errValidate := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidate != nil {
+		logger.Error().Msgf("ID validation error: %v", errValidate)
+		return nil, &response.StandardErrors{Errors: errValidate}
+	}
+	logger.Info().Msg("User ID validated successfully")
+
+	// Retrieve the user whether or not it was soft-deleted, the email is needed to drop its roles.
+	users, standardErrors := usersUsecase.handleGetByIdWithDeleted(ctx, id)
+	if standardErrors != nil {
+		logger.Error().Msgf("Failed to retrieve user by ID: %v", standardErrors)
+		return nil, standardErrors
+	}
+
+	// Purge the user together with its roles and cache entries.
+	if errPurge := usersUsecase.handlePurge(ctx, users); errPurge != nil {
+		logger.Error().Msgf("Failed to purge user: %v", errPurge)
+		return nil, errPurge
+	}
+
+	// Return a successful response indicating the user was purged.
+	logger.Info().Msg("User purged successfully")
 	return &response.Standard{
 		Status: http.StatusOK,
 		Code:   "STATUS_OK",
 	}, nil
 }
 
+// PurgeExpired permanently removes users soft-deleted before the given time, batchSize users at a time.
+// It returns the number of purged users.
+func (usersUsecase UsersUsecase) PurgeExpired(ctx context.Context, before time.Time, batchSize int) (int, *response.StandardErrors) {
+	ctx, span := tracing.Start(ctx, "UsersUsecase.PurgeExpired")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msgf("PurgeExpired method called for users deleted before %s", before.Format(time.RFC3339))
+
+	purged := 0
+	for {
+		// Set a timeout context for database retrieval operation.
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		users, err := usersUsecase.usersRepository.GetDeletedBefore(ctxDB, before.UnixMilli(), batchSize)
+		cancel()
+		if err != nil {
+			logger.Error().Msgf("Failed to fetch expired users: %v", err)
+			return purged, usersUsecase.handleErrFromRepository(ctx, err, "Failed to fetch expired users: ")
+		}
+
+		failed := false
+		for _, user := range users {
+			if errPurge := usersUsecase.handlePurge(ctx, user); errPurge != nil {
+				// Keep going with the rest of the batch, the failed user is retried on the next run.
+				logger.Error().Msgf("Failed to purge user '%s': %v", user.ID, errPurge)
+				failed = true
+				continue
+			}
+			purged++
+			logger.Info().Msgf("Purged user '%s' soft-deleted at %d", user.ID, int64(user.DeletedAt))
+		}
+
+		// Stop on the last batch, or when a failure would make the next batch return the same users.
+		if len(users) < batchSize || failed {
+			break
+		}
+	}
+
+	logger.Info().Msgf("PurgeExpired completed, %d users purged", purged)
+	return purged, nil
+}
+
 // Get used for get uses by id
 func (usersUsecase UsersUsecase) Get(ctx context.Context, id string) (*response.Standard, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("Get method called")
+	ctx, span := tracing.Start(ctx, "UsersUsecase.Get")
+	defer span.End()
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("Get method called")
 
 	// Validate the ID format
 	if errValidateVars := usersUsecase.validator.ValidateVars(id, "ksuid"); errValidateVars != nil {
-		usersUsecase.logger.Error().Msgf("ID validation error: %v", errValidateVars)
+		logger.Error().Msgf("ID validation error: %v", errValidateVars)
 		return nil, &response.StandardErrors{Errors: errValidateVars}
 	}
 
-	// Check if the user exists by ID
-	if errCount := usersUsecase.handleCountById(ctx, id); errCount != nil {
-		usersUsecase.logger.Error().Msgf("User existence check failed: %v", errCount)
-		return nil, errCount
-	}
+	// Retrieve the user from the cache, concurrent misses share a single database read.
+	users, errFetch := usersUsecase.cacheRepository.FetchOne(ctx, usersIDKey(id), func(ctx context.Context) (*entity.Users, []string, error) {
+		logger.Info().Msg("Cache miss, fetching from database")
 
-	// Retrieve user details by ID
-	users, standardErrors := usersUsecase.handleGetById(ctx, id)
-	if standardErrors != nil {
-		usersUsecase.logger.Error().Msgf("Failed to retrieve get user by ID: %v", standardErrors)
-		return nil, standardErrors
+		// Check if the user exists by ID
+		if errCount := usersUsecase.handleCountById(ctx, id); errCount != nil {
+			logger.Error().Msgf("User existence check failed: %v", errCount)
+			return nil, nil, errCount
+		}
+
+		// Retrieve user details by ID
+		users, standardErrors := usersUsecase.handleGetById(ctx, id)
+		if standardErrors != nil {
+			logger.Error().Msgf("Failed to retrieve get user by ID: %v", standardErrors)
+			return nil, nil, standardErrors
+		}
+
+		// Cache the user, registered in its own tag so writes to the user invalidate it.
+		return users, []string{usersTag(id)}, nil
+	})
+	if errFetch != nil {
+		logger.Error().Msgf("Failed to fetch user: %v", errFetch)
+		return nil, usersUsecase.handleErrFromFetch(ctx, errFetch, "Failed to fetch user from cache: ")
 	}
 
 	// Return user details with HTTP 200 OK status
@@ -438,12 +639,14 @@ func (usersUsecase UsersUsecase) Get(ctx context.Context, id string) (*response.
 		Status: http.StatusOK,
 		Code:   "STATUS_OK",
 		Data:   mapper.EntityUserToResponse(users),
+		ETag:   userETag(users),
 	}, nil
 }
 
 // handleCountById checks if a user with the specified ID exists in the database.
 func (usersUsecase UsersUsecase) handleCountById(ctx context.Context, id string) *response.StandardErrors {
-	usersUsecase.logger.Info().Msg("handleCountById method called")
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("handleCountById method called")
 
 	// Set a timeout context for database count operation.
 	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
@@ -452,21 +655,22 @@ func (usersUsecase UsersUsecase) handleCountById(ctx context.Context, id string)
 	// Check if the user exists by ID.
 	count, err := usersUsecase.usersRepository.CountById(ctxDB, id)
 	if err != nil {
-		usersUsecase.logger.Error().Msgf("Failed to count user by ID: %v", err)
-		return usersUsecase.handleErrFromRepository(err, "Failed to count user by ID: ")
+		logger.Error().Msgf("Failed to count user by ID: %v", err)
+		return usersUsecase.handleErrFromRepository(ctx, err, "Failed to count user by ID: ")
 	}
 	if count != 1 {
-		usersUsecase.logger.Info().Msgf("User with ID '%s' does not exist", id)
+		logger.Info().Msgf("User with ID '%s' does not exist", id)
 		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.NOT_FOUND, "User with ID '"+id+"' not found")}}
 	}
 
-	usersUsecase.logger.Info().Msgf("User with ID '%s' exists", id)
+	logger.Info().Msgf("User with ID '%s' exists", id)
 	return nil
 }
 
 // handleGetById retrieves a user by their ID from the database.
 func (usersUsecase UsersUsecase) handleGetById(ctx context.Context, id string) (*entity.Users, *response.StandardErrors) {
-	usersUsecase.logger.Info().Msg("handleGetById method called")
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("handleGetById method called")
 
 	// Set a timeout context for database retrieval operation.
 	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
@@ -476,36 +680,215 @@ func (usersUsecase UsersUsecase) handleGetById(ctx context.Context, id string) (
 	user := entity.Users{}
 	err := usersUsecase.usersRepository.GetById(ctxDB, &user, id)
 	if err != nil {
-		usersUsecase.logger.Error().Msgf("Failed to fetch user by ID from database: %v", err)
-		return nil, usersUsecase.handleErrFromRepository(err, "Failed to fetch user by ID from database: ")
+		logger.Error().Msgf("Failed to fetch user by ID from database: %v", err)
+		return nil, usersUsecase.handleErrFromRepository(ctx, err, "Failed to fetch user by ID from database: ")
+	}
+
+	logger.Info().Msgf("User with ID '%s' retrieved successfully", id)
+	return &user, nil
+}
+
+// handleGetByIdWithDeleted retrieves a user by their ID from the database, including soft-deleted users.
+func (usersUsecase UsersUsecase) handleGetByIdWithDeleted(ctx context.Context, id string) (*entity.Users, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("handleGetByIdWithDeleted method called")
+
+	// Set a timeout context for database retrieval operation.
+	ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+	defer cancel()
+
+	// Retrieve the user from the database.
+	user := entity.Users{}
+	err := usersUsecase.usersRepository.GetByIdWithDeleted(ctxDB, &user, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Info().Msgf("User with ID '%s' does not exist", id)
+		return nil, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.NOT_FOUND, "User with ID '"+id+"' not found")}}
+	}
+	if err != nil {
+		logger.Error().Msgf("Failed to fetch user by ID from database: %v", err)
+		return nil, usersUsecase.handleErrFromRepository(ctx, err, "Failed to fetch user by ID from database: ")
 	}
 
-	usersUsecase.logger.Info().Msgf("User with ID '%s' retrieved successfully", id)
+	logger.Info().Msgf("User with ID '%s' retrieved successfully", id)
 	return &user, nil
 }
 
+// handlePurge removes the user's Casbin rules, the user row and the cached user pages.
+// Refresh tokens are stateless, so there is no server-side session to revoke.
+func (usersUsecase UsersUsecase) handlePurge(ctx context.Context, users *entity.Users) *response.StandardErrors {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msgf("handlePurge method called for user '%s'", users.ID)
+
+	// Remove the rules, the row and record the purge in one unit of work, so a failed purge leaves the user intact and can be retried.
+	errWork := inUnitOfWork(ctx, usersUsecase.unitOfWork, usersUsecase.enforcer, usersUsecase.watcher, usersUsecase.logger, func(ctx context.Context) error {
+		// Remove the grouping and policy rules first, so without a unit of work a failed purge can still be retried safely.
+		errRole := withPolicyTx(ctx, usersUsecase.enforcer, func(policy policyEditor) error {
+			_, err := policy.DeleteUser(users.Email)
+			return err
+		})
+		if errRole != nil {
+			logger.Error().Msgf("Failed to remove roles of user '%s': %v", users.ID, errRole)
+			return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, "Error Internal Server: "+errRole.Error())}}
+		}
+
+		// Set a timeout context for database purge operation.
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		defer cancel()
+
+		// Permanently delete the user from the database.
+		if errDB := usersUsecase.usersRepository.Purge(ctxDB, users.ID); errDB != nil {
+			logger.Error().Msgf("Failed to purge from database: %v", errDB)
+			return usersUsecase.handleErrFromRepository(ctx, errDB, "Failed to purge from database")
+		}
+
+		// Record the purge in the audit trail, by the ID only as the trail outlives the user.
+		if errAudit := usersUsecase.audit.recordInWork(ctx, entity.AuditUserPurge, entity.AuditSuccess, &entity.Users{ID: users.ID}, nil); errAudit != nil {
+			logger.Error().Msgf("Failed to record the purge in the audit trail: %v", errAudit)
+			return usersUsecase.handleErrFromRepository(ctx, errAudit, "Failed to record the purge in the audit trail")
+		}
+		return nil
+	})
+	if errWork != nil {
+		return usersUsecase.handleErrFromFetch(ctx, errWork, "Failed to purge from database: ")
+	}
+
+	// Drop the export archive, it holds the personal data of the purged user.
+	usersUsecase.handleDeleteArchive(ctx, users.ID)
+
+	// Invalidate related cache entries after database changes.
+	if errCache := usersUsecase.handleInvalidateCache(ctx, usersTag(users.ID), usersListTag); errCache != nil {
+		logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
+		return errCache
+	}
+	return nil
+}
+
+// handleUpdate saves the updated user and records the changed fields in one unit of work, so the change is never saved without its audit event,
+// and returns the user as saved. The update only applies while the stored user still has the version of updated.
+func (usersUsecase UsersUsecase) handleUpdate(ctx context.Context, id string, before *entity.Users, updated *entity.Users) (*entity.Users, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msgf("handleUpdate method called for user '%s'", id)
+
+	var users *entity.Users
+	saved := false
+	errWork := inUnitOfWork(ctx, usersUsecase.unitOfWork, usersUsecase.enforcer, usersUsecase.watcher, usersUsecase.logger, func(ctx context.Context) error {
+		// Set a timeout context for database update operation.
+		ctxDB, cancel := usersUsecase.timeoutConfig.CreateDatabaseTimeout(ctx)
+		defer cancel()
+
+		// Update the user in the database.
+		if errDB := usersUsecase.usersRepository.Update(ctxDB, updated, id); errDB != nil {
+			logger.Error().Msgf("Failed to update in database: %v", errDB)
+			if errors.Is(errDB, repository.ErrVersionConflict) {
+				return usersUsecase.handlePreconditionFailed(ctx, id)
+			}
+			return usersUsecase.handleErrFromRepository(ctx, errDB, "Failed to update in database")
+		}
+		saved = true
+
+		// Retrieve the updated user by ID for the response.
+		current, standardErrors := usersUsecase.handleGetById(ctx, id)
+		if standardErrors != nil {
+			logger.Error().Msgf("Failed to retrieve updated user by ID: %v", standardErrors)
+			return standardErrors
+		}
+		users = current
+
+		// Record the changed fields in the audit trail.
+		if errAudit := usersUsecase.audit.recordInWork(ctx, entity.AuditUserUpdate, entity.AuditSuccess, users, auditChanges(before, users)); errAudit != nil {
+			logger.Error().Msgf("Failed to record the update in the audit trail: %v", errAudit)
+			return usersUsecase.handleErrFromRepository(ctx, errAudit, "Failed to record the update in the audit trail")
+		}
+		return nil
+	})
+
+	// Drop the export archive holding the previous data and invalidate related cache entries once the changes are committed,
+	// also when a step after the update failed since without a unit of work the user is updated anyway.
+	var errCache *response.StandardErrors
+	if saved {
+		usersUsecase.handleDeleteArchive(ctx, id)
+		errCache = usersUsecase.handleInvalidateCache(ctx, usersTag(id))
+	}
+	if errWork != nil {
+		return nil, usersUsecase.handleErrFromFetch(ctx, errWork, "Failed to update in database: ")
+	}
+	if errCache != nil {
+		logger.Error().Msgf("Failed to invalidate cache: %v", errCache)
+		return nil, errCache
+	}
+	return users, nil
+}
+
+// handlePrecondition compares the If-Match header with the current version of the user,
+// returning the version the update must be applied against.
+func (usersUsecase UsersUsecase) handlePrecondition(ctx context.Context, ifMatch string, users *entity.Users) (int64, *response.StandardErrors) {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msg("handlePrecondition method called")
+
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		logger.Info().Msgf("Missing If-Match header for user with ID '%s'", users.ID)
+		return 0, &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.PRECONDITION_REQUIRED, "The If-Match header with the ETag of the user is required")}}
+	}
+	if ifMatch == "*" {
+		return users.Version, nil
+	}
+
+	// If-Match uses the strong comparison, so weak ETags never match.
+	current := userETag(users)
+	for _, etag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(etag) == current {
+			return users.Version, nil
+		}
+	}
+	return 0, usersUsecase.handlePreconditionFailed(ctx, users.ID)
+}
+
+// handlePreconditionFailed reports that the user was modified since the client retrieved it.
+func (usersUsecase UsersUsecase) handlePreconditionFailed(ctx context.Context, id string) *response.StandardErrors {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msgf("User with ID '%s' was modified by another request", id)
+	return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.PRECONDITION_FAILED, "User with ID '"+id+"' has been modified, retrieve it again before updating")}}
+}
+
+// userETag returns the strong ETag of the user, derived from its version.
+func userETag(users *entity.Users) string {
+	return strconv.Quote(strconv.FormatInt(users.Version, 10))
+}
+
 // handleErrFromRepository handles errors from the repository, including context.DeadlineExceeded, and logs them.
-func (usersUsecase UsersUsecase) handleErrFromRepository(err error, message string) *response.StandardErrors {
+func (usersUsecase UsersUsecase) handleErrFromRepository(ctx context.Context, err error, message string) *response.StandardErrors {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
 	if errors.Is(err, context.DeadlineExceeded) {
-		usersUsecase.logger.Error().Msgf("%s: operation timed out: %v", message, err)
+		logger.Error().Msgf("%s: operation timed out: %v", message, err)
 		return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.REQUEST_TIMEOUT, "Request timed out: "+err.Error())}}
 	}
 
-	usersUsecase.logger.Error().Msgf("%s: %v", message, err)
+	logger.Error().Msgf("%s: %v", message, err)
 	return &response.StandardErrors{Errors: []*response.Error{errorshandler.NewError(errorshandler.INTERNAL_SERVER_ERROR, message+err.Error())}}
 }
 
-// handleDeleteCache invalidates cache entries related to users.
-func (usersUsecase UsersUsecase) handleDeleteCache(ctx context.Context, pattern string) *response.StandardErrors {
-	usersUsecase.logger.Info().Msgf("handleDeleteCache method called with pattern: %s", pattern)
+// handleErrFromFetch returns the errors of a cache loader or a unit of work as they are, other errors are handled as repository errors.
+func (usersUsecase UsersUsecase) handleErrFromFetch(ctx context.Context, err error, message string) *response.StandardErrors {
+	var standardErrors *response.StandardErrors
+	if errors.As(err, &standardErrors) {
+		return standardErrors
+	}
+	return usersUsecase.handleErrFromRepository(ctx, err, message)
+}
+
+// handleInvalidateCache invalidates the cache entries registered in the tags.
+func (usersUsecase UsersUsecase) handleInvalidateCache(ctx context.Context, tags ...string) *response.StandardErrors {
+	logger := loggerconfig.FromContext(ctx, usersUsecase.logger)
+	logger.Info().Msgf("handleInvalidateCache method called with tags: %v", tags)
 	ctxTimeout, cancel := usersUsecase.timeoutConfig.CreateCacheTimeout(ctx)
 	defer cancel()
-	// Invalidate cache entries based on the pattern.
-	if err := usersUsecase.cacheRepository.DeleteToCacheByRegexKey(ctxTimeout, pattern); err != nil {
-		usersUsecase.logger.Error().Msgf("Failed to invalidate cache with pattern '%s': %v", pattern, err)
-		return usersUsecase.handleErrFromRepository(err, "Failed to invalidate cache: "+err.Error())
+	// Invalidate cache entries registered in the tags.
+	if err := usersUsecase.cacheRepository.InvalidateTags(ctxTimeout, tags...); err != nil {
+		logger.Error().Msgf("Failed to invalidate cache with tags %v: %v", tags, err)
+		return usersUsecase.handleErrFromRepository(ctx, err, "Failed to invalidate cache: ")
 	}
 
-	usersUsecase.logger.Info().Msgf("Cache invalidated successfully for pattern: %s", pattern)
+	logger.Info().Msgf("Cache invalidated successfully for tags: %v", tags)
 	return nil
 }
@@ -1,42 +1,18 @@
 package main
 
 import (
-	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
-	"github.com/tirtahakimpambudhi/restful_api/internal/configs/bootstrap"
-	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http"
-	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/http/route"
+	"context"
 	"log"
+	"os"
+
+	"github.com/tirtahakimpambudhi/restful_api/internal/configs"
+	"github.com/tirtahakimpambudhi/restful_api/internal/delivery/cli"
 )
 
 func main() {
 	configs.ConfigFile = ".env.dev"
-	app, err := bootstrap.New()
-	if err != nil {
+	// Without a subcommand the binary serves the API
+	if err := cli.New(os.Stdin, os.Stdout).Run(context.Background(), os.Args[1:]); err != nil {
 		log.Fatal(err.Error())
-		return
-	}
-	usersController, authController, err := http.NewController(app)
-	if err != nil {
-		log.Fatal(err.Error())
-		return
-	}
-	routes, errRoute := route.NewRoute(app, usersController, authController)
-	if errRoute != nil {
-		log.Fatal(errRoute.Error())
-		return
 	}
-
-	if errInit := routes.Init(app.FiberServer.App); errInit != nil {
-		log.Fatal(errInit.Error())
-		return
-	}
-	err = app.FiberServer.Serve()
-	if err != nil {
-		log.Fatal(err.Error())
-	}
-	//if errServer := app.FiberServer.App.Listen(":3000"); errServer != nil {
-	//	log.Fatal(err.Error())
-	//	return
-	//}
-
 }
@@ -0,0 +1,147 @@
+package breaker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	Closed   State = iota // Calls pass through
+	Open                  // Calls are refused until the open duration elapses
+	HalfOpen              // A single probe call decides whether to close again
+)
+
+// String returns the name of the state.
+func (state State) String() string {
+	switch state {
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a circuit breaker opening after consecutive failures.
+// Once the open duration elapses a single call probes the dependency, closing the breaker on success and opening it again on failure.
+type Breaker struct {
+	name      string
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+
+	mutex    sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// registry holds every breaker by name, so health checks can report them without a reference.
+var registry sync.Map
+
+// New creates a breaker opening after threshold consecutive failures for openFor, registered under the name.
+func New(name string, threshold int, openFor time.Duration) *Breaker {
+	breaker := &Breaker{name: name, threshold: max(threshold, 1), openFor: openFor, now: time.Now}
+	registry.Store(name, breaker)
+	return breaker
+}
+
+// Name returns the name of the breaker.
+func (breaker *Breaker) Name() string {
+	return breaker.name
+}
+
+// Allow reports whether a call may proceed, the caller reports its outcome with Success or Failure.
+func (breaker *Breaker) Allow() bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	switch breaker.state {
+	case Open:
+		if breaker.now().Sub(breaker.openedAt) < breaker.openFor {
+			return false
+		}
+		breaker.state = HalfOpen
+		breaker.probing = true
+		return true
+	case HalfOpen:
+		// Only the probe passes until it reports its outcome.
+		if breaker.probing {
+			return false
+		}
+		breaker.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful call, closing the breaker.
+func (breaker *Breaker) Success() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.state = Closed
+	breaker.failures = 0
+	breaker.probing = false
+}
+
+// Failure records a failed call, opening the breaker at the threshold or when the probe fails.
+func (breaker *Breaker) Failure() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.failures++
+	breaker.probing = false
+	if breaker.state == HalfOpen || breaker.failures >= breaker.threshold {
+		breaker.state = Open
+		breaker.openedAt = breaker.now()
+	}
+}
+
+// Release ends a call without an outcome, such as one cancelled by its caller, letting another call probe.
+func (breaker *Breaker) Release() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	breaker.probing = false
+}
+
+// State returns the current state, an open breaker whose duration elapsed reports half-open.
+func (breaker *Breaker) State() State {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if breaker.state == Open && breaker.now().Sub(breaker.openedAt) >= breaker.openFor {
+		return HalfOpen
+	}
+	return breaker.state
+}
+
+// Snapshot is the state of a registered breaker.
+type Snapshot struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// Snapshots returns the state of every registered breaker, sorted by name.
+func Snapshots() []Snapshot {
+	snapshots := []Snapshot{}
+	registry.Range(func(key, value any) bool {
+		snapshots = append(snapshots, Snapshot{Name: key.(string), State: value.(*Breaker).State().String()})
+		return true
+	})
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots
+}
+
+// Degraded reports whether any registered breaker is not closed.
+func Degraded() bool {
+	for _, snapshot := range Snapshots() {
+		if snapshot.State != Closed.String() {
+			return true
+		}
+	}
+	return false
+}
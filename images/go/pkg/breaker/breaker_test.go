@@ -0,0 +1,71 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerStates(t *testing.T) {
+	now := time.Now()
+	breaker := New("test", 2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	require.True(t, breaker.Allow())
+	breaker.Failure()
+	require.Equal(t, Closed, breaker.State())
+
+	require.True(t, breaker.Allow())
+	breaker.Failure()
+	require.Equal(t, Open, breaker.State())
+	require.False(t, breaker.Allow())
+	require.True(t, Degraded())
+
+	// Once the open duration elapses a single probe passes.
+	now = now.Add(time.Minute)
+	require.Equal(t, HalfOpen, breaker.State())
+	require.True(t, breaker.Allow())
+	require.False(t, breaker.Allow())
+
+	// A failed probe opens the breaker again at once.
+	breaker.Failure()
+	require.Equal(t, Open, breaker.State())
+
+	now = now.Add(time.Minute)
+	require.True(t, breaker.Allow())
+	breaker.Success()
+	require.Equal(t, Closed, breaker.State())
+	require.True(t, breaker.Allow())
+	require.False(t, Degraded())
+}
+
+func TestBreakerRelease(t *testing.T) {
+	now := time.Now()
+	breaker := New("release", 1, time.Second)
+	breaker.now = func() time.Time { return now }
+
+	breaker.Failure()
+	now = now.Add(time.Second)
+	require.True(t, breaker.Allow())
+	breaker.Release()
+	require.True(t, breaker.Allow())
+}
+
+func TestSnapshots(t *testing.T) {
+	New("snapshot-b", 1, time.Minute)
+	opened := New("snapshot-a", 1, time.Minute)
+	opened.Failure()
+
+	snapshots := Snapshots()
+	states := map[string]string{}
+	for _, snapshot := range snapshots {
+		states[snapshot.Name] = snapshot.State
+	}
+	require.Equal(t, "open", states["snapshot-a"])
+	require.Equal(t, "closed", states["snapshot-b"])
+
+	// Reset the registry so other tests see a healthy process.
+	registry.Delete("snapshot-a")
+	registry.Delete("snapshot-b")
+}